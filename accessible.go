@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// accessible is set by --accessible, for screen readers. Nothing is redrawn
+// in place, tables are printed as records instead of aligned columns, and
+// status changes are announced as sentences, so they can be heard without
+// scanning the screen for what changed.
+var accessible bool
+
+// accessibleStatusInterval is how often wait says a run is still going in
+// accessible mode. Status changes are announced as they happen, so this can
+// be much longer than statusInterval.
+const accessibleStatusInterval = time.Minute
+
+// runPhrase names a run in a sentence, like "Test run #105 on main".
+func runPhrase(run *TestRun) string {
+	s := "Test run"
+	if run.Number > 0 {
+		s += " #" + strconv.Itoa(run.Number)
+	} else {
+		s += " " + shortSHA(run.ID.String())
+	}
+	if run.CommitBranch != "" {
+		s += " on " + run.CommitBranch
+	}
+	return s
+}
+
+// statusPhrase describes a status in words, to follow "Test run #105".
+func statusPhrase(status RunStatus) string {
+	switch status {
+	case StatusPending:
+		return "is waiting to start"
+	case StatusCreating:
+		return "is starting up"
+	case StatusBuilding:
+		return "is building"
+	case StatusRunning:
+		return "is running tests"
+	case StatusDebugging:
+		return "is open for debugging"
+	case StatusSucceeded:
+		return "passed"
+	case StatusFailed:
+		return "failed"
+	case StatusErrored:
+		return "stopped with an error"
+	case StatusCancelled:
+		return "was cancelled"
+	default:
+		return "has status " + string(status)
+	}
+}
+
+// announceStatus prints a sentence saying run's status changed.
+func announceStatus(w io.Writer, prefix string, run *TestRun) {
+	fmt.Fprintf(w, "%s%s %s.\n", prefix, runPhrase(run), statusPhrase(run.Status))
+}
+
+// announceStillGoing prints a sentence saying run is still in progress.
+func announceStillGoing(w io.Writer, prefix string, run *TestRun) {
+	fmt.Fprintf(w, "%s%s %s, %s so far.\n", prefix, runPhrase(run), statusPhrase(run.Status), roundDuration(time.Since(run.CreatedAt)).Round(time.Second))
+}
+
+// announceTopChanges prints a sentence for each run that started, changed
+// status, or finished between two top refreshes.
+func announceTopChanges(w io.Writer, prev, cur []*topRow) {
+	before := make(map[string]*topRow, len(prev))
+	for _, row := range prev {
+		before[row.Run.ID.String()] = row
+	}
+	for _, row := range cur {
+		old, ok := before[row.Run.ID.String()]
+		delete(before, row.Run.ID.String())
+		switch {
+		case !ok:
+			fmt.Fprintf(w, "%s: %s started and %s.\n", row.Pipeline, runPhrase(row.Run), statusPhrase(row.Run.Status))
+		case old.Run.Status != row.Run.Status:
+			fmt.Fprintf(w, "%s: %s %s.\n", row.Pipeline, runPhrase(row.Run), statusPhrase(row.Run.Status))
+		}
+	}
+	for _, row := range prev {
+		if _, ok := before[row.Run.ID.String()]; ok {
+			fmt.Fprintf(w, "%s: %s finished.\n", row.Pipeline, runPhrase(row.Run))
+		}
+	}
+}
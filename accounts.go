@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgentry/go-netrc/netrc"
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+	"github.com/kevinburke/rest"
+)
+
+// herokuMachine is the .netrc machine the Heroku CLI writes the logged in
+// account's credentials to.
+const herokuMachine = "api.heroku.com"
+
+// accountMachines are .netrc machines holding the credentials of other
+// Heroku accounts to look for a pipeline in when the default account can't
+// see it. It is set from Config.AccountMachines when heroku-ci starts.
+var accountMachines []string
+
+// netrcClient returns a Client authenticated with the credentials for
+// machine in the user's .netrc file.
+func netrcClient(machine string) (*Client, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	m, err := netrc.FindMachine(filepath.Join(homedir, ".netrc"), machine)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, &credentialsError{fmt.Sprintf("reading ~/.netrc: %v", err)}
+	}
+	if m == nil || (m.IsDefault() && machine != herokuMachine) {
+		return nil, &credentialsError{fmt.Sprintf("no machine %s in ~/.netrc", machine)}
+	}
+	redact.addSecret(m.Password)
+	return newAPIClient(m.Login, m.Password), nil
+}
+
+// herokuAPI is the API a Client talks to unless told otherwise.
+const herokuAPI = herokuci.DefaultBaseURL
+
+// apiClientOptions are the [api] settings every Client gets, before any
+// options passed to newAPIClient. They are set from Config when heroku-ci
+// starts.
+var apiClientOptions []herokuci.Option
+
+// newAPIClient returns a Client for the Heroku API, as the account with the
+// given email, which may be empty. Unless opts say otherwise, it talks to
+// herokuAPI and retries reads until the circuit breaker gives up or the
+// context is done.
+func newAPIClient(login, token string, opts ...herokuci.Option) *Client {
+	client := &Client{
+		ID:      login,
+		limiter: newRequestLimiter(),
+	}
+	if !cacheDisabled {
+		client.cache = newResponseCache()
+	}
+	// rest's transport prints the traffic with DEBUG_HTTP_TRAFFIC=true.
+	var transport http.RoundTripper = rest.DefaultTransport
+	if chaosConfig != nil {
+		transport = chaosConfig.transport(transport, true)
+	}
+	if client.limiter != nil {
+		transport = &rateLimitTransport{next: transport, limiter: client.limiter}
+	}
+	all := []herokuci.Option{
+		herokuci.WithBaseURL(herokuAPI),
+		herokuci.WithUserAgent("heroku-ci/" + Version),
+		herokuci.WithRetries(0),
+		herokuci.WithHTTPClient(&http.Client{Transport: transport}),
+	}
+	all = append(all, apiClientOptions...)
+	all = append(all, opts...)
+	// Do retries, and calls the herokuci.Client's Send itself.
+	all = append(all, herokuci.WithMiddleware(func(herokuci.DoFunc) herokuci.DoFunc { return client.Do }))
+	client.Client = herokuci.NewClient(token, all...)
+	return client
+}
+
+// A credentialsError is returned when there are no Heroku credentials to use.
+type credentialsError struct {
+	msg string
+}
+
+func (e *credentialsError) Error() string {
+	return e.msg
+}
+
+// A pipelineNotFoundError is returned when the account heroku-ci is using
+// can't see a pipeline. The most common reason is that it belongs to
+// another account, so the error says which of accountMachines can see it,
+// if any.
+type pipelineNotFoundError struct {
+	Name    string
+	Account string
+	// Other is a client for the first of accountMachines that can see the
+	// pipeline, or nil if none can.
+	Other   *Client
+	Machine string
+	// Tried are the other accounts that were looked in.
+	Tried []string
+	// Similar are the names of pipelines Account can see that look like
+	// Name, closest first, or if none do and there are only a few, all of
+	// them, with Close false.
+	Similar []string
+	Close   bool
+}
+
+func (e *pipelineNotFoundError) Error() string {
+	var msg string
+	switch {
+	case e.Other != nil:
+		return fmt.Sprintf("could not find pipeline named %q as %s, but %s (.netrc machine %s) can see it", e.Name, e.Account, e.Other.ID, e.Machine)
+	case len(e.Tried) > 0:
+		msg = fmt.Sprintf("could not find pipeline named %q as %s or %s", e.Name, e.Account, strings.Join(e.Tried, ", "))
+	case e.Close:
+		// A typo, most likely, rather than another account.
+		msg = fmt.Sprintf("could not find pipeline named %q as %s", e.Name, e.Account)
+	default:
+		msg = fmt.Sprintf("could not find pipeline named %q as %s. If it belongs to another Heroku account, add its credentials to ~/.netrc and name the machine under [accounts] in the config file", e.Name, e.Account)
+	}
+	switch {
+	case e.Close && len(e.Similar) == 1:
+		msg += fmt.Sprintf(". Did you mean %q?", e.Similar[0])
+	case e.Close:
+		msg += ". Did you mean one of " + strings.Join(quoteAll(e.Similar), ", ") + "?"
+	case len(e.Similar) > 0:
+		msg += ". Pipelines " + e.Account + " can see: " + strings.Join(e.Similar, ", ")
+	}
+	return msg
+}
+
+// findPipelineInOtherAccounts looks for the pipeline in each of
+// accountMachines, for the error to return when client can't see it.
+func findPipelineInOtherAccounts(ctx context.Context, client *Client, name string) *pipelineNotFoundError {
+	nf := &pipelineNotFoundError{Name: name, Account: client.ID}
+	for _, machine := range accountMachines {
+		other, err := netrcClient(machine)
+		if err != nil {
+			warnf("%v", err)
+			continue
+		}
+		if other.ID == client.ID {
+			continue
+		}
+		p, err := lookupPipeline(ctx, other, name)
+		if err != nil {
+			warnf("looking for %s as %s: %v", name, other.ID, err)
+			continue
+		}
+		if p != nil {
+			nf.Other, nf.Machine = other, machine
+			return nf
+		}
+		nf.Tried = append(nf.Tried, other.ID)
+	}
+	return nf
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// anomalyConfig is set from Config when heroku-ci starts.
+var anomalyConfig = struct {
+	Enabled bool
+	// ZScore alerts when a run takes this many standard deviations longer
+	// than the baseline.
+	ZScore float64
+	// Percent, if more than zero, also alerts when a run takes this many
+	// percent longer than the baseline.
+	Percent float64
+	// FailureRate alerts when the share of recent runs that failed is this
+	// many percentage points above the baseline.
+	FailureRate float64
+	// MinRuns is how many runs a baseline needs before we trust it.
+	MinRuns int
+}{Enabled: true, ZScore: 3, FailureRate: 30, MinRuns: 10}
+
+const (
+	// baselineAlpha weights each new run in the rolling baselines, so they
+	// mostly reflect the last few dozen runs.
+	baselineAlpha = 0.1
+	// failRateAlpha is smaller, so the baseline failure rate changes slowly
+	// enough to compare the last recentOutcomes runs to.
+	failRateAlpha = 0.02
+	// recentOutcomes is how many of the latest runs we compare to the
+	// baseline failure rate.
+	recentOutcomes = 10
+)
+
+// A baseline is the usual duration and failure rate of the runs on one
+// branch of one pipeline, as exponentially weighted moving averages.
+type baseline struct {
+	Runs int `json:"runs"`
+	// Mean and Variance are of the duration in seconds, over the runs that
+	// passed or failed.
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	FailRate float64 `json:"fail_rate"`
+	// Recent are the latest outcomes, oldest first; true means failed.
+	Recent []bool `json:"recent"`
+	// Alerting is set while the failure rate is high, so we alert once
+	// when it goes up instead of after every run.
+	Alerting bool `json:"alerting,omitempty"`
+	LastRun  int  `json:"last_run"`
+}
+
+// baselines maps "<pipeline>/<branch>" to its baseline.
+type baselines map[string]*baseline
+
+// An anomaly is something unusual about a finished run.
+type anomaly struct {
+	// Kind is "duration" or "failure_rate".
+	Kind string
+	Text string
+	// Value and Baseline are the run's duration and the usual duration in
+	// seconds, or the recent and usual failure rates as fractions.
+	Value    float64
+	Baseline float64
+}
+
+// check returns the anomalies in run compared to b, and then adds run to b.
+func (b *baseline) check(run *TestRun) []*anomaly {
+	failed := isFailing(run.Status)
+	dur := run.UpdatedAt.Sub(run.CreatedAt).Seconds()
+	var found []*anomaly
+	cfg := anomalyConfig
+	if b.Runs >= cfg.MinRuns && b.Mean > 0 {
+		std := math.Sqrt(b.Variance)
+		z := 0.0
+		if std > 0 {
+			z = (dur - b.Mean) / std
+		}
+		slow := cfg.ZScore > 0 && z >= cfg.ZScore
+		if cfg.Percent > 0 && dur >= b.Mean*(1+cfg.Percent/100) {
+			slow = true
+		}
+		if slow {
+			found = append(found, &anomaly{
+				Kind: "duration",
+				Text: fmt.Sprintf("run #%d on %s took %s, %.1fx the usual %s", run.Number, run.CommitBranch,
+					roundDuration(time.Duration(dur*float64(time.Second))).Round(time.Second), dur/b.Mean,
+					roundDuration(time.Duration(b.Mean*float64(time.Second))).Round(time.Second)),
+				Value:    dur,
+				Baseline: b.Mean,
+			})
+		}
+	}
+	b.Recent = append(b.Recent, failed)
+	if len(b.Recent) > recentOutcomes {
+		b.Recent = b.Recent[len(b.Recent)-recentOutcomes:]
+	}
+	failures := 0
+	for _, f := range b.Recent {
+		if f {
+			failures++
+		}
+	}
+	rate := float64(failures) / float64(len(b.Recent))
+	if b.Runs >= cfg.MinRuns && len(b.Recent) == recentOutcomes {
+		high := cfg.FailureRate > 0 && (rate-b.FailRate)*100 >= cfg.FailureRate
+		if high && !b.Alerting {
+			found = append(found, &anomaly{
+				Kind:     "failure_rate",
+				Text:     fmt.Sprintf("%d of the last %d runs on %s failed, up from a usual %.0f%%", failures, len(b.Recent), run.CommitBranch, 100*b.FailRate),
+				Value:    rate,
+				Baseline: b.FailRate,
+			})
+		}
+		b.Alerting = high
+	}
+	// Update the baselines after checking, so an unusual run doesn't hide
+	// itself.
+	outcome := 0.0
+	if failed {
+		outcome = 1
+	}
+	if b.Runs == 0 {
+		b.Mean, b.FailRate = dur, outcome
+	} else {
+		diff := dur - b.Mean
+		b.Mean += baselineAlpha * diff
+		b.Variance = (1 - baselineAlpha) * (b.Variance + baselineAlpha*diff*diff)
+		b.FailRate += failRateAlpha * (outcome - b.FailRate)
+	}
+	b.Runs++
+	b.LastRun = run.Number
+	return found
+}
+
+// AnomalyEvent is published to the event sinks for each anomaly.
+type AnomalyEvent struct {
+	Type       string           `json:"type"`
+	Time       time.Time        `json:"time"`
+	PipelineID types.PrefixUUID `json:"pipeline_id"`
+	RunID      types.PrefixUUID `json:"run_id"`
+	RunNumber  int              `json:"run_number"`
+	Branch     string           `json:"branch"`
+	Kind       string           `json:"kind"`
+	Message    string           `json:"message"`
+	Value      float64          `json:"value"`
+	Baseline   float64          `json:"baseline"`
+}
+
+// checkAnomalies compares a finished run to the rolling baseline for its
+// pipeline and branch, updates the baseline, and reports anything unusual
+// to the terminal, the event sinks, and the chat notifiers. Cancelled runs
+// and runs another heroku-ci process already counted are skipped.
+func checkAnomalies(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun) {
+	if !anomalyConfig.Enabled || !(isPassing(run.Status) || isFailing(run.Status)) {
+		return
+	}
+	path, err := baselinesPath()
+	if err != nil {
+		return
+	}
+	var found []*anomaly
+	err = withLock(path, func() error {
+		bs := make(baselines)
+		if data, err := os.ReadFile(path); err == nil {
+			// A corrupt file only costs us the history; start over.
+			json.Unmarshal(data, &bs)
+		}
+		key := id.String() + "/" + run.CommitBranch
+		b, ok := bs[key]
+		if !ok {
+			b = new(baseline)
+			bs[key] = b
+		}
+		if run.Number <= b.LastRun {
+			return nil
+		}
+		found = b.check(run)
+		data, err := json.Marshal(bs)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path, data, 0644)
+	})
+	if err != nil {
+		warnf("could not update run baselines: %v", err)
+	}
+	for _, a := range found {
+		fmt.Printf("unusual: %s\n", a.Text)
+		data, err := json.Marshal(&AnomalyEvent{
+			Type:       "test_run.anomaly",
+			Time:       time.Now().UTC(),
+			PipelineID: id,
+			RunID:      run.ID,
+			RunNumber:  run.Number,
+			Branch:     run.CommitBranch,
+			Kind:       a.Kind,
+			Message:    a.Text,
+			Value:      a.Value,
+			Baseline:   a.Baseline,
+		})
+		if err == nil {
+			publishEvent(ctx, data, map[string]string{
+				"type":     "test_run.anomaly",
+				"pipeline": id.String(),
+				"branch":   run.CommitBranch,
+				"kind":     a.Kind,
+			})
+		}
+		if loadSilences().silencedBy(id, run.CommitBranch) != "" {
+			continue
+		}
+		text := a.Text
+		notifyBranch(ctx, client, id, run.CommitBranch, nil, func(pipeline string) string {
+			return pipeline + ": unusual: " + text
+		})
+	}
+}
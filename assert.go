@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// assertGreen returns an error unless the most recent test run on branch
+// succeeded and finished less than maxAge ago. If maxAge is zero the run may
+// be any age. assertGreen never waits for a run to finish.
+func assertGreen(ctx context.Context, client *Client, id types.PrefixUUID, branch string, maxAge time.Duration) error {
+	run, err := findTestRun(ctx, client, id, branch, "")
+	if err != nil {
+		return err
+	}
+	short := run.ID.String()[:8]
+	if tests := quarantinedFailures(ctx, client, run); tests != nil {
+		return &quarantinedError{run, tests}
+	}
+	if run.Status != StatusSucceeded {
+		return checkFailedf("most recent test run on %s (%s, commit %s) has status %s", branch, short, shortSHA(run.CommitSHA), run.Status)
+	}
+	age := time.Since(run.UpdatedAt)
+	if maxAge > 0 && age > maxAge {
+		return checkFailedf("most recent test run on %s (%s) succeeded %s ago, longer than the max age of %s", branch, short, roundDuration(age), maxAge)
+	}
+	fmt.Printf("Test run %s on %s (commit %s) succeeded %s ago.\n", short, branch, shortSHA(run.CommitSHA), roundDuration(age))
+	return nil
+}
+
+// assertGreenByTree returns an error unless some successful run, on any
+// branch, tested exactly the contents of the tip of branch, and finished
+// less than maxAge ago. The run's commit may differ from the tip, for
+// example if the tip is a merge commit with nothing new in it.
+func assertGreenByTree(ctx context.Context, client *Client, id types.PrefixUUID, branch string, maxAge time.Duration) error {
+	sha, err := fullSHA(localRef(branch))
+	if err != nil {
+		return err
+	}
+	run, err := findTestRun(ctx, client, id, herokuBranch(ctx, branch), sha)
+	if err != nil || run.Status != StatusSucceeded {
+		run, err = findEquivalentRun(ctx, client, id, sha, true)
+		if err != nil {
+			return err
+		}
+	}
+	if run == nil {
+		return checkFailedf("no successful test run has the same tree as %s (commit %s)", branch, shortSHA(sha))
+	}
+	short := run.ID.String()[:8]
+	age := time.Since(run.UpdatedAt)
+	if maxAge > 0 && age > maxAge {
+		return checkFailedf("test run %s, for the same tree as %s, succeeded %s ago, longer than the max age of %s", short, branch, roundDuration(age), maxAge)
+	}
+	if run.CommitSHA == sha {
+		fmt.Printf("Test run %s on %s (commit %s) succeeded %s ago.\n", short, branch, shortSHA(sha), roundDuration(age))
+	} else {
+		fmt.Printf("Test run %s on %s (commit %s) tested the same tree as %s (commit %s), and succeeded %s ago.\n", short, run.CommitBranch, shortSHA(run.CommitSHA), branch, shortSHA(sha), roundDuration(age))
+	}
+	return nil
+}
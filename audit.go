@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"time"
+)
+
+// auditWebhook is set from Config.AuditWebhook when heroku-ci starts.
+var auditWebhook string
+
+// How long to wait for the audit webhook to respond.
+const auditWebhookTimeout = 5 * time.Second
+
+// An AuditEntry records one change heroku-ci made on the user's behalf.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// User is the local user who ran heroku-ci, as user@host.
+	User string `json:"user"`
+	// Account is the Heroku or GitHub account the change was made with, if
+	// known.
+	Account string `json:"account,omitempty"`
+	// Action names the change, like "cancel" or "coupling.add".
+	Action     string `json:"action"`
+	PipelineID string `json:"pipeline_id,omitempty"`
+	RunNumber  int    `json:"run_number,omitempty"`
+	// Target is the app, branch, pull request or tag that was changed.
+	Target string `json:"target,omitempty"`
+	// Text is a one line summary. The field is named so that the entry can be
+	// posted to a Slack incoming webhook as is.
+	Text string `json:"text"`
+}
+
+func localUser() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// recordAudit appends e to the audit log and posts it to the audit webhook,
+// if one is configured. The change has already happened by the time we get
+// here, so failures are reported but don't fail the command.
+func recordAudit(ctx context.Context, e *AuditEntry) {
+	e.Time = time.Now().UTC()
+	e.User = localUser()
+	data, err := json.Marshal(e)
+	if err != nil {
+		warnf("could not write audit log: %v", err)
+		return
+	}
+	if err := appendAuditLog(data); err != nil {
+		warnf("could not write audit log: %v", err)
+	}
+	if auditWebhook != "" {
+		if err := postAuditWebhook(ctx, data); err != nil {
+			warnf("could not post to audit webhook: %v", err)
+		}
+	}
+}
+
+func appendAuditLog(data []byte) error {
+	path, err := auditLogPath()
+	if err != nil {
+		return err
+	}
+	return withLock(path, func() error {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func postAuditWebhook(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, auditWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequest("POST", auditWebhook, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "heroku-ci/"+Version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", auditWebhook, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long to give `heroku auth:token`, which starts the whole Heroku CLI.
+const herokuCLITimeout = 20 * time.Second
+
+// herokuClient returns a Client for the first Heroku credentials it finds:
+// $HEROKU_API_KEY, then the output of `heroku auth:token`, then the
+// api.heroku.com entry in ~/.netrc, for machines without the CLI.
+func herokuClient() (*Client, error) {
+	tried := make([]string, 0, 3)
+	if key := strings.TrimSpace(os.Getenv("HEROKU_API_KEY")); key != "" {
+		return tokenClient(key, "$HEROKU_API_KEY")
+	}
+	tried = append(tried, "$HEROKU_API_KEY is not set")
+	token, err := herokuCLIToken()
+	if err == nil {
+		return tokenClient(token, "`heroku auth:token`")
+	}
+	tried = append(tried, err.Error())
+	client, err := netrcClient(herokuMachine)
+	if err == nil {
+		client.auth = oauthRefresher()
+		return client, nil
+	}
+	var creds *credentialsError
+	if !errors.As(err, &creds) {
+		return nil, err
+	}
+	tried = append(tried, err.Error())
+	return nil, &credentialsError{"no Heroku credentials found: " + strings.Join(tried, "; ") + ". Set HEROKU_API_KEY, or run `heroku login`"}
+}
+
+// herokuCLIToken returns the token the Heroku CLI is logged in with.
+func herokuCLIToken() (string, error) {
+	if _, err := exec.LookPath("heroku"); err != nil {
+		return "", errors.New("the heroku CLI is not installed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), herokuCLITimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "heroku", "auth:token")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	token := strings.TrimSpace(string(out))
+	if err != nil || token == "" {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" && err != nil {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("`heroku auth:token` failed: %s", msg)
+	}
+	return token, nil
+}
+
+// tokenClient returns a Client authenticated with token, which came from
+// source. A token carries no email, which audit entries and permission
+// checks use, so it is looked up, and a rejected token is reported here
+// rather than by whichever request happens to come first.
+func tokenClient(token, source string) (*Client, error) {
+	redact.addSecret(token)
+	client := newAPIClient("", token)
+	client.auth = oauthRefresher()
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := client.NewRequest("GET", "/account", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	var account struct {
+		Email string `json:"email"`
+	}
+	if err := client.do(req, &account); err != nil {
+		var herr *HerokuError
+		if errors.As(err, &herr) && herr.StatusCode == http.StatusUnauthorized {
+			return nil, &credentialsError{fmt.Sprintf("Heroku rejected the token from %s: %v", source, herr)}
+		}
+		// Offline, probably; commands that can work offline still should.
+		return client, nil
+	}
+	client.ID = account.Email
+	return client, nil
+}
+
+// A tokenRefresher gets a new OAuth access token when the current one
+// expires, so a long wait doesn't fail with a 401 halfway through.
+type tokenRefresher struct {
+	// mu makes one request at a time refresh the token, and guards
+	// refreshToken.
+	mu           sync.RWMutex
+	refreshToken string
+	clientSecret string
+}
+
+// oauthRefresher returns a refresher for the OAuth client in
+// $HEROKU_OAUTH_REFRESH_TOKEN and $HEROKU_OAUTH_CLIENT_SECRET, or nil if
+// they aren't set.
+func oauthRefresher() *tokenRefresher {
+	refresh, secret := os.Getenv("HEROKU_OAUTH_REFRESH_TOKEN"), os.Getenv("HEROKU_OAUTH_CLIENT_SECRET")
+	if refresh == "" || secret == "" {
+		return nil
+	}
+	redact.addSecret(refresh)
+	redact.addSecret(secret)
+	return &tokenRefresher{refreshToken: refresh, clientSecret: secret}
+}
+
+// refresh replaces the access token, unless another request already did
+// since used was read.
+func (c *Client) refresh(ctx context.Context, used string) error {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	if c.Token() != used {
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"client":        map[string]string{"secret": c.auth.clientSecret},
+		"grant":         map[string]string{"type": "refresh_token"},
+		"refresh_token": map[string]string{"token": c.auth.refreshToken},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.BaseURL()+"/oauth/tokens", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.heroku+json; version=3")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return parseHerokuError(resp)
+	}
+	defer resp.Body.Close()
+	var tokens struct {
+		AccessToken struct {
+			Token string `json:"token"`
+		} `json:"access_token"`
+		RefreshToken struct {
+			Token string `json:"token"`
+		} `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return err
+	}
+	if tokens.AccessToken.Token == "" {
+		return errors.New("no access token in the response")
+	}
+	redact.addSecret(tokens.AccessToken.Token)
+	c.SetToken(tokens.AccessToken.Token)
+	if tokens.RefreshToken.Token != "" {
+		redact.addSecret(tokens.RefreshToken.Token)
+		c.auth.refreshToken = tokens.RefreshToken.Token
+	}
+	return nil
+}
+
+// retryUnauthorized refreshes the access token if r failed with a 401 and
+// the token can be refreshed, and reports whether r should be sent again.
+// r's credentials and body are reset for the retry.
+func (c *Client) retryUnauthorized(r *http.Request, err error) bool {
+	var herr *HerokuError
+	if c.auth == nil || !errors.As(err, &herr) || herr.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	if r.Body != nil && r.GetBody == nil {
+		return false
+	}
+	used := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if err := c.refresh(r.Context(), used); err != nil {
+		warnf("could not refresh the Heroku OAuth token: %v", err)
+		return false
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return false
+		}
+		r.Body = body
+	}
+	r.Header.Set("Authorization", "Bearer "+c.Token())
+	return true
+}
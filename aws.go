@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knq/ini"
+)
+
+// awsCredentials are the keys used to sign AWS requests.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// loadAWSCredentials reads AWS credentials from the standard environment
+// variables, or else from the $AWS_PROFILE (or default) profile in
+// ~/.aws/credentials.
+func loadAWSCredentials() (*awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		homedir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(homedir, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	file, err := ini.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	section := file.GetSection(profile)
+	if section == nil || section.Get("aws_access_key_id") == "" {
+		return nil, fmt.Errorf("no AWS credentials in $AWS_ACCESS_KEY_ID or the %q profile in %s", profile, path)
+	}
+	return &awsCredentials{
+		AccessKeyID:     section.Get("aws_access_key_id"),
+		SecretAccessKey: section.Get("aws_secret_access_key"),
+		SessionToken:    section.Get("aws_session_token"),
+	}, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signAWSRequest signs req, whose body is body, with AWS Signature Version 4.
+func signAWSRequest(req *http.Request, body []byte, creds *awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	// Headers are signed in sorted order, and content-type only if the
+	// request has one.
+	signed := []string{"host", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signed = append([]string{"content-type"}, signed...)
+	}
+	if creds.SessionToken != "" {
+		signed = append(signed, "x-amz-security-token")
+	}
+	var headers strings.Builder
+	for _, name := range signed {
+		val := req.Header.Get(name)
+		if name == "host" {
+			val = req.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(val) + "\n")
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		headers.String(),
+		strings.Join(signed, ";"),
+		sha256Hex(body),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, strings.Join(signed, ";"), signature))
+}
+
+// awsQuery POSTs a signed AWS Query API request to endpoint.
+func awsQuery(ctx context.Context, endpoint, region, service string, form url.Values) error {
+	creds, err := loadAWSCredentials()
+	if err != nil {
+		return err
+	}
+	body := []byte(form.Encode())
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signAWSRequest(req, body, creds, region, service, time.Now())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s returned %s: %s", service, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// snsSink publishes events to an SNS topic.
+type snsSink struct {
+	topicARN string
+	region   string
+}
+
+// newSNSSink returns a sink for the topic with the given ARN, like
+// arn:aws:sns:us-east-1:123456789012:ci-events.
+func newSNSSink(arn string) (*snsSink, error) {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" {
+		return nil, fmt.Errorf("invalid SNS topic ARN %q", arn)
+	}
+	return &snsSink{topicARN: arn, region: parts[3]}, nil
+}
+
+func (s *snsSink) String() string { return s.topicARN }
+
+func (s *snsSink) Publish(ctx context.Context, data []byte, attrs map[string]string) error {
+	form := url.Values{}
+	form.Set("Action", "Publish")
+	form.Set("Version", "2010-03-31")
+	form.Set("TopicArn", s.topicARN)
+	form.Set("Message", string(data))
+	for i, k := range sortedKeys(attrs) {
+		prefix := "MessageAttributes.entry." + strconv.Itoa(i+1)
+		form.Set(prefix+".Name", k)
+		form.Set(prefix+".Value.DataType", "String")
+		form.Set(prefix+".Value.StringValue", attrs[k])
+	}
+	return awsQuery(ctx, "https://sns."+s.region+".amazonaws.com/", s.region, "sns", form)
+}
+
+// sqsSink sends events to an SQS queue.
+type sqsSink struct {
+	queueURL string
+	region   string
+}
+
+// newSQSSink returns a sink for the queue at queueURL, like
+// https://sqs.us-east-1.amazonaws.com/123456789012/ci-events.
+func newSQSSink(queueURL string) (*sqsSink, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.Split(u.Host, ".")
+	if u.Scheme != "https" || len(host) < 4 || host[0] != "sqs" {
+		return nil, fmt.Errorf("invalid SQS queue URL %q, want https://sqs.<region>.amazonaws.com/<account>/<queue>", queueURL)
+	}
+	return &sqsSink{queueURL: queueURL, region: host[1]}, nil
+}
+
+func (s *sqsSink) String() string { return s.queueURL }
+
+func (s *sqsSink) Publish(ctx context.Context, data []byte, attrs map[string]string) error {
+	form := url.Values{}
+	form.Set("Action", "SendMessage")
+	form.Set("Version", "2012-11-05")
+	form.Set("MessageBody", string(data))
+	for i, k := range sortedKeys(attrs) {
+		prefix := "MessageAttribute." + strconv.Itoa(i+1)
+		form.Set(prefix+".Name", k)
+		form.Set(prefix+".Value.DataType", "String")
+		form.Set(prefix+".Value.StringValue", attrs[k])
+	}
+	return awsQuery(ctx, s.queueURL, s.region, "sqs", form)
+}
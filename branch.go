@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// normalizeBranch returns a branch name as typed on the command line or in
+// a manifest the way Heroku records it in commit_branch: without a
+// refs/heads/ prefix or surrounding space.
+func normalizeBranch(branch string) string {
+	return strings.TrimPrefix(strings.TrimSpace(branch), "refs/heads/")
+}
+
+// currentBranch returns the checked out branch. Unlike
+// `git symbolic-ref --short`, it doesn't shorten the name to "heads/main"
+// when a tag is also called main.
+func currentBranch() (string, error) {
+	out, err := exec.Command("git", "symbolic-ref", "--quiet", "HEAD").Output()
+	if err != nil {
+		return "", errors.New("git: HEAD is not on a branch")
+	}
+	return normalizeBranch(string(out)), nil
+}
+
+// localRef returns the ref to hand git for the local branch: its full name
+// if it exists, so a tag with the same name isn't picked instead, or the
+// name as given otherwise.
+func localRef(branch string) string {
+	ref := "refs/heads/" + branch
+	if err := exec.Command("git", "show-ref", "--verify", "--quiet", ref).Run(); err != nil {
+		return branch
+	}
+	return ref
+}
@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestNormalizeBranch(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"main", "main"},
+		{"  main\n", "main"},
+		{"refs/heads/main", "main"},
+		{"refs/heads/feature/login", "feature/login"},
+		{"feature/login", "feature/login"},
+		{"refs/heads/refs/heads/x", "refs/heads/x"},
+		{"refs/tags/v1", "refs/tags/v1"},
+		{"heads/main", "heads/main"},
+		{"fix-ünïcode-☃", "fix-ünïcode-☃"},
+		{"refs/heads/日本語", "日本語"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeBranch(tt.in); got != tt.want {
+			t.Errorf("normalizeBranch(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLocalRef(t *testing.T) {
+	t.Chdir(t.TempDir())
+	runGit(t, "init", "-q", "-b", "main")
+	runGit(t, "-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "first")
+	for _, b := range []string{"feature/login", "fix-ünïcode-☃", "v1"} {
+		runGit(t, "branch", b)
+	}
+	// A tag with the same name as a branch, and one with no branch.
+	runGit(t, "tag", "main")
+	runGit(t, "tag", "release")
+
+	tests := []struct {
+		branch, want string
+	}{
+		{"main", "refs/heads/main"},
+		{"feature/login", "refs/heads/feature/login"},
+		{"fix-ünïcode-☃", "refs/heads/fix-ünïcode-☃"},
+		{"v1", "refs/heads/v1"},
+		{"release", "release"},
+		{"missing", "missing"},
+		{"feature", "feature"},
+	}
+	for _, tt := range tests {
+		if got := localRef(tt.branch); got != tt.want {
+			t.Errorf("localRef(%q) = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+
+	// With a tag called main too, symbolic-ref --short would say heads/main.
+	got, err := currentBranch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "main" {
+		t.Errorf("currentBranch() = %q, want main", got)
+	}
+	runGit(t, "checkout", "-q", "fix-ünïcode-☃")
+	if got, _ := currentBranch(); got != "fix-ünïcode-☃" {
+		t.Errorf("currentBranch() = %q, want fix-ünïcode-☃", got)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// How many recent runs to look through for the local branches' runs. A
+// branch with no run this recent is shown without one.
+const branchStatusDepth = 2000
+
+// A localBranch is a branch in the local checkout.
+type localBranch struct {
+	Name string
+	// Upstream is the branch it tracks, like refs/remotes/origin/main, or
+	// empty if it doesn't track one.
+	Upstream string
+	// Tip is the SHA the branch points to.
+	Tip string
+	// Gone is true if the upstream branch was deleted, as it usually is
+	// once a pull request is merged.
+	Gone bool
+}
+
+// localBranches returns the checkout's branches, sorted by name.
+func localBranches() ([]*localBranch, error) {
+	out, err := exec.Command("git", "for-each-ref", "--format=%(refname)%00%(upstream)%00%(objectname)%00%(upstream:track)", "refs/heads/").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref: %v", err)
+	}
+	branches := make([]*localBranch, 0)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 4 {
+			continue
+		}
+		branches = append(branches, &localBranch{Name: normalizeBranch(fields[0]), Upstream: fields[1], Tip: fields[2], Gone: fields[3] == "[gone]"})
+	}
+	return branches, nil
+}
+
+// A branchRun is a local branch and the newest run on it, if there is one.
+type branchRun struct {
+	Branch *localBranch
+	Run    *TestRun
+}
+
+// MarshalJSON is what status --query sees: the branch's name, tip and note,
+// and its newest run, or null if it has none.
+func (b *branchRun) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Branch string   `json:"branch"`
+		Tip    string   `json:"tip,omitempty"`
+		Note   string   `json:"note"`
+		Run    *TestRun `json:"run"`
+	}{b.Branch.Name, b.Branch.Tip, b.Note(), b.Run})
+}
+
+// Note says whether the branch is ready for a pull request: its tip was
+// tested and passed.
+func (b *branchRun) Note() string {
+	switch {
+	case b.Run == nil:
+		return "no recent runs"
+	case !strings.HasPrefix(b.Branch.Tip, b.Run.CommitSHA) && !strings.HasPrefix(b.Run.CommitSHA, b.Branch.Tip):
+		return "tip " + shortSHA(b.Branch.Tip) + " not tested"
+	case b.Run.Status == StatusSucceeded:
+		return "ready"
+	case b.Run.InProgress():
+		return "running"
+	default:
+		return ""
+	}
+}
+
+// localBranchRuns matches each local branch that tracks an upstream to the
+// newest run on it.
+func localBranchRuns(ctx context.Context, client *Client, id types.PrefixUUID) ([]*branchRun, error) {
+	branches, err := localBranches()
+	if err != nil {
+		return nil, err
+	}
+	tracking := make([]*localBranch, 0, len(branches))
+	for _, b := range branches {
+		if b.Upstream != "" {
+			tracking = append(tracking, b)
+		}
+	}
+	return matchBranchRuns(ctx, client, id, tracking)
+}
+
+// matchBranchRuns returns each branch with the newest run on it, walking back
+// through the pipeline's recent runs until every branch has one, or through
+// the journal if the API can't be reached.
+func matchBranchRuns(ctx context.Context, client *Client, id types.PrefixUUID, branches []*localBranch) ([]*branchRun, error) {
+	results := make([]*branchRun, 0, len(branches))
+	// Heroku names a branch checked out from a fork's pull request after the
+	// fork's branch.
+	byHeroku := make(map[string]*branchRun)
+	for _, b := range branches {
+		br := &branchRun{Branch: b}
+		results = append(results, br)
+		byHeroku[herokuBranch(ctx, b.Name)] = br
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+	left := len(byHeroku)
+	match := func(page []*TestRun) error {
+		for _, run := range page {
+			br, ok := byHeroku[run.CommitBranch]
+			if !ok || br.Run != nil {
+				continue
+			}
+			br.Run = run
+			if left--; left == 0 {
+				return herokuci.ErrStopWalk
+			}
+		}
+		return nil
+	}
+	err := client.WalkTestRuns(ctx, id, herokuci.WalkOptions{Limit: branchStatusDepth, NewestFirst: true, Workers: 1}, match)
+	if isOffline(err) {
+		runs, jerr := offlineRuns(id)
+		if jerr != nil {
+			return nil, err
+		}
+		err = match(runs)
+		if err == herokuci.ErrStopWalk {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// printBranchRuns prints one line per branch with its newest run.
+func printBranchRuns(w io.Writer, runs []*branchRun) error {
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "No local branches track an upstream branch")
+		return nil
+	}
+	t := newTable("BRANCH", "RUN", "STATUS", "COMMIT", "AGE", "NOTE")
+	t.flex = []int{0, 5}
+	for _, br := range runs {
+		if br.Run == nil {
+			t.addf(br.Branch.Name, "-", "-", shortSHA(br.Branch.Tip), "-", br.Note())
+			continue
+		}
+		t.addf(br.Branch.Name, "#"+strconv.Itoa(br.Run.Number), br.Run.Status, shortSHA(br.Run.CommitSHA),
+			roundDuration(time.Since(br.Run.UpdatedAt)), br.Note())
+	}
+	return t.print(w)
+}
+
+// branchRunFromArgs returns the newest run on the branch named in args, or
+// the current branch.
+func branchRunFromArgs(ctx context.Context, client *Client, id types.PrefixUUID, args []string) ([]*branchRun, error) {
+	branch, err := getBranchFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	b := &localBranch{Name: branch}
+	// The branch may only exist on Heroku; then there's no tip to compare.
+	b.Tip, _ = fullSHA(localRef(branch))
+	run, err := findTestRun(ctx, client, id, herokuBranch(ctx, branch), "")
+	if isOffline(err) {
+		return matchBranchRuns(ctx, client, id, []*localBranch{b})
+	}
+	if err != nil && exitCodeFor(err) != herokuci.ExitNotFound {
+		return nil, err
+	}
+	return []*branchRun{{Branch: b, Run: run}}, nil
+}
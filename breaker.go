@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold is how many requests in a row can fail before we stop
+	// sending requests to the Heroku API.
+	breakerThreshold = 5
+	// breakerCooldown is how long to wait before checking whether the API is
+	// back.
+	breakerCooldown = 60 * time.Second
+	// breakerGiveUp is how long the API can be unreachable before requests
+	// fail instead of waiting for it.
+	breakerGiveUp = 10 * time.Minute
+)
+
+// A circuitBreaker stops a Client from hammering the Heroku API while it is
+// down. GET requests that fail with a 5xx or network error are retried; after
+// breakerThreshold failures in a row the breaker opens, and every request
+// waits until a cheap probe request succeeds.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	failures int
+	// openedAt is when the breaker opened, or the zero time if it is
+	// closed.
+	openedAt time.Time
+	retryAt  time.Time
+	lastErr  error
+
+	// probing is held by the goroutine probing the API, so concurrent
+	// requests wait for one probe instead of sending their own.
+	probing sync.Mutex
+}
+
+// record notes the outcome of a request, and reports whether it is worth
+// trying again.
+func (b *circuitBreaker) record(ctx context.Context, err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil || ctx.Err() != nil || !ambiguousError(err) {
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	b.lastErr = err
+	if b.failures >= breakerThreshold && b.openedAt.IsZero() {
+		b.openedAt = time.Now()
+		b.retryAt = b.openedAt.Add(breakerCooldown)
+	}
+	return true
+}
+
+// backoff returns how long to wait before the next request, given the
+// failures so far.
+func (b *circuitBreaker) backoff() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.openedAt.IsZero() {
+		return 0
+	}
+	return time.Duration(b.failures) * time.Second
+}
+
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.openedAt.IsZero()
+}
+
+// wait blocks until the breaker is closed, probing the API with probe every
+// breakerCooldown. It returns an error if ctx is cancelled or the API has
+// been unreachable for breakerGiveUp.
+func (b *circuitBreaker) wait(ctx context.Context, probe func(context.Context) error) error {
+	if d := b.backoff(); d > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+	b.probing.Lock()
+	defer b.probing.Unlock()
+	for b.isOpen() {
+		b.mu.Lock()
+		openedAt, retryAt, lastErr := b.openedAt, b.retryAt, b.lastErr
+		b.mu.Unlock()
+		if time.Since(openedAt) > breakerGiveUp {
+			return fmt.Errorf("Heroku API unreachable for %s: %v", roundDuration(time.Since(openedAt)), lastErr)
+		}
+		warnf("Heroku API unreachable (%v), retrying in %s", lastErr, time.Until(retryAt).Round(time.Second))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(retryAt)):
+		}
+		err := probe(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		b.mu.Lock()
+		if err == nil || !ambiguousError(err) {
+			b.openedAt = time.Time{}
+			b.failures = 0
+			warnf("Heroku API is reachable again")
+		} else {
+			b.lastErr = err
+			b.retryAt = time.Now().Add(breakerCooldown)
+		}
+		b.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How long to cache responses from endpoints that rarely change.
+const (
+	pipelinesCacheTTL = time.Hour
+	appCacheTTL       = 5 * time.Minute
+)
+
+// responseCache stores API responses in memory and on disk, so that bursts of
+// invocations don't turn into bursts of API calls. Entries are keyed by the
+// request's credentials, URL and Range header.
+type responseCache struct {
+	dir string
+
+	mu  sync.Mutex
+	mem map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	Fetched time.Time       `json:"fetched"`
+	Expires time.Time       `json:"expires"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func newResponseCache() *responseCache {
+	c := &responseCache{mem: make(map[string]*cacheEntry)}
+	if dir, err := httpCacheDir(); err == nil {
+		c.dir = dir
+	}
+	return c
+}
+
+func cacheKey(r *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(r.Header.Get("Authorization")))
+	h.Write([]byte{0})
+	h.Write([]byte(r.Method + " " + r.URL.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(r.Header.Get("Range")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *responseCache) get(key string) (json.RawMessage, bool) {
+	e, ok := c.entry(key)
+	if !ok || !time.Now().Before(e.Expires) {
+		return nil, false
+	}
+	return e.Body, true
+}
+
+// getStale returns the cached response for key even if it has expired,
+// along with when it was fetched.
+func (c *responseCache) getStale(key string) (json.RawMessage, time.Time, bool) {
+	e, ok := c.entry(key)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return e.Body, e.Fetched, true
+}
+
+func (c *responseCache) entry(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mem, ok := c.mem[key]
+	if ok && time.Now().Before(mem.Expires) {
+		return mem, true
+	}
+	// Another process may have fetched a newer copy.
+	if c.dir != "" {
+		data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+		e := new(cacheEntry)
+		if err == nil && json.Unmarshal(data, e) == nil {
+			c.mem[key] = e
+			return e, true
+		}
+	}
+	return mem, ok
+}
+
+func (c *responseCache) set(key string, body json.RawMessage, ttl time.Duration) {
+	now := time.Now()
+	e := &cacheEntry{Fetched: now, Expires: now.Add(ttl), Body: body}
+	c.mu.Lock()
+	c.mem[key] = e
+	c.mu.Unlock()
+	if c.dir == "" {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	// A failed write just means a cache miss next time.
+	writeFileAtomic(filepath.Join(c.dir, key+".json"), data, 0600)
+}
+
+// clear drops every cached response.
+func (c *responseCache) clear() {
+	c.mu.Lock()
+	c.mem = make(map[string]*cacheEntry)
+	c.mu.Unlock()
+	if c.dir != "" {
+		os.RemoveAll(c.dir)
+	}
+}
+
+// cacheDisabled turns off the response cache, set by the --no-cache flag.
+var cacheDisabled bool
+
+// DoCached is like Do, but serves GET requests from the response cache if a
+// fresh enough copy is available, and caches the response for ttl otherwise.
+// Use it only for data that can be slightly stale. If we're offline, an
+// expired copy is better than nothing, and is used instead.
+func (c *Client) DoCached(r *http.Request, v interface{}, ttl time.Duration) error {
+	if c.cache == nil || r.Method != "GET" {
+		return c.Do(r, v)
+	}
+	key := cacheKey(r)
+	if body, ok := c.cache.get(key); ok {
+		if err := json.Unmarshal(body, v); err == nil {
+			return nil
+		}
+	}
+	var body json.RawMessage
+	if err := c.Do(r, &body); err != nil {
+		if isOffline(err) {
+			if stale, fetched, ok := c.cache.getStale(key); ok && json.Unmarshal(stale, v) == nil {
+				warnf("offline, using %s from %s", r.URL.Path, fmtDateTimeMinutes(fetched))
+				return nil
+			}
+		}
+		return err
+	}
+	c.cache.set(key, body, ttl)
+	return json.Unmarshal(body, v)
+}
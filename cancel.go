@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// How many recent runs cancel looks through for matches.
+const cancelSearchDepth = 200
+
+// cancelFilter selects the test runs to cancel.
+type cancelFilter struct {
+	// Branch is a glob matched against the run's branch, as in path.Match.
+	// Empty matches every branch.
+	Branch string
+	// Statuses lists the statuses to match. Empty matches every run that is
+	// still in progress.
+	Statuses []RunStatus
+}
+
+func (f cancelFilter) match(run *TestRun) (bool, error) {
+	if !run.InProgress() {
+		// Finished runs can't be cancelled.
+		return false, nil
+	}
+	if f.Branch != "" {
+		ok, err := path.Match(f.Branch, run.CommitBranch)
+		if err != nil {
+			return false, fmt.Errorf("bad --branch pattern %q: %v", f.Branch, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	if len(f.Statuses) == 0 {
+		return true, nil
+	}
+	for _, status := range f.Statuses {
+		if run.Status == status {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// printRunTable prints the number, branch, commit, status and age of each
+// run, so the user can see exactly what a bulk operation will touch.
+func printRunTable(w io.Writer, runs []*TestRun) {
+	t := newTable("RUN", "BRANCH", "COMMIT", "STATUS", "AGE")
+	t.flex = []int{1}
+	for _, run := range runs {
+		t.addf("#"+strconv.Itoa(run.Number), run.CommitBranch, shortSHA(run.CommitSHA), run.Status, roundDuration(time.Since(run.CreatedAt)))
+	}
+	t.print(w)
+}
+
+// cancelTestRun asks Heroku to stop the test run with the given number.
+func cancelTestRun(ctx context.Context, client *Client, id types.PrefixUUID, number int) error {
+	data, err := json.Marshal(map[string]RunStatus{"status": StatusCancelled})
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest("PATCH", "/pipelines/"+id.String()+"/test-runs/"+strconv.Itoa(number), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return client.Do(req, nil)
+}
+
+// cancelTestRuns cancels every recent in-progress run in the pipeline that
+// matches filter. Unless yes is true, it lists the matches and asks before
+// cancelling anything. If dryRun is true it lists the matches and stops.
+func cancelTestRuns(ctx context.Context, client *Client, id types.PrefixUUID, filter cancelFilter, yes, dryRun bool) error {
+	runs, err := fetchTestRunHistory(ctx, client, id, cancelSearchDepth)
+	if err != nil {
+		return err
+	}
+	matches := make([]*TestRun, 0)
+	for _, run := range runs {
+		ok, err := filter.match(run)
+		if err != nil {
+			return err
+		}
+		if ok {
+			matches = append(matches, run)
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Println("No matching test runs to cancel")
+		return nil
+	}
+	printRunTable(os.Stdout, matches)
+	if dryRun {
+		fmt.Printf("Dry run: would cancel %d test runs\n", len(matches))
+		return nil
+	}
+	if err := checkPermission(ctx, client, id, "cancel", permOperate); err != nil {
+		return err
+	}
+	if err := checkWritable("cancel test runs"); err != nil {
+		return err
+	}
+	if err := confirm(fmt.Sprintf("Cancel %d test runs?", len(matches)), yes); err != nil {
+		return err
+	}
+	for _, run := range matches {
+		if err := cancelTestRun(ctx, client, id, run.Number); err != nil {
+			return fmt.Errorf("cancelling run #%d: %v", run.Number, err)
+		}
+		fmt.Printf("Cancelled run #%d on %s\n", run.Number, run.CommitBranch)
+		recordAudit(ctx, &AuditEntry{
+			Account:    client.ID,
+			Action:     "cancel",
+			PipelineID: id.String(),
+			RunNumber:  run.Number,
+			Target:     run.CommitBranch,
+			Text:       fmt.Sprintf("Cancelled test run #%d on %s (%s)", run.Number, run.CommitBranch, shortSHA(run.CommitSHA)),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// chaosConfig is set by --chaos. With it, requests are slowed down and fail
+// on purpose, so retries, backoff, and stream reconnects can be tried out,
+// say against --demo, without waiting for Heroku to misbehave.
+var chaosConfig *chaos
+
+// chaos describes the faults to inject. Each probability is between 0 and 1.
+type chaos struct {
+	// Latency is the most to delay each request by. Each request waits a
+	// random time up to it.
+	Latency time.Duration
+	// RateLimited is the chance an API request gets a 429.
+	RateLimited float64
+	// ServerError is the chance a request gets a 503.
+	ServerError float64
+	// Drop is the chance a stream is cut off partway through.
+	Drop float64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// parseChaos parses a --chaos value like
+// "latency=500ms,429=10%,5xx=5%,drop=20%,seed=7". The same seed injects the
+// same faults in the same order.
+func parseChaos(s string) (*chaos, error) {
+	c := new(chaos)
+	seed := int64(1)
+	for _, field := range splitLabels(s) {
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("--chaos: %q should look like key=value", field)
+		}
+		var err error
+		switch key {
+		case "latency":
+			c.Latency, err = time.ParseDuration(val)
+		case "429":
+			c.RateLimited, err = parseChance(val)
+		case "5xx":
+			c.ServerError, err = parseChance(val)
+		case "drop":
+			c.Drop, err = parseChance(val)
+		case "seed":
+			seed, err = strconv.ParseInt(val, 10, 64)
+		default:
+			return nil, fmt.Errorf("--chaos: unknown fault %q, want latency, 429, 5xx, drop, or seed", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("--chaos: bad %s: %v", key, err)
+		}
+	}
+	c.rng = rand.New(rand.NewSource(seed))
+	return c, nil
+}
+
+// parseChance parses a probability written as "10%" or "0.1".
+func parseChance(s string) (float64, error) {
+	percent := strings.HasSuffix(s, "%")
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if percent {
+		f /= 100
+	}
+	if f < 0 || f > 1 {
+		return 0, fmt.Errorf("%s is not between 0 and 100%%", s)
+	}
+	return f, nil
+}
+
+func (c *chaos) float() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Float64()
+}
+
+func (c *chaos) delay() time.Duration {
+	if c.Latency <= 0 {
+		return 0
+	}
+	return time.Duration(c.float() * float64(c.Latency))
+}
+
+// transport returns next with faults injected. Responses from the Heroku
+// API can be rate limited; streams, from anywhere else, can be dropped.
+func (c *chaos) transport(next http.RoundTripper, api bool) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &chaosTransport{chaos: c, next: next, api: api}
+}
+
+type chaosTransport struct {
+	chaos *chaos
+	next  http.RoundTripper
+	api   bool
+}
+
+func (t *chaosTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c := t.chaos
+	if d := c.delay(); d > 0 {
+		select {
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-time.After(d):
+		}
+	}
+	if t.api && c.RateLimited > 0 && c.float() < c.RateLimited {
+		return chaosResponse(r, http.StatusTooManyRequests, `{"id":"rate_limit","message":"Your account reached the API rate limit (injected by --chaos)"}`), nil
+	}
+	if c.ServerError > 0 && c.float() < c.ServerError {
+		return chaosResponse(r, http.StatusServiceUnavailable, `{"id":"unavailable","message":"Service unavailable (injected by --chaos)"}`), nil
+	}
+	resp, err := t.next.RoundTrip(r)
+	if err != nil || t.api || c.Drop <= 0 || c.float() >= c.Drop {
+		return resp, err
+	}
+	// Cut the stream off after a few lines' worth of bytes.
+	c.mu.Lock()
+	n := 64 + c.rng.Int63n(4096)
+	c.mu.Unlock()
+	resp.Body = &droppedBody{ReadCloser: resp.Body, left: n}
+	return resp, nil
+}
+
+func chaosResponse(r *http.Request, status int, body string) *http.Response {
+	h := make(http.Header)
+	h.Set("Content-Type", "application/json")
+	if status == http.StatusTooManyRequests {
+		h.Set("Retry-After", "1")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+// droppedBody returns io.ErrUnexpectedEOF once left bytes have been read,
+// like a connection that went away.
+type droppedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (b *droppedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// Where GitHub looks for a CODEOWNERS file, in order.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// An ownerRule is one line of a CODEOWNERS file.
+type ownerRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// codeowners is the parsed CODEOWNERS file for the repository in the
+// working directory; nil if it doesn't have one.
+type codeowners struct {
+	root  string
+	rules []ownerRule
+}
+
+var (
+	codeownersOnce sync.Once
+	repoCodeowners *codeowners
+	codeownersErr  error
+)
+
+// loadCodeowners reads and parses the repository's CODEOWNERS file once. It
+// returns nil and no error if there isn't one.
+func loadCodeowners() (*codeowners, error) {
+	codeownersOnce.Do(func() {
+		out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+		if err != nil {
+			return
+		}
+		root := strings.TrimSpace(string(out))
+		for _, p := range codeownersPaths {
+			data, err := os.ReadFile(filepath.Join(root, p))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				codeownersErr = err
+				return
+			}
+			repoCodeowners, codeownersErr = parseCodeowners(data)
+			if codeownersErr != nil {
+				codeownersErr = fmt.Errorf("%s: %v", p, codeownersErr)
+			} else {
+				repoCodeowners.root = root
+			}
+			return
+		}
+	})
+	return repoCodeowners, codeownersErr
+}
+
+func parseCodeowners(data []byte) (*codeowners, error) {
+	c := new(codeowners)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := codeownersPattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		// A pattern with no owners unsets the owners of the files it
+		// matches.
+		c.rules = append(c.rules, ownerRule{pattern: re, owners: fields[1:]})
+	}
+	return c, scanner.Err()
+}
+
+// codeownersPattern translates a CODEOWNERS pattern, which follows the
+// .gitignore rules, to a regular expression that matches file paths.
+func codeownersPattern(pat string) (*regexp.Regexp, error) {
+	// A pattern with a slash before its last character is relative to the
+	// root; otherwise it matches at any depth.
+	anchored := strings.Contains(strings.TrimSuffix(pat, "/"), "/")
+	pat = strings.TrimPrefix(pat, "/")
+	dir := strings.HasSuffix(pat, "/")
+	pat = strings.TrimSuffix(pat, "/")
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pat); i++ {
+		switch c := pat[i]; {
+		case strings.HasPrefix(pat[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pat[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if dir {
+		// Only the files inside the directory.
+		b.WriteString("/.*$")
+	} else {
+		// The file, or everything inside it if it's a directory.
+		b.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(b.String())
+}
+
+// owners returns the owners of file, a path relative to the repository
+// root. The last matching rule wins.
+func (c *codeowners) owners(file string) []string {
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if c.rules[i].pattern.MatchString(file) {
+			return c.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// testFile returns the path in the repository of the file a failing test
+// name points at, like "./spec/order_spec.rb:41" or
+// "tests/test_cart.py::test_total", or "" if it doesn't name a file that
+// exists.
+func (c *codeowners) testFile(test string) string {
+	name := strings.TrimPrefix(test, "./")
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[:i]
+	}
+	if !strings.Contains(name, "/") {
+		return ""
+	}
+	if fi, err := os.Stat(filepath.Join(c.root, filepath.FromSlash(name))); err != nil || fi.IsDir() {
+		return ""
+	}
+	return name
+}
+
+// implicatedOwners returns the owners of the changed files and failing
+// tests in h, those owning the most files first.
+func (c *codeowners) implicatedOwners(h *failureHint) []string {
+	seen := make(map[string]bool)
+	counts := make(map[string]int)
+	add := func(file string) {
+		if file == "" || seen[file] {
+			return
+		}
+		seen[file] = true
+		for _, o := range c.owners(file) {
+			counts[o]++
+		}
+	}
+	for _, t := range h.Tests {
+		add(c.testFile(t))
+	}
+	for _, f := range h.Files {
+		add(f)
+	}
+	owners := make([]string, 0, len(counts))
+	for o := range counts {
+		owners = append(owners, o)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		if counts[owners[i]] != counts[owners[j]] {
+			return counts[owners[i]] > counts[owners[j]]
+		}
+		return owners[i] < owners[j]
+	})
+	return owners
+}
+
+// runOwners returns the owners implicated in a failing run, for
+// notifications and events, or nil if the run didn't fail or the repository
+// has no CODEOWNERS file. Errors are left for wait to report.
+func runOwners(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun) []string {
+	if !isFailing(run.Status) {
+		return nil
+	}
+	if c, err := loadCodeowners(); c == nil || err != nil {
+		return nil
+	}
+	h, _ := runFailureHint(ctx, client, id, run)
+	if h == nil {
+		return nil
+	}
+	return h.Owners
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A Component is one service in a monorepo, configured in git config:
+//
+//	[heroku-ci-component "api"]
+//		path = services/api
+//		pattern = services/api/|api_test
+//
+// pattern is a regular expression; a log line belongs to the component if it
+// matches. It defaults to the component's path.
+type Component struct {
+	Name    string
+	Path    string
+	Pattern *regexp.Regexp
+}
+
+// loadComponents reads every component configured for the current
+// repository, sorted by name.
+func loadComponents() ([]*Component, error) {
+	out, err := exec.Command("git", "config", "--get-regexp", `^heroku-ci-component\.`).Output()
+	if err != nil {
+		// git config exits 1 when nothing matches.
+		return nil, nil
+	}
+	byName := make(map[string]*Component)
+	patterns := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, val, _ := strings.Cut(line, " ")
+		key = strings.TrimPrefix(key, "heroku-ci-component.")
+		dot := strings.LastIndex(key, ".")
+		if dot < 0 {
+			continue
+		}
+		name, field := key[:dot], key[dot+1:]
+		c, ok := byName[name]
+		if !ok {
+			c = &Component{Name: name}
+			byName[name] = c
+		}
+		switch field {
+		case "path":
+			c.Path = strings.Trim(filepath.ToSlash(val), "/")
+		case "pattern":
+			patterns[name] = val
+		}
+	}
+	components := make([]*Component, 0, len(byName))
+	for name, c := range byName {
+		if c.Path == "" {
+			return nil, fmt.Errorf("component %q has no path; set heroku-ci-component.%s.path", name, name)
+		}
+		pattern, ok := patterns[name]
+		if !ok {
+			pattern = regexp.QuoteMeta(c.Path + "/")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for component %q: %v", name, err)
+		}
+		c.Pattern = re
+		components = append(components, c)
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].Name < components[j].Name })
+	return components, nil
+}
+
+// currentComponent returns the component to scope output to. If name is
+// empty it is the component whose path contains the working directory, if
+// any; "all" turns scoping off.
+func currentComponent(name string) (*Component, error) {
+	if name == "all" {
+		return nil, nil
+	}
+	components, err := loadComponents()
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		for _, c := range components {
+			if c.Name == name {
+				return c, nil
+			}
+		}
+		return nil, fmt.Errorf("no component named %q is configured", name)
+	}
+	root := currentRepoRoot()
+	wd, err := os.Getwd()
+	if root == "" || err != nil {
+		return nil, nil
+	}
+	rel, err := filepath.Rel(root, canonicalRoot(wd))
+	if err != nil {
+		return nil, nil
+	}
+	rel = filepath.ToSlash(rel)
+	var best *Component
+	for _, c := range components {
+		if rel == c.Path || strings.HasPrefix(rel, c.Path+"/") {
+			// The most specific path wins.
+			if best == nil || len(c.Path) > len(best.Path) {
+				best = c
+			}
+		}
+	}
+	return best, nil
+}
+
+// scopeLines returns the lines that belong to c, and how many were dropped.
+// A nil component keeps every line.
+func scopeLines(c *Component, lines []string) ([]string, int) {
+	if c == nil {
+		return lines, 0
+	}
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if c.Pattern.MatchString(line) {
+			kept = append(kept, line)
+		}
+	}
+	return kept, len(lines) - len(kept)
+}
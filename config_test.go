@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigReadonlyAtTop(t *testing.T) {
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{"readonly = true\n\n[updates]\ncheck = false\n", true},
+		// Under a section it's updates.readonly, which isn't a setting.
+		{"[updates]\ncheck = false\n\nreadonly = true\n", false},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", dir)
+		t.Setenv("HEROKU_CI_READONLY", "")
+		path := filepath.Join(dir, "heroku-ci", "config")
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Readonly != tt.want {
+			t.Errorf("%q: got Readonly %t, want %t", tt.file, cfg.Readonly, tt.want)
+		}
+		if cfg.UpdatesCheck {
+			t.Errorf("%q: updates.check = false was ignored", tt.file)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errNotConfirmed is returned when the user declines a confirmation prompt.
+var errNotConfirmed = errors.New("aborted; nothing was changed")
+
+// stdinIsTerminal reports whether there is a user at a terminal to answer
+// questions.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks the user a yes or no question on the terminal before a
+// destructive action, defaulting to no. If yes is true, because the user
+// passed --yes, it returns immediately. When stdin is not a terminal there is
+// nobody to ask, so scripts must pass --yes.
+func confirm(question string, yes bool) error {
+	if yes {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return errors.New("stdin is not a terminal; pass --yes to skip confirmation")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errNotConfirmed
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// The stages an app can occupy in a pipeline.
+var pipelineStages = []string{"test", "review", "development", "staging", "production"}
+
+type PipelineCoupling struct {
+	ID    types.PrefixUUID `json:"id"`
+	Stage string           `json:"stage"`
+	App   struct {
+		ID   types.PrefixUUID `json:"id"`
+		Name string           `json:"name"`
+	} `json:"app"`
+	Pipeline struct {
+		ID types.PrefixUUID `json:"id"`
+	} `json:"pipeline"`
+}
+
+// pipelineCouplings returns every app coupling in the pipeline. The API only
+// returns app IDs, so we look up each app's name as well.
+func pipelineCouplings(ctx context.Context, client *Client, id types.PrefixUUID) ([]*PipelineCoupling, error) {
+	req, err := client.NewRequest("GET", "/pipelines/"+id.String()+"/pipeline-couplings", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	couplings := make([]*PipelineCoupling, 0)
+	if err := client.DoCached(req, &couplings, appCacheTTL); err != nil {
+		return nil, err
+	}
+	for _, coupling := range couplings {
+		if coupling.App.Name != "" {
+			continue
+		}
+		app, err := getApp(ctx, client, coupling.App.ID.String())
+		if err != nil {
+			return nil, err
+		}
+		coupling.App.Name = app.Name
+	}
+	return couplings, nil
+}
+
+// getApp returns the app with the given name or ID.
+func getApp(ctx context.Context, client *Client, app string) (*App, error) {
+	req, err := client.NewRequest("GET", "/apps/"+app, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	a := new(App)
+	if err := client.DoCached(req, a, appCacheTTL); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// appPipelineCoupling returns the pipeline coupling for app, which says which
+// pipeline (and stage) the app belongs to.
+func appPipelineCoupling(ctx context.Context, client *Client, app string) (*PipelineCoupling, error) {
+	req, err := client.NewRequest("GET", "/apps/"+app+"/pipeline-couplings", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	coupling := new(PipelineCoupling)
+	if err := client.Do(req, coupling); err != nil {
+		return nil, err
+	}
+	return coupling, nil
+}
+
+func validStage(stage string) bool {
+	for _, s := range pipelineStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+func listCouplings(ctx context.Context, client *Client, id types.PrefixUUID) error {
+	couplings, err := pipelineCouplings(ctx, client, id)
+	if err != nil {
+		return err
+	}
+	if len(couplings) == 0 {
+		fmt.Println("No apps are coupled to this pipeline.")
+		return nil
+	}
+	t := newTable("APP", "STAGE")
+	for _, stage := range pipelineStages {
+		for _, coupling := range couplings {
+			if coupling.Stage == stage {
+				t.add(coupling.App.Name, coupling.Stage)
+			}
+		}
+	}
+	return t.print(os.Stdout)
+}
+
+func addCoupling(ctx context.Context, client *Client, id types.PrefixUUID, app, stage string) error {
+	if !validStage(stage) {
+		return fmt.Errorf("invalid stage %q, want one of %v", stage, pipelineStages)
+	}
+	if err := checkPermission(ctx, client, id, "couplings add", permAdmin); err != nil {
+		return err
+	}
+	data, err := json.Marshal(map[string]string{
+		"app":      app,
+		"pipeline": id.String(),
+		"stage":    stage,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest("POST", "/pipeline-couplings", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	coupling := new(PipelineCoupling)
+	if err := client.Do(req, coupling); err != nil {
+		return err
+	}
+	fmt.Printf("Added %s to the %s stage\n", app, coupling.Stage)
+	recordAudit(ctx, &AuditEntry{
+		Account:    client.ID,
+		Action:     "coupling.add",
+		PipelineID: id.String(),
+		Target:     app,
+		Text:       fmt.Sprintf("Added %s to the %s stage", app, coupling.Stage),
+	})
+	return nil
+}
+
+func removeCoupling(ctx context.Context, client *Client, app string, yes, dryRun bool) error {
+	coupling, err := appPipelineCoupling(ctx, client, app)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("Dry run: would remove %s from the %s stage\n", app, coupling.Stage)
+		return nil
+	}
+	if err := confirm(fmt.Sprintf("Remove %s from the %s stage?", app, coupling.Stage), yes); err != nil {
+		return err
+	}
+	req, err := client.NewRequest("DELETE", "/pipeline-couplings/"+coupling.ID.String(), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if err := client.Do(req, nil); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s from the %s stage\n", app, coupling.Stage)
+	recordAudit(ctx, &AuditEntry{
+		Account:    client.ID,
+		Action:     "coupling.remove",
+		PipelineID: coupling.Pipeline.ID.String(),
+		Target:     app,
+		Text:       fmt.Sprintf("Removed %s from the %s stage", app, coupling.Stage),
+	})
+	return nil
+}
+
+const couplingsUsage = "usage: heroku-ci couplings list | add <app> <stage> | remove <app>"
+
+// couplingsCommand runs the couplings subcommand named by args[0]. yes skips
+// the confirmation before removing a coupling, and dryRun prints the
+// coupling that would be removed instead.
+func couplingsCommand(ctx context.Context, client *Client, pipelineID string, args []string, yes, dryRun bool) error {
+	if len(args) == 0 {
+		return errors.New(couplingsUsage)
+	}
+	switch args[0] {
+	case "list":
+		id, err := resolvePipelineID(ctx, client, pipelineID)
+		if err != nil {
+			return err
+		}
+		return listCouplings(ctx, client, id)
+	case "add":
+		if len(args) != 3 {
+			return errors.New(couplingsUsage)
+		}
+		id, err := resolvePipelineID(ctx, client, pipelineID)
+		if err != nil {
+			return err
+		}
+		return addCoupling(ctx, client, id, args[1], args[2])
+	case "remove":
+		if len(args) != 2 {
+			return errors.New(couplingsUsage)
+		}
+		return removeCoupling(ctx, client, args[1], yes, dryRun)
+	default:
+		return fmt.Errorf("unknown couplings command %q, want list, add, or remove", args[0])
+	}
+}
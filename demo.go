@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// demoMode is set by --demo or $HEROKU_CI_DEMO. heroku-ci then talks to a
+// fake Heroku API in the same process instead of api.heroku.com, so it can be
+// tried without an account, and so talks and screenshots show the same runs
+// every time.
+var demoMode bool
+
+// demoURL is the fake API's URL, once it has started.
+var demoURL string
+
+// The pipeline and branch demo commands use when none is named, since there
+// may be no git checkout to find them in.
+const (
+	demoPipelineName = "demo-api"
+	demoBranch       = "main"
+)
+
+// demoConfig is the config file demo mode uses in place of the user's, so
+// their notifications, webhooks, and journal are never touched.
+const demoConfig = `readonly = true
+
+[updates]
+check = false
+
+[top]
+pipelines = demo-api, demo-web
+`
+
+// A demoStep is a status a scripted run reaches, After its start.
+type demoStep struct {
+	After  time.Duration
+	Status RunStatus
+}
+
+// Every scripted run goes through these steps, ending in its own final
+// status.
+var demoSteps = []demoStep{
+	{0, StatusPending},
+	{2 * time.Second, StatusCreating},
+	{4 * time.Second, StatusBuilding},
+	{10 * time.Second, StatusRunning},
+}
+
+// A demoRun is a canned test run. Runs with a zero Ago are scripted: they
+// start when heroku-ci does and finish after Takes.
+type demoRun struct {
+	Number  int
+	Branch  string
+	SHA     string
+	Message string
+	Author  string
+	Final   RunStatus
+	Ago     time.Duration
+	Takes   time.Duration
+}
+
+type demoPipeline struct {
+	ID   types.PrefixUUID
+	Name string
+	Runs []demoRun
+}
+
+var demoPipelines = []*demoPipeline{
+	{
+		ID:   mustDemoID("0de00001-0000-4000-8000-000000000000"),
+		Name: "demo-api",
+		Runs: []demoRun{
+			{101, "main", "5b1e0c4f2a9d8e7b6c5a4f3e2d1c0b9a8f7e6d5c", "Add rate limiting to the public API", "alice@example.com", StatusSucceeded, 3 * time.Hour, 4 * time.Minute},
+			{102, "feature/login", "8c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d", "Start on passwordless login", "bob@example.com", StatusFailed, 2 * time.Hour, 3 * time.Minute},
+			{103, "main", "c4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5", "Fix flaky session test", "alice@example.com", StatusSucceeded, time.Hour, 4 * time.Minute},
+			{104, "main", "e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8", "Cache pipeline names", "carol@example.com", StatusSucceeded, 0, 20 * time.Second},
+			{105, "feature/login", "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b", "Send login links by email", "bob@example.com", StatusFailed, 0, 25 * time.Second},
+		},
+	},
+	{
+		ID:   mustDemoID("0de00002-0000-4000-8000-000000000000"),
+		Name: "demo-web",
+		Runs: []demoRun{
+			{57, "main", "9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b2a1f0e", "Dark mode for the dashboard", "dana@example.com", StatusSucceeded, 90 * time.Minute, 6 * time.Minute},
+			{58, "main", "0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f", "Bump the design system", "dana@example.com", StatusSucceeded, 0, 30 * time.Second},
+		},
+	},
+}
+
+var demoSetupLines = []string{
+	"-----> Restoring cache",
+	"-----> Building on the Heroku-22 stack",
+	"-----> Using buildpack: heroku/nodejs",
+	"       Installing node 20.11.0",
+	"-----> Installing dependencies",
+	"       added 812 packages in 9s",
+	"-----> Running test setup",
+}
+
+var demoPassingOutput = []string{
+	"> demo@1.0.0 test",
+	"> jest --ci",
+	"",
+	"PASS test/api.test.js",
+	"PASS test/session.test.js",
+	"PASS test/cache.test.js",
+	"",
+	"Tests:       48 passed, 48 total",
+}
+
+var demoFailingOutput = []string{
+	"> demo@1.0.0 test",
+	"> jest --ci",
+	"",
+	"PASS test/api.test.js",
+	"FAIL test/login.test.js",
+	"  ● login › sends a link to the email address",
+	"",
+	"    expect(received).toBe(expected)",
+	"    Expected: 1",
+	"    Received: 0",
+	"",
+	"Tests:       1 failed, 47 passed, 48 total",
+}
+
+// demoServer is the fake Heroku API.
+type demoServer struct {
+	start time.Time
+	url   string
+}
+
+// startDemo points heroku-ci's config, cache, and data directories at a new
+// temporary directory and starts the fake API. It returns the API's URL.
+func startDemo() (string, error) {
+	dir, err := os.MkdirTemp("", "heroku-ci-demo-")
+	if err != nil {
+		return "", err
+	}
+	for _, v := range []string{"XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME"} {
+		if err := os.Setenv(v, filepath.Join(dir, strings.ToLower(strings.TrimPrefix(v, "XDG_")))); err != nil {
+			return "", err
+		}
+	}
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(demoConfig), 0600); err != nil {
+		return "", err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	s := &demoServer{start: time.Now(), url: "http://" + ln.Addr().String()}
+	go http.Serve(ln, s)
+	return s.url, nil
+}
+
+// demoClient returns a Client for the fake API.
+func demoClient(url string) *Client {
+	return newAPIClient("demo@example.com", "demo-token", herokuci.WithBaseURL(url))
+}
+
+func (s *demoServer) pipeline(ref string) *demoPipeline {
+	for _, p := range demoPipelines {
+		if ref == p.ID.String() || ref == p.Name {
+			return p
+		}
+	}
+	return nil
+}
+
+func mustDemoID(s string) types.PrefixUUID {
+	id, err := types.NewPrefixUUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// demoRunID returns the ID of p's run with the given number, which is also
+// the ID of its one node.
+func demoRunID(p *demoPipeline, number int) types.PrefixUUID {
+	return mustDemoID(fmt.Sprintf("%s-%012d", p.ID.String()[:23], number))
+}
+
+// started returns when r was created.
+func (s *demoServer) started(r demoRun) time.Time {
+	if r.Ago == 0 {
+		return s.start
+	}
+	return s.start.Add(-r.Ago)
+}
+
+// status returns r's status at now, and when it last changed.
+func (s *demoServer) status(r demoRun, now time.Time) (RunStatus, time.Time) {
+	start := s.started(r)
+	elapsed := now.Sub(start)
+	if elapsed >= r.Takes {
+		return r.Final, start.Add(r.Takes)
+	}
+	step := demoSteps[0]
+	for _, st := range demoSteps {
+		if elapsed >= st.After {
+			step = st
+		}
+	}
+	return step.Status, start.Add(step.After)
+}
+
+func (s *demoServer) testRun(p *demoPipeline, r demoRun, now time.Time) *TestRun {
+	status, updated := s.status(r, now)
+	return &TestRun{
+		CreatedAt:     s.started(r),
+		ID:            demoRunID(p, r.Number),
+		UpdatedAt:     updated,
+		Number:        r.Number,
+		CommitBranch:  r.Branch,
+		CommitSHA:     r.SHA,
+		CommitMessage: r.Message,
+		ActorEmail:    r.Author,
+		Status:        status,
+	}
+}
+
+func (s *demoServer) testNode(p *demoPipeline, r demoRun, now time.Time) *TestNode {
+	status, updated := s.status(r, now)
+	node := &TestNode{
+		CreatedAt: s.started(r),
+		ID:        demoRunID(p, r.Number),
+		UpdatedAt: updated,
+		Index:     0,
+		Status:    string(status),
+	}
+	if status == StatusPending {
+		return node
+	}
+	base := fmt.Sprintf("%s/streams/%s/%d/", s.url, p.Name, r.Number)
+	node.SetupStreamURL = base + "setup"
+	if status != StatusCreating && status != StatusBuilding {
+		node.OutputStreamURL = base + "output"
+	}
+	if status.Terminal() {
+		code := 0
+		if status != StatusSucceeded {
+			code = 1
+		}
+		node.ExitCode = &code
+	}
+	return node
+}
+
+// demoRange matches the Range header heroku-ci sends when listing runs.
+var demoRange = regexp.MustCompile(`^number (\d*)\.\.(\d*); order=(asc|desc), max=(\d+)$`)
+
+// listRuns returns p's runs created by now that the Range header picks.
+func (s *demoServer) listRuns(p *demoPipeline, rangeHeader string, now time.Time) []*TestRun {
+	runs := make([]*TestRun, 0, len(p.Runs))
+	for _, r := range p.Runs {
+		runs = append(runs, s.testRun(p, r, now))
+	}
+	from, to, desc, max := 0, 0, false, 0
+	if m := demoRange.FindStringSubmatch(rangeHeader); m != nil {
+		from, _ = strconv.Atoi(m[1])
+		to, _ = strconv.Atoi(m[2])
+		desc = m[3] == "desc"
+		max, _ = strconv.Atoi(m[4])
+	}
+	picked := runs[:0]
+	for _, run := range runs {
+		if (from == 0 || run.Number >= from) && (to == 0 || run.Number <= to) {
+			picked = append(picked, run)
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool {
+		if desc {
+			return picked[i].Number > picked[j].Number
+		}
+		return picked[i].Number < picked[j].Number
+	})
+	if max > 0 && len(picked) > max {
+		picked = picked[:max]
+	}
+	return picked
+}
+
+func (s *demoServer) findRun(number int) (*demoPipeline, demoRun, bool) {
+	for _, p := range demoPipelines {
+		for _, r := range p.Runs {
+			if r.Number == number {
+				return p, r, true
+			}
+		}
+	}
+	return nil, demoRun{}, false
+}
+
+func (s *demoServer) findRunByID(id string) (*demoPipeline, demoRun, bool) {
+	for _, p := range demoPipelines {
+		for _, r := range p.Runs {
+			if demoRunID(p, r.Number).String() == id {
+				return p, r, true
+			}
+		}
+	}
+	return nil, demoRun{}, false
+}
+
+func demoJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func demoNotFound(w http.ResponseWriter, what string) {
+	demoJSON(w, http.StatusNotFound, &HerokuError{ID: "not_found", Message: "Couldn't find that " + what + "."})
+}
+
+func (s *demoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != "GET" {
+		demoJSON(w, http.StatusForbidden, &HerokuError{ID: "forbidden", Message: "The demo can't change anything."})
+		return
+	}
+	switch {
+	case len(parts) == 1 && parts[0] == "account":
+		demoJSON(w, 200, map[string]string{"id": "00000000-0000-0000-0000-00000000de00", "email": "demo@example.com"})
+	case len(parts) == 1 && parts[0] == "pipelines":
+		ps := make([]*Pipeline, 0, len(demoPipelines))
+		for _, p := range demoPipelines {
+			ps = append(ps, &Pipeline{ID: p.ID, Name: p.Name, CreatedAt: s.start.Add(-30 * 24 * time.Hour), UpdatedAt: s.start})
+		}
+		demoJSON(w, 200, ps)
+	case len(parts) >= 2 && parts[0] == "pipelines":
+		p := s.pipeline(parts[1])
+		if p == nil {
+			demoNotFound(w, "pipeline")
+			return
+		}
+		switch {
+		case len(parts) == 2:
+			demoJSON(w, 200, &Pipeline{ID: p.ID, Name: p.Name, CreatedAt: s.start.Add(-30 * 24 * time.Hour), UpdatedAt: s.start})
+		case len(parts) == 3 && parts[2] == "test-runs":
+			demoJSON(w, 200, s.listRuns(p, r.Header.Get("Range"), now))
+		case len(parts) == 4 && parts[2] == "test-runs":
+			n, _ := strconv.Atoi(parts[3])
+			if rp, run, ok := s.findRun(n); ok && rp == p {
+				demoJSON(w, 200, s.testRun(p, run, now))
+				return
+			}
+			demoNotFound(w, "test run")
+		case len(parts) == 3 && parts[2] == "pipeline-couplings":
+			demoJSON(w, 200, []struct{}{})
+		case len(parts) == 5 && parts[2] == "stage" && parts[3] == "test" && parts[4] == "config-vars":
+			demoJSON(w, 200, map[string]string{"RAILS_ENV": "test"})
+		default:
+			demoNotFound(w, "resource")
+		}
+	case len(parts) >= 2 && parts[0] == "test-runs":
+		p, run, ok := s.findRunByID(parts[1])
+		if !ok {
+			demoNotFound(w, "test run")
+			return
+		}
+		switch {
+		case len(parts) == 2:
+			demoJSON(w, 200, s.testRun(p, run, now))
+		case len(parts) == 3 && parts[2] == "test-nodes":
+			demoJSON(w, 200, []*TestNode{s.testNode(p, run, now)})
+		default:
+			demoNotFound(w, "resource")
+		}
+	case len(parts) == 4 && parts[0] == "streams":
+		p := s.pipeline(parts[1])
+		n, _ := strconv.Atoi(parts[2])
+		rp, run, ok := s.findRun(n)
+		if p == nil || !ok || rp != p {
+			http.NotFound(w, r)
+			return
+		}
+		s.stream(w, r, run, parts[3])
+	default:
+		demoNotFound(w, "resource")
+	}
+}
+
+// stream writes a node's setup or output stream, a line at a time, and
+// holds it open until that part of the run is done, like Heroku does.
+func (s *demoServer) stream(w http.ResponseWriter, r *http.Request, run demoRun, kind string) {
+	start := s.started(run)
+	lines, from, until := demoSetupLines, demoSteps[1].After, demoSteps[3].After
+	if kind == "output" {
+		lines, from, until = demoPassingOutput, demoSteps[3].After, run.Takes
+		if run.Final != StatusSucceeded {
+			lines = demoFailingOutput
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	flusher, _ := w.(http.Flusher)
+	for i, line := range lines {
+		at := start.Add(from + (until-from)*time.Duration(i)/time.Duration(len(lines)))
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Until(at)):
+		}
+		fmt.Fprintln(w, line)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	select {
+	case <-r.Context().Done():
+	case <-time.After(time.Until(start.Add(until))):
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+type Build struct {
+	CreatedAt  time.Time        `json:"created_at"`
+	ID         types.PrefixUUID `json:"id"`
+	Status     string           `json:"status"`
+	SourceBlob struct {
+		Version string `json:"version"`
+	} `json:"source_blob"`
+	Release *struct {
+		ID types.PrefixUUID `json:"id"`
+	} `json:"release"`
+}
+
+// How long to look for builds after a run succeeds. Auto deploys usually
+// start within a few seconds of the run finishing.
+const deployWaitTime = 30 * time.Second
+
+// recentBuilds returns the most recent builds for app, newest first.
+func recentBuilds(ctx context.Context, client *Client, app string) ([]*Build, error) {
+	req, err := client.NewRequest("GET", "/apps/"+app+"/builds", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Range", "created_at ..; order=desc, max=10")
+	builds := make([]*Build, 0)
+	if err := client.Do(req, &builds); err != nil {
+		return nil, err
+	}
+	return builds, nil
+}
+
+// reportDeploys prints which apps in the pipeline started a build of run's
+// commit after the run was created, which is what happens when a coupled app
+// has automatic deploys enabled. It looks for up to deployWaitTime before
+// giving up.
+func reportDeploys(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun) error {
+	couplings, err := pipelineCouplings(ctx, client, id)
+	if err != nil {
+		return err
+	}
+	if len(couplings) == 0 {
+		return nil
+	}
+	found := make(map[string]bool)
+	deadline := time.Now().Add(deployWaitTime)
+	for {
+		for _, coupling := range couplings {
+			app := coupling.App.Name
+			if found[app] {
+				continue
+			}
+			builds, err := recentBuilds(ctx, client, coupling.App.ID.String())
+			if err != nil {
+				return err
+			}
+			for _, build := range builds {
+				if build.CreatedAt.Before(run.CreatedAt) {
+					continue
+				}
+				n := getMinTipLength(build.SourceBlob.Version, run.CommitSHA)
+				if n == 0 || build.SourceBlob.Version[:n] != run.CommitSHA[:n] {
+					continue
+				}
+				found[app] = true
+				fmt.Printf("Deploy: %s (%s) started build %s of %s, status %s\n", app, coupling.Stage, build.ID.String()[:8], shortSHA(run.CommitSHA), build.Status)
+				break
+			}
+		}
+		if len(found) == len(couplings) || time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+	if len(found) == 0 {
+		fmt.Printf("No apps in the pipeline started a build of %s.\n", shortSHA(run.CommitSHA))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// How many recent runs to search for an equivalent commit.
+const equivalentSearchDepth = 200
+
+// haveCommit reports whether sha is in the local repository.
+func haveCommit(sha string) bool {
+	return exec.Command("git", "cat-file", "-e", sha+"^{commit}").Run() == nil
+}
+
+// treeHash returns the hash of the tree sha points to. Two commits with the
+// same tree have identical contents, however they were made.
+func treeHash(sha string) (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--verify", sha+"^{tree}").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git: could not find the tree for %s: %s", shortSHA(sha), strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// patchID returns the stable patch ID of the change sha introduces, which
+// survives rebasing the commit onto a different parent. Commits that don't
+// change anything, like most merge commits, have an empty patch ID.
+func patchID(sha string) (string, error) {
+	diff, err := exec.Command("git", "show", "--format=", sha).Output()
+	if err != nil {
+		return "", fmt.Errorf("git show %s: %v", shortSHA(sha), err)
+	}
+	cmd := exec.Command("git", "patch-id", "--stable")
+	cmd.Stdin = bytes.NewReader(diff)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git patch-id: %v", err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], nil
+}
+
+// commitKey returns the key used to compare commits: their tree hash if
+// byTree is true, or their patch ID otherwise.
+func commitKey(sha string, byTree bool) (string, error) {
+	if byTree {
+		return treeHash(sha)
+	}
+	return patchID(sha)
+}
+
+// findEquivalentRun returns the most recent successful run for a commit
+// other than sha that makes the same change as sha, or nil if there isn't
+// one. With byTree, commits match if they have identical trees; otherwise
+// they match if they have the same patch ID, as after a clean rebase.
+//
+// Only commits that exist in the local repository can be compared.
+func findEquivalentRun(ctx context.Context, client *Client, id types.PrefixUUID, sha string, byTree bool) (*TestRun, error) {
+	want, err := commitKey(sha, byTree)
+	if err != nil {
+		return nil, err
+	}
+	if want == "" {
+		return nil, nil
+	}
+	runs, err := recentTestRuns(ctx, client, id, equivalentSearchDepth)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, run := range runs {
+		if run.Status != StatusSucceeded || seen[run.CommitSHA] || strings.HasPrefix(run.CommitSHA, sha) || strings.HasPrefix(sha, run.CommitSHA) {
+			continue
+		}
+		seen[run.CommitSHA] = true
+		if !haveCommit(run.CommitSHA) {
+			continue
+		}
+		key, err := commitKey(run.CommitSHA, byTree)
+		if err != nil {
+			return nil, err
+		}
+		if key == want {
+			return run, nil
+		}
+	}
+	return nil, nil
+}
+
+// offerEquivalentRun looks for a successful run of a change identical to
+// sha, and if there is one, offers to use its result instead of waiting. It
+// returns the run to use, or nil to wait as usual.
+func offerEquivalentRun(ctx context.Context, client *Client, id types.PrefixUUID, sha string) *TestRun {
+	run, err := findEquivalentRun(ctx, client, id, sha, false)
+	if err != nil {
+		warnf("could not look for an equivalent run: %v", err)
+		return nil
+	}
+	if run == nil {
+		return nil
+	}
+	fmt.Printf("An identical change already passed CI as run #%d (%s on %s).\n", run.Number, shortSHA(run.CommitSHA), run.CommitBranch)
+	if !stdinIsTerminal() {
+		return nil
+	}
+	if err := confirm("Use that result instead of waiting?", false); err != nil {
+		return nil
+	}
+	return run
+}
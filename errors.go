@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// HerokuError is an error returned by the Heroku Platform API.
+type HerokuError = herokuci.Error
+
+// parseHerokuError parses an error response from the Heroku API.
+func parseHerokuError(resp *http.Response) error {
+	return herokuci.ParseError(resp)
+}
+
+// A ScopeError is returned when the Heroku token is missing the OAuth scope
+// a request needs. Read-only tokens can still wait for and inspect runs, but
+// can't change anything.
+type ScopeError struct {
+	Method string
+	Path   string
+	// Scope is the OAuth scope the request needs, for example "write".
+	Scope string
+	Err   *HerokuError
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("%s %s: your Heroku token does not have the %q scope, which this command needs (%s). Create a token with `heroku authorizations:create --scope %s`",
+		e.Method, e.Path, e.Scope, e.Err.Error(), e.Scope)
+}
+
+func (e *ScopeError) Unwrap() error {
+	return e.Err
+}
+
+// requiredScope returns the OAuth scope a request with the given method and
+// path needs.
+func requiredScope(method, path string) string {
+	protected := strings.HasSuffix(path, "/config-vars")
+	if method == "GET" || method == "HEAD" {
+		if protected {
+			return "read-protected"
+		}
+		return "read"
+	}
+	if protected {
+		return "write-protected"
+	}
+	return "write"
+}
+
+// scopeError converts a 403 from the Heroku API into a ScopeError, so the
+// user knows which scope is missing. Other errors are returned unchanged.
+func scopeError(req *http.Request, err error) error {
+	var herr *HerokuError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusForbidden {
+		return err
+	}
+	return &ScopeError{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Scope:  requiredScope(req.Method, req.URL.Path),
+		Err:    herr,
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A RunEvent is published to every configured event sink when a test run
+// that heroku-ci was waiting on finishes.
+type RunEvent struct {
+	Type       string           `json:"type"`
+	Time       time.Time        `json:"time"`
+	PipelineID types.PrefixUUID `json:"pipeline_id"`
+	RunID      types.PrefixUUID `json:"run_id"`
+	RunNumber  int              `json:"run_number"`
+	Branch     string           `json:"branch"`
+	CommitSHA  string           `json:"commit_sha"`
+	Status     RunStatus        `json:"status"`
+	// Duration is the run's duration in seconds.
+	Duration float64 `json:"duration"`
+	// Owners are the CODEOWNERS of the files implicated in a failing run.
+	Owners []string `json:"owners,omitempty"`
+}
+
+// An eventSink publishes events somewhere other programs can subscribe to
+// them. attrs are a few of the event's fields again, for sinks that let
+// subscribers filter on message attributes.
+type eventSink interface {
+	Publish(ctx context.Context, data []byte, attrs map[string]string) error
+	String() string
+}
+
+// eventSinks is set from Config when heroku-ci starts.
+var eventSinks []eventSink
+
+// How long to wait for each sink to accept an event.
+const eventPublishTimeout = 10 * time.Second
+
+// newEventSinks returns the sinks configured in cfg.
+func newEventSinks(cfg *Config) ([]eventSink, error) {
+	sinks := make([]eventSink, 0)
+	if cfg.EventsSNS != "" {
+		s, err := newSNSSink(cfg.EventsSNS)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.EventsSQS != "" {
+		s, err := newSQSSink(cfg.EventsSQS)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.EventsPubSub != "" {
+		s, err := newPubSubSink(cfg.EventsPubSub)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.MQTTBroker != "" {
+		s, err := newMQTTSink(cfg.MQTTBroker, cfg.MQTTTopic, cfg.MQTTBranch)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// publishRunCompleted publishes a "test_run.completed" event for run to
+// every configured sink. Errors are reported and otherwise ignored, since
+// the run has finished either way.
+func publishRunCompleted(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun) {
+	if len(eventSinks) == 0 {
+		return
+	}
+	data, err := json.Marshal(&RunEvent{
+		Type:       "test_run.completed",
+		Time:       time.Now().UTC(),
+		PipelineID: id,
+		RunID:      run.ID,
+		RunNumber:  run.Number,
+		Branch:     run.CommitBranch,
+		CommitSHA:  run.CommitSHA,
+		Status:     run.Status,
+		Duration:   run.Duration().Seconds(),
+		Owners:     runOwners(ctx, client, id, run),
+	})
+	if err != nil {
+		warnf("could not publish run event: %v", err)
+		return
+	}
+	publishEvent(ctx, data, map[string]string{
+		"type":     "test_run.completed",
+		"pipeline": id.String(),
+		"branch":   run.CommitBranch,
+		"status":   string(run.Status),
+	})
+}
+
+// publishEvent sends an encoded event to every configured sink, reporting
+// errors.
+func publishEvent(ctx context.Context, data []byte, attrs map[string]string) {
+	for _, sink := range eventSinks {
+		ctx, cancel := context.WithTimeout(ctx, eventPublishTimeout)
+		err := sink.Publish(ctx, data, attrs)
+		cancel()
+		if err != nil {
+			warnf("could not publish run event to %s: %v", sink, err)
+		}
+	}
+}
+
+// sortedKeys returns the keys of m in order, so that attributes are sent in
+// a stable order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
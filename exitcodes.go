@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// stopSignal holds the signal that cancelled the command, once one has.
+var stopSignal atomic.Value
+
+// stoppedBy returns the signal that cancelled the command, or nil.
+func stoppedBy() os.Signal {
+	sig, _ := stopSignal.Load().(os.Signal)
+	return sig
+}
+
+// exitCodes documents each exit code, for the exit-codes command and the
+// README.
+var exitCodes = []struct {
+	Code    int    `json:"code"`
+	Name    string `json:"name"`
+	Meaning string `json:"meaning"`
+}{
+	{herokuci.ExitSucceeded, "success", "The command succeeded, and so did any run it checked or waited for."},
+	{herokuci.ExitRunFailed, "test-failure", "A test run, or a check on one, finished without succeeding."},
+	{herokuci.ExitUsage, "usage", "The flags, arguments, or config were invalid."},
+	{herokuci.ExitError, "error", "Heroku, git, or GitHub returned an error, or the network failed."},
+	{herokuci.ExitTimeout, "timeout", "heroku-ci gave up waiting."},
+	{herokuci.ExitAuth, "auth", "The credentials were missing, rejected, or don't allow the command."},
+	{herokuci.ExitNotFound, "not-found", "The pipeline, run, or app doesn't exist or isn't visible."},
+	{herokuci.ExitQuarantined, "quarantined", "A test run failed, but only tests on the quarantine list failed."},
+	{herokuci.ExitRunErrored, "run-errored", "A test run errored before it could report a result, as when setup fails."},
+	{herokuci.ExitRunCancelled, "run-cancelled", "A test run was cancelled on Heroku before it finished."},
+	{herokuci.ExitInterrupted, "interrupted", "heroku-ci got SIGINT (Ctrl-C) and stopped; runs carry on on Heroku."},
+	{herokuci.ExitTerminated, "terminated", "heroku-ci got SIGTERM and stopped; runs carry on on Heroku."},
+}
+
+// A runFailedError is returned when a test run finished without
+// succeeding.
+type runFailedError struct {
+	run *TestRun
+}
+
+func (e *runFailedError) Error() string {
+	return fmt.Sprintf("test run #%d %s", e.run.Number, e.run.Status)
+}
+
+// A checkFailedError is returned when a check on a run, like assert-green
+// or a GitHub check, didn't pass.
+type checkFailedError struct {
+	msg string
+}
+
+func (e *checkFailedError) Error() string {
+	return e.msg
+}
+
+// checkFailedf returns a *checkFailedError.
+func checkFailedf(format string, args ...interface{}) error {
+	return &checkFailedError{msg: fmt.Sprintf(format, args...)}
+}
+
+// A notFoundError is returned when a run, review app, or other thing a
+// command looked for doesn't exist.
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string {
+	return e.msg
+}
+
+// notFoundf returns a *notFoundError.
+func notFoundf(format string, args ...interface{}) error {
+	return &notFoundError{msg: fmt.Sprintf(format, args...)}
+}
+
+// A usageError is returned for invalid flags, arguments, or config.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// usagef returns a *usageError.
+func usagef(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// exitCodeFor returns the exit code for a command that failed with err, or
+// herokuci.ExitSucceeded if err is nil.
+func exitCodeFor(err error) int {
+	var (
+		runFailed   *runFailedError
+		quarantined *quarantinedError
+		checkFailed *checkFailedError
+		usage       *usageError
+		scope       *ScopeError
+		perm        *PermissionError
+		creds       *credentialsError
+		notFound    *notFoundError
+		noPipeline  *pipelineNotFoundError
+		herr        *HerokuError
+	)
+	switch {
+	case err == nil:
+		return herokuci.ExitSucceeded
+	case errors.As(err, &quarantined):
+		return herokuci.ExitQuarantined
+	case errors.As(err, &runFailed) && runFailed.run.Status == StatusErrored:
+		return herokuci.ExitRunErrored
+	case errors.As(err, &runFailed) && runFailed.run.Status == StatusCancelled:
+		return herokuci.ExitRunCancelled
+	case errors.As(err, &runFailed), errors.As(err, &checkFailed):
+		return herokuci.ExitRunFailed
+	case errors.As(err, &usage):
+		return herokuci.ExitUsage
+	case errors.Is(err, context.Canceled):
+		return herokuci.SignalExitCode(stoppedBy())
+	case errors.Is(err, context.DeadlineExceeded):
+		return herokuci.ExitTimeout
+	case errors.As(err, &scope), errors.As(err, &perm), errors.As(err, &creds):
+		return herokuci.ExitAuth
+	case errors.As(err, &notFound), errors.As(err, &noPipeline):
+		return herokuci.ExitNotFound
+	case errors.As(err, &herr):
+		switch herr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return herokuci.ExitAuth
+		case http.StatusNotFound:
+			return herokuci.ExitNotFound
+		}
+	}
+	return herokuci.ExitError
+}
+
+// fatal prints err and exits with its code.
+func fatal(err error) {
+	log.Print(err)
+	exit(exitCodeFor(err))
+}
+
+// exit flushes output held back for redaction and exits with code. Use it
+// instead of os.Exit.
+func exit(code int) {
+	flushRedactWriters()
+	os.Exit(code)
+}
+
+// printExitCodes prints the exit code table, or with asJSON, the table as a
+// JSON array.
+func printExitCodes(w io.Writer, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(exitCodes)
+	}
+	t := newTable("CODE", "NAME", "MEANING")
+	t.flex = []int{2}
+	for _, c := range exitCodes {
+		t.addf(c.Code, c.Name, c.Meaning)
+	}
+	return t.print(w)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, herokuci.ExitSucceeded},
+		{&runFailedError{&TestRun{Status: StatusFailed}}, herokuci.ExitRunFailed},
+		{&runFailedError{&TestRun{Status: StatusErrored}}, herokuci.ExitRunErrored},
+		{fmt.Errorf("waiting: %w", &runFailedError{&TestRun{Status: StatusCancelled}}), herokuci.ExitRunCancelled},
+		{usagef("bad flag"), herokuci.ExitUsage},
+		{context.DeadlineExceeded, herokuci.ExitTimeout},
+		{notFoundf("no such run"), herokuci.ExitNotFound},
+		{&HerokuError{StatusCode: 401}, herokuci.ExitAuth},
+	}
+	for _, tt := range tests {
+		if got := exitCodeFor(tt.err); got != tt.want {
+			t.Errorf("exitCodeFor(%v): got %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestExitCodeForSignal(t *testing.T) {
+	t.Cleanup(func() { stopSignal = atomic.Value{} })
+	stopSignal.Store(os.Interrupt)
+	if got := exitCodeFor(context.Canceled); got != herokuci.ExitInterrupted {
+		t.Errorf("SIGINT: got %d, want %d", got, herokuci.ExitInterrupted)
+	}
+	stopSignal.Store(syscall.SIGTERM)
+	if got := exitCodeFor(context.Canceled); got != herokuci.ExitTerminated {
+		t.Errorf("SIGTERM: got %d, want %d", got, herokuci.ExitTerminated)
+	}
+}
+
+func TestExitCodesDocumented(t *testing.T) {
+	seen := make(map[int]bool)
+	for _, c := range exitCodes {
+		if seen[c.Code] {
+			t.Errorf("exit code %d is listed twice", c.Code)
+		}
+		seen[c.Code] = true
+	}
+	for _, code := range []int{herokuci.ExitRunCancelled, herokuci.ExitInterrupted, herokuci.ExitTerminated} {
+		if !seen[code] {
+			t.Errorf("exit code %d isn't in the exit-codes table", code)
+		}
+	}
+}
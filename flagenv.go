@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// flagEnvPrefix starts the name of the environment variable for every flag:
+// --pipeline-id can also be set with HEROKU_CI_PIPELINE_ID.
+const flagEnvPrefix = "HEROKU_CI_"
+
+// flagEnvUnsafe are flags that skip a confirmation or destroy something.
+// They are never read from the environment, so they only take effect when
+// someone types them.
+var flagEnvUnsafe = map[string]bool{
+	"delete": true,
+	"yes":    true,
+}
+
+// flagEnvName returns the environment variable that sets the named flag in
+// fs. Global flags are HEROKU_CI_<FLAG>; a subcommand's own flags also have
+// the command's name, like HEROKU_CI_WAIT_RESULT_FILE, so that --format for
+// one command doesn't set --format for every other.
+func flagEnvName(fs *flag.FlagSet, name string) string {
+	env := flagEnvPrefix
+	if fs != flag.CommandLine {
+		env += fs.Name() + "_"
+	}
+	return strings.ToUpper(strings.ReplaceAll(env+name, "-", "_"))
+}
+
+// flagEnvIgnored reports whether the variable is one heroku-ci sets for
+// other reasons, and not a flag setting. Sourcing a --export-env file must
+// not turn into, say, a --status filter on the next command, and
+// HEROKU_CI_VERSION is a common name for something else entirely.
+func flagEnvIgnored(env string) bool {
+	if env == flagEnvPrefix+"VERSION" {
+		return true
+	}
+	for _, kv := range envExports(&RunResult{}) {
+		if env == kv[0] && env != flagEnvPrefix+"PIPELINE_ID" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFlags sets each flag in fs from its environment variable, if that is
+// set, and then parses args, so the command line wins over the environment.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if flagEnvUnsafe[f.Name] {
+			return
+		}
+		env := flagEnvName(fs, f.Name)
+		if flagEnvIgnored(env) {
+			return
+		}
+		val, ok := os.LookupEnv(env)
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, val); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid value %q for $%s: %v\n", val, env, err)
+			exit(herokuci.ExitUsage)
+		}
+	})
+	fs.Parse(args)
+}
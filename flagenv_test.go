@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestFlagEnvName(t *testing.T) {
+	tests := []struct {
+		fs   *flag.FlagSet
+		name string
+		want string
+	}{
+		{flag.CommandLine, "pipeline-id", "HEROKU_CI_PIPELINE_ID"},
+		{flag.CommandLine, "no-warnings", "HEROKU_CI_NO_WARNINGS"},
+		{flag.NewFlagSet("wait", flag.ContinueOnError), "result-file", "HEROKU_CI_WAIT_RESULT_FILE"},
+		{flag.NewFlagSet("annotate-release", flag.ContinueOnError), "app", "HEROKU_CI_ANNOTATE_RELEASE_APP"},
+	}
+	for _, tt := range tests {
+		if got := flagEnvName(tt.fs, tt.name); got != tt.want {
+			t.Errorf("flagEnvName(%s, %q): got %s, want %s", tt.fs.Name(), tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseFlagsFromEnv(t *testing.T) {
+	t.Setenv("HEROKU_CI_PROMPT_FORMAT", "[%s]")
+	t.Setenv("HEROKU_CI_PROMPT_LIMIT", "5")
+	t.Setenv("HEROKU_CI_PROMPT_YES", "true")
+	t.Setenv("HEROKU_CI_PROMPT_DELETE", "true")
+	fs := flag.NewFlagSet("prompt", flag.ContinueOnError)
+	format := fs.String("format", "", "")
+	limit := fs.Int("limit", 0, "")
+	yes := fs.Bool("yes", false, "")
+	del := fs.Bool("delete", false, "")
+	parseFlags(fs, nil)
+	if *format != "[%s]" {
+		t.Errorf("got prompt --format=%q, want [%%s] from HEROKU_CI_PROMPT_FORMAT", *format)
+	}
+	if *limit != 5 {
+		t.Errorf("got --limit=%d, want 5 from HEROKU_CI_PROMPT_LIMIT", *limit)
+	}
+	if *yes {
+		t.Errorf("--yes was read from HEROKU_CI_PROMPT_YES")
+	}
+	if *del {
+		t.Errorf("--delete was read from HEROKU_CI_PROMPT_DELETE")
+	}
+
+	parseFlags(fs, []string{"--limit=2"})
+	if *limit != 2 {
+		t.Errorf("got --limit=%d, want the command line to win", *limit)
+	}
+}
+
+func TestParseFlagsGlobalEnvIsNotShared(t *testing.T) {
+	// HEROKU_CI_FORMAT is the global flag's, not every command's --format.
+	t.Setenv("HEROKU_CI_FORMAT", "json")
+	fs := flag.NewFlagSet("prompt", flag.ContinueOnError)
+	format := fs.String("format", "", "")
+	parseFlags(fs, nil)
+	if *format != "" {
+		t.Errorf("HEROKU_CI_FORMAT set prompt --format to %q", *format)
+	}
+}
+
+func TestParseFlagsIgnoresExports(t *testing.T) {
+	t.Setenv("HEROKU_CI_RUN_NUMBER", "104")
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	number := fs.String("number", "", "")
+	parseFlags(fs, nil)
+	if *number != "" {
+		t.Errorf("sourced HEROKU_CI_RUN_NUMBER set run --number to %q", *number)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A branchWatch notices when the branch being waited on moves on its
+// upstream, because someone pushed while the run was in progress.
+type branchWatch struct {
+	// follow switches the wait to the newest run on the branch, instead of
+	// just reporting it.
+	follow bool
+	// remote and ref are the local branch's upstream, the remote (a name or,
+	// for a fork's pull request, a URL) and the ref on it that @{u} resolves
+	// to. If ref is empty the run's branch on origin is checked.
+	remote, ref string
+	// lastTip is the remote tip we last reported, so each push is reported
+	// once.
+	lastTip string
+	// failed is set once we fail to read the remote, so we only warn once.
+	failed bool
+}
+
+// newBranchWatch returns a branchWatch for the local branch, checking the
+// remote and ref it tracks. Heroku's name for the branch can differ from
+// both, for a renamed branch or a fork's pull request.
+func newBranchWatch(branch string, follow bool) *branchWatch {
+	b := &branchWatch{follow: follow, remote: "origin"}
+	if remote := gitConfig("branch." + branch + ".remote"); remote != "" {
+		if ref := gitConfig("branch." + branch + ".merge"); ref != "" {
+			b.remote, b.ref = remote, ref
+		}
+	}
+	return b
+}
+
+// upstream returns the remote and ref to check for run.
+func (b *branchWatch) upstream(run *TestRun) (remote, ref string) {
+	if b.ref == "" {
+		return b.remote, "refs/heads/" + run.CommitBranch
+	}
+	return b.remote, b.ref
+}
+
+// remoteTip returns the commit that ref points to on remote.
+func remoteTip(ctx context.Context, remote, ref string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "ls-remote", remote, ref).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote: %s", strings.TrimSpace(string(out)))
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%s does not exist on %s", ref, redact.String(remote))
+	}
+	return fields[0], nil
+}
+
+// commitsAhead returns how many commits tip has that base does not, or -1 if
+// we don't have tip locally and can't tell.
+func commitsAhead(base, tip string) int {
+	out, err := exec.Command("git", "rev-list", "--count", base+".."+tip).Output()
+	if err != nil {
+		return -1
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// latestBranchRun returns the newest run in runs (which are newest first) on
+// run's branch that started after run, or nil if there isn't one.
+func latestBranchRun(runs []*TestRun, run *TestRun) *TestRun {
+	for _, r := range runs {
+		if r.Number > run.Number && r.CommitBranch == run.CommitBranch {
+			return r
+		}
+	}
+	return nil
+}
+
+// check reports if the branch has moved past run's commit upstream. It
+// returns the run to switch to if the watch follows the branch and a newer
+// run has started, or nil to keep waiting on run.
+func (b *branchWatch) check(ctx context.Context, runs []*TestRun, run *TestRun, prefix string) *TestRun {
+	latest := latestBranchRun(runs, run)
+	if b.follow && latest != nil && latest.CommitSHA != run.CommitSHA {
+		fmt.Printf("%s%s has moved on to %s; following run #%d instead of run #%d\n", prefix, run.CommitBranch, shortSHA(latest.CommitSHA), latest.Number, run.Number)
+		b.lastTip = latest.CommitSHA
+		return latest
+	}
+	remote, ref := b.upstream(run)
+	where := redact.String(remote)
+	tip, err := remoteTip(ctx, remote, ref)
+	if err != nil {
+		if !b.failed && ctx.Err() == nil {
+			warnf("could not check whether %s moved on %s: %v", run.CommitBranch, where, err)
+			b.failed = true
+		}
+		return nil
+	}
+	if tip == run.CommitSHA || tip == b.lastTip {
+		return nil
+	}
+	b.lastTip = tip
+	msg := fmt.Sprintf("%s has moved to %s on %s", run.CommitBranch, shortSHA(tip), where)
+	if n := commitsAhead(run.CommitSHA, tip); n > 0 {
+		msg = fmt.Sprintf("%s has %d newer commits on %s", run.CommitBranch, n, where)
+	}
+	if latest != nil {
+		msg += fmt.Sprintf("; the newest run is #%d (%s)", latest.Number, latest.Status)
+	} else {
+		msg += "; no run has started for it yet"
+	}
+	if !b.follow {
+		msg += ". Pass --follow-branch to follow the newest run"
+	}
+	warnf("%s%s", prefix, msg)
+	return nil
+}
+
+// How often to check for a new run while waiting for the next push.
+const nextRunPollInterval = 10 * time.Second
+
+// waitForNextRun waits until a run newer than run starts on run's branch.
+func waitForNextRun(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun) (*TestRun, error) {
+	fmt.Printf("Waiting for the next push to %s...\n", run.CommitBranch)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(nextRunPollInterval):
+		}
+		runs, err := recentTestRuns(ctx, client, id, 20)
+		if err != nil {
+			return nil, err
+		}
+		// Take the oldest new run, so a burst of pushes is reported in order.
+		var next *TestRun
+		for _, r := range runs {
+			if r.Number > run.Number && r.CommitBranch == run.CommitBranch {
+				next = r
+			}
+		}
+		if next != nil {
+			return next, nil
+		}
+	}
+}
+
+// followBranch waits for run, reports the result, waits for the next run on
+// the branch, and so on until ctx is cancelled. A failed run or follow-up
+// step is reported, not returned, so the watch keeps going.
+func followBranch(ctx context.Context, client *Client, id types.PrefixUUID, branch string, run *TestRun, opts waitOptions) error {
+	bw := newBranchWatch(branch, true)
+	for {
+		finished, warnings, err := waitForTestRun(ctx, client, id, run, "", bw)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := finishWait(ctx, client, id, finished, warnings, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		fmt.Println()
+		run, err = waitForNextRun(ctx, client, id, finished)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func runGit(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestBranchWatchUsesUpstream(t *testing.T) {
+	// A fork's repository, with the pull request's branch.
+	fork := t.TempDir()
+	t.Chdir(fork)
+	runGit(t, "init", "-q", "-b", "fix-typo")
+	runGit(t, "-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "fix")
+	tip := runGit(t, "rev-parse", "HEAD")
+
+	// Our checkout, where the pull request is checked out as pr-12.
+	t.Chdir(t.TempDir())
+	runGit(t, "init", "-q")
+	runGit(t, "config", "branch.pr-12.remote", fork)
+	runGit(t, "config", "branch.pr-12.merge", "refs/heads/fix-typo")
+
+	b := newBranchWatch("pr-12", false)
+	remote, ref := b.upstream(&TestRun{CommitBranch: "heroku-name"})
+	if remote != fork || ref != "refs/heads/fix-typo" {
+		t.Fatalf("got upstream %s %s, want %s refs/heads/fix-typo", remote, ref, fork)
+	}
+	got, err := remoteTip(t.Context(), remote, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != tip {
+		t.Errorf("got tip %s, want %s", got, tip)
+	}
+
+	// Without an upstream, the run's branch on origin is checked.
+	remote, ref = newBranchWatch("no-upstream", false).upstream(&TestRun{CommitBranch: "main"})
+	if remote != "origin" || ref != "refs/heads/main" {
+		t.Errorf("got upstream %s %s, want origin refs/heads/main", remote, ref)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bgentry/go-netrc/netrc"
+	git "github.com/kevinburke/go-git"
+	"github.com/kevinburke/rest"
+)
+
+// GitHubClient talks to the GitHub API.
+type GitHubClient struct {
+	*rest.Client
+}
+
+func (c *GitHubClient) NewRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := c.Client.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	return req, nil
+}
+
+// Do performs the request, refusing writes in read-only mode.
+func (c *GitHubClient) Do(r *http.Request, v interface{}) error {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		if err := checkWritable(r.Method + " " + r.URL.Path); err != nil {
+			return err
+		}
+	}
+	return c.Client.Do(r, v)
+}
+
+// githubToken finds a GitHub API token in $GITHUB_TOKEN, $GH_TOKEN, the gh
+// CLI, or the api.github.com entry in ~/.netrc, in that order.
+func githubToken() (string, error) {
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if tok := os.Getenv(name); tok != "" {
+			return tok, nil
+		}
+	}
+	if out, err := exec.Command("gh", "auth", "token").Output(); err == nil {
+		if tok := strings.TrimSpace(string(out)); tok != "" {
+			return tok, nil
+		}
+	}
+	if homedir, err := os.UserHomeDir(); err == nil {
+		machine, err := netrc.FindMachine(filepath.Join(homedir, ".netrc"), "api.github.com")
+		if err == nil && machine != nil && machine.Password != "" {
+			return machine.Password, nil
+		}
+	}
+	return "", errors.New("could not find a GitHub token; set GITHUB_TOKEN, log in with `gh auth login`, or add api.github.com to ~/.netrc")
+}
+
+func newGitHubClient() (*GitHubClient, error) {
+	token, err := githubToken()
+	if err != nil {
+		return nil, err
+	}
+	redact.addSecret(token)
+	return &GitHubClient{rest.NewClient("heroku-ci", token, "https://api.github.com")}, nil
+}
+
+// githubRepo returns the owner and name of the GitHub repository for the
+// origin remote.
+func githubRepo() (string, string, error) {
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return "", "", err
+	}
+	if remote.Host != "github.com" {
+		return "", "", fmt.Errorf("origin is hosted on %s, not GitHub", remote.Host)
+	}
+	return remote.Path, remote.RepoName, nil
+}
+
+type combinedStatus struct {
+	State    string `json:"state"`
+	Statuses []struct {
+		Context string `json:"context"`
+		State   string `json:"state"`
+	} `json:"statuses"`
+}
+
+type checkRuns struct {
+	CheckRuns []struct {
+		Name       string `json:"name"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+	} `json:"check_runs"`
+}
+
+// A commitCheck is the state of one status or check run on a commit.
+type commitCheck struct {
+	Name string
+	// State is "pending", "success", or "failure".
+	State string
+}
+
+// commitChecks returns every commit status and check run on sha.
+func commitChecks(ctx context.Context, gh *GitHubClient, owner, repo, sha string) ([]commitCheck, error) {
+	prefix := "/repos/" + owner + "/" + repo + "/commits/" + sha
+	req, err := gh.NewRequest("GET", prefix+"/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	status := new(combinedStatus)
+	if err := gh.Do(req, status); err != nil {
+		return nil, err
+	}
+	req, err = gh.NewRequest("GET", prefix+"/check-runs?per_page=100", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	runs := new(checkRuns)
+	if err := gh.Do(req, runs); err != nil {
+		return nil, err
+	}
+	checks := make([]commitCheck, 0, len(status.Statuses)+len(runs.CheckRuns))
+	for _, s := range status.Statuses {
+		state := s.State
+		if state == "error" {
+			state = "failure"
+		}
+		checks = append(checks, commitCheck{Name: s.Context, State: state})
+	}
+	for _, r := range runs.CheckRuns {
+		state := "pending"
+		if r.Status == "completed" {
+			switch r.Conclusion {
+			case "success", "neutral", "skipped":
+				state = "success"
+			default:
+				state = "failure"
+			}
+		}
+		checks = append(checks, commitCheck{Name: r.Name, State: state})
+	}
+	return checks, nil
+}
+
+// waitForGitHubChecks polls the statuses and check runs on sha until none of
+// them are pending, and returns an error if any of them did not succeed.
+func waitForGitHubChecks(ctx context.Context, gh *GitHubClient, owner, repo, sha string) error {
+	count := 0
+	for {
+		checks, err := commitChecks(ctx, gh, owner, repo, sha)
+		if err != nil {
+			return err
+		}
+		pending := make([]string, 0)
+		failed := make([]string, 0)
+		for _, c := range checks {
+			switch c.State {
+			case "pending":
+				pending = append(pending, c.Name)
+			case "failure":
+				failed = append(failed, c.Name)
+			}
+		}
+		if len(pending) == 0 {
+			if len(failed) > 0 {
+				return checkFailedf("GitHub checks failed on %s: %s", shortSHA(sha), strings.Join(failed, ", "))
+			}
+			fmt.Printf("All %d GitHub checks on %s passed.\n", len(checks), shortSHA(sha))
+			return nil
+		}
+		if count%3 == 0 {
+			fmt.Printf("waiting for %d GitHub checks: %s\n", len(pending), strings.Join(pending, ", "))
+		}
+		count++
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
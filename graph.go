@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A trendPoint is one period of a trend.
+type trendPoint struct {
+	Start time.Time
+	// Median is the median duration of the runs that passed or failed, or
+	// zero if there were none.
+	Median time.Duration
+	// PassRate is the fraction of runs that passed, or -1 if none passed
+	// or failed.
+	PassRate float64
+}
+
+type trend struct {
+	Period time.Duration
+	Points []trendPoint
+}
+
+// newTrend returns a point for every period between from and to, including
+// periods without any runs.
+func newTrend(s *runStats, from, to time.Time) *trend {
+	t := &trend{Period: s.Period}
+	for start := from.Truncate(s.Period); !start.After(to); start = start.Add(s.Period) {
+		p := trendPoint{Start: start, PassRate: -1}
+		if g, ok := s.ByPeriod[start.Unix()]; ok {
+			p.Median = g.percentile(0.5)
+			if finished := g.Passed + g.Failed; finished > 0 {
+				p.PassRate = float64(g.Passed) / float64(finished)
+			}
+		}
+		t.Points = append(t.Points, p)
+	}
+	return t
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws vals, scaled between their minimum and maximum, with a
+// space for each value where ok is false.
+func sparkline(vals []float64, ok func(int) bool) string {
+	lo, hi := seriesRange(vals, ok)
+	var b strings.Builder
+	for i, v := range vals {
+		if !ok(i) {
+			b.WriteRune(' ')
+			continue
+		}
+		level := len(sparks) - 1
+		if hi > lo {
+			level = int((v - lo) / (hi - lo) * float64(len(sparks)-1))
+		}
+		b.WriteRune(sparks[level])
+	}
+	return b.String()
+}
+
+// series returns the trend's durations in minutes and pass rates in
+// percent.
+func (t *trend) series() (durations, rates []float64) {
+	for _, p := range t.Points {
+		durations = append(durations, p.Median.Minutes())
+		rates = append(rates, 100*p.PassRate)
+	}
+	return durations, rates
+}
+
+func (t *trend) hasDuration(i int) bool { return t.Points[i].Median > 0 }
+func (t *trend) hasRate(i int) bool     { return t.Points[i].PassRate >= 0 }
+
+// printSparklines prints the trend's median duration and pass rate, one
+// character per period.
+func printSparklines(w io.Writer, t *trend) {
+	if len(t.Points) == 0 {
+		return
+	}
+	durations, rates := t.series()
+	unit := "day"
+	if t.Period > 24*time.Hour {
+		unit = "week"
+	}
+	fmt.Fprintf(w, "Per %s, %s to %s:\n", unit, fmtShortDate(t.Points[0].Start), fmtShortDate(t.Points[len(t.Points)-1].Start))
+	lo, hi := seriesRange(durations, t.hasDuration)
+	fmt.Fprintf(w, "  median duration  %s  %.0f-%.0f min\n", sparkline(durations, t.hasDuration), lo, hi)
+	lo, hi = seriesRange(rates, t.hasRate)
+	fmt.Fprintf(w, "  pass rate        %s  %.0f-%.0f%%\n", sparkline(rates, t.hasRate), lo, hi)
+}
+
+func seriesRange(vals []float64, ok func(int) bool) (lo, hi float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for i, v := range vals {
+		if ok(i) {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	if math.IsInf(lo, 1) {
+		return 0, 0
+	}
+	return lo, hi
+}
+
+// writeGraph draws the trend in path, as an SVG or a PNG depending on its
+// extension.
+func writeGraph(path string, t *trend) error {
+	if len(t.Points) == 0 {
+		return fmt.Errorf("no runs to graph")
+	}
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svg":
+		data = graphSVG(t)
+	case ".png":
+		data, err = graphPNG(t)
+	default:
+		return fmt.Errorf("--graph-out %s: want a .svg or .png file", path)
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// The size of each of the two charts, and the margin around them.
+const (
+	chartWidth  = 720
+	chartHeight = 200
+	chartMargin = 48
+)
+
+// chartPoints maps the values of a series to coordinates in a chart whose
+// top left corner is at (x0, y0). Values where ok is false are left out,
+// which breaks the line. The y axis starts at zero.
+func chartPoints(vals []float64, ok func(int) bool, x0, y0 float64) (lines [][][2]float64, lo, hi float64) {
+	_, hi = seriesRange(vals, ok)
+	if hi == lo {
+		hi = lo + 1
+	}
+	step := float64(chartWidth)
+	if len(vals) > 1 {
+		step = float64(chartWidth) / float64(len(vals)-1)
+	}
+	var line [][2]float64
+	for i, v := range vals {
+		if !ok(i) {
+			if len(line) > 0 {
+				lines = append(lines, line)
+				line = nil
+			}
+			continue
+		}
+		x := x0 + float64(i)*step
+		y := y0 + chartHeight - (v-lo)/(hi-lo)*chartHeight
+		line = append(line, [2]float64{x, y})
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines, lo, hi
+}
+
+var (
+	durationColor = color.RGBA{0x43, 0x6e, 0xb8, 0xff}
+	rateColor     = color.RGBA{0x3c, 0x9a, 0x5f, 0xff}
+	axisColor     = color.RGBA{0x99, 0x99, 0x99, 0xff}
+)
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// graphSVG draws the median duration above the pass rate.
+func graphSVG(t *trend) []byte {
+	durations, rates := t.series()
+	width := chartWidth + 2*chartMargin
+	height := 2*chartHeight + 3*chartMargin
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	charts := []struct {
+		title string
+		vals  []float64
+		ok    func(int) bool
+		unit  string
+		color color.RGBA
+	}{
+		{"Median duration", durations, t.hasDuration, " min", durationColor},
+		{"Pass rate", rates, t.hasRate, "%", rateColor},
+	}
+	for n, c := range charts {
+		x0 := float64(chartMargin)
+		y0 := float64(chartMargin + n*(chartHeight+chartMargin))
+		lines, lo, hi := chartPoints(c.vals, c.ok, x0, y0)
+		fmt.Fprintf(&buf, `<text x="%.0f" y="%.0f" font-weight="bold">%s</text>`+"\n", x0, y0-12, c.title)
+		fmt.Fprintf(&buf, `<rect x="%.0f" y="%.0f" width="%d" height="%d" fill="none" stroke="%s"/>`+"\n", x0, y0, chartWidth, chartHeight, hexColor(axisColor))
+		fmt.Fprintf(&buf, `<text x="%.0f" y="%.0f" text-anchor="end">%.0f%s</text>`+"\n", x0-4, y0+4, hi, c.unit)
+		fmt.Fprintf(&buf, `<text x="%.0f" y="%.0f" text-anchor="end">%.0f%s</text>`+"\n", x0-4, y0+chartHeight+4, lo, c.unit)
+		for _, line := range lines {
+			pts := make([]string, len(line))
+			for i, p := range line {
+				pts[i] = fmt.Sprintf("%.1f,%.1f", p[0], p[1])
+			}
+			fmt.Fprintf(&buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(pts, " "), hexColor(c.color))
+			if len(line) == 1 {
+				fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="2" fill="%s"/>`+"\n", line[0][0], line[0][1], hexColor(c.color))
+			}
+		}
+	}
+	bottom := float64(2*chartHeight + 2*chartMargin + 16)
+	fmt.Fprintf(&buf, `<text x="%d" y="%.0f">%s</text>`+"\n", chartMargin, bottom, fmtShortDate(t.Points[0].Start))
+	fmt.Fprintf(&buf, `<text x="%d" y="%.0f" text-anchor="end">%s</text>`+"\n", chartMargin+chartWidth, bottom, fmtShortDate(t.Points[len(t.Points)-1].Start))
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// graphPNG draws the same charts as graphSVG, without the labels, since the
+// standard library can't draw text.
+func graphPNG(t *trend) ([]byte, error) {
+	durations, rates := t.series()
+	width := chartWidth + 2*chartMargin
+	height := 2*chartHeight + 3*chartMargin
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for n, c := range []struct {
+		vals  []float64
+		ok    func(int) bool
+		color color.RGBA
+	}{{durations, t.hasDuration, durationColor}, {rates, t.hasRate, rateColor}} {
+		x0 := float64(chartMargin)
+		y0 := float64(chartMargin + n*(chartHeight+chartMargin))
+		box := [][2]float64{{x0, y0}, {x0 + chartWidth, y0}, {x0 + chartWidth, y0 + chartHeight}, {x0, y0 + chartHeight}, {x0, y0}}
+		drawLine(img, box, axisColor)
+		lines, _, _ := chartPoints(c.vals, c.ok, x0, y0)
+		for _, line := range lines {
+			drawLine(img, line, c.color)
+			if len(line) == 1 {
+				drawLine(img, [][2]float64{line[0], {line[0][0] + 1, line[0][1]}}, c.color)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLine draws a two pixel wide line through pts.
+func drawLine(img *image.RGBA, pts [][2]float64, c color.RGBA) {
+	for i := 1; i < len(pts); i++ {
+		x0, y0, x1, y1 := pts[i-1][0], pts[i-1][1], pts[i][0], pts[i][1]
+		steps := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0)))
+		if steps == 0 {
+			steps = 1
+		}
+		for s := 0; s <= steps; s++ {
+			x := int(math.Round(x0 + (x1-x0)*float64(s)/float64(steps)))
+			y := int(math.Round(y0 + (y1-y0)*float64(s)/float64(steps)))
+			img.SetRGBA(x, y, c)
+			img.SetRGBA(x, y+1, c)
+		}
+	}
+}
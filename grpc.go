@@ -0,0 +1,263 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/heroku_ci.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"time"
+
+	herokucipb "github.com/kevinburke/heroku-ci/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The gRPC methods that change state, and so need a write token.
+var grpcWriteMethods = map[string]bool{
+	"/herokuci.v1.HerokuCI/Ack":    true,
+	"/herokuci.v1.HerokuCI/Snooze": true,
+}
+
+// grpcServer adapts apiServer to the HerokuCI service.
+type grpcServer struct {
+	herokucipb.UnimplementedHerokuCIServer
+	s *apiServer
+}
+
+// grpcError converts an error from apiServer to a gRPC status.
+func grpcError(err error) error {
+	var bad *badRequestError
+	var herr *HerokuError
+	var aerr *authError
+	switch {
+	case errors.As(err, &bad):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &aerr):
+		if aerr.Forbidden {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.As(err, &herr):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// authorize checks the token in the request metadata for method.
+func (g *grpcServer) authorize(ctx context.Context, method string) error {
+	var auth string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get("authorization"); len(vals) > 0 {
+			auth = vals[0]
+		}
+	}
+	tok, err := g.s.checkToken(auth, grpcWriteMethods[method])
+	if err != nil {
+		return grpcError(err)
+	}
+	if grpcWriteMethods[method] {
+		log.Printf("serve: %s by token %q", method, tok.Name)
+	}
+	return nil
+}
+
+func (g *grpcServer) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := g.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (g *grpcServer) streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := g.authorize(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (g *grpcServer) GetStatus(ctx context.Context, req *herokucipb.GetStatusRequest) (*herokucipb.GetStatusResponse, error) {
+	sf, err := g.s.status()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := new(herokucipb.GetStatusResponse)
+	for repo, branches := range sf {
+		for branch, bs := range branches {
+			resp.Branches = append(resp.Branches, &herokucipb.BranchStatus{
+				Repository: repo,
+				Branch:     branch,
+				RunId:      bs.RunID,
+				CommitSha:  bs.CommitSHA,
+				Status:     string(bs.Status),
+				UpdatedAt:  timestamppb.New(bs.UpdatedAt),
+			})
+		}
+	}
+	sort.Slice(resp.Branches, func(i, j int) bool {
+		a, b := resp.Branches[i], resp.Branches[j]
+		if a.Repository != b.Repository {
+			return a.Repository < b.Repository
+		}
+		return a.Branch < b.Branch
+	})
+	return resp, nil
+}
+
+func (g *grpcServer) ListRuns(ctx context.Context, req *herokucipb.ListRunsRequest) (*herokucipb.ListRunsResponse, error) {
+	runs, err := g.s.runs(req.PipelineId, req.Branch)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &herokucipb.ListRunsResponse{Runs: make([]*herokucipb.TestRun, len(runs))}
+	for i, run := range runs {
+		resp.Runs[i] = &herokucipb.TestRun{
+			Id:            run.ID.String(),
+			Number:        int64(run.Number),
+			CommitBranch:  run.CommitBranch,
+			CommitSha:     run.CommitSHA,
+			CommitMessage: run.CommitMessage,
+			ActorEmail:    run.ActorEmail,
+			Status:        string(run.Status),
+			CreatedAt:     timestamppb.New(run.CreatedAt),
+			UpdatedAt:     timestamppb.New(run.UpdatedAt),
+		}
+	}
+	return resp, nil
+}
+
+func journalEventProto(ev *JournalEvent) *herokucipb.JournalEvent {
+	return &herokucipb.JournalEvent{
+		Time:           timestamppb.New(ev.Time),
+		PipelineId:     ev.PipelineID.String(),
+		RunId:          ev.RunID.String(),
+		RunNumber:      int64(ev.RunNumber),
+		CommitBranch:   ev.CommitBranch,
+		CommitSha:      ev.CommitSHA,
+		PreviousStatus: string(ev.PreviousStatus),
+		Status:         string(ev.Status),
+	}
+}
+
+func (g *grpcServer) ListRunEvents(ctx context.Context, req *herokucipb.ListRunEventsRequest) (*herokucipb.ListRunEventsResponse, error) {
+	events, err := g.s.runEvents(req.RunId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &herokucipb.ListRunEventsResponse{Events: make([]*herokucipb.JournalEvent, len(events))}
+	for i, ev := range events {
+		resp.Events[i] = journalEventProto(ev)
+	}
+	return resp, nil
+}
+
+func (g *grpcServer) StreamEvents(req *herokucipb.StreamEventsRequest, stream herokucipb.HerokuCI_StreamEventsServer) error {
+	f := journalFilter{PipelineID: req.PipelineId, RunPrefix: req.RunId}
+	err := followJournal(stream.Context(), f, time.Now(), func(ev *JournalEvent) error {
+		return stream.Send(journalEventProto(ev))
+	})
+	if err != nil && stream.Context().Err() == nil {
+		return grpcError(err)
+	}
+	return nil
+}
+
+func silencesProto(sl *silences) *herokucipb.Silences {
+	resp := new(herokucipb.Silences)
+	for _, a := range sl.Acks {
+		resp.Acks = append(resp.Acks, &herokucipb.Ack{
+			PipelineId: a.PipelineID,
+			Pipeline:   a.Pipeline,
+			Branch:     a.Branch,
+			RunNumber:  int64(a.RunNumber),
+			By:         a.By,
+			At:         timestamppb.New(a.At),
+			Note:       a.Note,
+		})
+	}
+	for _, sn := range sl.Snoozes {
+		resp.Snoozes = append(resp.Snoozes, &herokucipb.SnoozeEntry{
+			PipelineId: sn.PipelineID,
+			Pipeline:   sn.Pipeline,
+			Until:      timestamppb.New(sn.Until),
+			By:         sn.By,
+		})
+	}
+	return resp
+}
+
+func (g *grpcServer) ListSilences(ctx context.Context, req *herokucipb.ListSilencesRequest) (*herokucipb.Silences, error) {
+	sl, err := g.s.silences()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return silencesProto(sl), nil
+}
+
+func (g *grpcServer) Ack(ctx context.Context, req *herokucipb.AckRequest) (*herokucipb.Silences, error) {
+	sl, err := g.s.ack(ctx, req.PipelineId, req.Run, req.Note)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return silencesProto(sl), nil
+}
+
+func (g *grpcServer) Snooze(ctx context.Context, req *herokucipb.SnoozeRequest) (*herokucipb.Silences, error) {
+	sl, err := g.s.snooze(ctx, req.PipelineId, req.Duration)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return silencesProto(sl), nil
+}
+
+// serveGRPC serves the HerokuCI service, described in proto/heroku_ci.proto,
+// on addr until ctx is cancelled, with the same tokens and TLS settings as
+// the HTTP API.
+func serveGRPC(ctx context.Context, s *apiServer, addr string, tlsConfig *tls.Config) error {
+	scheme := "grpc"
+	if tlsConfig != nil {
+		scheme = "grpcs"
+	} else if !isLoopback(addr) {
+		warnf("serving gRPC on %s without TLS; tokens are sent in the clear", addr)
+	}
+	srv := newGRPCServer(s, tlsConfig)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Serving the heroku-ci gRPC API on %s://%s\n", scheme, ln.Addr())
+	go func() {
+		<-ctx.Done()
+		// Not GracefulStop, which would wait for every StreamEvents
+		// client to hang up.
+		srv.Stop()
+	}()
+	return srv.Serve(ln)
+}
+
+// newGRPCServer returns a gRPC server for the HerokuCI service, which checks
+// each call's token.
+func newGRPCServer(s *apiServer, tlsConfig *tls.Config) *grpc.Server {
+	g := &grpcServer{s: s}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(g.unaryAuth),
+		grpc.StreamInterceptor(g.streamAuth),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	srv := grpc.NewServer(opts...)
+	herokucipb.RegisterHerokuCIServer(srv, g)
+	return srv
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	herokucipb "github.com/kevinburke/heroku-ci/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCChecksTokens(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	s := &apiServer{tokens: []APIToken{{Name: "bot", Token: "read-token-0123456789"}}}
+	srv := newGRPCServer(s, nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	defer srv.Stop()
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := herokucipb.NewHerokuCIClient(conn)
+	listSilences := func(ctx context.Context) error {
+		_, err := client.ListSilences(ctx, &herokucipb.ListSilencesRequest{})
+		return err
+	}
+
+	tests := []struct {
+		name string
+		auth string
+		call func(ctx context.Context) error
+		want codes.Code
+	}{
+		{"no token", "", listSilences, codes.Unauthenticated},
+		{"wrong token", "Bearer nope", listSilences, codes.Unauthenticated},
+		{"read token", "Bearer read-token-0123456789", listSilences, codes.OK},
+		{"read token writing", "Bearer read-token-0123456789", func(ctx context.Context) error {
+			_, err := client.Snooze(ctx, &herokucipb.SnoozeRequest{PipelineId: "api", Duration: "1h"})
+			return err
+		}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		ctx := t.Context()
+		if tt.auth != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.auth)
+		}
+		if got := status.Code(tt.call(ctx)); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
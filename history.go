@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	types "github.com/kevinburke/go-types"
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// How many pages to fetch at once.
+const historyWorkers = 4
+
+// fetchTestRunHistory returns up to limit of the most recent test runs in the
+// pipeline, oldest first. If limit is 0 it returns every run.
+func fetchTestRunHistory(ctx context.Context, client *Client, id types.PrefixUUID, limit int) ([]*TestRun, error) {
+	runs := make([]*TestRun, 0)
+	err := client.WalkTestRuns(ctx, id, herokuci.WalkOptions{Limit: limit, Workers: historyWorkers}, func(page []*TestRun) error {
+		runs = append(runs, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// exportTestRuns writes up to limit recent test runs to w as newline
+// delimited JSON, oldest first. Each page is written as soon as it arrives,
+// so exporting a long history doesn't hold all of it in memory.
+func exportTestRuns(ctx context.Context, client *Client, id types.PrefixUUID, w io.Writer, limit int) error {
+	enc := json.NewEncoder(w)
+	return client.WalkTestRuns(ctx, id, herokuci.WalkOptions{Limit: limit, Workers: historyWorkers}, func(page []*TestRun) error {
+		for _, run := range page {
+			if err := enc.Encode(run); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// The journal is rotated once it grows past this size.
+const maxJournalSize = 10 * 1024 * 1024
+
+// How many rotated journal files (journal.ndjson.1, .2, ...) to keep.
+const maxJournalBackups = 3
+
+// JournalEvent records a test run state transition observed by heroku-ci.
+type JournalEvent struct {
+	Time           time.Time        `json:"time"`
+	PipelineID     types.PrefixUUID `json:"pipeline_id"`
+	RunID          types.PrefixUUID `json:"run_id"`
+	RunNumber      int              `json:"run_number,omitempty"`
+	CommitBranch   string           `json:"commit_branch"`
+	CommitSHA      string           `json:"commit_sha"`
+	PreviousStatus RunStatus        `json:"previous_status,omitempty"`
+	Status         RunStatus        `json:"status"`
+}
+
+// appendJournal records ev in the configured journal store.
+func appendJournal(ev *JournalEvent) error {
+	store, err := openJournal()
+	if err != nil {
+		return err
+	}
+	return store.Append(ev)
+}
+
+// appendJournalLocked writes ev as a single JSON line to the end of the
+// journal file, rotating the file first if it is too large.
+func appendJournalLocked(path string, ev *JournalEvent) error {
+	if fi, err := os.Stat(path); err == nil && fi.Size() >= maxJournalSize {
+		if err := rotateJournal(path); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rotateJournal shifts path to path.1, path.1 to path.2, and so on, dropping
+// the oldest backup.
+func rotateJournal(path string) error {
+	for i := maxJournalBackups - 1; i >= 1; i-- {
+		old := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(old); err == nil {
+			if err := os.Rename(old, fmt.Sprintf("%s.%d", path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
+// journal records state transitions for test runs, remembering the last
+// status it saw for each run.
+type journal struct {
+	pipelineID types.PrefixUUID
+	// root is the checkout we are running in, used to key the status file.
+	root string
+	// local is the branch checked out in root, and its tip, if any.
+	local  *repoState
+	last   map[string]RunStatus
+	warned bool
+}
+
+func newJournal(pipelineID types.PrefixUUID) *journal {
+	local, _ := readRepoState()
+	return &journal{
+		pipelineID: pipelineID,
+		root:       currentRepoRoot(),
+		local:      local,
+		last:       make(map[string]RunStatus),
+	}
+}
+
+// statusBranches returns the branch names to record run's status under:
+// Heroku's name for the branch, and the local branch's name if it differs,
+// as for a fork's pull request, and run is for the local branch's tip. The
+// prompt command looks the status up by the local name.
+func (j *journal) statusBranches(run *TestRun) []string {
+	branches := []string{run.CommitBranch}
+	if l := j.local; l != nil && l.Branch != "" && l.Branch != run.CommitBranch && l.Head != "" && run.CommitSHA != "" &&
+		(strings.HasPrefix(l.Head, run.CommitSHA) || strings.HasPrefix(run.CommitSHA, l.Head)) {
+		branches = append(branches, l.Branch)
+	}
+	return branches
+}
+
+// observe records run's status if it differs from the last status we saw for
+// run, and updates the status file the prompt command reads. Errors writing
+// the journal are reported once and otherwise ignored, since they should not
+// interrupt a wait.
+func (j *journal) observe(run *TestRun) {
+	id := run.ID.String()
+	prev, ok := j.last[id]
+	if ok && prev == run.Status {
+		return
+	}
+	j.last[id] = run.Status
+	emitProgress("status", j.pipelineID, run, prev)
+	emitStatusJSON(run)
+	err := appendJournal(&JournalEvent{
+		Time:           time.Now().UTC(),
+		PipelineID:     j.pipelineID,
+		RunID:          run.ID,
+		RunNumber:      run.Number,
+		CommitBranch:   run.CommitBranch,
+		CommitSHA:      run.CommitSHA,
+		PreviousStatus: prev,
+		Status:         run.Status,
+	})
+	if err == nil && j.root != "" {
+		for _, branch := range j.statusBranches(run) {
+			if err = recordBranchStatus(j.root, branch, run); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil && !j.warned {
+		j.warned = true
+		warnf("could not write to journal: %v", err)
+	}
+}
+
+// readJournal returns the events in the journal store that match f, oldest
+// first.
+func readJournal(f journalFilter) ([]*JournalEvent, error) {
+	store, err := openJournal()
+	if err != nil {
+		return nil, err
+	}
+	return store.Events(f)
+}
+
+// How often followJournal checks the store for new events.
+const journalFollowInterval = 2 * time.Second
+
+// followJournal calls fn with each event matching f that is recorded after
+// since, by this or any other heroku-ci process sharing the store, until
+// ctx is cancelled or fn returns an error.
+func followJournal(ctx context.Context, f journalFilter, since time.Time, fn func(ev *JournalEvent) error) error {
+	last := since
+	// The events recorded at last that were already sent, since two can
+	// have the same time.
+	sent := make(map[string]bool)
+	for {
+		events, err := readJournal(f)
+		if err != nil {
+			return err
+		}
+		for _, ev := range events {
+			key := ev.RunID.String() + " " + string(ev.Status)
+			if !ev.Time.After(since) || ev.Time.Before(last) || (ev.Time.Equal(last) && sent[key]) {
+				continue
+			}
+			if ev.Time.After(last) {
+				last = ev.Time
+				sent = make(map[string]bool)
+			}
+			sent[key] = true
+			if err := fn(ev); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(journalFollowInterval):
+		}
+	}
+}
+
+// readJournalLocked returns every event in the journal file, oldest first,
+// including events in rotated journal files.
+func readJournalLocked(path string) ([]*JournalEvent, error) {
+	events := make([]*JournalEvent, 0)
+	for i := maxJournalBackups; i >= 0; i-- {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(f)
+		for {
+			ev := new(JournalEvent)
+			if err := dec.Decode(ev); err == io.EOF {
+				break
+			} else if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			events = append(events, ev)
+		}
+		f.Close()
+	}
+	return events, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+func TestObserveRecordsLocalBranchStatus(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	journalConfig.Store = "file"
+	t.Cleanup(func() { journalConfig.Store = "" })
+	pipelineID, _ := types.NewPrefixUUID("0de00001-0000-4000-8000-000000000000")
+	run := &TestRun{
+		Number:       12,
+		CommitBranch: "fix-typo",
+		CommitSHA:    "e7f6a5b4c3d2e1f0e7f6a5b4c3d2e1f0e7f6a5b4",
+		Status:       StatusSucceeded,
+		UpdatedAt:    time.Now(),
+	}
+	run.ID, _ = types.NewPrefixUUID("aaaa0012-0000-4000-8000-000000000000")
+	j := &journal{
+		pipelineID: pipelineID,
+		root:       "/src/app",
+		// A fork's pull request, checked out under another name.
+		local: &repoState{Root: "/src/app", Branch: "pr-12", Head: run.CommitSHA},
+		last:  make(map[string]RunStatus),
+	}
+	j.observe(run)
+	other := *run
+	other.ID, _ = types.NewPrefixUUID("bbbb0013-0000-4000-8000-000000000000")
+	other.CommitBranch, other.CommitSHA = "main", "0123456789abcdef0123456789abcdef01234567"
+	j.observe(&other)
+
+	path, err := statusPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf, err := readStatusFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches := sf["/src/app"]
+	for _, name := range []string{"fix-typo", "pr-12"} {
+		if st := branches[name]; st == nil || st.RunID != run.ID.String() {
+			t.Errorf("%s: got %+v, want run %s", name, st, run.ID)
+		}
+	}
+	if st := branches["main"]; st == nil || st.RunID != other.ID.String() {
+		t.Errorf("main: got %+v, want run %s", st, other.ID)
+	}
+	if len(branches) != 3 {
+		t.Errorf("got statuses for %d branches, want 3: a run for another commit isn't the local branch's", len(branches))
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+	"github.com/kevinburke/rest"
+)
+
+// How long to wait after SIGTERM for in-flight requests and notifications
+// before exiting anyway. Kubernetes's default grace period is 30 seconds.
+const jobShutdownGrace = 10 * time.Second
+
+// A k8sJob runs wait the way a Kubernetes Job step wants it: every line is
+// timestamped and stripped of color, a heartbeat is printed whenever the
+// output has been quiet for a while, SIGTERM stops the wait at once, and the
+// exit code says what happened.
+type k8sJob struct {
+	stdout, stderr *jobStream
+	// lastWrite is when anything was last printed, in Unix nanoseconds.
+	lastWrite int64
+	// terminated is the exit code for the signal that stopped the wait, or
+	// 0 if none has.
+	terminated int32
+	stop       chan struct{}
+	stopOnce   sync.Once
+	wg         sync.WaitGroup
+	// mu serializes lines from the two streams and the heartbeat, so they
+	// aren't interleaved mid-line where stdout and stderr are the same file.
+	mu sync.Mutex
+}
+
+// A jobStream timestamps the lines written to a pipe and copies them to out.
+type jobStream struct {
+	w    *os.File
+	done chan struct{}
+}
+
+func (j *k8sJob) newStream(out io.Writer) (*jobStream, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	s := &jobStream{w: w, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				atomic.StoreInt64(&j.lastWrite, time.Now().UnixNano())
+				j.writeLine(out, ansiEscape.ReplaceAllString(line, ""))
+			}
+			if err != nil {
+				r.Close()
+				return
+			}
+		}
+	}()
+	return s, nil
+}
+
+func (j *k8sJob) writeLine(out io.Writer, line string) {
+	if line[len(line)-1] != '\n' {
+		line += "\n"
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	io.WriteString(out, time.Now().UTC().Format(time.RFC3339)+" "+line)
+}
+
+// startK8sJob redirects stdout and stderr through the timestamping streams,
+// starts the heartbeat, and cancels the wait on SIGTERM.
+func startK8sJob(heartbeat time.Duration, cancel context.CancelFunc) (*k8sJob, error) {
+	j := &k8sJob{stop: make(chan struct{}), lastWrite: time.Now().UnixNano()}
+	stdout, stderr := os.Stdout, os.Stderr
+	var err error
+	if j.stdout, err = j.newStream(stdout); err != nil {
+		return nil, err
+	}
+	if j.stderr, err = j.newStream(stderr); err != nil {
+		return nil, err
+	}
+	os.Stdout, os.Stderr = j.stdout.w, j.stderr.w
+	w := newRedactWriter(os.Stderr)
+	log.SetOutput(w)
+	rest.DefaultTransport.Output = w
+	start := time.Now()
+	if heartbeat > 0 {
+		j.wg.Add(1)
+		go func() {
+			defer j.wg.Done()
+			ticker := time.NewTicker(heartbeat / 2)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-j.stop:
+					return
+				case <-ticker.C:
+				}
+				last := time.Unix(0, atomic.LoadInt64(&j.lastWrite))
+				if time.Since(last) >= heartbeat {
+					atomic.StoreInt64(&j.lastWrite, time.Now().UnixNano())
+					j.writeLine(stdout, fmt.Sprintf("heartbeat: still waiting after %s\n", roundDuration(time.Since(start)).Round(time.Second)))
+				}
+			}
+		}()
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-sigs
+		atomic.StoreInt32(&j.terminated, int32(herokuci.SignalExitCode(sig)))
+		fmt.Fprintf(os.Stderr, "got %s, stopping; the test run carries on on Heroku\n", sig)
+		cancel()
+		select {
+		case <-j.stop:
+		case <-time.After(jobShutdownGrace):
+			fmt.Fprintf(os.Stderr, "still shutting down after %s, exiting\n", jobShutdownGrace)
+			j.exit(int(atomic.LoadInt32(&j.terminated)))
+		}
+	}()
+	return j, nil
+}
+
+// exitCode maps the error wait returned to one of the documented codes.
+func (j *k8sJob) exitCode(err error) int {
+	if code := atomic.LoadInt32(&j.terminated); err != nil && code != 0 {
+		return int(code)
+	}
+	return exitCodeFor(err)
+}
+
+// finish reports err, if there is one, and exits with its code.
+func (j *k8sJob) finish(err error) {
+	code := j.exitCode(err)
+	if err != nil && atomic.LoadInt32(&j.terminated) == 0 {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	fmt.Fprintf(os.Stderr, "exiting with code %d\n", code)
+	j.exit(code)
+}
+
+// exit flushes everything printed so far and exits.
+func (j *k8sJob) exit(code int) {
+	flushRedactWriters()
+	j.stopOnce.Do(func() { close(j.stop) })
+	j.wg.Wait()
+	j.stdout.w.Close()
+	j.stderr.w.Close()
+	<-j.stdout.done
+	<-j.stderr.done
+	os.Exit(code)
+}
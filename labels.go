@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A labelledRun is a run someone attached labels to with `heroku-ci label`.
+// Labels only exist on this machine; Heroku never sees them.
+type labelledRun struct {
+	PipelineID string    `json:"pipeline_id"`
+	RunID      string    `json:"run_id"`
+	RunNumber  int       `json:"run_number"`
+	Branch     string    `json:"branch"`
+	Labels     []string  `json:"labels"`
+	At         time.Time `json:"at"`
+}
+
+// runLabels are the labelled runs recorded in labelsPath.
+type runLabels struct {
+	Runs []*labelledRun `json:"runs"`
+}
+
+func readLabels(path string) (*runLabels, error) {
+	l := new(runLabels)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return l, nil
+}
+
+// updateLabels calls fn with the recorded labels and saves what it leaves,
+// holding the lock so concurrent heroku-ci processes don't lose each other's
+// changes.
+func updateLabels(fn func(l *runLabels) error) error {
+	path, err := labelsPath()
+	if err != nil {
+		return err
+	}
+	return withLock(path, func() error {
+		l, err := readLabels(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(l, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path, append(data, '\n'), 0644)
+	})
+}
+
+// loadLabels returns the recorded labels.
+func loadLabels() (*runLabels, error) {
+	path, err := labelsPath()
+	if err != nil {
+		return nil, err
+	}
+	return readLabels(path)
+}
+
+// labelled returns the IDs of the pipeline's runs that have label.
+func (l *runLabels) labelled(id types.PrefixUUID, label string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, r := range l.Runs {
+		if r.PipelineID != id.String() {
+			continue
+		}
+		for _, have := range r.Labels {
+			if have == label {
+				ids[r.RunID] = true
+			}
+		}
+	}
+	return ids
+}
+
+// validLabel reports whether label can be typed after --label without
+// quoting, and isn't confused with a list.
+func validLabel(label string) error {
+	if label == "" || strings.ContainsAny(label, ", \t\n") {
+		return usagef("bad label %q: labels can't be empty or contain commas or spaces", label)
+	}
+	return nil
+}
+
+// labelRun adds add to run's labels and takes away remove, and returns the
+// labels it is left with.
+func labelRun(id types.PrefixUUID, run *TestRun, add, remove []string) ([]string, error) {
+	var left []string
+	err := updateLabels(func(l *runLabels) error {
+		var r *labelledRun
+		runs := l.Runs[:0]
+		for _, old := range l.Runs {
+			if old.PipelineID == id.String() && old.RunID == run.ID.String() {
+				r = old
+				continue
+			}
+			runs = append(runs, old)
+		}
+		if r == nil {
+			r = &labelledRun{PipelineID: id.String(), RunID: run.ID.String()}
+		}
+		r.RunNumber, r.Branch, r.At = run.Number, run.CommitBranch, time.Now().UTC()
+		set := make(map[string]bool)
+		for _, label := range append(r.Labels, add...) {
+			set[label] = true
+		}
+		for _, label := range remove {
+			delete(set, label)
+		}
+		r.Labels = r.Labels[:0]
+		for label := range set {
+			r.Labels = append(r.Labels, label)
+		}
+		sort.Strings(r.Labels)
+		if len(r.Labels) > 0 {
+			runs = append(runs, r)
+		}
+		l.Runs = runs
+		left = r.Labels
+		return nil
+	})
+	return left, err
+}
+
+// printLabels prints the pipeline's labelled runs, newest first.
+func printLabels(w io.Writer, l *runLabels, id types.PrefixUUID) error {
+	runs := make([]*labelledRun, 0)
+	for _, r := range l.Runs {
+		if r.PipelineID == id.String() {
+			runs = append(runs, r)
+		}
+	}
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "No labelled runs.")
+		return nil
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].RunNumber > runs[j].RunNumber })
+	t := newTable("RUN", "BRANCH", "LABELS")
+	t.flex = []int{1, 2}
+	for _, r := range runs {
+		t.add("#"+strconv.Itoa(r.RunNumber), r.Branch, strings.Join(r.Labels, ", "))
+	}
+	return t.print(w)
+}
@@ -0,0 +1,413 @@
+// Package herokuci is a client for the parts of the Heroku Platform API that
+// Heroku CI uses: pipelines, test runs and their nodes.
+//
+// The heroku-ci command sends all of its Heroku API requests through a
+// Client, with a middleware (see WithMiddleware) that adds its response
+// cache, circuit breaker and request pacing. Its wait command, which also
+// streams each node's output, doesn't use WaitForRun.
+package herokuci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// DefaultBaseURL is the Heroku Platform API.
+const DefaultBaseURL = "https://api.heroku.com"
+
+// DefaultPollInterval is how often WaitForRun checks a run, unless told
+// otherwise.
+const DefaultPollInterval = 2 * time.Second
+
+// MaxPageSize is the most results Heroku returns in a single page.
+const MaxPageSize = 1000
+
+// pageSize is how many results to ask for at a time. Tests make it smaller.
+var pageSize = MaxPageSize
+
+// How many times Do tries a read that fails because the API is down, by
+// default, and a request Heroku rate limits.
+const (
+	readAttempts      = 3
+	rateLimitAttempts = 5
+)
+
+// retryBase is how long Do waits before its first retry; each retry after
+// that waits twice as long, up to maxRetryWait.
+var retryBase = time.Second
+
+const maxRetryWait = time.Minute
+
+// A DoFunc sends a request and decodes the JSON response into v.
+type DoFunc func(r *http.Request, v interface{}) error
+
+// A Client makes requests to the Heroku Platform API. It is safe to use from
+// several goroutines at once.
+type Client struct {
+	mu    sync.RWMutex
+	token string
+
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+	userAgent  string
+	attempts   int
+	middleware func(send DoFunc) DoFunc
+	do         DoFunc
+}
+
+// An Option configures a Client made by NewClient.
+type Option func(*Client)
+
+// WithBaseURL points the client at another API than Heroku's, like a test
+// server.
+func WithBaseURL(base string) Option {
+	return func(c *Client) { c.baseURL = base }
+}
+
+// WithHTTPClient sends requests with hc instead of a new http.Client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTimeout limits how long each request may take, on top of any deadline
+// on its context. By default requests have no limit of their own, so the
+// context decides.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.timeout = d }
+}
+
+// WithRetries sets how many times Do tries a read when the API is down. The
+// default is 3; 0 means it keeps trying until the context is done.
+func WithRetries(attempts int) Option {
+	return func(c *Client) { c.attempts = attempts }
+}
+
+// WithUserAgent sends ua as the User-Agent of every request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithMiddleware replaces the client's retries with m, which is given the
+// client's single attempt at a request, Send, and returns what Do and every
+// other method should call instead.
+func WithMiddleware(m func(send DoFunc) DoFunc) Option {
+	return func(c *Client) { c.middleware = m }
+}
+
+// NewClient returns a Client that authenticates with token, a Heroku API key
+// or OAuth access token.
+func NewClient(token string, opts ...Option) *Client {
+	c := &Client{token: token, baseURL: DefaultBaseURL, attempts: readAttempts}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{}
+	}
+	if c.timeout > 0 {
+		// Copy it, so a client passed to WithHTTPClient doesn't change.
+		hc := *c.httpClient
+		hc.Timeout = c.timeout
+		c.httpClient = &hc
+	}
+	if c.middleware != nil {
+		c.do = c.middleware(c.Send)
+	} else {
+		c.do = c.retry
+	}
+	return c
+}
+
+// Token returns the token requests are authenticated with.
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// SetToken replaces the token, say after refreshing an expired OAuth token.
+// Requests made from then on use the new one.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// BaseURL returns the API the client talks to.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Retries returns how many times Do tries a read when the API is down, or 0
+// for no limit; see WithRetries.
+func (c *Client) Retries() int {
+	return c.attempts
+}
+
+// HTTPClient returns the http.Client requests are sent with.
+func (c *Client) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
+// NewRequest returns a request for path, relative to the client's base URL,
+// with the headers the Heroku API wants. A body is sent as JSON.
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.heroku+json; version=3")
+	req.Header.Set("Authorization", "Bearer "+c.Token())
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	return req, nil
+}
+
+// Send sends r once and decodes the JSON response into v, if v is not nil.
+// A response of 400 or more is returned as an *Error.
+func (c *Client) Send(r *http.Request, v interface{}) error {
+	resp, err := c.httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return ParseError(resp)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+// Do sends r and decodes the JSON response into v, if v is not nil. Reads
+// that fail because the API is down are tried again, up to three times in
+// all unless WithRetries says otherwise, and a request Heroku rate limits is
+// sent again once Heroku says to.
+// Other writes are never retried, since they may have gone through.
+func (c *Client) Do(r *http.Request, v interface{}) error {
+	return c.do(r, v)
+}
+
+func (c *Client) retry(r *http.Request, v interface{}) error {
+	read := r.Method == "GET" || r.Method == "HEAD"
+	reads, limited := 0, 0
+	for {
+		err := c.Send(r, v)
+		if err == nil {
+			return nil
+		}
+		var herr *Error
+		isHerr := errors.As(err, &herr)
+		var wait time.Duration
+		switch {
+		case isHerr && herr.StatusCode == http.StatusTooManyRequests:
+			limited++
+			if limited >= rateLimitAttempts {
+				return err
+			}
+			wait = backoff(limited)
+			if herr.RetryAfter > 0 {
+				wait = herr.RetryAfter
+			}
+		case read && (isHerr && herr.StatusCode >= 500 || !isHerr && isNetError(err)):
+			reads++
+			if c.attempts > 0 && reads >= c.attempts {
+				return err
+			}
+			wait = backoff(reads)
+		default:
+			return err
+		}
+		if r.Body != nil {
+			if r.GetBody == nil {
+				return err
+			}
+			body, berr := r.GetBody()
+			if berr != nil {
+				return err
+			}
+			r.Body = body
+		}
+		select {
+		case <-r.Context().Done():
+			return r.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// backoff returns how long to wait before the given retry.
+func backoff(retry int) time.Duration {
+	d := retryBase
+	for i := 1; i < retry && d < maxRetryWait; i++ {
+		d *= 2
+	}
+	if d > maxRetryWait {
+		d = maxRetryWait
+	}
+	return d
+}
+
+func isNetError(err error) bool {
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// get fetches path into v.
+func (c *Client) get(ctx context.Context, path, rangeHeader string, v interface{}) error {
+	req, err := c.NewRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	return c.Do(req, v)
+}
+
+// ErrPipelineNotFound is returned by FindPipeline when the token can't see a
+// pipeline with the name it was given.
+var ErrPipelineNotFound = errors.New("pipeline not found")
+
+// Pipelines returns every pipeline the client can see.
+func (c *Client) Pipelines(ctx context.Context) ([]*Pipeline, error) {
+	all := make([]*Pipeline, 0)
+	rangeHeader := fmt.Sprintf("id ..; max=%d", pageSize)
+	for {
+		page := make([]*Pipeline, 0)
+		if err := c.get(ctx, "/pipelines", rangeHeader, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < pageSize {
+			return all, nil
+		}
+		// The next page starts after the last ID on this one.
+		rangeHeader = fmt.Sprintf("id ]%s..; max=%d", page[len(page)-1].ID.String(), pageSize)
+	}
+}
+
+// FindPipeline returns the pipeline with the given name. If there isn't one
+// the client can see, the error wraps ErrPipelineNotFound.
+func (c *Client) FindPipeline(ctx context.Context, name string) (*Pipeline, error) {
+	pipelines, err := c.Pipelines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pipelines {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPipelineNotFound, name)
+}
+
+// ListTestRuns returns the pipeline's most recent test runs, newest first.
+// If limit is 0 or less, every run is returned, which for an old pipeline
+// takes a request per thousand runs.
+func (c *Client) ListTestRuns(ctx context.Context, pipelineID types.PrefixUUID, limit int) ([]*TestRun, error) {
+	path := "/pipelines/" + pipelineID.String() + "/test-runs"
+	all := make([]*TestRun, 0)
+	// Heroku pages by run number; each page starts below the last one.
+	to := ""
+	for limit <= 0 || len(all) < limit {
+		max := pageSize
+		if limit > 0 && limit-len(all) < max {
+			max = limit - len(all)
+		}
+		runs := make([]*TestRun, 0)
+		if err := c.get(ctx, path, fmt.Sprintf("number ..%s; order=desc, max=%d", to, max), &runs); err != nil {
+			return nil, err
+		}
+		all = append(all, runs...)
+		last := 0
+		if len(runs) > 0 {
+			last = runs[len(runs)-1].Number
+		}
+		if len(runs) < max || last <= 1 {
+			break
+		}
+		to = strconv.Itoa(last - 1)
+	}
+	return all, nil
+}
+
+// GetTestRun returns the pipeline's test run with the given number.
+func (c *Client) GetTestRun(ctx context.Context, pipelineID types.PrefixUUID, number int) (*TestRun, error) {
+	run := new(TestRun)
+	if err := c.get(ctx, "/pipelines/"+pipelineID.String()+"/test-runs/"+strconv.Itoa(number), "", run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// TestNodes returns the nodes of the test run with the given ID.
+func (c *Client) TestNodes(ctx context.Context, runID types.PrefixUUID) ([]*TestNode, error) {
+	nodes := make([]*TestNode, 0)
+	if err := c.get(ctx, "/test-runs/"+runID.String()+"/test-nodes", "", &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// WaitOptions configure WaitForRun.
+type WaitOptions struct {
+	// Interval is how often to check the run; DefaultPollInterval if 0.
+	Interval time.Duration
+	// OnStatus, if set, is called with the run each time its status
+	// changes, including the first time it is checked.
+	OnStatus func(run *TestRun)
+}
+
+// WaitForRun polls the test run with the given ID until it finishes or ctx
+// is done, and returns it as it was last seen, which is nil if it never was.
+// A run that finished without succeeding is not an error; check the returned
+// run's Status.
+func (c *Client) WaitForRun(ctx context.Context, runID types.PrefixUUID, opts WaitOptions) (*TestRun, error) {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	var last *TestRun
+	for {
+		run := new(TestRun)
+		if err := c.get(ctx, "/test-runs/"+runID.String(), "", run); err != nil {
+			if ctx.Err() != nil {
+				return last, ctx.Err()
+			}
+			return last, err
+		}
+		if (last == nil || run.Status != last.Status) && opts.OnStatus != nil {
+			opts.OnStatus(run)
+		}
+		last = run
+		if run.Status.Terminal() {
+			return run, nil
+		}
+		select {
+		case <-ctx.Done():
+			return run, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package herokuci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+const pipelineID = "0de00001-0000-4000-8000-000000000000"
+
+// fakeAPI serves pipelines and test runs a page at a time, the way Heroku
+// reads the Range header.
+type fakeAPI struct {
+	mu        sync.Mutex
+	pipelines []string
+	runs      int
+	// fail, if more than 0, is how many of the next requests get a 503.
+	fail     int
+	limited  int
+	requests int
+}
+
+var (
+	idRange     = regexp.MustCompile(`^id (?:\](\S+))?\.\.; max=(\d+)$`)
+	numberRange = regexp.MustCompile(`^number \.\.(\d*); order=desc, max=(\d+)$`)
+	ascRange    = regexp.MustCompile(`^number (\d+)\.\.(\d+); order=asc, max=(\d+)$`)
+)
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	if f.limited > 0 {
+		f.limited--
+		w.Header().Set("Retry-After", "0")
+		writeJSON(w, http.StatusTooManyRequests, &Error{ID: "rate_limit", Message: "slow down"})
+		return
+	}
+	if f.fail > 0 {
+		f.fail--
+		writeJSON(w, http.StatusServiceUnavailable, &Error{ID: "unavailable", Message: "try again"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		writeJSON(w, http.StatusUnauthorized, &Error{ID: "unauthorized", Message: "bad token"})
+		return
+	}
+	switch r.URL.Path {
+	case "/pipelines":
+		m := idRange.FindStringSubmatch(r.Header.Get("Range"))
+		if m == nil {
+			writeJSON(w, http.StatusBadRequest, &Error{ID: "bad_request", Message: "bad Range"})
+			return
+		}
+		ids := make([]string, len(f.pipelines))
+		for i := range f.pipelines {
+			ids[i] = fmt.Sprintf("0de%05d-0000-4000-8000-000000000000", i+1)
+		}
+		max, _ := strconv.Atoi(m[2])
+		page := make([]*Pipeline, 0)
+		for i, id := range ids {
+			if id > m[1] && len(page) < max {
+				p := &Pipeline{Name: f.pipelines[i]}
+				p.ID, _ = types.NewPrefixUUID(id)
+				page = append(page, p)
+			}
+		}
+		writeJSON(w, http.StatusOK, page)
+	case "/pipelines/" + pipelineID + "/test-runs":
+		if m := ascRange.FindStringSubmatch(r.Header.Get("Range")); m != nil {
+			from, _ := strconv.Atoi(m[1])
+			to, _ := strconv.Atoi(m[2])
+			max, _ := strconv.Atoi(m[3])
+			page := make([]*TestRun, 0)
+			for n := from; n <= to && n <= f.runs && len(page) < max; n++ {
+				page = append(page, &TestRun{Number: n})
+			}
+			writeJSON(w, http.StatusOK, page)
+			return
+		}
+		m := numberRange.FindStringSubmatch(r.Header.Get("Range"))
+		if m == nil {
+			writeJSON(w, http.StatusBadRequest, &Error{ID: "bad_request", Message: "bad Range"})
+			return
+		}
+		to := f.runs
+		if m[1] != "" {
+			to, _ = strconv.Atoi(m[1])
+		}
+		max, _ := strconv.Atoi(m[2])
+		page := make([]*TestRun, 0)
+		for n := to; n >= 1 && len(page) < max; n-- {
+			page = append(page, &TestRun{Number: n})
+		}
+		writeJSON(w, http.StatusOK, page)
+	default:
+		writeJSON(w, http.StatusNotFound, &Error{ID: "not_found", Message: "no such thing"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newTestClient(t *testing.T, f *fakeAPI) *Client {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	page, base := pageSize, retryBase
+	t.Cleanup(func() { pageSize, retryBase = page, base })
+	pageSize, retryBase = 2, time.Millisecond
+	return NewClient("token", WithBaseURL(srv.URL))
+}
+
+func TestPipelinesPages(t *testing.T) {
+	f := &fakeAPI{pipelines: []string{"api", "web", "worker"}}
+	c := newTestClient(t, f)
+	pipelines, err := c.Pipelines(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(pipelines))
+	for i, p := range pipelines {
+		names[i] = p.Name
+	}
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[api web worker]" {
+		t.Errorf("got pipelines %v, want [api web worker]", names)
+	}
+	if f.requests != 2 {
+		t.Errorf("got %d requests, want 2", f.requests)
+	}
+}
+
+func TestFindPipelineNotFound(t *testing.T) {
+	c := newTestClient(t, &fakeAPI{pipelines: []string{"api", "web", "worker"}})
+	p, err := c.FindPipeline(t.Context(), "missing")
+	if !errors.Is(err, ErrPipelineNotFound) {
+		t.Fatalf("got %v, %v, want ErrPipelineNotFound", p, err)
+	}
+	if p, err := c.FindPipeline(t.Context(), "worker"); err != nil || p.Name != "worker" {
+		t.Errorf("FindPipeline(worker): got %v, %v", p, err)
+	}
+}
+
+func TestListTestRunsPages(t *testing.T) {
+	id, _ := types.NewPrefixUUID(pipelineID)
+	tests := []struct {
+		limit    int
+		want     []int
+		requests int
+	}{
+		{0, []int{5, 4, 3, 2, 1}, 3},
+		{3, []int{5, 4, 3}, 2},
+		{1, []int{5}, 1},
+	}
+	for _, tt := range tests {
+		f := &fakeAPI{runs: 5}
+		c := newTestClient(t, f)
+		runs, err := c.ListTestRuns(t.Context(), id, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]int, len(runs))
+		for i, r := range runs {
+			got[i] = r.Number
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("limit %d: got runs %v, want %v", tt.limit, got, tt.want)
+		}
+		if f.requests != tt.requests {
+			t.Errorf("limit %d: got %d requests, want %d", tt.limit, f.requests, tt.requests)
+		}
+	}
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		fail     int
+		limited  int
+		wantErr  bool
+		requests int
+	}{
+		{"down", 2, 0, false, 3},
+		{"down too long", readAttempts, 0, true, readAttempts},
+		{"rate limited", 0, 3, false, 4},
+	}
+	for _, tt := range tests {
+		f := &fakeAPI{pipelines: []string{"api"}, fail: tt.fail, limited: tt.limited}
+		c := newTestClient(t, f)
+		_, err := c.FindPipeline(t.Context(), "api")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error: %t", tt.name, err, tt.wantErr)
+		}
+		if f.requests != tt.requests {
+			t.Errorf("%s: got %d requests, want %d", tt.name, f.requests, tt.requests)
+		}
+	}
+}
+
+func TestDoDoesNotRetryWrites(t *testing.T) {
+	f := &fakeAPI{fail: 1}
+	c := newTestClient(t, f)
+	req, err := c.NewRequest(t.Context(), "POST", "/test-runs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var herr *Error
+	if err := c.Do(req, nil); !errors.As(err, &herr) || herr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want a 503", err)
+	}
+	if f.requests != 1 {
+		t.Errorf("got %d requests, want 1", f.requests)
+	}
+}
+
+func TestWaitForRunStopsWhenCanceled(t *testing.T) {
+	var mu sync.Mutex
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		polls++
+		mu.Unlock()
+		writeJSON(w, http.StatusOK, &TestRun{Number: 7, Status: StatusRunning})
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	id, _ := types.NewPrefixUUID(pipelineID)
+	seen := 0
+	start := time.Now()
+	run, err := c.WaitForRun(ctx, id, WaitOptions{
+		Interval: 10 * time.Millisecond,
+		OnStatus: func(*TestRun) { seen++ },
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("WaitForRun took %v to notice ctx was done", elapsed)
+	}
+	if run == nil || run.Number != 7 {
+		t.Errorf("got run %v, want the last one seen", run)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if polls < 2 {
+		t.Errorf("polled %d times, want at least 2", polls)
+	}
+	if seen != 1 {
+		t.Errorf("OnStatus called %d times, want 1 for an unchanged status", seen)
+	}
+}
+
+func TestWaitForRunReturnsFinishedRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, &TestRun{Number: 7, Status: StatusFailed})
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+	id, _ := types.NewPrefixUUID(pipelineID)
+	run, err := c.WaitForRun(t.Context(), id, WaitOptions{Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != StatusFailed {
+		t.Errorf("got status %s, want failed", run.Status)
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	f := &fakeAPI{pipelines: []string{"api"}, fail: 5}
+	c := newTestClient(t, f)
+	c = NewClient("token", WithBaseURL(c.BaseURL()), WithRetries(1))
+	if _, err := c.Pipelines(t.Context()); err == nil {
+		t.Fatal("got nil error, want a 503")
+	}
+	if f.requests != 1 {
+		t.Errorf("got %d requests, want 1", f.requests)
+	}
+
+	f = &fakeAPI{pipelines: []string{"api"}, fail: 5}
+	c = newTestClient(t, f)
+	c = NewClient("token", WithBaseURL(c.BaseURL()), WithRetries(0))
+	if _, err := c.Pipelines(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if f.requests != 6 {
+		t.Errorf("got %d requests, want 6", f.requests)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	hc := &http.Client{}
+	c := NewClient("token", WithBaseURL(srv.URL), WithHTTPClient(hc), WithTimeout(20*time.Millisecond), WithRetries(1))
+	start := time.Now()
+	if _, err := c.Pipelines(t.Context()); err == nil {
+		t.Fatal("got nil error, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request took %v, want it to time out", elapsed)
+	}
+	if hc.Timeout != 0 {
+		t.Errorf("WithTimeout changed the http.Client passed to WithHTTPClient")
+	}
+}
@@ -0,0 +1,47 @@
+package herokuci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error returned by the Heroku Platform API.
+type Error struct {
+	StatusCode int    `json:"-"`
+	ID         string `json:"id"`
+	Message    string `json:"message"`
+	URL        string `json:"url"`
+	// RetryAfter is how long Heroku asked us to wait before trying again,
+	// from the Retry-After header of a 429 or 503.
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Heroku API error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return e.Message
+}
+
+// ParseError parses an error response from the Heroku API into an *Error,
+// and closes resp's body.
+func ParseError(resp *http.Response) error {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+	herr := &Error{StatusCode: resp.StatusCode}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		herr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	if err := json.Unmarshal(body, herr); err != nil || herr.Message == "" {
+		herr.Message = strings.TrimSpace(string(body))
+	}
+	return herr
+}
@@ -0,0 +1,55 @@
+package herokuci
+
+import (
+	"os"
+	"syscall"
+)
+
+// Exit codes for every heroku-ci command. These are part of the interface,
+// so a script, a Makefile, or a Job's podFailurePolicy can act on them;
+// don't change them, only add new ones.
+const (
+	ExitSucceeded = 0
+	// ExitRunFailed means a test run, or a check on one, finished without
+	// succeeding.
+	ExitRunFailed = 1
+	// ExitUsage means the flags, arguments, or config were invalid.
+	ExitUsage = 2
+	// ExitError means something went wrong talking to Heroku, git, or
+	// GitHub.
+	ExitError = 3
+	// ExitTimeout means heroku-ci gave up waiting.
+	ExitTimeout = 4
+	// ExitAuth means the credentials were missing, rejected, or don't allow
+	// what the command tried to do.
+	ExitAuth = 5
+	// ExitNotFound means the pipeline, run, app, or other thing named on
+	// the command line doesn't exist, or can't be seen with these
+	// credentials.
+	ExitNotFound = 6
+	// ExitQuarantined means a test run failed, but every test that failed
+	// is on the repository's quarantine list.
+	ExitQuarantined = 7
+	// ExitRunErrored means a test run errored: Heroku couldn't set up or
+	// run the tests, so there is no test result.
+	ExitRunErrored = 8
+	// ExitRunCancelled means a test run was cancelled on Heroku before it
+	// finished, from the dashboard, the API, or heroku-ci cancel.
+	ExitRunCancelled = 9
+	// ExitInterrupted means heroku-ci got SIGINT, usually Ctrl-C, and
+	// stopped before it finished. A test run it was waiting for carries on
+	// on Heroku.
+	ExitInterrupted = 130
+	// ExitTerminated means heroku-ci got SIGTERM and stopped before it
+	// finished. A test run it was waiting for carries on on Heroku.
+	ExitTerminated = 143
+)
+
+// SignalExitCode returns the exit code for stopping because of sig:
+// ExitTerminated for SIGTERM and ExitInterrupted for anything else.
+func SignalExitCode(sig os.Signal) int {
+	if sig == syscall.SIGTERM {
+		return ExitTerminated
+	}
+	return ExitInterrupted
+}
@@ -0,0 +1,65 @@
+package herokuci
+
+import "strings"
+
+// RunStatus is the status of a Heroku CI test run.
+type RunStatus string
+
+// The statuses Heroku documents for test runs.
+const (
+	StatusPending   RunStatus = "pending"
+	StatusCreating  RunStatus = "creating"
+	StatusBuilding  RunStatus = "building"
+	StatusRunning   RunStatus = "running"
+	StatusDebugging RunStatus = "debugging"
+	StatusErrored   RunStatus = "errored"
+	StatusFailed    RunStatus = "failed"
+	StatusSucceeded RunStatus = "succeeded"
+	StatusCancelled RunStatus = "cancelled"
+)
+
+// OnUnknownStatus, if set, is called each time Terminal sees a status Heroku
+// doesn't document, before it guesses. heroku-ci uses it to warn.
+var OnUnknownStatus func(s RunStatus)
+
+// Known reports whether s is one of the statuses Heroku documents.
+func (s RunStatus) Known() bool {
+	switch s {
+	case StatusPending, StatusCreating, StatusBuilding, StatusRunning, StatusDebugging,
+		StatusErrored, StatusFailed, StatusSucceeded, StatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Terminal reports whether a run with status s has finished and will not
+// change again.
+//
+// If Heroku adds a status we don't know about, we guess: the finished
+// statuses are all past tense, so an unknown status ending in "ed" is
+// treated as finished, and anything else as still in progress. Guessing
+// wrong the other way would mean waiting forever.
+func (s RunStatus) Terminal() bool {
+	switch s {
+	case StatusErrored, StatusFailed, StatusSucceeded, StatusCancelled:
+		return true
+	case StatusPending, StatusCreating, StatusBuilding, StatusRunning, StatusDebugging:
+		return false
+	}
+	if OnUnknownStatus != nil {
+		OnUnknownStatus(s)
+	}
+	return strings.HasSuffix(string(s), "ed")
+}
+
+// Setup reports whether a run with status s is still being set up, before
+// any tests have started.
+func (s RunStatus) Setup() bool {
+	return s == StatusPending || s == StatusCreating || s == StatusBuilding
+}
+
+// Failed reports whether a run with status s finished without succeeding.
+func (s RunStatus) Failed() bool {
+	return s.Terminal() && s != StatusSucceeded
+}
@@ -0,0 +1,58 @@
+package herokuci
+
+import (
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+type Pipeline struct {
+	CreatedAt time.Time        `json:"created_at"`
+	ID        types.PrefixUUID `json:"id"`
+	Name      string           `json:"name"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+type TestRun struct {
+	CreatedAt     time.Time        `json:"created_at"`
+	ID            types.PrefixUUID `json:"id"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	Number        int              `json:"number"`
+	ClearCache    bool             `json:"clear_cache"`
+	CommitBranch  string           `json:"commit_branch"`
+	CommitSHA     string           `json:"commit_sha"`
+	CommitMessage string           `json:"commit_message"`
+	ActorEmail    string           `json:"actor_email"`
+	Status        RunStatus        `json:"status"`
+}
+
+// InProgress reports whether the run has yet to finish.
+func (t TestRun) InProgress() bool {
+	return !t.Status.Terminal()
+}
+
+// Duration returns the time between the run's creation and its last update.
+func (t TestRun) Duration() time.Duration {
+	return RoundDuration(t.UpdatedAt.Sub(t.CreatedAt))
+}
+
+// A TestNode is one dyno running part of a test run. Runs that don't use
+// parallel tests have a single node.
+type TestNode struct {
+	CreatedAt       time.Time        `json:"created_at"`
+	ID              types.PrefixUUID `json:"id"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	Index           int              `json:"index"`
+	Status          string           `json:"status"`
+	ExitCode        *int             `json:"exit_code"`
+	SetupStreamURL  string           `json:"setup_stream_url"`
+	OutputStreamURL string           `json:"output_stream_url"`
+}
+
+// RoundDuration rounds d to a precision that is useful for display.
+func RoundDuration(d time.Duration) time.Duration {
+	if d > time.Minute {
+		return d.Round(time.Second)
+	}
+	return d.Round(10 * time.Millisecond)
+}
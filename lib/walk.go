@@ -0,0 +1,124 @@
+package herokuci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// ErrStopWalk, returned by a WalkTestRuns callback, ends the walk early
+// without an error.
+var ErrStopWalk = errors.New("stop walking test runs")
+
+// WalkOptions control the order and extent of WalkTestRuns.
+type WalkOptions struct {
+	// Limit is how many of the most recent run numbers to walk; 0 walks the
+	// whole history.
+	Limit int
+	// NewestFirst walks from the latest run back, instead of from the
+	// oldest forward.
+	NewestFirst bool
+	// Workers is how many pages to fetch at once. Pages are handed to the
+	// callback in order either way; more workers just keep it busier.
+	Workers int
+}
+
+// LatestTestRunNumber returns the number of the pipeline's most recent test
+// run, or 0 if it has none.
+func (c *Client) LatestTestRunNumber(ctx context.Context, pipelineID types.PrefixUUID) (int, error) {
+	runs := make([]*TestRun, 0)
+	if err := c.get(ctx, "/pipelines/"+pipelineID.String()+"/test-runs", "number ..; order=desc, max=1", &runs); err != nil {
+		return 0, err
+	}
+	if len(runs) == 0 {
+		return 0, nil
+	}
+	return runs[0].Number, nil
+}
+
+// testRunsPage returns the test runs whose numbers fall in the inclusive
+// range [from, to], in ascending order.
+func (c *Client) testRunsPage(ctx context.Context, pipelineID types.PrefixUUID, from, to int) ([]*TestRun, error) {
+	runs := make([]*TestRun, 0)
+	rangeHeader := fmt.Sprintf("number %d..%d; order=asc, max=%d", from, to, pageSize)
+	if err := c.get(ctx, "/pipelines/"+pipelineID.String()+"/test-runs", rangeHeader, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// WalkTestRuns calls fn with each page of the pipeline's test runs, in the
+// order opts asks for, so a history of any length can be processed while
+// holding at most opts.Workers pages in memory. If fn returns ErrStopWalk no
+// more pages are fetched and WalkTestRuns returns nil; any other error is
+// returned as is.
+//
+// Heroku's Next-Range pagination forces one page at a time, but since run
+// numbers are sequential we can split the history into number ranges up
+// front and fetch several pages at once.
+func (c *Client) WalkTestRuns(ctx context.Context, pipelineID types.PrefixUUID, opts WalkOptions, fn func([]*TestRun) error) error {
+	latest, err := c.LatestTestRunNumber(ctx, pipelineID)
+	if err != nil {
+		return err
+	}
+	first := 1
+	if opts.Limit > 0 && latest-opts.Limit+1 > first {
+		first = latest - opts.Limit + 1
+	}
+	type page struct{ from, to int }
+	pages := make([]page, 0)
+	for from := first; from <= latest; from += pageSize {
+		to := from + pageSize - 1
+		if to > latest {
+			to = latest
+		}
+		pages = append(pages, page{from, to})
+	}
+	if opts.NewestFirst {
+		for i, j := 0, len(pages)-1; i < j; i, j = i+1, j-1 {
+			pages[i], pages[j] = pages[j], pages[i]
+		}
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for len(pages) > 0 {
+		wave := pages
+		if len(wave) > workers {
+			wave = wave[:workers]
+		}
+		pages = pages[len(wave):]
+		results := make([][]*TestRun, len(wave))
+		errs := make([]error, len(wave))
+		var wg sync.WaitGroup
+		for i, p := range wave {
+			wg.Add(1)
+			go func(i int, p page) {
+				defer wg.Done()
+				results[i], errs[i] = c.testRunsPage(ctx, pipelineID, p.from, p.to)
+			}(i, p)
+		}
+		wg.Wait()
+		for i, runs := range results {
+			if errs[i] != nil {
+				return errs[i]
+			}
+			if opts.NewestFirst {
+				for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+					runs[i], runs[j] = runs[j], runs[i]
+				}
+			}
+			if err := fn(runs); err != nil {
+				if err == ErrStopWalk {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package herokuci
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	types "github.com/kevinburke/go-types"
+)
+
+func TestWalkTestRuns(t *testing.T) {
+	id, _ := types.NewPrefixUUID(pipelineID)
+	tests := []struct {
+		opts     WalkOptions
+		stop     bool
+		want     string
+		requests int
+	}{
+		{WalkOptions{}, false, "[[1 2] [3 4] [5]]", 4},
+		{WalkOptions{Workers: 3}, false, "[[1 2] [3 4] [5]]", 4},
+		{WalkOptions{Limit: 3, NewestFirst: true}, false, "[[5] [4 3]]", 3},
+		{WalkOptions{NewestFirst: true}, true, "[[5]]", 2},
+	}
+	for _, tt := range tests {
+		f := &fakeAPI{runs: 5}
+		c := newTestClient(t, f)
+		var pages [][]int
+		err := c.WalkTestRuns(t.Context(), id, tt.opts, func(runs []*TestRun) error {
+			page := make([]int, len(runs))
+			for i, r := range runs {
+				page[i] = r.Number
+			}
+			pages = append(pages, page)
+			if tt.stop {
+				return ErrStopWalk
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(pages); got != tt.want {
+			t.Errorf("%+v: got pages %s, want %s", tt.opts, got, tt.want)
+		}
+		if f.requests != tt.requests {
+			t.Errorf("%+v: got %d requests, want %d", tt.opts, f.requests, tt.requests)
+		}
+	}
+}
+
+func TestWalkTestRunsReturnsCallbackError(t *testing.T) {
+	id, _ := types.NewPrefixUUID(pipelineID)
+	c := newTestClient(t, &fakeAPI{runs: 5})
+	want := errors.New("disk full")
+	err := c.WalkTestRuns(t.Context(), id, WalkOptions{}, func([]*TestRun) error { return want })
+	if err != want {
+		t.Errorf("got %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseStatuses parses a comma-separated list of run statuses, like
+// "failed,errored".
+func parseStatuses(list string) ([]RunStatus, error) {
+	statuses := make([]RunStatus, 0)
+	for _, s := range splitLabels(list) {
+		st := RunStatus(strings.ToLower(s))
+		if !st.Known() {
+			return nil, usagef("unknown status %q", s)
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// globEscape quotes the characters path.Match treats specially, so s only
+// matches itself.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// runTook returns how long run took, or for a run in progress, how long it
+// has been going.
+func runTook(run *TestRun) time.Duration {
+	if run.InProgress() {
+		return roundDuration(time.Since(run.CreatedAt))
+	}
+	return run.Duration()
+}
+
+// printRunList prints one line per run, with who started it and how long it
+// took.
+func printRunList(w io.Writer, runs []*TestRun) error {
+	t := newTable("RUN", "BRANCH", "COMMIT", "STATUS", "DURATION", "ACTOR")
+	t.flex = []int{1, 5}
+	for _, run := range runs {
+		t.addf("#"+strconv.Itoa(run.Number), run.CommitBranch, shortSHA(run.CommitSHA), run.Status, runTook(run), run.ActorEmail)
+	}
+	return t.print(w)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A localeFormat is how one locale writes dates, times, and numbers. Go's
+// time package only knows English month names, so every layout is numeric
+// except the default's.
+type localeFormat struct {
+	// Date is the layout for a full date.
+	Date string
+	// ShortDate is the layout for a month and day.
+	ShortDate string
+	// Clock is the layout for a time of day, with seconds.
+	Clock string
+	// ClockMinutes is the layout for a time of day, without seconds.
+	ClockMinutes string
+	// Thousands separates groups of digits in large numbers.
+	Thousands string
+}
+
+// defaultLocale is used for the C and POSIX locales, and any locale we
+// don't know about.
+var defaultLocale = &localeFormat{
+	Date:         "2006-01-02",
+	ShortDate:    "Jan 2",
+	Clock:        "15:04:05",
+	ClockMinutes: "15:04",
+	Thousands:    ",",
+}
+
+// locales are the formats we know, by locale name or language.
+var locales = map[string]*localeFormat{
+	"en_US": {Date: "01/02/2006", ShortDate: "Jan 2", Clock: "3:04:05 PM", ClockMinutes: "3:04 PM", Thousands: ","},
+	"en_GB": {Date: "02/01/2006", ShortDate: "2 Jan", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: ","},
+	"en_AU": {Date: "02/01/2006", ShortDate: "2 Jan", Clock: "3:04:05 PM", ClockMinutes: "3:04 PM", Thousands: ","},
+	"en_CA": {Date: "2006-01-02", ShortDate: "Jan 2", Clock: "3:04:05 PM", ClockMinutes: "3:04 PM", Thousands: ","},
+	"en":    {Date: "2006-01-02", ShortDate: "Jan 2", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: ","},
+	"de":    {Date: "02.01.2006", ShortDate: "02.01.", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: "."},
+	"fr":    {Date: "02/01/2006", ShortDate: "02/01", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: " "},
+	"es":    {Date: "02/01/2006", ShortDate: "02/01", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: "."},
+	"it":    {Date: "02/01/2006", ShortDate: "02/01", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: "."},
+	"nl":    {Date: "02-01-2006", ShortDate: "02-01", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: "."},
+	"pt":    {Date: "02/01/2006", ShortDate: "02/01", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: "."},
+	"sv":    {Date: "2006-01-02", ShortDate: "01-02", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: " "},
+	"ja":    {Date: "2006/01/02", ShortDate: "01/02", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: ","},
+	"zh":    {Date: "2006/01/02", ShortDate: "01/02", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: ","},
+	"ko":    {Date: "2006. 01. 02.", ShortDate: "01. 02.", Clock: "15:04:05", ClockMinutes: "15:04", Thousands: ","},
+}
+
+// userLocale is set from Config.Locale, or the environment, when heroku-ci
+// starts.
+var userLocale = defaultLocale
+
+// lookupLocale returns the format for a locale name like "de_DE.UTF-8". The
+// empty name uses $LC_ALL, $LC_TIME, or $LANG, like other Unix programs.
+func lookupLocale(name string) *localeFormat {
+	if name == "" {
+		for _, key := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+			if name = os.Getenv(key); name != "" {
+				break
+			}
+		}
+	}
+	if i := strings.IndexAny(name, ".@"); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.Replace(name, "-", "_", 1)
+	if f, ok := locales[name]; ok {
+		return f
+	}
+	if i := strings.Index(name, "_"); i >= 0 {
+		if f, ok := locales[strings.ToLower(name[:i])]; ok {
+			return f
+		}
+	}
+	if f, ok := locales[strings.ToLower(name)]; ok {
+		return f
+	}
+	return defaultLocale
+}
+
+// fmtDateTime formats t as a local date and time of day.
+func fmtDateTime(t time.Time) string {
+	return t.Local().Format(userLocale.Date + " " + userLocale.Clock)
+}
+
+// fmtDateTimeMinutes is fmtDateTime without the seconds.
+func fmtDateTimeMinutes(t time.Time) string {
+	return t.Local().Format(userLocale.Date + " " + userLocale.ClockMinutes)
+}
+
+// fmtShortDate formats t as a local month and day.
+func fmtShortDate(t time.Time) string {
+	return t.Local().Format(userLocale.ShortDate)
+}
+
+// fmtClock formats t as a local time of day.
+func fmtClock(t time.Time) string {
+	return t.Local().Format(userLocale.Clock)
+}
+
+// fmtInt formats n with the locale's thousands separator.
+func fmtInt(n int) string {
+	s := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(userLocale.Thousands)
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String()
+}
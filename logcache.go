@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The default for logCacheSize.
+const defaultLogCacheSize = 256 << 20
+
+// logCacheSize is the most disk space the output of finished runs may take
+// up in the log cache, set from Config.LogCacheSize. Zero turns the cache
+// off. The least recently read logs are evicted first.
+var logCacheSize int64 = defaultLogCacheSize
+
+func logCacheEnabled() bool {
+	return !cacheDisabled && logCacheSize > 0
+}
+
+func logCachePath(run *TestRun) (string, error) {
+	dir, err := logCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, run.ID.String()+".log"), nil
+}
+
+// cachedRunLog returns the cached output of run, or nil if it isn't cached.
+// A finished run's output never changes, so a cached copy is always good.
+func cachedRunLog(run *TestRun) *runLog {
+	if !logCacheEnabled() || !run.Status.Terminal() {
+		return nil
+	}
+	path, err := logCachePath(run)
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil
+	}
+	s := &logSpool{file: f, written: fi.Size()}
+	if err := s.each(func(line string) error {
+		s.keepTail(line)
+		return nil
+	}); err != nil {
+		f.Close()
+		return nil
+	}
+	// Eviction goes by modification time, so mark it as recently used.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return &runLog{parts: []*logSpool{s}}
+}
+
+// storeRunLog saves the output of a finished run in the log cache, then
+// evicts old logs until the cache fits in logCacheSize. Failures only mean
+// a cache miss next time, so they are ignored.
+func storeRunLog(run *TestRun, out *runLog) {
+	if !logCacheEnabled() || !run.Status.Terminal() {
+		return
+	}
+	path, err := logCachePath(run)
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(f)
+	err = out.Each(func(line string) error {
+		_, err := w.WriteString(line + "\n")
+		return err
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	evictLogCache(dir, logCacheSize)
+}
+
+// evictLogCache removes the least recently used logs in dir until the rest
+// take up no more than max bytes.
+func evictLogCache(dir string, max int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		path string
+		size int64
+		used time.Time
+	}
+	logs := make([]cached, 0, len(entries))
+	var total int64
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".log") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		logs = append(logs, cached{filepath.Join(dir, e.Name()), fi.Size(), fi.ModTime()})
+		total += fi.Size()
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i].used.Before(logs[j].used) })
+	for _, l := range logs {
+		if total <= max {
+			break
+		}
+		if os.Remove(l.path) == nil {
+			total -= l.size
+		}
+	}
+}
+
+// parseByteSize parses a size like "256MB", "1.5GB", or "4096".
+func parseByteSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	mult := 1.0
+	for _, u := range []struct {
+		suffix string
+		mult   float64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.mult
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("%q is not a size like 256MB", size)
+	}
+	return int64(f * mult), nil
+}
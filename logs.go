@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// How many recent runs to search for the runs to show or compare.
+const logsSearchDepth = 100
+
+// fetchRunOutput returns the test output of every node in run. Lines are
+// prefixed with the node index if the run has more than one node. If the
+// output was prefetched, it waits for that download instead of starting
+// another. Callers should Close the log when they are done with it.
+func fetchRunOutput(ctx context.Context, client *Client, run *TestRun) (*runLog, error) {
+	runOutputs.Lock()
+	o, ok := runOutputs.m[run.ID.String()]
+	runOutputs.Unlock()
+	if !ok {
+		return loadRunOutput(ctx, client, run)
+	}
+	select {
+	case <-o.done:
+		if o.err != nil {
+			return nil, o.err
+		}
+		return &runLog{parts: o.log.parts, shared: true}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runOutputs holds the output of runs that prefetchRunOutput started
+// downloading, by run ID.
+var runOutputs struct {
+	sync.Mutex
+	m map[string]*runOutput
+}
+
+type runOutput struct {
+	done chan struct{}
+	log  *runLog
+	err  error
+}
+
+// prefetchRunOutput starts downloading the output of run in the
+// background, if it hasn't already, so that the failure summary, the
+// quarantine check and notifications have it as soon as the run finishes
+// instead of each waiting on the download. Only call it for failed runs;
+// their output doesn't change, and there's rarely more than one to keep.
+func prefetchRunOutput(ctx context.Context, client *Client, run *TestRun) {
+	runOutputs.Lock()
+	defer runOutputs.Unlock()
+	if _, ok := runOutputs.m[run.ID.String()]; ok {
+		return
+	}
+	if runOutputs.m == nil {
+		runOutputs.m = make(map[string]*runOutput)
+	}
+	o := &runOutput{done: make(chan struct{})}
+	runOutputs.m[run.ID.String()] = o
+	go func() {
+		o.log, o.err = loadRunOutput(ctx, client, run)
+		if o.err != nil {
+			// Let the next caller try again.
+			runOutputs.Lock()
+			delete(runOutputs.m, run.ID.String())
+			runOutputs.Unlock()
+		}
+		close(o.done)
+	}()
+}
+
+// loadRunOutput returns the output of run from the log cache, or downloads
+// it and adds it to the cache.
+func loadRunOutput(ctx context.Context, client *Client, run *TestRun) (*runLog, error) {
+	if out := cachedRunLog(run); out != nil {
+		return out, nil
+	}
+	out, err := downloadRunOutput(ctx, client, run)
+	if err != nil {
+		return nil, err
+	}
+	storeRunLog(run, out)
+	return out, nil
+}
+
+// downloadRunOutput fetches the output of each of run's nodes at once, and
+// returns it in node order.
+func downloadRunOutput(ctx context.Context, client *Client, run *TestRun) (*runLog, error) {
+	nodes, err := getTestNodes(ctx, client, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := &runLog{parts: make([]*logSpool, len(nodes))}
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		out.parts[i] = new(logSpool)
+		if node.OutputStreamURL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, node *TestNode) {
+			defer wg.Done()
+			errs[i] = downloadNodeOutput(ctx, node, len(nodes) > 1, out.parts[i])
+		}(i, node)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// downloadNodeOutput adds the lines of node's output to s as they arrive,
+// prefixed with its index if prefix is true.
+func downloadNodeOutput(ctx context.Context, node *TestNode, prefix bool, s *logSpool) error {
+	req, err := http.NewRequest("GET", node.OutputStreamURL, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("output for node %d: unexpected status %d", node.Index, resp.StatusCode)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLine)
+	for scanner.Scan() {
+		line := redact.String(scanner.Text())
+		if prefix {
+			line = fmt.Sprintf("node %d: %s", node.Index, line)
+		}
+		if err := s.add(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return s.finish()
+}
+
+// latestRunOn returns the newest run in runs (newest first) on branch whose
+// status satisfies ok, created before the run numbered before, or nil if
+// there isn't one. before of 0 means any run.
+func latestRunOn(runs []*TestRun, branch string, before int, ok func(RunStatus) bool) *TestRun {
+	for _, run := range runs {
+		if run.CommitBranch != branch || (before > 0 && run.Number >= before) {
+			continue
+		}
+		if ok(run.Status) {
+			return run
+		}
+	}
+	return nil
+}
+
+func isFailing(s RunStatus) bool { return s.Failed() && s != StatusCancelled }
+
+func isPassing(s RunStatus) bool { return s == StatusSucceeded }
+
+// printLogs prints the output of the run named by ref, or if ref is empty
+// the latest finished run on branch.
+func printLogs(ctx context.Context, client *Client, id types.PrefixUUID, branch, ref string, w io.Writer) error {
+	var run *TestRun
+	if ref != "" {
+		var err error
+		if run, err = findRun(ctx, client, id, branch, ref); err != nil {
+			return err
+		}
+	} else {
+		runs, err := recentTestRuns(ctx, client, id, logsSearchDepth)
+		if err != nil {
+			return err
+		}
+		run = latestRunOn(runs, branch, 0, RunStatus.Terminal)
+		if run == nil {
+			return notFoundf("no finished test runs on %s", branch)
+		}
+	}
+	out, err := fetchRunOutput(ctx, client, run)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return out.Each(func(line string) error {
+		_, err := fmt.Fprintln(w, line)
+		return err
+	})
+}
+
+// Lines that start a test framework's summary of the run.
+var summaryStart = regexp.MustCompile(`(?i)^(?:node \d+: )?\s*(?:failures:|failed tests|failing tests|summary of failures|short test summary|=+ *failures *=+|finished in |ran \d+ tests?|tests? (?:run|results)|\d+ (?:examples?|tests?|specs?)[ ,]|--- fail|fail\s|ok\s+\S+\s)`)
+
+// Noise that changes between otherwise identical runs: ANSI colors,
+// durations, and timestamps.
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	durations  = regexp.MustCompile(`\b\d+(?:\.\d+)?\s?(?:ms|s|sec|seconds|m|min|minutes)\b`)
+	timestamps = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?\b`)
+)
+
+// The most lines of a summary we compare, counting from the end.
+const maxSummaryLines = 200
+
+// summarySection returns the test summary at the end of a run's output,
+// with noise removed: the lines from the first summary heading within the
+// last maxSummaryLines lines, or just the last maxSummaryLines if there is
+// no heading.
+func summarySection(lines []string) []string {
+	if len(lines) > maxSummaryLines {
+		lines = lines[len(lines)-maxSummaryLines:]
+	}
+	start := 0
+	for i, line := range lines {
+		if summaryStart.MatchString(ansiEscape.ReplaceAllString(line, "")) {
+			start = i
+			break
+		}
+	}
+	out := make([]string, 0, len(lines)-start)
+	for _, line := range lines[start:] {
+		line = ansiEscape.ReplaceAllString(line, "")
+		line = timestamps.ReplaceAllString(line, "<time>")
+		line = durations.ReplaceAllString(line, "<duration>")
+		out = append(out, strings.TrimRight(line, " \t\r"))
+	}
+	return out
+}
+
+// logsDiff compares the test summary of the latest failing run on branch to
+// that of the latest passing run before it, on the same branch if there is
+// one and on any branch otherwise.
+func logsDiff(ctx context.Context, client *Client, id types.PrefixUUID, branch string, w io.Writer) error {
+	runs, err := recentTestRuns(ctx, client, id, logsSearchDepth)
+	if err != nil {
+		return err
+	}
+	failing := latestRunOn(runs, branch, 0, isFailing)
+	if failing == nil {
+		return notFoundf("no failing test runs on %s in the last %d runs", branch, logsSearchDepth)
+	}
+	passing := latestRunOn(runs, branch, failing.Number, isPassing)
+	if passing == nil {
+		for _, run := range runs {
+			if run.Number < failing.Number && isPassing(run.Status) {
+				passing = run
+				break
+			}
+		}
+	}
+	if passing == nil {
+		return notFoundf("no passing test run before run #%d to compare it to", failing.Number)
+	}
+	fmt.Fprintf(os.Stderr, "comparing run #%d (%s, %s on %s) to run #%d (%s on %s)\n",
+		failing.Number, shortSHA(failing.CommitSHA), failing.Status, failing.CommitBranch,
+		passing.Number, shortSHA(passing.CommitSHA), passing.CommitBranch)
+	passingOut, err := fetchRunOutput(ctx, client, passing)
+	if err != nil {
+		return err
+	}
+	defer passingOut.Close()
+	failingOut, err := fetchRunOutput(ctx, client, failing)
+	if err != nil {
+		return err
+	}
+	defer failingOut.Close()
+	diff := unifiedDiff(summarySection(passingOut.Tail()), summarySection(failingOut.Tail()),
+		fmt.Sprintf("run #%d (%s)", passing.Number, passing.Status),
+		fmt.Sprintf("run #%d (%s)", failing.Number, failing.Status), 3)
+	if diff == "" {
+		fmt.Fprintln(w, "The test summaries are the same.")
+		return nil
+	}
+	_, err = io.WriteString(w, diff)
+	return err
+}
+
+// unifiedDiff returns a unified diff of a and b with the given lines of
+// context, or the empty string if they are the same.
+func unifiedDiff(a, b []string, nameA, nameB string, context int) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	type edit struct {
+		op   byte // ' ', '-' or '+'
+		line string
+		i, j int // line numbers in a and b before this edit
+	}
+	edits := make([]edit, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j], i, j})
+			j++
+		}
+	}
+	var buf strings.Builder
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+		// Grow the hunk until there are more than 2*context unchanged
+		// lines in a row.
+		start := k - context
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == ' ' {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		countA, countB := 0, 0
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				countA++
+			}
+			if e.op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", edits[start].i+1, countA, edits[start].j+1, countB)
+		for _, e := range edits[start:end] {
+			buf.WriteByte(e.op)
+			buf.WriteString(e.line)
+			buf.WriteByte('\n')
+		}
+		k = end
+	}
+	return buf.String()
+}
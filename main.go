@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -10,81 +11,274 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/bgentry/go-netrc/netrc"
 	git "github.com/kevinburke/go-git"
 	types "github.com/kevinburke/go-types"
+	herokuci "github.com/kevinburke/heroku-ci/lib"
 	"github.com/kevinburke/rest"
-	"github.com/knq/ini"
 )
 
 const Version = "0.1"
 
+// A Client talks to the Heroku API. Requests are sent by the embedded
+// herokuci.Client, through Do, so the herokuci methods get the cache,
+// circuit breaker and pacing too.
 type Client struct {
-	*rest.Client
+	*herokuci.Client
+	// ID is the account's email, for audit entries and permission checks.
+	// It is empty if it couldn't be looked up.
+	ID      string
+	cache   *responseCache
+	breaker circuitBreaker
+	// limiter paces requests so many watchers in one process stay within
+	// Heroku's rate limit; see apiLimits.
+	limiter *requestLimiter
+	// failFast returns errors that mean we're offline right away, instead of
+	// waiting for the network to come back, for commands that can fall back
+	// to local data.
+	failFast bool
+	// auth, if set, refreshes an expired OAuth token; see tokenRefresher.
+	auth *tokenRefresher
 }
 
+// NewRequest returns a request for path on the Heroku API. Callers set its
+// context.
 func (c *Client) NewRequest(method, path string, body io.Reader) (*http.Request, error) {
-	req, err := c.Client.NewRequest(method, path, body)
-	if err != nil {
-		return nil, err
+	return c.Client.NewRequest(context.Background(), method, path, body)
+}
+
+// Do performs the request. A 403 is reported as a ScopeError naming the
+// OAuth scope the request needs. Any successful write clears the response
+// cache, since we can't tell which cached responses it made stale. Writes
+// are refused in read-only mode.
+//
+// Reads that fail because the API is down are retried, and once too many
+// requests fail in a row every request waits for the API to come back; see
+// circuitBreaker. Writes are never retried, since they may have gone through,
+// except after a 429, which means Heroku didn't process the request.
+func (c *Client) Do(r *http.Request, v interface{}) error {
+	write := r.Method != "GET" && r.Method != "HEAD"
+	if write {
+		if err := checkWritable(r.Method + " " + r.URL.Path); err != nil {
+			return err
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.wait(r.Context(), c.probe); err != nil {
+			return err
+		}
+		release, err := c.limiter.wait(r.Context())
+		if err != nil {
+			return err
+		}
+		err = c.do(r, v)
+		release()
+		if c.failFast && isOffline(err) {
+			return err
+		}
+		if d, ok := c.rateLimited(r, err, attempt); ok {
+			warnf("Heroku API rate limit reached, retrying in %s", d.Round(time.Second))
+			select {
+			case <-r.Context().Done():
+				return r.Context().Err()
+			case <-time.After(d):
+			}
+			continue
+		}
+		if !c.breaker.record(r.Context(), err) || write || attempt == c.Retries() {
+			return err
+		}
 	}
-	req.Header.Set("Accept", "application/vnd.heroku+json; version=3")
-	return req, nil
 }
 
-func getPipeline() string {
-	// try to get the root
-	root, err := git.Root("")
+// rateLimited reports whether r should be sent again after it failed with a
+// 429 on its attempt'th try, and how long to wait first.
+func (c *Client) rateLimited(r *http.Request, err error, attempt int) (time.Duration, bool) {
+	var herr *HerokuError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusTooManyRequests || attempt >= rateLimitAttempts {
+		return 0, false
+	}
+	if r.Body != nil {
+		if r.GetBody == nil {
+			return 0, false
+		}
+		body, err := r.GetBody()
+		if err != nil {
+			return 0, false
+		}
+		r.Body = body
+	}
+	return rateLimitBackoff(attempt, herr.RetryAfter), true
+}
+
+// probe checks whether the API is up with a cheap request.
+func (c *Client) probe(ctx context.Context) error {
+	req, err := c.NewRequest("GET", "/account", nil)
 	if err != nil {
-		return ""
+		return err
+	}
+	req = req.WithContext(ctx)
+	var account struct {
+		ID string `json:"id"`
+	}
+	return c.do(req, &account)
+}
+
+func (c *Client) do(r *http.Request, v interface{}) error {
+	err := c.Client.Send(r, v)
+	if err != nil && c.retryUnauthorized(r, err) {
+		err = c.Client.Send(r, v)
 	}
-	f, err := os.Open(filepath.Join(root, ".git", "config"))
 	if err != nil {
-		return ""
+		return scopeError(r, err)
+	}
+	if c.cache != nil && r.Method != "GET" && r.Method != "HEAD" {
+		c.cache.clear()
+	}
+	return nil
+}
+
+func getPipeline() string {
+	if pipelineFlag != "" {
+		return pipelineFlag
+	}
+	if demoMode {
+		return demoPipelineName
 	}
-	file, err := ini.Load(f)
+	if name := gitConfig("heroku.pipeline"); name != "" {
+		return name
+	}
+	return repoPipeline()
+}
+
+// newClient returns a Client authenticated with the first Heroku credentials
+// herokuClient finds.
+func newClient() (*Client, error) {
+	if demoURL != "" {
+		return demoClient(demoURL), nil
+	}
+	return herokuClient()
+}
+
+// The API types are shared with the herokuci package, which other programs
+// can use to talk to Heroku CI.
+type (
+	Pipeline = herokuci.Pipeline
+	TestRun  = herokuci.TestRun
+	TestNode = herokuci.TestNode
+)
+
+// getTestNodes returns the nodes for the test run with the given ID.
+func getTestNodes(ctx context.Context, client *Client, runID types.PrefixUUID) ([]*TestNode, error) {
+	req, err := client.NewRequest("GET", "/test-runs/"+runID.String()+"/test-nodes", nil)
 	if err != nil {
-		return ""
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	nodes := make([]*TestNode, 0)
+	if err := client.Do(req, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// resolvePipelineID returns the ID of the pipeline to operate on. If id is
+// nonempty it is used as is, skipping both git config detection and the
+// /pipelines lookup. Otherwise we look up the pipeline named in git config.
+func resolvePipelineID(ctx context.Context, client *Client, id string) (types.PrefixUUID, error) {
+	if id != "" {
+		pid, err := types.NewPrefixUUID(id)
+		if err != nil {
+			return types.PrefixUUID{}, fmt.Errorf("invalid pipeline ID %q: %v", id, err)
+		}
+		return pid, nil
+	}
+	name := getPipeline()
+	if name == "" {
+		return types.PrefixUUID{}, noPipelineError(ctx, client)
 	}
-	section := file.GetSection("heroku")
-	if section == nil {
-		return ""
+	pipeline, err := findPipelineByName(ctx, client, name)
+	if nf, ok := err.(*pipelineNotFoundError); ok && nf.Other != nil {
+		// Carry on as the account that can see the pipeline.
+		warnf("%s can't see pipeline %s; using %s (.netrc machine %s)", nf.Account, nf.Name, nf.Other.ID, nf.Machine)
+		client.Client, client.ID = nf.Other.Client, nf.Other.ID
+		pipeline, err = findPipelineByName(ctx, client, nf.Name)
+	}
+	if err != nil {
+		return types.PrefixUUID{}, err
 	}
-	return section.Get("pipeline")
+	return pipeline.ID, nil
 }
 
-type Pipeline struct {
-	CreatedAt time.Time        `json:"created_at"`
-	ID        types.PrefixUUID `json:"id"`
-	Name      string           `json:"name"`
-	UpdatedAt time.Time        `json:"updated_at"`
+// findPipelineByName returns the pipeline with the given name. If client
+// can't see it, the error is a *pipelineNotFoundError.
+func findPipelineByName(ctx context.Context, client *Client, name string) (*Pipeline, error) {
+	p, err := lookupPipeline(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		nf := findPipelineInOtherAccounts(ctx, client, name)
+		if nf.Other == nil {
+			nf.Similar, nf.Close = similarPipelines(ctx, client, name)
+		}
+		return nil, nf
+	}
+	return p, nil
 }
 
-type TestRun struct {
-	CreatedAt     time.Time        `json:"created_at"`
-	ID            types.PrefixUUID `json:"id"`
-	UpdatedAt     time.Time        `json:"updated_at"`
-	ClearCache    bool             `json:"clear_cache"`
-	CommitBranch  string           `json:"commit_branch"`
-	CommitSHA     string           `json:"commit_sha"`
-	CommitMessage string           `json:"commit_message"`
-	Status        string           `json:"status"`
+// listPipelines returns every pipeline client can see. Like
+// herokuci.Client.Pipelines, it asks for a page at a time, but caches each one.
+func listPipelines(ctx context.Context, client *Client) ([]*Pipeline, error) {
+	all := make([]*Pipeline, 0)
+	rangeHeader := fmt.Sprintf("id ..; max=%d", herokuci.MaxPageSize)
+	for {
+		req, err := client.NewRequest("GET", "/pipelines", nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Range", rangeHeader)
+		page := make([]*Pipeline, 0)
+		if err := client.DoCached(req, &page, pipelinesCacheTTL); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < herokuci.MaxPageSize {
+			return all, nil
+		}
+		rangeHeader = fmt.Sprintf("id ]%s..; max=%d", page[len(page)-1].ID.String(), herokuci.MaxPageSize)
+	}
 }
 
-func (t TestRun) InProgress() bool {
-	return t.Status != "succeeded" && t.Status != "failed" && t.Status != "errored"
+// lookupPipeline returns the pipeline with the given name, or nil if client
+// can't see one.
+func lookupPipeline(ctx context.Context, client *Client, name string) (*Pipeline, error) {
+	pipelineBody, err := listPipelines(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pipelineBody {
+		if pipelineBody[i].Name == name {
+			return pipelineBody[i], nil
+		}
+	}
+	return nil, nil
 }
 
 // Given a set of command line args, return the git branch or an error. Returns
 // the current git branch if no argument is specified
 func getBranchFromArgs(args []string) (string, error) {
 	if len(args) == 0 {
-		return git.CurrentBranch()
+		if demoMode {
+			return demoBranch, nil
+		}
+		return currentBranch()
 	} else {
-		return args[0], nil
+		return normalizeBranch(args[0]), nil
 	}
 }
 
@@ -97,75 +291,422 @@ func getMinTipLength(remoteTip string, localTip string) int {
 	return len(localTip)
 }
 
-func getTestRuns(ctx context.Context, client *Client, id types.PrefixUUID, args []string) error {
+// waitOptions control what wait does around the Heroku CI run.
+type waitOptions struct {
+	// Deploys reports which apps started deploying the commit after a
+	// successful run.
+	Deploys bool
+	// AllChecks waits for every GitHub status and check run on the commit
+	// to pass, in addition to the Heroku CI run.
+	AllChecks bool
+	// TagOnSuccess, if set, is the name of an annotated tag to create and
+	// push once the run (and checks) succeed.
+	TagOnSuccess string
+	// GitHubRelease creates a GitHub release for TagOnSuccess.
+	GitHubRelease bool
+	// WarningsAsErrors fails the wait if buildpacks printed warnings during
+	// setup.
+	WarningsAsErrors bool
+	// MatchEquivalent looks for a successful run of an identical change,
+	// like the same commit before a rebase, and offers to use its result.
+	MatchEquivalent bool
+	// Component, if set, limits the setup output we print to lines that
+	// belong to one component of a monorepo.
+	Component *Component
+	// FollowBranch never exits: it follows the newest run on the branch,
+	// and after each run finishes waits for the next push.
+	FollowBranch bool
+	// ResultFile, if set, is where to write a JSON description of the
+	// finished run.
+	ResultFile string
+	// ExportEnv, if set, is where to write the finished run as shell
+	// variable assignments.
+	ExportEnv string
+	// UntilMergeable, after a successful run, waits until the branch's pull
+	// request can be merged, with the approvals branch protection requires
+	// and every label in RequireLabels.
+	UntilMergeable bool
+	RequireLabels  []string
+	// Query, if set, prints fields of the finished run's result to
+	// QueryOut.
+	Query    *resultQuery
+	QueryOut io.Writer
+	// FailOnFailure returns a *runFailedError if the run doesn't succeed,
+	// so the command exits with the code for the run's status.
+	FailOnFailure bool
+	// Hints lists the failing tests and the files changed since the last
+	// passing run, if the run fails.
+	Hints bool
+	// JSONOut, if set, is where to print the finished run as JSON.
+	JSONOut io.Writer
+}
+
+// getTestRuns waits for the test run for the branch named in args.
+func getTestRuns(ctx context.Context, client *Client, id types.PrefixUUID, args []string, opts waitOptions) error {
 	branch, err := getBranchFromArgs(args)
 	if err != nil {
 		return err
 	}
-	remote, err := git.GetRemoteURL("origin")
-	if err != nil {
-		return err
+	// In demo mode there may be no checkout, so wait for the newest run.
+	var tip string
+	bw := newBranchWatch(branch, false)
+	if demoMode {
+		bw = nil
+	} else {
+		remote, err := git.GetRemoteURL("origin")
+		if err != nil {
+			return err
+		}
+		_ = remote
+		if tip, err = git.Tip(localRef(branch)); err != nil {
+			return err
+		}
 	}
-	_ = remote
-	tip, err := git.Tip(branch)
+	if opts.MatchEquivalent && !opts.FollowBranch {
+		sha, err := fullSHA(localRef(branch))
+		if err != nil {
+			return err
+		}
+		if run := offerEquivalentRun(ctx, client, id, sha); run != nil {
+			return finishWait(ctx, client, id, run, nil, opts)
+		}
+	}
+	foundRun, err := findTestRun(ctx, client, id, herokuBranch(ctx, branch), tip)
 	if err != nil {
 		return err
 	}
-	req, err := client.NewRequest("GET", "/pipelines/"+id.String()+"/test-runs", nil)
+	if opts.FollowBranch {
+		return followBranch(ctx, client, id, branch, foundRun, opts)
+	}
+	foundRun, warnings, err := waitForTestRun(ctx, client, id, foundRun, "", bw)
 	if err != nil {
 		return err
 	}
-	req = req.WithContext(ctx)
-	runs := make([]*TestRun, 0)
-	if err := client.Do(req, &runs); err != nil {
-		return err
+	return finishWait(ctx, client, id, foundRun, warnings, opts)
+}
+
+// finishWait reports on a finished run and does whatever opts asks for after
+// a successful run.
+func finishWait(ctx context.Context, client *Client, id types.PrefixUUID, foundRun *TestRun, warnings []string, opts waitOptions) error {
+	fmt.Printf("Test run %q completed after %s with status %s! Exiting.\n", foundRun.ID.String()[:8], foundRun.Duration(), foundRun.Status)
+	if opts.JSONOut != nil {
+		if err := printRunJSON(opts.JSONOut, foundRun); err != nil {
+			return err
+		}
 	}
-	var foundRun *TestRun
-	for i := range runs {
-		if runs[i].CommitBranch != branch {
-			continue
+	var hint *failureHint
+	if opts.Hints && isFailing(foundRun.Status) {
+		var err error
+		hint, err = runFailureHint(ctx, client, id, foundRun)
+		if err != nil {
+			warnf("could not look for the cause of the failure: %v", err)
 		}
-		maxTipLengthToCompare := getMinTipLength(runs[i].CommitSHA, tip)
-		if runs[i].CommitSHA[:maxTipLengthToCompare] == tip[:maxTipLengthToCompare] {
-			foundRun = runs[i]
-			break
+	}
+	if opts.ResultFile != "" || opts.ExportEnv != "" || opts.Query != nil {
+		res := newRunResult(ctx, client, id, foundRun, warnings)
+		if hint != nil {
+			res.FailingTests = hint.Tests
+			res.ChangedFiles = hint.Files
+			res.Owners = hint.Owners
+		}
+		if opts.Query != nil {
+			if err := opts.Query.Print(opts.QueryOut, res); err != nil {
+				return err
+			}
+		}
+		if opts.ResultFile != "" {
+			if err := writeResultFile(opts.ResultFile, res); err != nil {
+				return err
+			}
+		}
+		if opts.ExportEnv != "" {
+			if err := writeEnvFile(opts.ExportEnv, res); err != nil {
+				return err
+			}
 		}
 	}
-	if foundRun == nil {
-		return fmt.Errorf("Could not find test run for commit %s\n", tip[:8])
+	if len(warnings) > 0 {
+		scoped, hidden := scopeLines(opts.Component, warnings)
+		fmt.Printf("\nThe buildpacks printed %d warnings during setup:\n", len(warnings))
+		for _, w := range scoped {
+			fmt.Printf("  %s\n", redact.String(w))
+		}
+		if hidden > 0 {
+			fmt.Printf("  (%d more outside the %s component; pass --component=all to see them)\n", hidden, opts.Component.Name)
+		}
+		if opts.WarningsAsErrors {
+			return checkFailedf("setup printed %d warnings", len(warnings))
+		}
 	}
-	count := 0
-	for foundRun.InProgress() {
-		dur := time.Since(foundRun.CreatedAt)
-		if dur > time.Minute {
-			dur = dur.Round(time.Second)
-		} else {
-			dur = dur.Round(10 * time.Millisecond)
+	if hint != nil {
+		hint.print(os.Stdout)
+	}
+	if foundRun.Status != StatusSucceeded {
+		if !opts.FailOnFailure {
+			return nil
 		}
-		if count%5 == 0 {
-			fmt.Printf("status is %q, running for %s, sleeping...\n", foundRun.Status, dur)
+		if tests := quarantinedFailures(ctx, client, foundRun); tests != nil {
+			fmt.Printf("Only quarantined tests failed: %s\n", strings.Join(tests, ", "))
+			return &quarantinedError{foundRun, tests}
 		}
-		count++
-		time.Sleep(2 * time.Second)
-		req, err := client.NewRequest("GET", "/test-runs/"+foundRun.ID.String(), nil)
+		return &runFailedError{foundRun}
+	}
+	if opts.AllChecks {
+		gh, err := newGitHubClient()
 		if err != nil {
 			return err
 		}
-		req = req.WithContext(ctx)
-		if err := client.Do(req, &foundRun); err != nil {
+		owner, repo, err := githubRepo()
+		if err != nil {
+			return err
+		}
+		if err := waitForGitHubChecks(ctx, gh, owner, repo, foundRun.CommitSHA); err != nil {
 			return err
 		}
 	}
-	dur := foundRun.UpdatedAt.Sub(foundRun.CreatedAt)
-	if dur > time.Minute {
-		dur = dur.Round(time.Second)
-	} else {
-		dur = dur.Round(10 * time.Millisecond)
+	if opts.UntilMergeable {
+		if err := waitUntilMergeable(ctx, client, id, foundRun, opts.RequireLabels); err != nil {
+			return err
+		}
+	}
+	if opts.TagOnSuccess != "" {
+		if err := tagOnSuccess(ctx, opts.TagOnSuccess, foundRun, opts.GitHubRelease); err != nil {
+			return err
+		}
+	}
+	if opts.Deploys {
+		if err := reportDeploys(ctx, client, id, foundRun); err != nil {
+			warnf("could not check for deploys: %v", err)
+		}
+	}
+	return nil
+}
+
+// How many recent runs findTestRun looks through, two pages' worth.
+const findRunDepth = 2 * herokuci.MaxPageSize
+
+// findTestRun returns the test run for the given branch and commit. If sha is
+// empty, findTestRun returns the most recently created run on the branch.
+func findTestRun(ctx context.Context, client *Client, id types.PrefixUUID, branch, sha string) (*TestRun, error) {
+	var foundRun *TestRun
+	// Walk back from the newest run, so a run started moments ago is found
+	// however long the pipeline's history is.
+	err := client.WalkTestRuns(ctx, id, herokuci.WalkOptions{Limit: findRunDepth, NewestFirst: true, Workers: 1}, func(page []*TestRun) error {
+		for i := range page {
+			if page[i].CommitBranch != branch {
+				continue
+			}
+			if sha == "" {
+				foundRun = page[i]
+				return herokuci.ErrStopWalk
+			}
+			maxTipLengthToCompare := getMinTipLength(page[i].CommitSHA, sha)
+			if page[i].CommitSHA[:maxTipLengthToCompare] == sha[:maxTipLengthToCompare] {
+				foundRun = page[i]
+				return herokuci.ErrStopWalk
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if foundRun == nil {
+		if sha == "" {
+			return nil, notFoundf("Could not find test run for branch %s\n", branch)
+		}
+		return nil, notFoundf("Could not find test run for commit %s\n", shortSHA(sha))
+	}
+	return foundRun, nil
+}
+
+// progressRank orders the in-progress statuses a run moves through. A run
+// whose status moves to a lower rank was restarted by Heroku.
+var progressRank = map[RunStatus]int{
+	StatusPending:   0,
+	StatusCreating:  1,
+	StatusBuilding:  2,
+	StatusRunning:   3,
+	StatusDebugging: 3,
+}
+
+const (
+	// How often to print the run's status while waiting.
+	statusInterval = 10 * time.Second
+	// How often to check for newer runs on the branch.
+	newerRunCheckInterval = 30 * time.Second
+)
+
+// newerRunForCommit returns the newest run in runs (which are newest first)
+// for the same branch and commit as run that was created after it, or nil if
+// there isn't one. Heroku creates a new run when a run is restarted or re-run.
+func newerRunForCommit(runs []*TestRun, run *TestRun) *TestRun {
+	for _, r := range runs {
+		if r.Number > run.Number && r.CommitBranch == run.CommitBranch && r.CommitSHA == run.CommitSHA {
+			return r
+		}
 	}
-	fmt.Printf("Test run %q completed after %s with status %s! Exiting.\n", foundRun.ID.String()[:8], dur, foundRun.Status)
 	return nil
 }
 
+// waitForTestRun polls run until it finishes, and returns the finished run
+// along with any buildpack warnings printed during setup. Progress messages
+// are printed to stdout, starting with prefix. While the run's node streams
+// are open it polls every 10 seconds and again as soon as a stream closes;
+// otherwise it polls every 2 seconds.
+//
+// If Heroku restarts the run, or starts a new run for the same commit, the
+// restart is reported and waitForTestRun follows the current run. If bw is
+// not nil, pushes to the branch during the wait are reported too.
+func waitForTestRun(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun, prefix string, bw *branchWatch) (*TestRun, []string, error) {
+	j := newJournal(id)
+	j.observe(run)
+	watchCtx, cancelWatch := context.WithCancel(ctx)
+	defer cancelWatch()
+	stopSetup := func() {}
+	// streams tells us when the run's node streams close, so we can poll
+	// less often in between.
+	var streams *streamWatch
+	// drainTail waits for the end of the output wait --tail is printing.
+	drainTail := func() {}
+	startSetup := func(run *TestRun, live bool) *setupWatch {
+		stopSetup()
+		setupCtx, cancel := context.WithCancel(watchCtx)
+		stopSetup = cancel
+		streams = watchStreams(setupCtx, client, run)
+		drainTail = startTail(setupCtx, client, run, prefix)
+		return watchSetup(setupCtx, client, id, run, live)
+	}
+	// We can only time setup phases if we watch them as they happen.
+	setup := startSetup(run, run.Status.Setup())
+	nodes := newNodeWatch(run)
+	// queued is how long the run waited for a dyno, if we saw it start.
+	var queued time.Duration
+	count := 0
+	var lastStatus time.Time
+	lastCheck := time.Now()
+	// After a stream closes, poll quickly a few times in case the API
+	// hasn't caught up with the new status yet.
+	quick := 0
+	// idle counts the polls in a row that saw no change, to back off while
+	// the run sits in one status with no stream open.
+	idle := 0
+	interval := statusInterval
+	if accessible {
+		interval = accessibleStatusInterval
+		announceStatus(os.Stdout, prefix, run)
+		lastStatus = time.Now()
+	}
+	for run.InProgress() {
+		if time.Since(lastStatus) >= interval {
+			if accessible {
+				announceStillGoing(os.Stdout, prefix, run)
+			} else if !tailOutput {
+				// With --tail, the run's own output shows it's still going.
+				fmt.Printf("%sstatus is %q, running for %s, sleeping...\n", prefix, run.Status, roundDuration(time.Since(run.CreatedAt)))
+			}
+			emitProgress("waiting", id, run, "")
+			lastStatus = time.Now()
+		}
+		count++
+		wait := streams.interval(idle)
+		if quick > 0 {
+			wait = pollInterval
+			quick--
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-streams.wake:
+			quick, idle = 3, 0
+		case <-time.After(jitter(wait)):
+		}
+		if time.Since(lastCheck) >= newerRunCheckInterval {
+			lastCheck = time.Now()
+			runs, err := recentTestRuns(ctx, client, id, 20)
+			if err != nil {
+				return nil, nil, err
+			}
+			newer := newerRunForCommit(runs, run)
+			if newer != nil {
+				fmt.Printf("%sa newer run #%d was started for %s, following it instead of run #%d\n", prefix, newer.Number, shortSHA(run.CommitSHA), run.Number)
+			} else if bw != nil {
+				newer = bw.check(ctx, runs, run, prefix)
+			}
+			if newer != nil {
+				run = newer
+				queued = 0
+				j.observe(run)
+				setup = startSetup(run, run.Status.Setup())
+				nodes = newNodeWatch(run)
+				idle = 0
+				continue
+			}
+		}
+		prev := run.Status
+		req, err := client.NewRequest("GET", "/test-runs/"+run.ID.String(), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		req = req.WithContext(ctx)
+		if err := client.Do(req, &run); err != nil {
+			return nil, nil, err
+		}
+		j.observe(run)
+		if run.Status == prev {
+			idle++
+		} else {
+			idle = 0
+		}
+		if run.Status == StatusRunning || run.Status == StatusDebugging || run.Status.Terminal() {
+			nodes.check(ctx, client, prefix)
+		}
+		if isFailing(run.Status) {
+			// Everything after the loop that explains the failure needs
+			// the output; start on it before the notifications go out.
+			prefetchRunOutput(ctx, client, run)
+		}
+		if queued == 0 && (prev == StatusPending || prev == StatusCreating) && run.Status != StatusPending && run.Status != StatusCreating {
+			queued = time.Since(run.CreatedAt)
+		}
+		prevRank, ok1 := progressRank[prev]
+		rank, ok2 := progressRank[run.Status]
+		if ok1 && ok2 && rank < prevRank {
+			fmt.Printf("%srun #%d was restarted: status went from %q back to %q\n", prefix, run.Number, prev, run.Status)
+		} else if accessible && run.Status != prev {
+			announceStatus(os.Stdout, prefix, run)
+		}
+	}
+	if count > 0 {
+		// Only report runs we saw finish, so the same run isn't reported
+		// every time someone checks on it.
+		sendRunMetrics(run, id.String(), queued)
+		publishRunCompleted(ctx, client, id, run)
+		notifyRunCompleted(ctx, client, id, run)
+		checkAnomalies(ctx, client, id, run)
+	}
+	emitProgress("finished", id, run, "")
+	drainTail()
+	select {
+	case <-setup.done:
+	case <-time.After(10 * time.Second):
+	}
+	return run, setup.Warnings(), nil
+}
+
+// roundDuration rounds d to a precision that is useful for display.
+func roundDuration(d time.Duration) time.Duration {
+	return herokuci.RoundDuration(d)
+}
+
+// shortSHA returns the first 8 characters of sha.
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
 const help = `The heroku-ci binary interacts with Heroku CI.
 
 Usage: 
@@ -174,6 +715,40 @@ Usage:
 
 The commands are:
 
+	access              Show who owns the pipeline and what you may do with it.
+	ack                 Stop failure notifications for a branch until it passes.
+	annotate-release    Match an app's latest release to its test run.
+	assert-green        Check that the latest run on a branch succeeded.
+	cancel              Cancel every in-progress run matching a filter.
+	check-stack         Compare the stacks of the pipeline's apps and CI.
+	couplings           List, add, or remove the apps in a pipeline.
+	exit-codes          Print what each exit code means.
+	export              Print the pipeline's test run history as JSON lines.
+	label               Attach local labels to a run, to filter search and stats.
+	list                List recent test runs, newest first.
+	logs                Print or follow a run's output, or diff a failure against a pass.
+	matrix              Start a run for each combination of config vars.
+	merge-when-green    Merge a pull request once its test run succeeds.
+	open                Open a test run on the Heroku dashboard.
+	paths               Print the location of every file heroku-ci uses.
+	predict             Say how often recent failures involved the files you changed.
+	prompt              Print the current branch's status for a shell prompt.
+	replay              Print the status timeline recorded for a past run.
+	report              Write an HTML report on a pipeline's recent runs.
+	rerun               Rerun a failed test run, or only its failed nodes.
+	review-app          Create, delete, or open the review app for a branch.
+	run                 Upload HEAD and start a test run of it, without pushing.
+	schema              Print the JSON schema for one of heroku-ci's outputs.
+	search              Find runs by commit message, author, branch, or status.
+	serve               Serve local run status and acknowledgements over HTTP.
+	setup-report        Show how long each setup phase takes over time.
+	smoke               Test HEAD on a temporary branch, then delete the branch.
+	snooze              Stop a pipeline's failure notifications for a while.
+	status              Show the latest run on a branch, or on every local branch.
+	stats               Show pass rates and durations by branch and author.
+	tidy                Suggest merged branches with green runs to delete.
+	top                 Show every run in progress, refreshing in place.
+	trigger             Start a test run for a branch.
 	version             Print the current version
 	wait                Wait for tests to finish on a branch.
 
@@ -186,63 +761,1252 @@ func usage() {
 }
 
 func main() {
-	flag.Parse()
-	args := flag.Args()
-	if len(args) < 1 {
-		usage()
-		os.Exit(2)
+	printVersion := flag.Bool("version", false, "Print the current version and exit")
+	pipelineID := flag.String("pipeline-id", "", "Pipeline ID to use for every command, instead of looking it up by name")
+	flag.StringVar(&pipelineFlag, "pipeline", "", "Name of the pipeline to use for every command, instead of the one in git config or "+pipelineConfigFile)
+	flag.StringVar(&pipelineFlag, "p", "", "Shorthand for --pipeline")
+	flag.BoolVar(&cacheDisabled, "no-cache", false, "Don't read or write cached API responses or run output")
+	flag.BoolVar(&demoMode, "demo", false, "Use made-up pipelines and runs from a fake Heroku API, to try heroku-ci without an account")
+	flag.BoolVar(&accessible, "accessible", false, "Make output easier to follow with a screen reader: no redrawing in place, and status changes announced as sentences")
+	flag.BoolVar(&warningsDisabled, "no-warnings", false, "Don't print warnings, like stale cached data or a newer release, to stderr")
+	repo := flag.String("repo", "", "Run as if heroku-ci was started in this git checkout, like git -C")
+	chaosSpec := flag.String("chaos", "", "Inject faults for testing, like 'latency=500ms,429=10%,5xx=5%,drop=20%,seed=7'")
+	progressFD := flag.Int("progress-fd", 0, "Also write progress events to this open file descriptor, as JSON lines")
+	parseFlags(flag.CommandLine, os.Args[1:])
+	// Errors and DEBUG_HTTP_TRAFFIC dumps can include credentials.
+	stderr := newRedactWriter(os.Stderr)
+	defer flushRedactWriters()
+	log.SetOutput(stderr)
+	rest.DefaultTransport.Output = stderr
+	if *repo != "" {
+		// Every git command, and every relative path in other flags, is
+		// resolved from the working directory.
+		if err := os.Chdir(*repo); err != nil {
+			fatal(usagef("--repo: %v", err))
+		}
+	}
+	if *chaosSpec != "" {
+		c, err := parseChaos(*chaosSpec)
+		if err != nil {
+			fatal(usagef("%v", err))
+		}
+		chaosConfig = c
+		http.DefaultClient.Transport = c.transport(http.DefaultTransport, false)
+	}
+	if *progressFD != 0 {
+		if err := openProgressFD(*progressFD); err != nil {
+			fatal(err)
+		}
+	}
+	if demoMode {
+		url, err := startDemo()
+		if err != nil {
+			fatal(err)
+		}
+		demoURL = url
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		fatal(err)
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-c
+		stopSignal.Store(<-c)
 		cancel()
+		// Cancelling stops everything that waits on ctx; a second Ctrl-C
+		// doesn't wait for the rest to clean up.
+		exit(herokuci.SignalExitCode(<-c))
 	}()
+	readonly = cfg.Readonly
+	accountMachines = cfg.AccountMachines
+	auditWebhook = cfg.AuditWebhook
+	userLocale = lookupLocale(cfg.Locale)
+	if err := configureRedaction(cfg); err != nil {
+		fatal(err)
+	}
+	metricsConfig.Addr = cfg.StatsdAddr
+	metricsConfig.Prefix = cfg.StatsdPrefix
+	metricsConfig.DogStatsD = cfg.DogStatsD
+	anomalyConfig.Enabled = cfg.AnomalyEnabled
+	anomalyConfig.ZScore = cfg.AnomalyZScore
+	anomalyConfig.Percent = cfg.AnomalyPercent
+	anomalyConfig.FailureRate = cfg.AnomalyFailureRate
+	anomalyConfig.MinRuns = cfg.AnomalyMinRuns
+	apiLimits.RequestsPerSecond = cfg.APIRequestsPerSecond
+	apiLimits.Burst = cfg.APIBurst
+	apiLimits.MaxConcurrent = cfg.APIMaxConcurrent
+	apiClientOptions = []herokuci.Option{herokuci.WithTimeout(cfg.APITimeout), herokuci.WithRetries(cfg.APIRetries)}
+	logCacheSize = cfg.LogCacheSize
+	journalConfig.Store = cfg.JournalStore
+	journalConfig.DSN = cfg.JournalDSN
+	eventSinks, err = newEventSinks(cfg)
+	if err != nil {
+		fatal(err)
+	}
+	notifyRoutes, err = newNotifyRoutes(cfg)
+	if err != nil {
+		fatal(err)
+	}
+	if *printVersion {
+		printVersionInfo(ctx, cfg)
+		return
+	}
+	args := flag.Args()
+	if len(args) < 1 {
+		usage()
+		exit(herokuci.ExitUsage)
+	}
 	subargs := args[1:]
 	switch flag.Arg(0) {
 	case "wait":
-		homedir, err := os.UserHomeDir()
+		updates := startUpdateCheck(ctx, cfg)
+		waitflags := flag.NewFlagSet("wait", flag.ExitOnError)
+		waitPipelineID := waitflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		manifest := waitflags.String("manifest", "", "Wait for every pipeline and branch listed in this file")
+		deploys := waitflags.Bool("deploys", true, "After a successful run, report which apps started deploying it")
+		allChecks := waitflags.Bool("all-checks", false, "Also wait for every GitHub status and check on the commit to pass")
+		tag := waitflags.String("tag-on-success", "", "Create and push this annotated tag if the run succeeds")
+		githubRelease := waitflags.Bool("github-release", false, "With --tag-on-success, also create a GitHub release for the tag")
+		warningsAsErrors := waitflags.Bool("warnings-as-errors", false, "Fail if buildpacks print warnings during setup")
+		component := waitflags.String("component", "", "Only print output for this monorepo component; \"all\" prints everything (default: the component containing the working directory)")
+		matchEquivalent := waitflags.Bool("match-equivalent", false, "Point out a successful run of an identical change, like before a rebase, and offer to use it")
+		followBranch := waitflags.Bool("follow-branch", false, "Never exit: follow the newest run on the branch, and wait for the next push after each run")
+		exportEnv := waitflags.String("export-env", "", "Write the finished run's status and IDs to this file as HEROKU_CI_* shell variables")
+		resultFile := waitflags.String("result-file", "", "Write the finished run's status, timings, nodes, and links to this file as JSON")
+		untilMergeable := waitflags.Bool("until-mergeable", false, "After the run succeeds, wait until the branch's pull request has the approvals it needs to merge")
+		requireLabel := waitflags.String("require-label", "", "With --until-mergeable, also wait for these comma-separated labels on the pull request")
+		k8sJobMode := waitflags.Bool("k8s-job", false, "Run as a Kubernetes Job step: timestamp every line, print heartbeats, stop on SIGTERM, and exit with a documented code")
+		heartbeat := waitflags.Duration("heartbeat", 30*time.Second, "With --k8s-job, print a heartbeat whenever nothing has been printed for this long")
+		hints := waitflags.Bool("hints", true, "If the run fails, list the failing tests and the files changed since the last passing run")
+		format := waitflags.String("format", "text", "Output format: text, or json to print the finished run as a line of JSON on stdout")
+		tailStatus := waitflags.Bool("tail-status", false, "With --format=json, also print the run each time its status changes")
+		timeout := waitflags.Duration("timeout", 0, "Give up and exit 4 if the run hasn't finished after this long, like 45m (default: wait forever)")
+		tail := waitflags.Bool("tail", false, "Print the run's setup and test output as it happens")
+		notifyDesktop := waitflags.Bool("notify", false, "Show a desktop notification when the run finishes")
+		notifyURL := waitflags.String("notify-url", "", "Also post the finished run to this Slack-compatible incoming webhook URL")
+		query := waitflags.String("query", "", "Print only these fields of the finished run, as JSONPath like '{.status}' or a Go template like '{{.run_number}}'")
+		waitflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci wait [--pipeline-id=<id>] [branch]\n       heroku-ci wait --manifest=<file>\n\n")
+			waitflags.PrintDefaults()
+		}
+		parseFlags(waitflags, subargs)
+		usageError := func(v ...interface{}) {
+			log.Print(v...)
+			exit(herokuci.ExitUsage)
+		}
+		if *k8sJobMode && (*followBranch || *manifest != "") {
+			usageError("--k8s-job can't be used with --follow-branch or --manifest")
+		}
+		if *followBranch && *tag != "" {
+			usageError("--tag-on-success can't be used with --follow-branch, since every run would get the same tag")
+		}
+		if *followBranch && *untilMergeable {
+			usageError("--until-mergeable can't be used with --follow-branch")
+		}
+		if *requireLabel != "" && !*untilMergeable {
+			usageError("--require-label needs --until-mergeable")
+		}
+		tailOutput = *tail
+		if *notifyURL != "" {
+			redact.addSecret(*notifyURL)
+			n, err := newWebhookNotifier("slack", *notifyURL, "text")
+			if err != nil {
+				usageError("--notify-url: ", err)
+			}
+			notifyRoutes = append(notifyRoutes, &notifyRoute{notifier: n})
+		}
+		if *notifyDesktop {
+			// The run matters more than hearing about it.
+			if n, err := newDesktopNotifier(); err != nil {
+				warnf("--notify: %v", err)
+			} else {
+				notifyRoutes = append(notifyRoutes, &notifyRoute{notifier: n})
+			}
+		}
+		var jsonOut io.Writer
+		switch *format {
+		case "text":
+			if *tailStatus {
+				usageError("--tail-status needs --format=json")
+			}
+		case "json":
+			if *query != "" || *manifest != "" || *tail {
+				usageError("--format=json can't be used with --query, --manifest, or --tail")
+			}
+			// As with --query, keep stdout for the JSON.
+			jsonOut = os.Stdout
+			os.Stdout = os.Stderr
+			if *tailStatus {
+				runJSON.out = jsonOut
+			}
+		default:
+			usageError(fmt.Sprintf("unknown --format %q; want text or json", *format))
+		}
+		var q *resultQuery
+		queryOut := os.Stdout
+		if *query != "" {
+			if *manifest != "" {
+				usageError("--query can't be used with --manifest")
+			}
+			if q, err = parseQuery(*query); err != nil {
+				usageError(err)
+			}
+			// Keep stdout for the answer, so scripts can capture it, and
+			// send our progress output to stderr.
+			os.Stdout = os.Stderr
+		}
+		var job *k8sJob
+		if *k8sJobMode {
+			if job, err = startK8sJob(*heartbeat, cancel); err != nil {
+				fatal(err)
+			}
+		}
+		// fail exits with a documented code in a Kubernetes job, and 1
+		// otherwise.
+		fail := func(err error) {
+			if job != nil {
+				job.finish(err)
+			}
+			fatal(err)
+		}
+		if *tag != "" {
+			if err := checkWritable("tag a release"); err != nil {
+				fail(err)
+			}
+		}
+		client, err := newClient()
+		if err != nil {
+			fail(err)
+		}
+		if *manifest != "" {
+			ok, err := waitManifest(ctx, client, *manifest)
+			if err != nil {
+				fail(err)
+			}
+			printUpdateNotice(updates)
+			if !ok {
+				exit(herokuci.ExitRunFailed)
+			}
+			return
+		}
+		id, err := resolvePipelineID(ctx, client, *waitPipelineID)
 		if err != nil {
-			log.Fatal(err)
+			fail(err)
 		}
-		machine, err := netrc.FindMachine(filepath.Join(homedir, ".netrc"), "api.heroku.com")
+		scope, err := currentComponent(*component)
 		if err != nil {
-			log.Fatal(err)
+			fail(err)
 		}
-		client := &Client{
-			rest.NewClient(machine.Login, machine.Password, "https://api.heroku.com"),
+		if *timeout > 0 {
+			var cancelTimeout context.CancelFunc
+			ctx, cancelTimeout = context.WithTimeout(ctx, *timeout)
+			defer cancelTimeout()
 		}
-		client.Client.Client.Timeout = 0
-		pipelineName := getPipeline()
-		req, err := client.NewRequest("GET", "/pipelines", nil)
+		if err := getTestRuns(ctx, client, id, waitflags.Args(), waitOptions{
+			Component:        scope,
+			Deploys:          *deploys,
+			AllChecks:        *allChecks,
+			TagOnSuccess:     *tag,
+			GitHubRelease:    *githubRelease,
+			WarningsAsErrors: *warningsAsErrors,
+			MatchEquivalent:  *matchEquivalent,
+			FollowBranch:     *followBranch,
+			ResultFile:       *resultFile,
+			ExportEnv:        *exportEnv,
+			FailOnFailure:    !*followBranch,
+			UntilMergeable:   *untilMergeable,
+			RequireLabels:    splitLabels(*requireLabel),
+			Query:            q,
+			QueryOut:         queryOut,
+			Hints:            *hints,
+			JSONOut:          jsonOut,
+		}); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+				err = fmt.Errorf("gave up waiting after --timeout=%s: %w", *timeout, err)
+			}
+			fail(err)
+		}
+		printUpdateNotice(updates)
+		if job != nil {
+			job.finish(nil)
+		}
+	case "access":
+		accessflags := flag.NewFlagSet("access", flag.ExitOnError)
+		accessPipelineID := accessflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		accessflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci access [--pipeline-id=<id>]\n\n")
+			accessflags.PrintDefaults()
+		}
+		parseFlags(accessflags, subargs)
+		client, err := newClient()
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
-		req = req.WithContext(ctx)
-		pipelineBody := make([]*Pipeline, 0)
-		if err := client.Do(req, &pipelineBody); err != nil {
-			log.Fatal(err)
+		id, err := resolvePipelineID(ctx, client, *accessPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		access, err := getPipelineAccess(ctx, client, id)
+		if err != nil {
+			fatal(err)
 		}
-		var ourPipeline *Pipeline
-		for i := range pipelineBody {
-			if pipelineBody[i].Name == pipelineName {
-				ourPipeline = pipelineBody[i]
-				break
+		printPipelineAccess(os.Stdout, access)
+	case "ack":
+		ackflags := flag.NewFlagSet("ack", flag.ExitOnError)
+		ackPipelineID := ackflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		note := ackflags.String("note", "", "Say why, or who is fixing it")
+		ackflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci ack [--note=<text>] [<run>]\n\nThe run is a number, an ID, or a shorthand like @last-failed. With no run,\nlist the acknowledged branches and snoozed pipelines.\n\n")
+			ackflags.PrintDefaults()
+		}
+		parseFlags(ackflags, subargs)
+		if ackflags.NArg() == 0 {
+			path, err := silencesPath()
+			if err != nil {
+				fatal(err)
+			}
+			s, err := readSilences(path)
+			if err != nil {
+				fatal(err)
 			}
+			printSilences(os.Stdout, s, nil)
+			break
+		}
+		if ackflags.NArg() > 1 {
+			ackflags.Usage()
+			exit(herokuci.ExitUsage)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *ackPipelineID)
+		if err != nil {
+			fatal(err)
 		}
-		if ourPipeline == nil {
-			log.Fatalf("could not find pipeline named %q", pipelineName)
+		var branch string
+		if isRunShorthand(ackflags.Arg(0)) {
+			if b, err := currentBranch(); err == nil {
+				branch = herokuBranch(ctx, b)
+			}
+		}
+		run, err := findRun(ctx, client, id, branch, ackflags.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		name := pipelineName(ctx, client, id)
+		if name == "" {
+			name = id.String()
+		}
+		if err := acknowledge(id, name, run, *note); err != nil {
+			fatal(err)
+		}
+	case "annotate-release":
+		annotateflags := flag.NewFlagSet("annotate-release", flag.ExitOnError)
+		app := annotateflags.String("app", "", "Name of the app to annotate")
+		configVar := annotateflags.String("config-var", "", "Store the test run ID in this config var on the app")
+		gitNote := annotateflags.Bool("git-note", false, "Record the test run as a git note on the released commit")
+		annotateflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci annotate-release --app=<app> [--config-var=<name>] [--git-note]\n\n")
+			annotateflags.PrintDefaults()
+		}
+		parseFlags(annotateflags, subargs)
+		if *configVar != "" || *gitNote {
+			if err := checkWritable("annotate a release"); err != nil {
+				fatal(err)
+			}
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		if err := annotateRelease(ctx, client, *app, *configVar, *gitNote); err != nil {
+			fatal(err)
+		}
+	case "assert-green":
+		assertflags := flag.NewFlagSet("assert-green", flag.ExitOnError)
+		assertPipelineID := assertflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		maxAge := assertflags.Duration("max-age", 0, "Fail if the most recent run finished longer ago than this")
+		byTree := assertflags.Bool("by-tree", false, "Pass if any successful run tested the same tree as the branch tip, even for a different commit")
+		assertflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci assert-green [--max-age=<duration>] [--by-tree] [branch]\n\n")
+			assertflags.PrintDefaults()
+		}
+		parseFlags(assertflags, subargs)
+		branch, err := getBranchFromArgs(assertflags.Args())
+		if err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *assertPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if *byTree {
+			err = assertGreenByTree(ctx, client, id, branch, *maxAge)
+		} else {
+			err = assertGreen(ctx, client, id, herokuBranch(ctx, branch), *maxAge)
+		}
+		if err != nil {
+			fatal(err)
+		}
+	case "cancel":
+		cancelflags := flag.NewFlagSet("cancel", flag.ExitOnError)
+		cancelPipelineID := cancelflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		branch := cancelflags.String("branch", "", "Only cancel runs on branches matching this glob, like 'feature/*'")
+		status := cancelflags.String("status", "", "Only cancel runs with these comma-separated statuses, like 'pending,building'")
+		yes := cancelflags.Bool("yes", false, "Cancel without asking for confirmation")
+		dryRun := cancelflags.Bool("dry-run", false, "Print the runs that would be cancelled, and stop")
+		cancelflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci cancel [--branch=<glob>] [--status=<status>] [--yes] [--dry-run]\n\n")
+			cancelflags.PrintDefaults()
+		}
+		parseFlags(cancelflags, subargs)
+		filter := cancelFilter{Branch: *branch}
+		if *status != "" {
+			for _, s := range strings.Split(*status, ",") {
+				filter.Statuses = append(filter.Statuses, RunStatus(s))
+			}
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *cancelPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := cancelTestRuns(ctx, client, id, filter, *yes, *dryRun); err != nil {
+			fatal(err)
+		}
+	case "check-stack":
+		stackflags := flag.NewFlagSet("check-stack", flag.ExitOnError)
+		stackPipelineID := stackflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		stackflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci check-stack\n\n")
+			stackflags.PrintDefaults()
+		}
+		parseFlags(stackflags, subargs)
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *stackPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := checkStack(ctx, client, id); err != nil {
+			fatal(err)
+		}
+	case "couplings":
+		couplingflags := flag.NewFlagSet("couplings", flag.ExitOnError)
+		couplingPipelineID := couplingflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		couplingYes := couplingflags.Bool("yes", false, "Remove a coupling without asking for confirmation")
+		couplingDryRun := couplingflags.Bool("dry-run", false, "Print the coupling that would be removed, and stop")
+		couplingflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci couplings list | add <app> <stage> | remove [--dry-run] <app>\n\n")
+			couplingflags.PrintDefaults()
+		}
+		parseFlags(couplingflags, subargs)
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		if err := couplingsCommand(ctx, client, *couplingPipelineID, couplingflags.Args(), *couplingYes, *couplingDryRun); err != nil {
+			fatal(err)
+		}
+	case "exit-codes":
+		exitflags := flag.NewFlagSet("exit-codes", flag.ExitOnError)
+		asJSON := exitflags.Bool("json", false, "Print the table as a JSON array")
+		exitflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci exit-codes [--json]\n\n")
+			exitflags.PrintDefaults()
+		}
+		parseFlags(exitflags, subargs)
+		if err := printExitCodes(os.Stdout, *asJSON); err != nil {
+			fatal(err)
+		}
+	case "export":
+		exportflags := flag.NewFlagSet("export", flag.ExitOnError)
+		exportPipelineID := exportflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		limit := exportflags.Int("limit", 0, "Only export this many recent runs (default all)")
+		exportflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci export [--limit=<n>]\n\n")
+			exportflags.PrintDefaults()
+		}
+		parseFlags(exportflags, subargs)
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *exportPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := exportTestRuns(ctx, client, id, os.Stdout, *limit); err != nil {
+			fatal(err)
+		}
+	case "label":
+		labelflags := flag.NewFlagSet("label", flag.ExitOnError)
+		labelPipelineID := labelflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		remove := labelflags.Bool("remove", false, "Take the labels off the run instead of adding them")
+		labelflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci label [--remove] [<run> <label>...]\n\nThe run is a number, an ID, or a shorthand like @last-failed. Labels are\nonly stored on this machine. With no run, list the labelled runs.\n\n")
+			labelflags.PrintDefaults()
+		}
+		parseFlags(labelflags, subargs)
+		if labelflags.NArg() == 1 {
+			labelflags.Usage()
+			exit(herokuci.ExitUsage)
+		}
+		labels := labelflags.Args()
+		if len(labels) > 0 {
+			labels = labels[1:]
+		}
+		for _, label := range labels {
+			if err := validLabel(label); err != nil {
+				fatal(err)
+			}
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *labelPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if labelflags.NArg() == 0 {
+			l, err := loadLabels()
+			if err != nil {
+				fatal(err)
+			}
+			if err := printLabels(os.Stdout, l, id); err != nil {
+				fatal(err)
+			}
+			break
+		}
+		var branch string
+		if isRunShorthand(labelflags.Arg(0)) {
+			if b, err := currentBranch(); err == nil {
+				branch = herokuBranch(ctx, b)
+			}
+		}
+		run, err := findRun(ctx, client, id, branch, labelflags.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		add, del := labels, []string(nil)
+		if *remove {
+			add, del = nil, labels
+		}
+		left, err := labelRun(id, run, add, del)
+		if err != nil {
+			fatal(err)
+		}
+		if len(left) == 0 {
+			fmt.Printf("Run #%d has no labels.\n", run.Number)
+		} else {
+			fmt.Printf("Run #%d is labelled %s.\n", run.Number, strings.Join(left, ", "))
+		}
+	case "list":
+		listflags := flag.NewFlagSet("list", flag.ExitOnError)
+		listPipelineID := listflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		branch := listflags.String("branch", "", "Only show runs on branches matching this glob, like 'feature/*'")
+		status := listflags.String("status", "", "Only show runs with these comma-separated statuses, like 'failed,errored'")
+		limit := listflags.Int("limit", 20, "Show at most this many runs, newest first (0 for all)")
+		listQuery := listflags.String("query", "", "Print only these fields of each run, as JSONPath like '{.number} {.status}' or a Go template like '{{.commit_sha}}'")
+		listLabel := listflags.String("label", "", "Only show runs with this label, added with `heroku-ci label`")
+		listflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci list [--status=<status>] [--label=<label>] [--limit=<n>] [--query=<query>] [--branch=<glob> | branch]\n\n")
+			listflags.PrintDefaults()
+		}
+		parseFlags(listflags, subargs)
+		if listflags.NArg() > 1 || (listflags.NArg() == 1 && *branch != "") {
+			listflags.Usage()
+			exit(herokuci.ExitUsage)
+		}
+		filter := searchFilter{Branch: *branch, Label: *listLabel}
+		if listflags.NArg() == 1 {
+			// A branch named as an argument is matched exactly.
+			filter.Branch = globEscape(herokuBranch(ctx, listflags.Arg(0)))
+		}
+		var err error
+		if filter.Statuses, err = parseStatuses(*status); err != nil {
+			fatal(err)
+		}
+		var q *resultQuery
+		if *listQuery != "" {
+			if q, err = parseQuery(*listQuery); err != nil {
+				fatal(usagef("%v", err))
+			}
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		client.failFast = true
+		id, err := resolvePipelineID(ctx, client, *listPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		runs, err := searchRuns(ctx, client, id, filter, *limit)
+		if err != nil {
+			fatal(err)
+		}
+		if len(runs) == 0 {
+			fmt.Fprintln(os.Stderr, "no matching runs")
+			break
+		}
+		if q != nil {
+			results := make([]interface{}, len(runs))
+			for i, run := range runs {
+				results[i] = run
+			}
+			err = q.PrintEach(os.Stdout, results)
+		} else {
+			err = printRunList(os.Stdout, runs)
+		}
+		if err != nil {
+			fatal(err)
+		}
+	case "open":
+		openflags := flag.NewFlagSet("open", flag.ExitOnError)
+		openPipelineID := openflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		printURL := openflags.Bool("print", false, "Print the dashboard URL instead of opening it")
+		openflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci open [--print] [branch | run]\n\nThe run is a number like 104, an ID, or a shorthand like @last-failed. With\nno run, open the newest run on the branch.\n\n")
+			openflags.PrintDefaults()
+		}
+		parseFlags(openflags, subargs)
+		if openflags.NArg() > 1 {
+			openflags.Usage()
+			exit(herokuci.ExitUsage)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *openPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := openRun(ctx, client, id, openflags.Args(), *printURL); err != nil {
+			fatal(err)
+		}
+	case "logs":
+		logsflags := flag.NewFlagSet("logs", flag.ExitOnError)
+		logsPipelineID := logsflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		diff := logsflags.Bool("diff", false, "Diff the test summary of the latest failing run against the latest passing run before it")
+		tail := logsflags.Bool("tail", false, "Follow the output of the newest run, or the named run, as it happens")
+		logsflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci logs [--diff | --tail] [branch | run]\n\nThe run is a shorthand like @latest, @latest-1, or @last-failed.\n\n")
+			logsflags.PrintDefaults()
+		}
+		parseFlags(logsflags, subargs)
+		branch, ref, err := runFromArgs(logsflags.Args())
+		if err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *logsPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if *diff && ref != "" {
+			fatal(usagef("--diff compares the latest failing and passing runs, and can't be used with a run"))
+		}
+		if *diff && *tail {
+			fatal(usagef("--diff and --tail can't be used together"))
+		}
+		switch {
+		case *diff:
+			err = logsDiff(ctx, client, id, herokuBranch(ctx, branch), os.Stdout)
+		case *tail:
+			err = tailLogs(ctx, client, id, herokuBranch(ctx, branch), ref, os.Stdout)
+		default:
+			err = printLogs(ctx, client, id, herokuBranch(ctx, branch), ref, os.Stdout)
+		}
+		if err != nil {
+			fatal(err)
+		}
+	case "matrix":
+		matrixflags := flag.NewFlagSet("matrix", flag.ExitOnError)
+		matrixPipelineID := matrixflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		var env, envSets stringList
+		matrixflags.Var(&env, "env", "Set NAME=value in every run (can be repeated)")
+		matrixflags.Var(&envSets, "env-set", "Comma-separated alternatives, like 'DB=postgres,DB=mysql'; one run per combination (can be repeated)")
+		sourceURL := matrixflags.String("source-url", "", "Tarball URL to test (defaults to the GitHub tarball for the branch)")
+		yes := matrixflags.Bool("yes", false, "Don't ask for confirmation")
+		dryRun := matrixflags.Bool("dry-run", false, "Print the runs that would be started, and the config vars each would see, and stop")
+		matrixflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci matrix [--env=NAME=value...] --env-set=<alternatives>... [--dry-run] [branch]\n\nHeroku CI has no per-run environment, so the pipeline's test config vars\nare set for each combination in turn while its run starts, and put back\nafterwards.\n\n")
+			matrixflags.PrintDefaults()
+		}
+		parseFlags(matrixflags, subargs)
+		if len(envSets) == 0 {
+			matrixflags.Usage()
+			exit(herokuci.ExitUsage)
+		}
+		cells, err := matrixCells(env, envSets)
+		if err != nil {
+			fatal(err)
+		}
+		branch, err := getBranchFromArgs(matrixflags.Args())
+		if err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *matrixPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := runMatrix(ctx, client, id, branch, *sourceURL, cells, *yes, *dryRun); err != nil {
+			fatal(err)
+		}
+	case "merge-when-green":
+		mergeflags := flag.NewFlagSet("merge-when-green", flag.ExitOnError)
+		mergePipelineID := mergeflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		method := mergeflags.String("method", cfg.MergeMethod, "How to merge the pull request: merge, squash, or rebase")
+		allChecks := mergeflags.Bool("all-checks", false, "Also wait for every GitHub status and check on the pull request to pass")
+		mergeflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci merge-when-green [--method=<method>] [--all-checks] [pr-number]\n\n")
+			mergeflags.PrintDefaults()
+		}
+		parseFlags(mergeflags, subargs)
+		if err := checkWritable("merge a pull request"); err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *mergePipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := mergeWhenGreen(ctx, client, id, mergeflags.Args(), *method, *allChecks); err != nil {
+			fatal(err)
+		}
+	case "paths":
+		if err := printPaths(); err != nil {
+			fatal(err)
+		}
+	case "predict":
+		predictflags := flag.NewFlagSet("predict", flag.ExitOnError)
+		predictPipelineID := predictflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		base := predictflags.String("base", "", "Compare HEAD to this ref (default: the upstream branch, or origin's default branch)")
+		since := predictflags.String("since", "30d", "Look at failures this long ago, like 14d or 3mo")
+		predictflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci predict [--base=<ref>] [--since=<duration>]\n\nBefore pushing, compare the files changed on this branch to the tests\nthat failed recently. This is a heuristic, not a guarantee.\n\n")
+			predictflags.PrintDefaults()
+		}
+		parseFlags(predictflags, subargs)
+		d, err := parseSince(*since)
+		if err != nil {
+			fatal(err)
+		}
+		if *base == "" {
+			if *base, err = pushBase(ctx); err != nil {
+				fatal(usagef("%v", err))
+			}
+		}
+		files, err := unpushedFiles(ctx, *base)
+		if err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *predictPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		sigs, err := recentSignatures(ctx, client, id, time.Now().Add(-d))
+		if err != nil {
+			fatal(err)
+		}
+		printPrediction(os.Stdout, *base, files, sigs, *since)
+	case "prompt":
+		promptflags := flag.NewFlagSet("prompt", flag.ExitOnError)
+		staleAfter := promptflags.Duration("stale-after", time.Hour, "Mark statuses older than this as stale")
+		format := promptflags.String("format", "plain", "Output format: plain, starship, or p10k")
+		promptflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci prompt [--format=<format>] [--stale-after=<duration>]\n\n")
+			promptflags.PrintDefaults()
+		}
+		parseFlags(promptflags, subargs)
+		if err := prompt(*format, *staleAfter); err != nil {
+			fatal(err)
+		}
+	case "replay":
+		if err := replay(os.Stdout, subargs); err != nil {
+			fatal(err)
+		}
+	case "report":
+		reportflags := flag.NewFlagSet("report", flag.ExitOnError)
+		reportPipelineID := reportflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		out := reportflags.String("out", "report.html", "Write the report to this file")
+		since := reportflags.String("since", "7d", "Include runs created this long ago, like 72h, 7d, 6mo, or 1y")
+		runs := reportflags.Int("runs", 100, "List this many of the newest runs (0 for all)")
+		failures := reportflags.Int("failures", 5, "Show the test summary of this many of the newest failing runs")
+		top := reportflags.Int("top", 20, "Only show this many of the busiest branches and authors (0 for all)")
+		reportflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci report [--out=<file>] [--since=<duration>]\n\n")
+			reportflags.PrintDefaults()
+		}
+		parseFlags(reportflags, subargs)
+		d, err := parseSince(*since)
+		if err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *reportPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := writeReport(ctx, client, id, d, *out, reportOptions{
+			Runs:     *runs,
+			Failures: *failures,
+			Top:      *top,
+		}); err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "wrote %s\n", *out)
+	case "rerun":
+		rerunflags := flag.NewFlagSet("rerun", flag.ExitOnError)
+		rerunPipelineID := rerunflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		runRef := rerunflags.String("run", "", "The run to rerun: a number, ID, or shorthand like @last-failed (default: the latest finished run on the branch)")
+		failedNodes := rerunflags.Bool("failed-nodes", false, "Only rerun the nodes that failed, and report them together with the nodes that passed")
+		sourceURL := rerunflags.String("source-url", "", "Tarball URL to test (defaults to the GitHub tarball for the commit)")
+		yes := rerunflags.Bool("yes", false, "Don't ask before changing the pipeline's test config vars")
+		rerunflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci rerun [--failed-nodes] [--run=<run>] [branch | run]\n\n")
+			rerunflags.PrintDefaults()
+		}
+		parseFlags(rerunflags, subargs)
+		branch, ref, err := runFromArgs(rerunflags.Args())
+		if err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *rerunPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if *runRef != "" {
+			ref = *runRef
+		}
+		if err := rerun(ctx, client, id, herokuBranch(ctx, branch), rerunOptions{
+			Run:         ref,
+			FailedNodes: *failedNodes,
+			SourceURL:   *sourceURL,
+			Yes:         *yes,
+		}); err != nil {
+			fatal(err)
+		}
+	case "review-app":
+		reviewflags := flag.NewFlagSet("review-app", flag.ExitOnError)
+		reviewPipelineID := reviewflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		sourceURL := reviewflags.String("source-url", "", "Tarball URL to build the review app from (defaults to the GitHub tarball for the branch)")
+		reviewYes := reviewflags.Bool("yes", false, "Delete a review app without asking for confirmation")
+		reviewDryRun := reviewflags.Bool("dry-run", false, "Print the review app that would be deleted, and stop")
+		reviewflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci review-app create|delete|open [branch]\n\n")
+			reviewflags.PrintDefaults()
+		}
+		parseFlags(reviewflags, subargs)
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *reviewPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := reviewAppCommand(ctx, client, id, reviewflags.Args(), *sourceURL, *reviewYes, *reviewDryRun); err != nil {
+			fatal(err)
+		}
+	case "run":
+		runflags := flag.NewFlagSet("run", flag.ExitOnError)
+		runPipelineID := runflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		workingTree := runflags.Bool("working-tree", false, "Include uncommitted changes to tracked files, instead of testing only HEAD")
+		clearCache := runflags.Bool("clear-cache", false, "Build the run without the build cache")
+		runflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci run [--working-tree] [--clear-cache]\n\nUpload HEAD as a tarball, start a test run of it on the current branch, and\nwait for the run, without pushing anything.\n\n")
+			runflags.PrintDefaults()
+		}
+		parseFlags(runflags, subargs)
+		if runflags.NArg() > 0 {
+			runflags.Usage()
+			exit(herokuci.ExitUsage)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *runPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := runSource(ctx, client, id, runOptions{WorkingTree: *workingTree, ClearCache: *clearCache}); err != nil {
+			fatal(err)
+		}
+	case "schema":
+		schemaflags := flag.NewFlagSet("schema", flag.ExitOnError)
+		schemaflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci schema [<output>]\n\nPrint the JSON schema (version %d) for a machine-readable output. With no\noutput, list them.\n\n", schemaVersion)
+			schemaflags.PrintDefaults()
+		}
+		parseFlags(schemaflags, subargs)
+		if schemaflags.NArg() > 1 {
+			schemaflags.Usage()
+			exit(herokuci.ExitUsage)
+		}
+		if err := printSchema(os.Stdout, schemaflags.Arg(0)); err != nil {
+			fatal(err)
+		}
+	case "smoke":
+		smokeflags := flag.NewFlagSet("smoke", flag.ExitOnError)
+		smokePipelineID := smokeflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		remote := smokeflags.String("remote", "origin", "Remote to push the temporary branch to")
+		sourceURL := smokeflags.String("source-url", "", "Tarball URL to test, instead of pushing HEAD")
+		keep := smokeflags.Bool("keep-branch", false, "Leave the temporary branch on the remote afterwards")
+		smokeflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci smoke [--remote=<remote>] [--source-url=<url>] [--keep-branch]\n\nPush HEAD to a temporary %s branch, wait for its test run, and\ndelete the branch, to try app.json or buildpack changes without a real\nbranch or pull request.\n\n", smokeBranchPrefix+"*")
+			smokeflags.PrintDefaults()
+		}
+		parseFlags(smokeflags, subargs)
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *smokePipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := smoke(ctx, client, id, smokeOptions{Remote: *remote, SourceURL: *sourceURL, KeepBranch: *keep}); err != nil {
+			fatal(err)
+		}
+	case "status":
+		statusflags := flag.NewFlagSet("status", flag.ExitOnError)
+		statusPipelineID := statusflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		allLocal := statusflags.Bool("all-local-branches", false, "Show every local branch that tracks an upstream branch")
+		statusQuery := statusflags.String("query", "", "Print only these fields of each branch, as JSONPath like '{.run.status}' or a Go template like '{{.note}}'")
+		statusflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci status [--all-local-branches] [--query=<query>] [branch]\n\n")
+			statusflags.PrintDefaults()
+		}
+		parseFlags(statusflags, subargs)
+		if *allLocal && statusflags.NArg() > 0 {
+			fatal(usagef("--all-local-branches can't be used with a branch"))
+		}
+		var q *resultQuery
+		if *statusQuery != "" {
+			var err error
+			if q, err = parseQuery(*statusQuery); err != nil {
+				fatal(usagef("%v", err))
+			}
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		client.failFast = true
+		id, err := resolvePipelineID(ctx, client, *statusPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		var runs []*branchRun
+		if *allLocal {
+			runs, err = localBranchRuns(ctx, client, id)
+		} else {
+			runs, err = branchRunFromArgs(ctx, client, id, statusflags.Args())
+		}
+		if err != nil {
+			fatal(err)
+		}
+		if q != nil {
+			results := make([]interface{}, len(runs))
+			for i, br := range runs {
+				results[i] = br
+			}
+			err = q.PrintEach(os.Stdout, results)
+		} else {
+			err = printBranchRuns(os.Stdout, runs)
+		}
+		if err != nil {
+			fatal(err)
+		}
+	case "tidy":
+		tidyflags := flag.NewFlagSet("tidy", flag.ExitOnError)
+		tidyPipelineID := tidyflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		base := tidyflags.String("base", "", "Branch that merged branches were merged into (default origin's default branch)")
+		del := tidyflags.Bool("delete", false, "Delete the suggested local branches")
+		tidyYes := tidyflags.Bool("yes", false, "With --delete, delete without asking for confirmation")
+		tidyDryRun := tidyflags.Bool("dry-run", false, "With --delete, print how many branches would be deleted, and stop")
+		tidyflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci tidy [--base=<ref>] [--delete [--yes | --dry-run]]\n\nList local branches that were merged, or whose upstream was deleted, and\nwhose latest run passed on their tip.\n\n")
+			tidyflags.PrintDefaults()
+		}
+		parseFlags(tidyflags, subargs)
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *tidyPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := tidy(ctx, client, id, *base, *del, *tidyYes, *tidyDryRun); err != nil {
+			fatal(err)
+		}
+	case "search":
+		searchflags := flag.NewFlagSet("search", flag.ExitOnError)
+		searchPipelineID := searchflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		message := searchflags.String("message", "", "Only show runs whose commit message contains this, ignoring case")
+		author := searchflags.String("author", "", "Only show runs by authors whose email contains this, like 'alice'")
+		branch := searchflags.String("branch", "", "Only show runs on branches matching this glob, like 'feature/*'")
+		status := searchflags.String("status", "", "Only show runs with these comma-separated statuses, like 'failed,errored'")
+		since := searchflags.String("since", "30d", "Search runs created this long ago, like 72h, 14d, 6mo, or 1y")
+		limit := searchflags.Int("limit", 50, "Show at most this many runs, newest first (0 for all)")
+		searchLabel := searchflags.String("label", "", "Only show runs with this label, added with `heroku-ci label`")
+		searchflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci search [--message=<text>] [--author=<text>] [--branch=<glob>] [--status=<status>] [--label=<label>] [--since=<duration>]\n\n")
+			searchflags.PrintDefaults()
+		}
+		parseFlags(searchflags, subargs)
+		d, err := parseSince(*since)
+		if err != nil {
+			fatal(err)
+		}
+		filter := searchFilter{Message: *message, Author: *author, Branch: *branch, Label: *searchLabel, Since: time.Now().Add(-d)}
+		if filter.Statuses, err = parseStatuses(*status); err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		client.failFast = true
+		id, err := resolvePipelineID(ctx, client, *searchPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		runs, err := searchRuns(ctx, client, id, filter, *limit)
+		if err != nil {
+			fatal(err)
+		}
+		if len(runs) == 0 {
+			fmt.Fprintf(os.Stderr, "no matching runs in the last %s\n", *since)
+			break
+		}
+		printSearchResults(os.Stdout, runs)
+	case "serve":
+		serveflags := flag.NewFlagSet("serve", flag.ExitOnError)
+		addr := serveflags.String("addr", cfg.ServeAddr, "Address to listen on")
+		grpcAddr := serveflags.String("grpc-addr", cfg.ServeGRPCAddr, "Also serve the gRPC API on this address")
+		tlsCert := serveflags.String("tls-cert", cfg.ServeTLSCert, "Serve HTTPS with this PEM certificate")
+		tlsKey := serveflags.String("tls-key", cfg.ServeTLSKey, "The PEM private key for --tls-cert")
+		clientCA := serveflags.String("client-ca", cfg.ServeClientCA, "Require client certificates signed by a CA in this PEM file")
+		serveflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci serve [--addr=<host:port>] [--grpc-addr=<host:port>] [--tls-cert=<file> --tls-key=<file>] [--client-ca=<file>]\n\n")
+			serveflags.PrintDefaults()
+		}
+		parseFlags(serveflags, subargs)
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		if err := serve(ctx, client, serveOptions{
+			Addr:     *addr,
+			GRPCAddr: *grpcAddr,
+			Tokens:   cfg.APITokens,
+			TLSCert:  *tlsCert,
+			TLSKey:   *tlsKey,
+			ClientCA: *clientCA,
+		}); err != nil {
+			fatal(err)
+		}
+	case "setup-report":
+		setupflags := flag.NewFlagSet("setup-report", flag.ExitOnError)
+		setupPipelineID := setupflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		limit := setupflags.Int("limit", 50, "Number of recent runs to include")
+		setupflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci setup-report [--limit=<n>]\n\n")
+			setupflags.PrintDefaults()
+		}
+		parseFlags(setupflags, subargs)
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *setupPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := setupReport(id, *limit); err != nil {
+			fatal(err)
+		}
+	case "snooze":
+		snoozeflags := flag.NewFlagSet("snooze", flag.ExitOnError)
+		snoozePipelineID := snoozeflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		clear := snoozeflags.Bool("clear", false, "Lift the snooze now")
+		snoozeflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci snooze [pipeline] <duration>\n       heroku-ci snooze --clear [pipeline]\n\n")
+			snoozeflags.PrintDefaults()
+		}
+		parseFlags(snoozeflags, subargs)
+		args := snoozeflags.Args()
+		var d time.Duration
+		if !*clear {
+			if len(args) == 0 {
+				snoozeflags.Usage()
+				exit(herokuci.ExitUsage)
+			}
+			d, err = parseSince(args[len(args)-1])
+			if err != nil || d <= 0 {
+				fatal(usagef("snooze: invalid duration %q, want something like 1h, 30m, or 2d", args[len(args)-1]))
+			}
+			args = args[:len(args)-1]
+		}
+		if len(args) > 1 {
+			snoozeflags.Usage()
+			exit(herokuci.ExitUsage)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		var id types.PrefixUUID
+		var name string
+		if len(args) == 1 {
+			p, err := findPipelineByName(ctx, client, args[0])
+			if err != nil {
+				fatal(err)
+			}
+			id, name = p.ID, args[0]
+		} else {
+			if id, err = resolvePipelineID(ctx, client, *snoozePipelineID); err != nil {
+				fatal(err)
+			}
+			if name = pipelineName(ctx, client, id); name == "" {
+				name = id.String()
+			}
+		}
+		if err := snoozePipeline(id, name, d); err != nil {
+			fatal(err)
+		}
+	case "stats":
+		statsflags := flag.NewFlagSet("stats", flag.ExitOnError)
+		statsPipelineID := statsflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		since := statsflags.String("since", "30d", "Include runs created this long ago, like 72h, 30d, 6mo, or 1y")
+		by := statsflags.String("by", "branch,author", "Group runs by branch, author, or both")
+		top := statsflags.Int("top", 20, "Only show this many of the busiest branches and authors (0 for all)")
+		graph := statsflags.Bool("graph", false, "Also print the duration and pass rate over time as sparklines")
+		graphOut := statsflags.String("graph-out", "", "Draw the duration and pass rate over time in this .svg or .png file")
+		prs := statsflags.Bool("prs", false, "Also report how long GitHub pull requests waited for green CI")
+		failures := statsflags.Bool("failures", false, "Also group failed runs by the tests and errors they failed with")
+		statsLabel := statsflags.String("label", "", "Only count runs with this label, added with `heroku-ci label`")
+		statsflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci stats [--since=<duration>] [--by=branch,author] [--top=<n>] [--graph] [--graph-out=<file>] [--failures] [--prs] [--label=<label>]\n\n")
+			statsflags.PrintDefaults()
+		}
+		parseFlags(statsflags, subargs)
+		d, err := parseSince(*since)
+		if err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		client.failFast = true
+		id, err := resolvePipelineID(ctx, client, *statsPipelineID)
+		if err != nil {
+			fatal(err)
+		}
+		if err := printStats(ctx, client, id, d, statsOptions{
+			By:        *by,
+			Top:       *top,
+			Graph:     *graph,
+			GraphFile: *graphOut,
+			PRs:       *prs,
+			Label:     *statsLabel,
+			Failures:  *failures,
+		}); err != nil {
+			fatal(err)
+		}
+	case "top":
+		topflags := flag.NewFlagSet("top", flag.ExitOnError)
+		topPipelineID := topflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		sortKey := topflags.String("sort", "age", "Sort runs by age (oldest first), newest, or pipeline")
+		once := topflags.Bool("once", false, "Print the runs once instead of refreshing")
+		topflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci top [--sort=<key>] [--once] [pipeline...]\n\n")
+			topflags.PrintDefaults()
+		}
+		parseFlags(topflags, subargs)
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		client.failFast = true
+		pipelines, err := topPipelines(ctx, client, cfg, *topPipelineID, topflags.Args())
+		if err != nil {
+			fatal(err)
+		}
+		if err := top(ctx, client, pipelines, *sortKey, *once); err != nil {
+			fatal(err)
+		}
+	case "trigger":
+		triggerflags := flag.NewFlagSet("trigger", flag.ExitOnError)
+		triggerPipelineID := triggerflags.String("pipeline-id", *pipelineID, "Pipeline ID to use, instead of looking it up by name")
+		sourceURL := triggerflags.String("source-url", "", "Tarball URL to test (defaults to the GitHub tarball for the branch)")
+		dryRun := triggerflags.Bool("dry-run", false, "Print the branch, commit, and source URL of the run that would be started, and stop")
+		triggerflags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: heroku-ci trigger [--source-url=<url>] [--dry-run] [branch]\n\n")
+			triggerflags.PrintDefaults()
+		}
+		parseFlags(triggerflags, subargs)
+		branch, err := getBranchFromArgs(triggerflags.Args())
+		if err != nil {
+			fatal(err)
+		}
+		client, err := newClient()
+		if err != nil {
+			fatal(err)
+		}
+		id, err := resolvePipelineID(ctx, client, *triggerPipelineID)
+		if err != nil {
+			fatal(err)
 		}
-		if err := getTestRuns(ctx, client, ourPipeline.ID, subargs); err != nil {
-			log.Fatal(err)
+		if err := trigger(ctx, client, id, branch, *sourceURL, *dryRun); err != nil {
+			fatal(err)
 		}
 	case "version":
-		fmt.Fprintf(os.Stderr, "heroku-ci version %s\n", Version)
-		os.Exit(1)
+		printVersionInfo(ctx, cfg)
 	default:
 		fmt.Fprintf(os.Stderr, "heroku-ci: unknown command %q\n\n", flag.Arg(0))
 		usage()
-		os.Exit(2)
+		exit(herokuci.ExitUsage)
 	}
 }
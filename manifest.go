@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A ManifestEntry is one (pipeline, branch) pair to wait for.
+type ManifestEntry struct {
+	// Pipeline is the name of the pipeline. Either Pipeline or PipelineID
+	// must be set.
+	Pipeline   string
+	PipelineID string
+	Branch     string
+	// Commit is optional. If it is empty we wait for the most recent run on
+	// Branch.
+	Commit string
+}
+
+func (m *ManifestEntry) name() string {
+	if m.Pipeline != "" {
+		return m.Pipeline
+	}
+	return m.PipelineID
+}
+
+// parseManifest parses a release manifest. The manifest is a small subset of
+// YAML - a list of mappings, optionally nested under a "pipelines" key:
+//
+//	pipelines:
+//	  - pipeline: api
+//	    branch: master
+//	  - pipeline_id: 2f3a7a8e-...
+//	    branch: release
+//	    commit: 4b5c6d7
+func parseManifest(r io.Reader) ([]*ManifestEntry, error) {
+	entries := make([]*ManifestEntry, 0)
+	var cur *ManifestEntry
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		if idx := strings.Index(line, " #"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || line == "---" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "pipelines:" && cur == nil {
+			continue
+		}
+		if strings.HasPrefix(line, "-") {
+			cur = new(ManifestEntry)
+			entries = append(entries, cur)
+			line = strings.TrimSpace(line[1:])
+			if line == "" {
+				continue
+			}
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("line %d: expected a list entry starting with \"-\"", lineno)
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"key: value\", got %q", lineno, line)
+		}
+		key := strings.TrimSpace(parts[0])
+		val := unquote(strings.TrimSpace(parts[1]))
+		switch key {
+		case "pipeline":
+			cur.Pipeline = val
+		case "pipeline_id":
+			cur.PipelineID = val
+		case "branch":
+			cur.Branch = normalizeBranch(val)
+		case "commit":
+			cur.Commit = val
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q", lineno, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("manifest does not list any pipelines")
+	}
+	for i, entry := range entries {
+		if entry.Pipeline == "" && entry.PipelineID == "" {
+			return nil, fmt.Errorf("manifest entry %d: pipeline or pipeline_id is required", i+1)
+		}
+		if entry.Branch == "" {
+			return nil, fmt.Errorf("manifest entry %d (%s): branch is required", i+1, entry.name())
+		}
+	}
+	return entries, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// manifestResult is the outcome of waiting for one manifest entry.
+type manifestResult struct {
+	entry *ManifestEntry
+	run   *TestRun
+	err   error
+}
+
+func (m manifestResult) succeeded() bool {
+	return m.err == nil && m.run != nil && m.run.Status == StatusSucceeded
+}
+
+// waitManifest waits for every entry in the manifest at path concurrently,
+// prints a summary, and reports whether every run succeeded.
+func waitManifest(ctx context.Context, client *Client, path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	entries, err := parseManifest(f)
+	f.Close()
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", path, err)
+	}
+	results := make([]manifestResult, len(entries))
+	var wg sync.WaitGroup
+	for i := range entries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Spread the watchers out, so they don't all poll at once.
+			select {
+			case <-ctx.Done():
+			case <-time.After(stagger(i, len(entries), pollInterval)):
+			}
+			run, err := waitManifestEntry(ctx, client, entries[i])
+			results[i] = manifestResult{entry: entries[i], run: run, err: err}
+		}(i)
+	}
+	wg.Wait()
+
+	fmt.Println("\nSummary:")
+	t := newTable("PIPELINE", "BRANCH", "RUN", "STATUS", "DURATION")
+	t.indent = "  "
+	t.flex = []int{0, 1, 3}
+	ok := true
+	for _, res := range results {
+		if !res.succeeded() {
+			ok = false
+		}
+		if res.err != nil {
+			t.add(res.entry.name(), res.entry.Branch, "-", "error: "+strings.TrimSpace(res.err.Error()), "")
+			continue
+		}
+		t.addf(res.entry.name(), res.entry.Branch, res.run.ID.String()[:8], res.run.Status, res.run.Duration())
+	}
+	t.print(os.Stdout)
+	if ok {
+		fmt.Printf("All %d test runs succeeded.\n", len(results))
+	}
+	return ok, nil
+}
+
+func waitManifestEntry(ctx context.Context, client *Client, entry *ManifestEntry) (*TestRun, error) {
+	var id types.PrefixUUID
+	if entry.PipelineID != "" {
+		var err error
+		id, err = resolvePipelineID(ctx, client, entry.PipelineID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		pipeline, err := findPipelineByName(ctx, client, entry.Pipeline)
+		if err != nil {
+			return nil, err
+		}
+		id = pipeline.ID
+	}
+	run, err := findTestRun(ctx, client, id, entry.Branch, entry.Commit)
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("[%s %s] ", entry.name(), entry.Branch)
+	run, _, err = waitForTestRun(ctx, client, id, run, prefix, nil)
+	return run, err
+}
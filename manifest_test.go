@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []ManifestEntry
+		wantErr string
+	}{
+		{
+			name: "nested",
+			in: `pipelines:
+  - pipeline: api
+    branch: master
+  - pipeline_id: 2f3a7a8e-0000-4000-8000-000000000000
+    branch: release
+    commit: 4b5c6d7
+`,
+			want: []ManifestEntry{
+				{Pipeline: "api", Branch: "master"},
+				{PipelineID: "2f3a7a8e-0000-4000-8000-000000000000", Branch: "release", Commit: "4b5c6d7"},
+			},
+		},
+		{
+			name: "top-level list with comments and quotes",
+			in: `---
+# release train
+- pipeline: "web"   # the frontend
+  branch: 'main'
+-
+  pipeline: worker
+  branch: main
+`,
+			want: []ManifestEntry{
+				{Pipeline: "web", Branch: "main"},
+				{Pipeline: "worker", Branch: "main"},
+			},
+		},
+		{name: "empty", in: "pipelines:\n", wantErr: "does not list any pipelines"},
+		{name: "no list", in: "pipeline: api\n", wantErr: `line 1: expected a list entry starting with "-"`},
+		{name: "no colon", in: "- pipeline api\n", wantErr: `line 1: expected "key: value"`},
+		{name: "unknown key", in: "- pipeline: api\n  branch: main\n  app: web\n", wantErr: `line 3: unknown key "app"`},
+		{name: "no pipeline", in: "- branch: main\n", wantErr: "manifest entry 1: pipeline or pipeline_id is required"},
+		{name: "no branch", in: "- pipeline: api\n", wantErr: "manifest entry 1 (api): branch is required"},
+	}
+	for _, tt := range tests {
+		entries, err := parseManifest(strings.NewReader(tt.in))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(entries) != len(tt.want) {
+			t.Errorf("%s: got %d entries, want %d", tt.name, len(entries), len(tt.want))
+			continue
+		}
+		for i, e := range entries {
+			if *e != tt.want[i] {
+				t.Errorf("%s: entry %d: got %+v, want %+v", tt.name, i, *e, tt.want[i])
+			}
+		}
+	}
+}
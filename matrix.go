@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// stringList is a flag that can be given more than once.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, " ") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// An envVar is one NAME=value setting.
+type envVar struct {
+	Name, Value string
+}
+
+func parseEnvVar(s string) (envVar, error) {
+	name, value, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok || name == "" {
+		return envVar{}, usagef("bad variable %q, want NAME=value", s)
+	}
+	return envVar{name, value}, nil
+}
+
+// A matrixCell is one combination of variables, and the run started for it.
+type matrixCell struct {
+	Env []envVar
+	Run *TestRun
+	Err error
+}
+
+func (c *matrixCell) label() string {
+	parts := make([]string, len(c.Env))
+	for i, v := range c.Env {
+		parts[i] = v.Name + "=" + v.Value
+	}
+	return strings.Join(parts, " ")
+}
+
+// matrixCells returns every combination of one setting from each of sets,
+// each comma-separated alternatives like "DB=postgres,DB=mysql", with
+// common added to all of them.
+func matrixCells(common []string, sets []string) ([]*matrixCell, error) {
+	base := make([]envVar, 0, len(common))
+	for _, s := range common {
+		v, err := parseEnvVar(s)
+		if err != nil {
+			return nil, err
+		}
+		base = append(base, v)
+	}
+	cells := []*matrixCell{{Env: base}}
+	for _, set := range sets {
+		var alts []envVar
+		for _, s := range splitLabels(set) {
+			v, err := parseEnvVar(s)
+			if err != nil {
+				return nil, err
+			}
+			alts = append(alts, v)
+		}
+		if len(alts) == 0 {
+			return nil, usagef("--env-set %q has no variables", set)
+		}
+		next := make([]*matrixCell, 0, len(cells)*len(alts))
+		for _, c := range cells {
+			for _, v := range alts {
+				env := append(append([]envVar(nil), c.Env...), v)
+				next = append(next, &matrixCell{Env: env})
+			}
+		}
+		cells = next
+	}
+	return cells, nil
+}
+
+// runMatrix starts a run of the tip of branch for each cell, one at a time,
+// waits for them all to finish, and prints a table of the results. The
+// pipeline's test config vars are put back the way they were at the end,
+// even if starting a run fails. If dryRun is true it prints the runs it
+// would start, and the config vars each would see, and stops.
+func runMatrix(ctx context.Context, client *Client, id types.PrefixUUID, branch, sourceURL string, cells []*matrixCell, yes, dryRun bool) error {
+	sha, err := fullSHA(localRef(branch))
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, c := range cells {
+		for _, v := range c.Env {
+			names[v.Name] = true
+		}
+	}
+	original, err := testConfigVars(ctx, client, id)
+	if err != nil {
+		return fmt.Errorf("could not read the pipeline's test config vars: %v", err)
+	}
+	if dryRun {
+		if err := printMatrixPlan(os.Stdout, cells, names, original); err != nil {
+			return err
+		}
+		fmt.Printf("Dry run: would start %s of %s (%s)\n", plural(len(cells), "run", "runs"), branch, shortSHA(sha))
+		return nil
+	}
+	if err := checkPermission(ctx, client, id, "matrix", permOperate); err != nil {
+		return err
+	}
+	if err := checkWritable("change the pipeline's test config vars"); err != nil {
+		return err
+	}
+	message, err := commitSubject(sha)
+	if err != nil {
+		return err
+	}
+	if sourceURL == "" {
+		if sourceURL, err = githubTarballURL(sha); err != nil {
+			return err
+		}
+	}
+	question := fmt.Sprintf("Start %s of %s (%s)? The pipeline's test config vars are changed while they start, so runs started by anyone else in the meantime will see them too.",
+		plural(len(cells), "run", "runs"), branch, shortSHA(sha))
+	if err := confirm(question, yes); err != nil {
+		return err
+	}
+	defer func() {
+		// Put the variables back even if ctx was cancelled.
+		rctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := setTestConfigVars(rctx, client, id, cellVars(nil, names, original)); err != nil {
+			warnf("could not restore the pipeline's test config vars: %v", err)
+		}
+	}()
+	for _, c := range cells {
+		if err := setTestConfigVars(ctx, client, id, cellVars(c, names, original)); err != nil {
+			return fmt.Errorf("setting %s: %v", c.label(), err)
+		}
+		run, err := createTestRun(ctx, client, id, branch, sha, message, sourceURL)
+		if err != nil {
+			return fmt.Errorf("starting the run for %s: %v", c.label(), err)
+		}
+		c.Run = run
+		fmt.Printf("Started test run #%d for %s\n", run.Number, c.label())
+		recordAudit(ctx, &AuditEntry{
+			Account:    client.ID,
+			Action:     "trigger",
+			PipelineID: id.String(),
+			RunNumber:  run.Number,
+			Target:     branch,
+			Text:       fmt.Sprintf("Started test run #%d on %s (%s) with %s", run.Number, branch, shortSHA(sha), c.label()),
+		})
+		if err := waitForNodesStarted(ctx, client, run); err != nil {
+			return err
+		}
+	}
+	var wg sync.WaitGroup
+	for _, c := range cells {
+		wg.Add(1)
+		go func(c *matrixCell) {
+			defer wg.Done()
+			prefix := "[" + c.label() + "] "
+			run, _, err := waitForTestRun(ctx, client, id, c.Run, prefix, nil)
+			if err != nil {
+				c.Err = err
+				return
+			}
+			c.Run = run
+		}(c)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Println()
+	printMatrix(os.Stdout, cells)
+	failed := 0
+	for _, c := range cells {
+		if c.Err != nil || c.Run.Status != StatusSucceeded {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return checkFailedf("%d of %s failed", failed, plural(len(cells), "matrix run", "matrix runs"))
+	}
+	return nil
+}
+
+// cellVars returns the config var changes that give every variable in
+// names its value in c, or its original value if c doesn't set it. A nil c
+// puts them all back. Variables that weren't set originally are removed.
+func cellVars(c *matrixCell, names map[string]bool, original map[string]string) map[string]*string {
+	vars := make(map[string]*string, len(names))
+	for name := range names {
+		if v, ok := original[name]; ok {
+			vars[name] = &v
+		} else {
+			vars[name] = nil
+		}
+	}
+	if c != nil {
+		for _, v := range c.Env {
+			value := v.Value
+			vars[v.Name] = &value
+		}
+	}
+	return vars
+}
+
+// printMatrixPlan prints one row per cell, with the config vars its run
+// would see, for matrix --dry-run. A variable the cell doesn't set keeps
+// the pipeline's value, or is shown as "(unset)".
+func printMatrixPlan(w io.Writer, cells []*matrixCell, names map[string]bool, original map[string]string) error {
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+	t := newTable(sorted...)
+	for _, c := range cells {
+		vars := cellVars(c, names, original)
+		row := make([]string, len(sorted))
+		for i, n := range sorted {
+			row[i] = "(unset)"
+			if v := vars[n]; v != nil {
+				row[i] = *v
+			}
+		}
+		t.add(row...)
+	}
+	return t.print(w)
+}
+
+// printMatrix prints one row per cell, with a column per variable.
+func printMatrix(w io.Writer, cells []*matrixCell) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, c := range cells {
+		for _, v := range c.Env {
+			if !seen[v.Name] {
+				seen[v.Name] = true
+				names = append(names, v.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	t := newTable(append(names, "RUN", "STATUS", "DURATION")...)
+	for _, c := range cells {
+		values := make(map[string]string)
+		for _, v := range c.Env {
+			values[v.Name] = v.Value
+		}
+		row := make([]string, 0, len(names)+3)
+		for _, n := range names {
+			row = append(row, values[n])
+		}
+		switch {
+		case c.Err != nil:
+			row = append(row, "#"+strconv.Itoa(c.Run.Number), "error: "+c.Err.Error(), "-")
+		default:
+			row = append(row, "#"+strconv.Itoa(c.Run.Number), string(c.Run.Status), c.Run.Duration().String())
+		}
+		t.add(row...)
+	}
+	t.print(w)
+}
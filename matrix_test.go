@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMatrixCells(t *testing.T) {
+	cells, err := matrixCells([]string{"RAILS_ENV=test"}, []string{"DB=postgres,DB=mysql", "RUBY=3.2,RUBY=3.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"RAILS_ENV=test DB=postgres RUBY=3.2",
+		"RAILS_ENV=test DB=postgres RUBY=3.3",
+		"RAILS_ENV=test DB=mysql RUBY=3.2",
+		"RAILS_ENV=test DB=mysql RUBY=3.3",
+	}
+	if len(cells) != len(want) {
+		t.Fatalf("got %d cells, want %d", len(cells), len(want))
+	}
+	for i, c := range cells {
+		if c.label() != want[i] {
+			t.Errorf("cell %d: got %q, want %q", i, c.label(), want[i])
+		}
+	}
+	if _, err := matrixCells(nil, []string{"DB"}); err == nil {
+		t.Error("got nil error for a variable without a value")
+	}
+}
+
+func TestCellVars(t *testing.T) {
+	names := map[string]bool{"DB": true, "RUBY": true}
+	original := map[string]string{"DB": "sqlite", "OTHER": "x"}
+	c := &matrixCell{Env: []envVar{{"RUBY", "3.3"}}}
+	vars := cellVars(c, names, original)
+	if len(vars) != 2 || vars["DB"] == nil || *vars["DB"] != "sqlite" || vars["RUBY"] == nil || *vars["RUBY"] != "3.3" {
+		t.Errorf("got %v, want DB=sqlite RUBY=3.3", vars)
+	}
+	restore := cellVars(nil, names, original)
+	if restore["DB"] == nil || *restore["DB"] != "sqlite" {
+		t.Errorf("restore: DB should go back to sqlite")
+	}
+	if v, ok := restore["RUBY"]; !ok || v != nil {
+		t.Errorf("restore: RUBY should be removed, got %v", v)
+	}
+}
+
+func TestPrintMatrixPlan(t *testing.T) {
+	cells, err := matrixCells(nil, []string{"DB=postgres,DB=mysql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	names := map[string]bool{"DB": true, "RUBY": true}
+	if err := printMatrixPlan(&buf, cells, names, map[string]string{"RUBY": "3.2"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "postgres") || !strings.Contains(lines[2], "mysql") || !strings.Contains(lines[2], "3.2") {
+		t.Errorf("got plan:\n%s", buf.String())
+	}
+}
+
+func TestNodesStarting(t *testing.T) {
+	// rerun and matrix both wait until the run's dynos boot, after building.
+	for _, s := range []RunStatus{StatusPending, StatusCreating, StatusBuilding} {
+		if !nodesStarting(s) {
+			t.Errorf("%s: nodes haven't started yet", s)
+		}
+	}
+	for _, s := range []RunStatus{StatusRunning, StatusSucceeded, StatusFailed} {
+		if nodesStarting(s) {
+			t.Errorf("%s: nodes have started", s)
+		}
+	}
+}
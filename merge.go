@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+type PullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	Head   struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	HTMLURL string `json:"html_url"`
+	Draft   bool   `json:"draft"`
+	// CreatedAt is when the pull request was opened.
+	CreatedAt time.Time `json:"created_at"`
+	// MergeableState is GitHub's summary of whether the pull request can
+	// be merged, like "clean" or "blocked". GitHub computes it in the
+	// background, so it is "unknown" or empty until that finishes.
+	MergeableState string `json:"mergeable_state"`
+	Labels         []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+func getPullRequest(ctx context.Context, gh *GitHubClient, owner, repo string, number int) (*PullRequest, error) {
+	req, err := gh.NewRequest("GET", "/repos/"+owner+"/"+repo+"/pulls/"+strconv.Itoa(number), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	pr := new(PullRequest)
+	if err := gh.Do(req, pr); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// pullRequestForBranch returns the open pull request whose head is branch.
+func pullRequestForBranch(ctx context.Context, gh *GitHubClient, owner, repo, branch string) (*PullRequest, error) {
+	query := url.Values{}
+	query.Set("head", owner+":"+branch)
+	query.Set("state", "open")
+	req, err := gh.NewRequest("GET", "/repos/"+owner+"/"+repo+"/pulls?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	prs := make([]*PullRequest, 0)
+	if err := gh.Do(req, &prs); err != nil {
+		return nil, err
+	}
+	if len(prs) == 0 {
+		return nil, notFoundf("no open pull request for branch %s", branch)
+	}
+	return prs[0], nil
+}
+
+// branchPullRequest returns the pull request for branch: the one it was
+// checked out from, if we know it, or else the open one whose head it is.
+func branchPullRequest(ctx context.Context, gh *GitHubClient, owner, repo, branch string) (*PullRequest, error) {
+	if co, ok := branchCheckout(branch); ok && co.Number > 0 {
+		return getPullRequest(ctx, gh, owner, repo, co.Number)
+	}
+	return pullRequestForBranch(ctx, gh, owner, repo, branch)
+}
+
+func validMergeMethod(method string) bool {
+	return method == "merge" || method == "squash" || method == "rebase"
+}
+
+// mergePullRequest merges pr with the given method. sha guards against
+// merging if the head has moved since we checked it.
+func mergePullRequest(ctx context.Context, gh *GitHubClient, owner, repo string, pr *PullRequest, method string) error {
+	data, err := json.Marshal(map[string]string{
+		"merge_method": method,
+		"sha":          pr.Head.SHA,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := gh.NewRequest("PUT", "/repos/"+owner+"/"+repo+"/pulls/"+strconv.Itoa(pr.Number)+"/merge", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	var resp struct {
+		SHA     string `json:"sha"`
+		Merged  bool   `json:"merged"`
+		Message string `json:"message"`
+	}
+	if err := gh.Do(req, &resp); err != nil {
+		return err
+	}
+	if !resp.Merged {
+		return fmt.Errorf("could not merge #%d: %s", pr.Number, resp.Message)
+	}
+	fmt.Printf("Merged #%d (%s) as %s\n", pr.Number, method, shortSHA(resp.SHA))
+	recordAudit(ctx, &AuditEntry{
+		Action: "merge",
+		Target: fmt.Sprintf("%s/%s#%d", owner, repo, pr.Number),
+		Text:   fmt.Sprintf("Merged %s/%s#%d (%s) as %s", owner, repo, pr.Number, method, shortSHA(resp.SHA)),
+	})
+	return nil
+}
+
+// mergeWhenGreen waits for the Heroku CI run on the head of a pull request,
+// and optionally every GitHub check, and then merges the pull request. If
+// args is empty we use the pull request for the current branch.
+func mergeWhenGreen(ctx context.Context, client *Client, id types.PrefixUUID, args []string, method string, allChecks bool) error {
+	if !validMergeMethod(method) {
+		return fmt.Errorf("invalid merge method %q, want merge, squash, or rebase", method)
+	}
+	gh, err := newGitHubClient()
+	if err != nil {
+		return err
+	}
+	owner, repo, err := githubRepo()
+	if err != nil {
+		return err
+	}
+	var pr *PullRequest
+	if len(args) > 0 {
+		number, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid pull request number %q", args[0])
+		}
+		pr, err = getPullRequest(ctx, gh, owner, repo, number)
+		if err != nil {
+			return err
+		}
+	} else {
+		branch, err := currentBranch()
+		if err != nil {
+			return err
+		}
+		pr, err = branchPullRequest(ctx, gh, owner, repo, branch)
+		if err != nil {
+			return err
+		}
+	}
+	if pr.State != "open" {
+		return fmt.Errorf("pull request #%d is %s", pr.Number, pr.State)
+	}
+	fmt.Printf("Waiting for #%d (%s) at %s\n", pr.Number, pr.Title, shortSHA(pr.Head.SHA))
+	run, err := findTestRun(ctx, client, id, pr.Head.Ref, pr.Head.SHA)
+	if err != nil {
+		return err
+	}
+	run, _, err = waitForTestRun(ctx, client, id, run, "", nil)
+	if err != nil {
+		return err
+	}
+	if run.Status != StatusSucceeded {
+		return checkFailedf("test run %s for #%d %s, not merging", run.ID.String()[:8], pr.Number, run.Status)
+	}
+	fmt.Printf("Test run %s succeeded after %s.\n", run.ID.String()[:8], run.Duration())
+	if allChecks {
+		if err := waitForGitHubChecks(ctx, gh, owner, repo, pr.Head.SHA); err != nil {
+			return err
+		}
+	}
+	return mergePullRequest(ctx, gh, owner, repo, pr, method)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// How often to check whether a pull request has become mergeable.
+const mergeablePollInterval = 30 * time.Second
+
+type pullRequestReview struct {
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	State string `json:"state"`
+}
+
+// reviewSummary describes the latest review of each reviewer on the pull
+// request, like "1 approval, 1 change request".
+func reviewSummary(ctx context.Context, gh *GitHubClient, owner, repo string, number int) (string, error) {
+	req, err := gh.NewRequest("GET", "/repos/"+owner+"/"+repo+"/pulls/"+strconv.Itoa(number)+"/reviews?per_page=100", nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	reviews := make([]*pullRequestReview, 0)
+	if err := gh.Do(req, &reviews); err != nil {
+		return "", err
+	}
+	// Reviews are oldest first, and a comment doesn't undo an approval or
+	// a change request.
+	latest := make(map[string]string)
+	for _, r := range reviews {
+		if r.State == "APPROVED" || r.State == "CHANGES_REQUESTED" || r.State == "DISMISSED" {
+			latest[r.User.Login] = r.State
+		}
+	}
+	approved, changes := 0, 0
+	for _, state := range latest {
+		switch state {
+		case "APPROVED":
+			approved++
+		case "CHANGES_REQUESTED":
+			changes++
+		}
+	}
+	summary := plural(approved, "approval", "approvals")
+	if changes > 0 {
+		summary += ", " + plural(changes, "change request", "change requests")
+	}
+	return summary, nil
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmtInt(n) + " " + many
+}
+
+// missingLabels returns the labels in want that pr doesn't have.
+func missingLabels(pr *PullRequest, want []string) []string {
+	have := make(map[string]bool, len(pr.Labels))
+	for _, l := range pr.Labels {
+		have[strings.ToLower(l.Name)] = true
+	}
+	missing := make([]string, 0)
+	for _, l := range want {
+		if !have[strings.ToLower(l)] {
+			missing = append(missing, l)
+		}
+	}
+	return missing
+}
+
+// mergeBlocker returns why pr can't be merged yet, or the empty string if it
+// can. It returns an error if waiting won't help.
+func mergeBlocker(ctx context.Context, gh *GitHubClient, owner, repo string, pr *PullRequest, labels []string) (string, error) {
+	if pr.State != "open" {
+		return "", fmt.Errorf("pull request #%d is %s", pr.Number, pr.State)
+	}
+	if missing := missingLabels(pr, labels); len(missing) > 0 {
+		return "waiting for the " + strings.Join(missing, ", ") + " label", nil
+	}
+	switch pr.MergeableState {
+	case "clean", "unstable", "has_hooks":
+		return "", nil
+	case "dirty":
+		return "", fmt.Errorf("pull request #%d has merge conflicts with its base branch", pr.Number)
+	case "draft":
+		return "waiting for the draft to be marked ready for review", nil
+	case "behind":
+		return "waiting for the branch to be brought up to date with its base", nil
+	case "blocked":
+		summary, err := reviewSummary(ctx, gh, owner, repo, pr.Number)
+		if err != nil {
+			return "", err
+		}
+		return "blocked by branch protection (" + summary + ")", nil
+	default:
+		return "waiting for GitHub to check mergeability", nil
+	}
+}
+
+// waitUntilMergeable waits until the pull request for run's branch has
+// everything branch protection asks for, like approvals and required checks,
+// plus every label in labels, and then notifies that it is ready to merge.
+func waitUntilMergeable(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun, labels []string) error {
+	gh, err := newGitHubClient()
+	if err != nil {
+		return err
+	}
+	owner, repo, err := githubRepo()
+	if err != nil {
+		return err
+	}
+	pr, err := branchPullRequest(ctx, gh, owner, repo, run.CommitBranch)
+	if err != nil {
+		return err
+	}
+	// The list of pull requests leaves out mergeable_state.
+	if pr.MergeableState == "" {
+		if pr, err = getPullRequest(ctx, gh, owner, repo, pr.Number); err != nil {
+			return err
+		}
+	}
+	last := ""
+	for {
+		if pr.Head.SHA != run.CommitSHA {
+			return fmt.Errorf("pull request #%d has moved to %s since run #%d tested %s", pr.Number, shortSHA(pr.Head.SHA), run.Number, shortSHA(run.CommitSHA))
+		}
+		reason, err := mergeBlocker(ctx, gh, owner, repo, pr, labels)
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			break
+		}
+		if reason != last {
+			fmt.Printf("#%d: %s\n", pr.Number, reason)
+			last = reason
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(mergeablePollInterval):
+		}
+		if pr, err = getPullRequest(ctx, gh, owner, repo, pr.Number); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("#%d (%s) is ready to merge: %s\n", pr.Number, pr.Title, pr.HTMLURL)
+	notifyBranch(ctx, client, id, run.CommitBranch, nil, func(pipeline string) string {
+		return fmt.Sprintf("%s: %s/%s#%d (%s) passed run #%d and is ready to merge: %s", pipeline, owner, repo, pr.Number, pr.Title, run.Number, pr.HTMLURL)
+	})
+	return nil
+}
+
+// splitLabels splits a comma-separated list of labels.
+func splitLabels(s string) []string {
+	labels := make([]string, 0)
+	for _, l := range strings.Split(s, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// metricsConfig is set from Config when heroku-ci starts.
+var metricsConfig struct {
+	// Addr is the host:port of a StatsD or DogStatsD agent. Metrics are only
+	// sent if it is set.
+	Addr string
+	// Prefix is prepended to every metric name.
+	Prefix string
+	// DogStatsD sends tags in the DogStatsD format. Plain StatsD has no
+	// tags, so the status goes in the metric name instead.
+	DogStatsD bool
+}
+
+// statsdTag cleans a tag value of the characters DogStatsD uses as
+// separators.
+func statsdTag(s string) string {
+	return strings.NewReplacer("|", "_", ",", "_", "#", "_", ":", "_").Replace(s)
+}
+
+// runMetrics formats the metrics for a finished run: its duration, its queue
+// time if known, and a count of runs finishing with its status.
+func runMetrics(run *TestRun, pipelineID string, queued time.Duration) []byte {
+	prefix := metricsConfig.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	var buf bytes.Buffer
+	if metricsConfig.DogStatsD {
+		tags := fmt.Sprintf("|#pipeline:%s,branch:%s,status:%s", statsdTag(pipelineID), statsdTag(run.CommitBranch), statsdTag(string(run.Status)))
+		fmt.Fprintf(&buf, "%srun.duration:%d|ms%s\n", prefix, run.Duration().Milliseconds(), tags)
+		if queued > 0 {
+			fmt.Fprintf(&buf, "%srun.queue_time:%d|ms%s\n", prefix, queued.Milliseconds(), tags)
+		}
+		fmt.Fprintf(&buf, "%srun.completed:1|c%s\n", prefix, tags)
+	} else {
+		fmt.Fprintf(&buf, "%srun.duration:%d|ms\n", prefix, run.Duration().Milliseconds())
+		if queued > 0 {
+			fmt.Fprintf(&buf, "%srun.queue_time:%d|ms\n", prefix, queued.Milliseconds())
+		}
+		fmt.Fprintf(&buf, "%srun.%s:1|c\n", prefix, run.Status)
+	}
+	return buf.Bytes()
+}
+
+// sendRunMetrics sends metrics for a finished run to the configured StatsD
+// agent, if there is one. queued is how long the run waited before it
+// started building, or zero if we didn't see it start. StatsD is fire and
+// forget, so errors are reported and otherwise ignored.
+func sendRunMetrics(run *TestRun, pipelineID string, queued time.Duration) {
+	if metricsConfig.Addr == "" {
+		return
+	}
+	conn, err := net.DialTimeout("udp", metricsConfig.Addr, time.Second)
+	if err != nil {
+		warnf("could not send metrics: %v", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write(runMetrics(run, pipelineID, queued)); err != nil {
+		warnf("could not send metrics: %v", err)
+	}
+}
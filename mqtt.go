@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mqttSink publishes the status of a branch as a retained MQTT message, for
+// build lights and other home automation gear. It speaks just enough MQTT
+// 3.1.1 to connect and publish at QoS 0.
+type mqttSink struct {
+	// broker is a tcp:// or mqtts:// URL, optionally with a username and
+	// password.
+	broker *url.URL
+	// topic may contain {pipeline} and {branch}, which are replaced with the
+	// run's pipeline ID and branch, escaped so slashes in branch names
+	// don't add topic levels.
+	topic string
+	// branch, if set, limits the sink to runs on that branch.
+	branch string
+}
+
+func newMQTTSink(broker, topic, branch string) (*mqttSink, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker URL %q: %v", broker, err)
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+	case "ssl", "mqtts":
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), "8883")
+		}
+	default:
+		return nil, fmt.Errorf("invalid MQTT broker URL %q, want tcp:// or mqtts://", broker)
+	}
+	if topic == "" {
+		topic = "heroku-ci/{pipeline}/{branch}/status"
+	}
+	return &mqttSink{broker: u, topic: topic, branch: branch}, nil
+}
+
+func (s *mqttSink) String() string { return s.broker.Redacted() }
+
+// mqttTopicLevel escapes s for one level of an MQTT topic. Unescaped, a
+// slash in a branch name would add a level, and + and # are wildcards that
+// brokers reject in published topics. Topics are UTF-8, so everything else
+// is left alone.
+var mqttTopicLevel = strings.NewReplacer("%", "%25", "/", "%2F", "+", "%2B", "#", "%23").Replace
+
+// Publish sends the run's status, like "succeeded", to the sink's topic.
+func (s *mqttSink) Publish(ctx context.Context, data []byte, attrs map[string]string) error {
+	if s.branch != "" && attrs["branch"] != s.branch {
+		return nil
+	}
+	topic := strings.NewReplacer("{pipeline}", attrs["pipeline"], "{branch}", mqttTopicLevel(attrs["branch"])).Replace(s.topic)
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := s.connect(conn); err != nil {
+		return err
+	}
+	// PUBLISH, QoS 0, retained so a light that reconnects gets the latest
+	// status.
+	var pub bytes.Buffer
+	writeMQTTString(&pub, topic)
+	pub.WriteString(attrs["status"])
+	if err := writeMQTTPacket(conn, 0x31, pub.Bytes()); err != nil {
+		return err
+	}
+	return writeMQTTPacket(conn, 0xe0, nil)
+}
+
+func (s *mqttSink) dial(ctx context.Context) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 5 * time.Second}
+	if s.broker.Scheme == "ssl" || s.broker.Scheme == "mqtts" {
+		td := &tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: s.broker.Hostname()}}
+		return td.DialContext(ctx, "tcp", s.broker.Host)
+	}
+	return d.DialContext(ctx, "tcp", s.broker.Host)
+}
+
+// connect sends CONNECT and waits for the broker to accept it.
+func (s *mqttSink) connect(conn net.Conn) error {
+	var body bytes.Buffer
+	writeMQTTString(&body, "MQTT")
+	body.WriteByte(4)   // protocol level 3.1.1
+	flags := byte(0x02) // clean session
+	user := s.broker.User
+	password, hasPassword := "", false
+	if user != nil {
+		flags |= 0x80
+		password, hasPassword = user.Password()
+		if hasPassword {
+			flags |= 0x40
+		}
+	}
+	body.WriteByte(flags)
+	body.Write([]byte{0, 30}) // keep alive, seconds
+	writeMQTTString(&body, fmt.Sprintf("heroku-ci-%d", time.Now().UnixNano()%1e9))
+	if user != nil {
+		writeMQTTString(&body, user.Username())
+		if hasPassword {
+			writeMQTTString(&body, password)
+		}
+	}
+	if err := writeMQTTPacket(conn, 0x10, body.Bytes()); err != nil {
+		return err
+	}
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("reading CONNACK: %v", err)
+	}
+	if ack[0] != 0x20 {
+		return errors.New("MQTT broker did not acknowledge the connection")
+	}
+	if ack[3] != 0 {
+		return fmt.Errorf("MQTT broker refused the connection (return code %d)", ack[3])
+	}
+	return nil
+}
+
+func writeMQTTString(buf *bytes.Buffer, s string) {
+	buf.WriteByte(byte(len(s) >> 8))
+	buf.WriteByte(byte(len(s)))
+	buf.WriteString(s)
+}
+
+// writeMQTTPacket writes a packet with the given fixed header byte and body,
+// encoding the body's length as MQTT's variable length integer.
+func writeMQTTPacket(w io.Writer, header byte, body []byte) error {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+	_, err := w.Write(packet)
+	return err
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWriteMQTTPacket(t *testing.T) {
+	// Remaining length boundaries from section 2.2.3 of the MQTT 3.1.1 spec.
+	tests := []struct {
+		length int
+		want   string
+	}{
+		{0, "00"},
+		{127, "7f"},
+		{128, "8001"},
+		{16383, "ff7f"},
+		{16384, "808001"},
+		{2097151, "ffff7f"},
+		{2097152, "80808001"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeMQTTPacket(&buf, 0x30, make([]byte, tt.length)); err != nil {
+			t.Fatal(err)
+		}
+		b := buf.Bytes()
+		if b[0] != 0x30 {
+			t.Errorf("length %d: got header %#x, want 0x30", tt.length, b[0])
+		}
+		if got := hex.EncodeToString(b[1 : 1+len(tt.want)/2]); got != tt.want {
+			t.Errorf("length %d: got remaining length %s, want %s", tt.length, got, tt.want)
+		}
+		if len(b) != 1+len(tt.want)/2+tt.length {
+			t.Errorf("length %d: packet is %d bytes", tt.length, len(b))
+		}
+	}
+}
+
+func TestWriteMQTTString(t *testing.T) {
+	var buf bytes.Buffer
+	writeMQTTString(&buf, "MQTT")
+	if got := hex.EncodeToString(buf.Bytes()); got != "00044d515454" {
+		t.Errorf("got %s, want 00044d515454", got)
+	}
+}
+
+// readMQTTPacket reads one packet the way a broker would.
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mult := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7f) * mult
+		mult *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func TestMQTTPublish(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	type packet struct {
+		header byte
+		body   []byte
+	}
+	packets := make(chan packet, 3)
+	// The broker reads CONNECT, accepts it, and reads two more packets. If
+	// anything goes wrong it closes packets, and the checks below fail.
+	go func() {
+		defer close(packets)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for i := 0; i < 3; i++ {
+			h, body, err := readMQTTPacket(r)
+			if err != nil {
+				return
+			}
+			packets <- packet{h, body}
+			if i == 0 {
+				conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+			}
+		}
+	}()
+	sink, err := newMQTTSink("tcp://light:secret@"+ln.Addr().String(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	err = sink.Publish(ctx, nil, map[string]string{"pipeline": "p1", "branch": "feature/x", "status": "succeeded"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect := <-packets
+	if connect.header != 0x10 || len(connect.body) < 10 {
+		t.Fatalf("got packet %#x, want CONNECT", connect.header)
+	}
+	// Protocol name and level, then flags: username, password, clean
+	// session; then a 30 second keep alive.
+	if got := hex.EncodeToString(connect.body[:10]); got != "00044d51545404c2001e" {
+		t.Errorf("got CONNECT variable header %s", got)
+	}
+	if !bytes.HasSuffix(connect.body, []byte("\x00\x05light\x00\x06secret")) {
+		t.Errorf("CONNECT doesn't end with the username and password: %q", connect.body)
+	}
+
+	publish := <-packets
+	if publish.header != 0x31 {
+		t.Errorf("got PUBLISH header %#x, want 0x31 for QoS 0, retained", publish.header)
+	}
+	topic := "heroku-ci/p1/feature%2Fx/status"
+	want := append([]byte{0, byte(len(topic))}, topic+"succeeded"...)
+	if !bytes.Equal(publish.body, want) {
+		t.Errorf("got PUBLISH body %q, want %q", publish.body, want)
+	}
+	if disconnect := <-packets; disconnect.header != 0xe0 || len(disconnect.body) != 0 {
+		t.Errorf("got %#x with %d bytes, want DISCONNECT", disconnect.header, len(disconnect.body))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// nodeStatus returns node's status, with its exit code if it has one.
+func nodeStatus(node *TestNode) string {
+	if node.ExitCode != nil {
+		return node.Status + " (exit " + strconv.Itoa(*node.ExitCode) + ")"
+	}
+	return node.Status
+}
+
+// nodeDone reports whether node has finished.
+func nodeDone(node *TestNode) bool {
+	return node.ExitCode != nil || RunStatus(node.Status).Terminal()
+}
+
+// A nodeWatch reports each node of a parallel run as it finishes, so a wait
+// on eight dynos shows which are done and which one failed, not just the
+// run's overall status.
+type nodeWatch struct {
+	run *TestRun
+	// single is true once we've seen the run has only one node, which the
+	// run's own status already describes.
+	single   bool
+	reported map[int]bool
+}
+
+func newNodeWatch(run *TestRun) *nodeWatch {
+	return &nodeWatch{run: run, reported: make(map[int]bool)}
+}
+
+// check prints a line for each node that has finished since the last check.
+// Errors only mean the nodes are reported later, so they are ignored.
+func (w *nodeWatch) check(ctx context.Context, client *Client, prefix string) {
+	if w.single {
+		return
+	}
+	nodes, err := getTestNodes(ctx, client, w.run.ID)
+	if err != nil || len(nodes) == 0 {
+		return
+	}
+	if len(nodes) == 1 {
+		w.single = true
+		return
+	}
+	done := 0
+	for _, node := range nodes {
+		if nodeDone(node) {
+			done++
+		}
+	}
+	for _, node := range nodes {
+		if !nodeDone(node) || w.reported[node.Index] {
+			continue
+		}
+		w.reported[node.Index] = true
+		fmt.Printf("%snode %d %s, %d of %d nodes done\n", prefix, node.Index, nodeStatus(node), done, len(nodes))
+	}
+}
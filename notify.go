@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// NotifierConfig configures one chat notification, from a [notify.<name>]
+// section of the config file.
+type NotifierConfig struct {
+	Name string
+	// Type is "slack", "discord", "matrix", or "irc".
+	Type string
+	// URL is the Slack or Discord webhook URL, the Matrix homeserver, or
+	// the IRC server, like ircs://irc.libera.chat:6697.
+	URL string
+	// Room is the Matrix room ID, or the IRC channel.
+	Room string
+	// Token is the Matrix access token.
+	Token string
+	// Nick is the IRC nickname. Defaults to "heroku-ci".
+	Nick string
+	// Pipeline, if set, limits the notifier to the pipeline with this name
+	// or ID.
+	Pipeline string
+	// Branch, if set, limits the notifier to branches matching this glob.
+	Branch string
+	// Owners, if set, limits the notifier to failing runs implicating one
+	// of these CODEOWNERS owners, like "@org/payments".
+	Owners []string
+}
+
+// A notifier posts a message to a chat room.
+type notifier interface {
+	Notify(ctx context.Context, text string) error
+	String() string
+}
+
+// A notifyRoute is a notifier and the runs it wants to hear about.
+type notifyRoute struct {
+	notifier
+	pipeline string
+	branch   string
+	owners   []string
+}
+
+// notifyRoutes is set from Config when heroku-ci starts.
+var notifyRoutes []*notifyRoute
+
+// newNotifyRoutes returns the notifiers configured in cfg.
+func newNotifyRoutes(cfg *Config) ([]*notifyRoute, error) {
+	routes := make([]*notifyRoute, 0, len(cfg.Notifiers))
+	for _, nc := range cfg.Notifiers {
+		if nc.Branch != "" {
+			if _, err := path.Match(nc.Branch, ""); err != nil {
+				return nil, fmt.Errorf("notify.%s: invalid branch pattern %q: %v", nc.Name, nc.Branch, err)
+			}
+		}
+		var n notifier
+		var err error
+		switch nc.Type {
+		case "slack":
+			n, err = newWebhookNotifier("slack", nc.URL, "text")
+		case "discord":
+			n, err = newWebhookNotifier("discord", nc.URL, "content")
+		case "matrix":
+			n, err = newMatrixNotifier(nc.URL, nc.Room, nc.Token)
+		case "irc":
+			n, err = newIRCNotifier(nc.URL, nc.Room, nc.Nick)
+		case "":
+			err = fmt.Errorf("missing type")
+		default:
+			err = fmt.Errorf("unknown type %q, want slack, discord, matrix, or irc", nc.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("notify.%s: %v", nc.Name, err)
+		}
+		routes = append(routes, &notifyRoute{notifier: n, pipeline: nc.Pipeline, branch: nc.Branch, owners: nc.Owners})
+	}
+	return routes, nil
+}
+
+// match reports whether the route wants to hear about runs on branch in the
+// pipeline with the given ID and name, implicating owners.
+func (r *notifyRoute) match(pipelineID, pipelineName, branch string, owners []string) bool {
+	if r.pipeline != "" && r.pipeline != pipelineID && r.pipeline != pipelineName {
+		return false
+	}
+	if r.branch != "" {
+		if ok, _ := path.Match(r.branch, branch); !ok {
+			return false
+		}
+	}
+	if len(r.owners) > 0 {
+		for _, o := range owners {
+			for _, want := range r.owners {
+				if strings.EqualFold(o, want) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// notifyText is the chat message for a finished run in the pipeline with the
+// given ID, with a link to it on the dashboard.
+func notifyText(pipeline string, id types.PrefixUUID, run *TestRun) string {
+	sha := run.CommitSHA
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	return fmt.Sprintf("%s: run #%d on %s (%s) %s after %s %s", pipeline, run.Number, run.CommitBranch, sha, run.Status, roundDuration(run.Duration()), dashboardURL(id, run))
+}
+
+// notifyRunCompleted tells every matching notifier that run finished.
+// Errors are reported and otherwise ignored, like publishRunCompleted.
+// If the run failed, the message names the CODEOWNERS of the files it
+// implicates.
+func notifyRunCompleted(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun) {
+	if len(notifyRoutes) == 0 || silenceNotification(id, run) {
+		return
+	}
+	owners := runOwners(ctx, client, id, run)
+	notifyBranch(ctx, client, id, run.CommitBranch, owners, func(pipeline string) string {
+		text := notifyText(pipeline, id, run)
+		if len(owners) > 0 {
+			text += " (owners: " + strings.Join(owners, ", ") + ")"
+		}
+		return text
+	})
+}
+
+// notifyBranch sends the message text returns to every notifier that
+// matches the pipeline, branch, and owners. text is passed the pipeline's
+// name, or its ID if no route needed the name.
+func notifyBranch(ctx context.Context, client *Client, id types.PrefixUUID, branch string, owners []string, text func(pipeline string) string) {
+	if len(notifyRoutes) == 0 {
+		return
+	}
+	// Only look up the pipeline's name if a route needs it.
+	name := ""
+	for _, r := range notifyRoutes {
+		if r.pipeline != "" && r.pipeline != id.String() {
+			name = pipelineName(ctx, client, id)
+			break
+		}
+	}
+	label := name
+	if label == "" {
+		label = id.String()
+	}
+	msg := text(label)
+	for _, r := range notifyRoutes {
+		if !r.match(id.String(), name, branch, owners) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(ctx, eventPublishTimeout)
+		err := r.Notify(ctx, msg)
+		cancel()
+		if err != nil {
+			warnf("could not notify %s: %v", r, err)
+		}
+	}
+}
+
+func pipelineName(ctx context.Context, client *Client, id types.PrefixUUID) string {
+	req, err := client.NewRequest("GET", "/pipelines/"+id.String(), nil)
+	if err != nil {
+		return ""
+	}
+	req = req.WithContext(ctx)
+	p := new(Pipeline)
+	if err := client.Do(req, p); err != nil {
+		return ""
+	}
+	return p.Name
+}
+
+// postJSON POSTs (or PUTs) v to rawurl as JSON.
+func postJSON(ctx context.Context, method, rawurl string, header http.Header, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, rawurl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for k, vals := range header {
+		req.Header[k] = vals
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// webhookNotifier posts to a Slack or Discord incoming webhook. The two only
+// differ in the name of the field holding the message.
+type webhookNotifier struct {
+	kind  string
+	url   string
+	field string
+}
+
+func newWebhookNotifier(kind, rawurl, field string) (*webhookNotifier, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid %s webhook URL %q", kind, rawurl)
+	}
+	return &webhookNotifier{kind: kind, url: rawurl, field: field}, nil
+}
+
+// String doesn't print the URL, since the URL is the webhook's secret.
+func (n *webhookNotifier) String() string { return n.kind + " webhook" }
+
+func (n *webhookNotifier) Notify(ctx context.Context, text string) error {
+	return postJSON(ctx, "POST", n.url, nil, map[string]string{n.field: text})
+}
+
+// desktopNotifier shows a desktop notification, with osascript on macOS and
+// notify-send elsewhere.
+type desktopNotifier struct {
+	command string
+}
+
+func newDesktopNotifier() (*desktopNotifier, error) {
+	command := "notify-send"
+	if runtime.GOOS == "darwin" {
+		command = "osascript"
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		return nil, fmt.Errorf("can't show desktop notifications: %s is not installed", command)
+	}
+	return &desktopNotifier{command: command}, nil
+}
+
+func (n *desktopNotifier) String() string { return "desktop notification" }
+
+func (n *desktopNotifier) Notify(ctx context.Context, text string) error {
+	var cmd *exec.Cmd
+	if n.command == "osascript" {
+		cmd = exec.CommandContext(ctx, "osascript", "-e", "display notification "+appleScriptString(text)+` with title "heroku-ci"`)
+	} else {
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=heroku-ci", "heroku-ci", text)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", n.command, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// appleScriptString returns s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// matrixNotifier sends a message to a Matrix room through the client-server
+// API.
+type matrixNotifier struct {
+	homeserver string
+	room       string
+	token      string
+}
+
+func newMatrixNotifier(homeserver, room, token string) (*matrixNotifier, error) {
+	if _, err := url.Parse(homeserver); err != nil || homeserver == "" {
+		return nil, fmt.Errorf("invalid Matrix homeserver URL %q", homeserver)
+	}
+	if room == "" || token == "" {
+		return nil, fmt.Errorf("Matrix notifications need a room and a token")
+	}
+	return &matrixNotifier{homeserver: strings.TrimSuffix(homeserver, "/"), room: room, token: token}, nil
+}
+
+func (n *matrixNotifier) String() string { return n.room }
+
+func (n *matrixNotifier) Notify(ctx context.Context, text string) error {
+	// The transaction ID makes retries of the same PUT idempotent.
+	txn := strconv.FormatInt(time.Now().UnixNano(), 10)
+	u := n.homeserver + "/_matrix/client/v3/rooms/" + url.PathEscape(n.room) + "/send/m.room.message/" + txn
+	header := http.Header{"Authorization": []string{"Bearer " + n.token}}
+	return postJSON(ctx, "PUT", u, header, map[string]string{"msgtype": "m.notice", "body": text})
+}
+
+// ircNotifier connects to an IRC server, joins a channel, says one thing,
+// and leaves.
+type ircNotifier struct {
+	server  *url.URL
+	channel string
+	nick    string
+}
+
+func newIRCNotifier(server, channel, nick string) (*ircNotifier, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IRC server URL %q: %v", server, err)
+	}
+	switch u.Scheme {
+	case "irc":
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), "6667")
+		}
+	case "ircs":
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), "6697")
+		}
+	default:
+		return nil, fmt.Errorf("invalid IRC server URL %q, want irc:// or ircs://", server)
+	}
+	if channel == "" {
+		return nil, fmt.Errorf("IRC notifications need a channel")
+	}
+	if !strings.HasPrefix(channel, "#") && !strings.HasPrefix(channel, "&") {
+		channel = "#" + channel
+	}
+	if nick == "" {
+		nick = "heroku-ci"
+	}
+	return &ircNotifier{server: u, channel: channel, nick: nick}, nil
+}
+
+func (n *ircNotifier) String() string { return n.channel + " on " + n.server.Hostname() }
+
+func (n *ircNotifier) Notify(ctx context.Context, text string) error {
+	d := &net.Dialer{Timeout: 5 * time.Second}
+	var conn net.Conn
+	var err error
+	if n.server.Scheme == "ircs" {
+		td := &tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: n.server.Hostname()}}
+		conn, err = td.DialContext(ctx, "tcp", n.server.Host)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", n.server.Host)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if pass, ok := n.server.User.Password(); ok {
+		fmt.Fprintf(conn, "PASS %s\r\n", pass)
+	}
+	fmt.Fprintf(conn, "NICK %s\r\nUSER %s 0 * :heroku-ci\r\n", n.nick, n.nick)
+	// Wait for the welcome message before joining, answering pings, which
+	// some servers send before they let a client in.
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("waiting for the IRC server to accept %s: %v", n.nick, err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "PING" {
+			fmt.Fprintf(conn, "PONG %s\r\n", strings.Join(fields[1:], " "))
+			continue
+		}
+		if len(fields) > 1 {
+			if fields[1] == "001" {
+				break
+			}
+			if fields[1] == "433" {
+				return fmt.Errorf("IRC nickname %s is already in use", n.nick)
+			}
+		}
+		if len(fields) > 0 && fields[0] == "ERROR" {
+			return fmt.Errorf("IRC server said %s", strings.TrimSpace(line))
+		}
+	}
+	text = strings.NewReplacer("\r", " ", "\n", " ").Replace(text)
+	_, err = fmt.Fprintf(conn, "JOIN %s\r\nPRIVMSG %s :%s\r\nQUIT\r\n", n.channel, n.channel, text)
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// isOffline reports whether err means we couldn't reach the Heroku API at
+// all, as opposed to the API returning an error.
+func isOffline(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// offlineBanner returns the notice printed above data read from disk while
+// offline.
+func offlineBanner(asOf time.Time) string {
+	if asOf.IsZero() {
+		return "OFFLINE, no local data"
+	}
+	return "OFFLINE, data as of " + fmtDateTimeMinutes(asOf)
+}
+
+// printOfflineBanner says, on stderr, that the runs that follow came from the
+// journal because the Heroku API couldn't be reached.
+func printOfflineBanner(asOf time.Time) {
+	fmt.Fprintf(os.Stderr, "*** %s; showing only runs heroku-ci has watched ***\n", offlineBanner(asOf))
+}
+
+// journalRuns rebuilds the runs in the pipeline from the journal, as of the
+// last time heroku-ci saw each of them, newest first. It also returns the
+// time of the newest journal entry for the pipeline. Only runs heroku-ci
+// has waited on are in the journal.
+func journalRuns(id types.PrefixUUID) ([]*TestRun, time.Time, error) {
+	events, err := readJournal(journalFilter{PipelineID: id.String()})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var asOf time.Time
+	byID := make(map[string]*TestRun)
+	for _, ev := range events {
+		if ev.PipelineID.String() != id.String() {
+			continue
+		}
+		key := ev.RunID.String()
+		run, ok := byID[key]
+		if !ok {
+			run = &TestRun{ID: ev.RunID, CreatedAt: ev.Time, CommitBranch: ev.CommitBranch, CommitSHA: ev.CommitSHA}
+			byID[key] = run
+		}
+		if ev.RunNumber > 0 {
+			run.Number = ev.RunNumber
+		}
+		run.Status = ev.Status
+		run.UpdatedAt = ev.Time
+		if ev.Time.After(asOf) {
+			asOf = ev.Time
+		}
+	}
+	runs := make([]*TestRun, 0, len(byID))
+	for _, run := range byID {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].CreatedAt.After(runs[j].CreatedAt)
+	})
+	return runs, asOf, nil
+}
+
+// offlineRuns is journalRuns for a command that couldn't reach the API: it
+// prints the offline banner before returning the runs.
+func offlineRuns(id types.PrefixUUID) ([]*TestRun, error) {
+	runs, asOf, err := journalRuns(id)
+	if err != nil {
+		return nil, err
+	}
+	printOfflineBanner(asOf)
+	return runs, nil
+}
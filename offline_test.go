@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// newOfflineClient returns a Client that can't reach the API, and a journal
+// with two runs on main, #7 succeeded and #8 running, and one failed run on
+// feature.
+func newOfflineClient(t *testing.T) (*Client, types.PrefixUUID) {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	cacheDisabled = true
+	journalConfig.Store = "file"
+	t.Cleanup(func() { journalConfig.Store = "" })
+	id, _ := types.NewPrefixUUID("0de00001-0000-4000-8000-000000000000")
+	start := time.Now().Add(-time.Hour)
+	for i, ev := range []struct {
+		run    string
+		number int
+		branch string
+		status RunStatus
+	}{
+		{"aaaa0007-0000-4000-8000-000000000000", 7, "main", StatusRunning},
+		{"aaaa0007-0000-4000-8000-000000000000", 7, "main", StatusSucceeded},
+		{"aaaa0006-0000-4000-8000-000000000000", 6, "feature", StatusFailed},
+		{"aaaa0008-0000-4000-8000-000000000000", 8, "main", StatusRunning},
+	} {
+		runID, _ := types.NewPrefixUUID(ev.run)
+		if err := (fileJournal{}).Append(&JournalEvent{
+			Time:         start.Add(time.Duration(i) * time.Minute),
+			PipelineID:   id,
+			RunID:        runID,
+			RunNumber:    ev.number,
+			CommitBranch: ev.branch,
+			Status:       ev.status,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Nothing listens on port 1, so every request fails to dial.
+	client := newAPIClient("", "token", herokuci.WithBaseURL("http://127.0.0.1:1"))
+	client.failFast = true
+	return client, id
+}
+
+func TestListFallsBackToJournal(t *testing.T) {
+	client, id := newOfflineClient(t)
+	runs, err := searchRuns(t.Context(), client, id, searchFilter{Branch: "main"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Number != 8 || runs[1].Number != 7 {
+		t.Fatalf("got %d runs, want #8 and #7", len(runs))
+	}
+	if runs[1].Status != StatusSucceeded {
+		t.Errorf("run #7 is %s, want its last status, succeeded", runs[1].Status)
+	}
+	runs, err = searchRuns(t.Context(), client, id, searchFilter{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Errorf("--limit=1: got %d runs", len(runs))
+	}
+}
+
+func TestStatusFallsBackToJournal(t *testing.T) {
+	client, id := newOfflineClient(t)
+	runs, err := matchBranchRuns(t.Context(), client, id, []*localBranch{{Name: "main"}, {Name: "feature"}, {Name: "other"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"main": 8, "feature": 6}
+	for _, br := range runs {
+		got := 0
+		if br.Run != nil {
+			got = br.Run.Number
+		}
+		if got != want[br.Branch.Name] {
+			t.Errorf("%s: got run #%d, want #%d", br.Branch.Name, got, want[br.Branch.Name])
+		}
+	}
+}
+
+func TestStatsFallsBackToJournal(t *testing.T) {
+	client, id := newOfflineClient(t)
+	bar := newProgressBar(os.Stderr, time.Now().Add(-24*time.Hour), time.Now())
+	stats, err := collectStats(t.Context(), client, id, time.Now().Add(-24*time.Hour), 24*time.Hour, nil, bar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// #8 is still running, so it isn't counted.
+	if stats.Runs != 2 {
+		t.Fatalf("got %d finished runs, want 2", stats.Runs)
+	}
+	if g := stats.ByBranch["main"]; g == nil || g.Passed != 1 {
+		t.Errorf("main: got %+v, want 1 passed", g)
+	}
+	if g := stats.ByBranch["feature"]; g == nil || g.Failed != 1 {
+		t.Errorf("feature: got %+v, want 1 failed", g)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// runToOpen returns the run named by open's arguments: a run number, like
+// 104 or #104, a run ID, a run shorthand, or a branch, for the newest run on
+// it. With no arguments it is the newest run on the current branch.
+func runToOpen(ctx context.Context, client *Client, id types.PrefixUUID, args []string) (*TestRun, error) {
+	if len(args) > 0 {
+		ref := strings.TrimPrefix(args[0], "#")
+		if _, err := strconv.Atoi(ref); err == nil {
+			return findRun(ctx, client, id, "", ref)
+		}
+		if _, err := types.NewPrefixUUID(ref); err == nil {
+			return findRun(ctx, client, id, "", ref)
+		}
+	}
+	branch, ref, err := runFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	branch = herokuBranch(ctx, branch)
+	if ref != "" {
+		return findRun(ctx, client, id, branch, ref)
+	}
+	return findTestRun(ctx, client, id, branch, "")
+}
+
+// openRun opens the run named by args on the Heroku dashboard, or if print
+// is true, prints the dashboard URL instead.
+func openRun(ctx context.Context, client *Client, id types.PrefixUUID, args []string, print bool) error {
+	run, err := runToOpen(ctx, client, id, args)
+	if err != nil {
+		return err
+	}
+	u := dashboardURL(id, run)
+	if print {
+		fmt.Println(u)
+		return nil
+	}
+	fmt.Printf("Opening run #%d on %s (%s): %s\n", run.Number, run.CommitBranch, run.Status, u)
+	return openURL(u)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// All of the files heroku-ci reads or writes live in one of three
+// directories, following the XDG base directory spec. If the XDG variable is
+// not set we use the platform's convention: ~/.config, ~/.cache and
+// ~/.local/share on Unix, ~/Library on macOS, and %AppData%/%LocalAppData%
+// on Windows.
+
+// configDir returns the directory heroku-ci reads configuration from.
+func configDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "heroku-ci"), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "heroku-ci"), nil
+}
+
+// cacheDir returns the directory heroku-ci stores cached data in. Anything
+// in this directory can be deleted without losing information.
+func cacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "heroku-ci"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "heroku-ci"), nil
+}
+
+// dataDir returns the directory heroku-ci stores persistent data in, like
+// the journal.
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "heroku-ci"), nil
+	}
+	switch runtime.GOOS {
+	case "windows":
+		dir := os.Getenv("LocalAppData")
+		if dir == "" {
+			return "", errors.New("%LocalAppData% is not defined")
+		}
+		return filepath.Join(dir, "heroku-ci"), nil
+	case "darwin", "ios":
+		homedir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(homedir, "Library", "Application Support", "heroku-ci"), nil
+	default:
+		homedir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(homedir, ".local", "share", "heroku-ci"), nil
+	}
+}
+
+func configPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config"), nil
+}
+
+func updateCachePath() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "update-check.json"), nil
+}
+
+func journalPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal.ndjson"), nil
+}
+
+// journalDBPath is the SQLite database the journal is kept in by default.
+func journalDBPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal.db"), nil
+}
+
+func setupPhasesPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "setup-phases.ndjson"), nil
+}
+
+func httpCacheDir() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "http"), nil
+}
+
+func logCacheDir() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logs"), nil
+}
+
+func auditLogPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audit.ndjson"), nil
+}
+
+func statusPath() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "status.json"), nil
+}
+
+func baselinesPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "baselines.json"), nil
+}
+
+func silencesPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "silences.json"), nil
+}
+
+func signaturesPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "failure-signatures.ndjson"), nil
+}
+
+func labelsPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "labels.json"), nil
+}
+
+// printPaths prints the location of every file heroku-ci uses.
+func printPaths() error {
+	paths := []struct {
+		name string
+		fn   func() (string, error)
+	}{
+		{"config", configPath},
+		{"update-cache", updateCachePath},
+		{"journal", journalLocation},
+		{"setup-phases", setupPhasesPath},
+		{"http-cache", httpCacheDir},
+		{"log-cache", logCacheDir},
+		{"status", statusPath},
+		{"audit-log", auditLogPath},
+		{"baselines", baselinesPath},
+		{"silences", silencesPath},
+		{"labels", labelsPath},
+		{"signatures", signaturesPath},
+	}
+	for _, p := range paths {
+		path, err := p.fn()
+		if err != nil {
+			return fmt.Errorf("could not determine %s path: %v", p.name, err)
+		}
+		fmt.Printf("%-14s %s\n", p.name, path)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A permission is a level of access to a pipeline, as Heroku's pipeline
+// permissions name them. Each level includes the ones before it.
+type permission int
+
+const (
+	permNone permission = iota
+	permView
+	permDeploy
+	permOperate
+	permAdmin
+)
+
+func (p permission) String() string {
+	switch p {
+	case permView:
+		return "view"
+	case permDeploy:
+		return "deploy"
+	case permOperate:
+		return "operate"
+	case permAdmin:
+		return "admin"
+	default:
+		return "no"
+	}
+}
+
+// pipelineOwner is the owner field of a pipeline.
+type pipelineOwner struct {
+	ID   string `json:"id"`
+	Type string `json:"type"` // "team" or "user"
+}
+
+// pipelineAccess describes who owns a pipeline and what the authenticated
+// user may do with it.
+type pipelineAccess struct {
+	Pipeline string
+	// Owner is the name of the team, or the email of the user, that owns
+	// the pipeline.
+	Owner     string
+	OwnerType string
+	// Have is the user's access. It is only meaningful if Known is true;
+	// for example, a team collaborator's access depends on the apps they
+	// were added to, which the preflight doesn't look at.
+	Have  permission
+	Known bool
+	// Why explains Have, like "you are a viewer on team acme".
+	Why string
+}
+
+// A PermissionError is returned when the user's access to a pipeline is too
+// low for what they asked to do, before anything is sent to Heroku.
+type PermissionError struct {
+	Action string
+	Need   permission
+	Access *pipelineAccess
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("you have %s access to %s (%s); %s requires %s. Ask an admin of %s for more access",
+		e.Access.Have, e.Access.Pipeline, e.Access.Why, e.Action, e.Need, e.Access.Owner)
+}
+
+// The access each team role has to the team's pipelines unless an admin
+// has changed the defaults.
+var teamRolePermission = map[string]permission{
+	"admin":  permAdmin,
+	"member": permOperate,
+	"viewer": permView,
+}
+
+// getPipelineAccess looks up the pipeline's owner and the user's access to
+// it.
+func getPipelineAccess(ctx context.Context, client *Client, id types.PrefixUUID) (*pipelineAccess, error) {
+	req, err := client.NewRequest("GET", "/pipelines/"+id.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	var p struct {
+		Name  string         `json:"name"`
+		Owner *pipelineOwner `json:"owner"`
+	}
+	if err := client.Do(req, &p); err != nil {
+		return nil, err
+	}
+	access := &pipelineAccess{Pipeline: p.Name}
+	if p.Owner == nil {
+		return access, nil
+	}
+	access.OwnerType = p.Owner.Type
+	me := strings.ToLower(client.ID)
+	switch p.Owner.Type {
+	case "user":
+		req, err := client.NewRequest("GET", "/users/"+p.Owner.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		var user struct {
+			Email string `json:"email"`
+		}
+		if err := client.Do(req, &user); err != nil {
+			return nil, err
+		}
+		access.Owner = user.Email
+		if strings.ToLower(user.Email) == me {
+			access.Have, access.Known, access.Why = permAdmin, true, "you own it"
+		}
+	case "team":
+		req, err := client.NewRequest("GET", "/teams/"+p.Owner.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		var team struct {
+			Name string `json:"name"`
+		}
+		if err := client.Do(req, &team); err != nil {
+			return nil, err
+		}
+		access.Owner = team.Name
+		req, err = client.NewRequest("GET", "/teams/"+p.Owner.ID+"/members", nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		members := make([]struct {
+			Email string `json:"email"`
+			Role  string `json:"role"`
+		}, 0)
+		if err := client.Do(req, &members); err != nil {
+			return nil, err
+		}
+		access.Known, access.Why = true, "you aren't a member of team "+team.Name
+		for _, m := range members {
+			if strings.ToLower(m.Email) != me {
+				continue
+			}
+			perm, ok := teamRolePermission[m.Role]
+			access.Have, access.Known = perm, ok
+			access.Why = fmt.Sprintf("you are %s %s on team %s", article(m.Role), m.Role, team.Name)
+			break
+		}
+	}
+	return access, nil
+}
+
+func article(word string) string {
+	if word != "" && strings.ContainsRune("aeiou", rune(word[0])) {
+		return "an"
+	}
+	return "a"
+}
+
+// checkPermission fails early with a PermissionError if the user clearly
+// doesn't have need access to the pipeline, so they find out before waiting
+// on anything else. If their access can't be worked out, for example
+// because the token can't list the team's members, it lets Heroku decide.
+func checkPermission(ctx context.Context, client *Client, id types.PrefixUUID, action string, need permission) error {
+	access, err := getPipelineAccess(ctx, client, id)
+	if err != nil || !access.Known || access.Have >= need {
+		return nil
+	}
+	return &PermissionError{Action: action, Need: need, Access: access}
+}
+
+// printPipelineAccess prints who owns the pipeline and what the user may
+// do with it.
+func printPipelineAccess(w io.Writer, access *pipelineAccess) {
+	switch {
+	case access.Owner == "":
+		fmt.Fprintf(w, "%s\n", access.Pipeline)
+	default:
+		fmt.Fprintf(w, "%s is owned by %s %s\n", access.Pipeline, access.OwnerType, access.Owner)
+	}
+	if !access.Known {
+		fmt.Fprintln(w, "Your access depends on the apps you collaborate on; Heroku will check it for each request.")
+		return
+	}
+	fmt.Fprintf(w, "You have %s access: %s.\n", access.Have, access.Why)
+	if access.Have > permNone {
+		fmt.Fprintln(w, "You can:")
+		for _, a := range pipelineActions {
+			if access.Have >= a.need {
+				fmt.Fprintf(w, "  %s\n", a.action)
+			}
+		}
+	}
+}
+
+// pipelineActions lists what heroku-ci can do to a pipeline, and the access
+// each needs.
+var pipelineActions = []struct {
+	action string
+	need   permission
+}{
+	{"wait for and inspect test runs", permView},
+	{"trigger and rerun test runs", permDeploy},
+	{"create review apps", permDeploy},
+	{"cancel test runs", permOperate},
+	{"rerun failed nodes", permOperate},
+	{"delete review apps", permOperate},
+	{"couple apps to the pipeline", permAdmin},
+}
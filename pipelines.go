@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	git "github.com/kevinburke/go-git"
+	"github.com/knq/ini"
+)
+
+// pipelineConfigFile, in the root of a repository, names the repository's
+// pipeline for everyone who checks it out, in the same format as git config:
+//
+//	[heroku]
+//	pipeline = my-pipeline
+const pipelineConfigFile = ".heroku-ci.ini"
+
+// pipelineFlag is the pipeline named with --pipeline or $HEROKU_CI_PIPELINE.
+var pipelineFlag string
+
+// How many pipelines to list when none look like the one that wasn't found.
+const maxListedPipelines = 10
+
+// repoPipeline returns the pipeline named in the repository's
+// pipelineConfigFile, or "" if there isn't one.
+func repoPipeline() string {
+	root, err := git.Root("")
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(filepath.Join(root, pipelineConfigFile))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	file, err := ini.Load(f)
+	if err != nil {
+		warnf("could not read %s: %v", pipelineConfigFile, err)
+		return ""
+	}
+	section := file.GetSection("heroku")
+	if section == nil {
+		return ""
+	}
+	return section.Get("pipeline")
+}
+
+// noPipelineError is the error for a checkout with no pipeline configured.
+// It lists the pipelines client can see, if there are only a few.
+func noPipelineError(ctx context.Context, client *Client) error {
+	msg := "no pipeline set: pass --pipeline, set HEROKU_CI_PIPELINE, run `git config heroku.pipeline <name>`, or add a " + pipelineConfigFile + " to the repository"
+	pipelines, err := listPipelines(ctx, client)
+	if err == nil && len(pipelines) > 0 && len(pipelines) <= maxListedPipelines {
+		names := make([]string, len(pipelines))
+		for i, p := range pipelines {
+			names[i] = p.Name
+		}
+		sort.Strings(names)
+		msg += ". Pipelines " + client.ID + " can see: " + strings.Join(names, ", ")
+	}
+	return usagef("%s", msg)
+}
+
+// similarPipelines returns the names of the pipelines client can see that
+// look like name, closest first, and true. If none do, but client can see
+// only a few pipelines, it returns all of their names, and false.
+func similarPipelines(ctx context.Context, client *Client, name string) ([]string, bool) {
+	pipelines, err := listPipelines(ctx, client)
+	if err != nil {
+		return nil, false
+	}
+	type match struct {
+		name string
+		dist int
+	}
+	matches := make([]match, 0)
+	all := make([]string, 0, len(pipelines))
+	want := strings.ToLower(name)
+	for _, p := range pipelines {
+		all = append(all, p.Name)
+		got := strings.ToLower(p.Name)
+		dist := editDistance(want, got)
+		// A third of the name is about as many typos as still look like
+		// the same word; "web" for "acme-web" is a match too.
+		if dist <= maxTypos(want) || (len(want) >= 3 && strings.Contains(got, want)) {
+			matches = append(matches, match{p.Name, dist})
+		}
+	}
+	if len(matches) == 0 {
+		if len(all) > maxListedPipelines {
+			return nil, false
+		}
+		sort.Strings(all)
+		return all, false
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].name < matches[j].name
+	})
+	if len(matches) > 3 {
+		matches = matches[:3]
+	}
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.name
+	}
+	return names, true
+}
+
+// maxTypos returns how many edits still make a name a close match for s.
+func maxTypos(s string) int {
+	n := len(s) / 3
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// quoteAll returns each of ss in double quotes.
+func quoteAll(ss []string) []string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = strconv.Quote(s)
+	}
+	return quoted
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// A prCheckout describes a local branch that was checked out from a pull
+// request, for example by `gh pr checkout`.
+type prCheckout struct {
+	// Number is the pull request number, if the branch tracks
+	// refs/pull/<n>/head.
+	Number int
+	// HeadRef is the name of the pull request's branch in the repository it
+	// came from, if the branch tracks a branch on another remote.
+	HeadRef string
+}
+
+func gitConfig(key string) string {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// branchCheckout reads the upstream configuration for branch and reports
+// whether it came from a pull request. Branches that track a named remote are
+// ordinary branches, even if the upstream branch has a different name.
+func branchCheckout(branch string) (prCheckout, bool) {
+	merge := gitConfig("branch." + branch + ".merge")
+	if strings.HasPrefix(merge, "refs/pull/") && strings.HasSuffix(merge, "/head") {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(merge, "refs/pull/"), "/head"))
+		if err == nil {
+			return prCheckout{Number: n}, true
+		}
+	}
+	// gh checks out pull requests from forks by pointing the branch's remote
+	// straight at the fork's URL, rather than adding a named remote.
+	remote := gitConfig("branch." + branch + ".remote")
+	if !strings.Contains(remote, "://") && !strings.Contains(remote, "@") {
+		return prCheckout{}, false
+	}
+	if ref := strings.TrimPrefix(merge, "refs/heads/"); ref != merge && ref != "" {
+		return prCheckout{HeadRef: ref}, true
+	}
+	return prCheckout{}, false
+}
+
+// herokuBranch returns the branch name Heroku records in commit_branch for
+// runs of the local branch. For a branch checked out from a fork's pull
+// request, that is the fork's branch name, which may differ from ours.
+func herokuBranch(ctx context.Context, branch string) string {
+	co, ok := branchCheckout(branch)
+	if !ok {
+		return branch
+	}
+	if co.HeadRef != "" {
+		return co.HeadRef
+	}
+	ref, err := prHeadRef(ctx, co.Number)
+	if err != nil {
+		warnf("%s was checked out from pull request #%d, but we could not look up its branch name: %v", branch, co.Number, err)
+		return branch
+	}
+	return ref
+}
+
+// prHeadRef returns the head branch name of pull request number on origin.
+func prHeadRef(ctx context.Context, number int) (string, error) {
+	gh, err := newGitHubClient()
+	if err != nil {
+		return "", err
+	}
+	owner, repo, err := githubRepo()
+	if err != nil {
+		return "", err
+	}
+	pr, err := getPullRequest(ctx, gh, owner, repo, number)
+	if err != nil {
+		return "", err
+	}
+	return pr.Head.Ref, nil
+}
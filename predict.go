@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// pushBase returns the ref a push of HEAD would be compared against: the
+// branch's upstream if it has one, or else origin's default branch.
+func pushBase(ctx context.Context) (string, error) {
+	for _, ref := range []string{"@{upstream}", "origin/HEAD"} {
+		out, err := exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "--verify", "--quiet", ref).Output()
+		if err == nil && strings.TrimSpace(string(out)) != "" {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	return "", errors.New("HEAD has no upstream and origin has no default branch; pass --base")
+}
+
+// unpushedFiles returns the files changed between the merge base of base
+// and HEAD, and HEAD.
+func unpushedFiles(ctx context.Context, base string) ([]string, error) {
+	out, err := exec.CommandContext(ctx, "git", "diff", "--name-only", "-z", base+"...HEAD").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s...HEAD: %v", base, err)
+	}
+	return strings.FieldsFunc(string(out), func(r rune) bool { return r == 0 }), nil
+}
+
+// Directory names too common to say what part of the code a file is in.
+var genericDirs = map[string]bool{
+	"app": true, "apps": true, "lib": true, "src": true, "pkg": true,
+	"internal": true, "cmd": true, "spec": true, "specs": true, "test": true,
+	"tests": true, "__tests__": true, "models": true, "controllers": true,
+	"views": true, "services": true, "components": true, "main": true,
+	"java": true, "go": true, "js": true, "ts": true,
+}
+
+// A codeArea is a part of the repository some changed files are in, like
+// app/billing, and the word that names it in test names, like "billing".
+type codeArea struct {
+	Dir   string
+	Word  string
+	Files int
+}
+
+// codeAreas groups files by the first directory in their path that isn't
+// a generic one like app or lib, so app/billing/invoice.rb and
+// spec/billing/invoice_spec.rb are both in the billing area.
+func codeAreas(files []string) []*codeArea {
+	byWord := make(map[string]*codeArea)
+	for _, f := range files {
+		parts := strings.Split(f, "/")
+		for i, p := range parts[:len(parts)-1] {
+			word := strings.ToLower(p)
+			if genericDirs[word] || strings.HasPrefix(word, ".") {
+				continue
+			}
+			a, ok := byWord[word]
+			if !ok {
+				a = &codeArea{Dir: strings.Join(parts[:i+1], "/"), Word: word}
+				byWord[word] = a
+			}
+			a.Files++
+			break
+		}
+	}
+	areas := make([]*codeArea, 0, len(byWord))
+	for _, a := range byWord {
+		areas = append(areas, a)
+	}
+	sort.Slice(areas, func(i, j int) bool { return areas[i].Dir < areas[j].Dir })
+	return areas
+}
+
+// words splits s into lowercase words at punctuation, spaces, and
+// CamelCase humps, so "billing" is found in BillingTest, billing_spec, and
+// test-billing alike, but not in "rebilling".
+func words(s string) []string {
+	var out []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			out = append(out, strings.ToLower(string(cur)))
+			cur = cur[:0]
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			flush()
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return out
+}
+
+// involves reports whether a failure with sig had a failing test in area:
+// one whose name has the area's words in a row.
+func (a *codeArea) involves(sig *FailureSignature) bool {
+	want := words(a.Word)
+	if len(want) == 0 {
+		return false
+	}
+	for _, t := range sig.Tests {
+		have := words(t)
+	next:
+		for i := 0; i+len(want) <= len(have); i++ {
+			for j, w := range want {
+				if have[i+j] != w {
+					continue next
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// A risk is how often recent failures involved a changed area.
+type risk struct {
+	Area     *codeArea
+	Failures int
+	Total    int
+}
+
+// predictRisks returns, for each area, how many of sigs involved it, the
+// riskiest first. Areas no failure involved are left out.
+func predictRisks(areas []*codeArea, sigs []*FailureSignature) []*risk {
+	risks := make([]*risk, 0)
+	for _, a := range areas {
+		r := &risk{Area: a, Total: len(sigs)}
+		for _, s := range sigs {
+			if a.involves(s) {
+				r.Failures++
+			}
+		}
+		if r.Failures > 0 {
+			risks = append(risks, r)
+		}
+	}
+	sort.SliceStable(risks, func(i, j int) bool { return risks[i].Failures > risks[j].Failures })
+	return risks
+}
+
+// recentSignatures returns the signatures of the pipeline's runs that
+// failed since since. New failures are fetched from Heroku and recorded; if
+// Heroku can't be reached, only those already recorded are used.
+func recentSignatures(ctx context.Context, client *Client, id types.PrefixUUID, since time.Time) ([]*FailureSignature, error) {
+	filter := searchFilter{Since: since, Statuses: []RunStatus{StatusFailed, StatusErrored}}
+	runs, err := searchRuns(ctx, client, id, filter, 0)
+	if err == nil {
+		return runSignatures(ctx, client, id, runs)
+	}
+	if !isOffline(err) {
+		return nil, err
+	}
+	warnf("could not reach Heroku (%v); using the failures recorded on this machine", err)
+	known, err := readSignatures()
+	if err != nil {
+		return nil, err
+	}
+	sigs := make([]*FailureSignature, 0)
+	for _, s := range known {
+		if s.PipelineID.String() == id.String() && !s.CreatedAt.Before(since) {
+			sigs = append(sigs, s)
+		}
+	}
+	return sigs, nil
+}
+
+// printPrediction prints a note on how risky the changes in files look,
+// going by sigs, the recent failures.
+func printPrediction(w io.Writer, base string, files []string, sigs []*FailureSignature, since string) {
+	if len(files) == 0 {
+		fmt.Fprintf(w, "No files changed since %s.\n", base)
+		return
+	}
+	areas := codeAreas(files)
+	fmt.Fprintf(w, "%s changed since %s.\n", plural(len(files), "file", "files"), base)
+	if len(sigs) == 0 {
+		fmt.Fprintf(w, "No runs failed in the last %s, so there is nothing to go on.\n", since)
+		return
+	}
+	risks := predictRisks(areas, sigs)
+	if len(risks) == 0 {
+		fmt.Fprintf(w, "None of the %s in the last %s involved tests for the areas you changed.\n", plural(len(sigs), "failure", "failures"), since)
+		return
+	}
+	for _, r := range risks {
+		fmt.Fprintf(w, "files under %s changed; %d%% of recent failures (%d of %d) involved %s tests\n",
+			r.Area.Dir, 100*r.Failures/r.Total, r.Failures, r.Total, r.Area.Word)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A ProgressEvent is one line of the --progress-fd stream, for wrappers that
+// show their own progress instead of parsing what we print.
+type ProgressEvent struct {
+	Time time.Time `json:"time"`
+	// Event is "status" when a run's status changes, "waiting" each time we
+	// print that a run is still going, and "finished" when we stop
+	// waiting for a run that has finished.
+	Event          string           `json:"event"`
+	PipelineID     types.PrefixUUID `json:"pipeline_id"`
+	RunID          types.PrefixUUID `json:"run_id"`
+	RunNumber      int              `json:"run_number,omitempty"`
+	Branch         string           `json:"branch"`
+	CommitSHA      string           `json:"commit_sha"`
+	PreviousStatus RunStatus        `json:"previous_status,omitempty"`
+	Status         RunStatus        `json:"status"`
+	// Elapsed is how long the run has been going, in seconds.
+	Elapsed float64 `json:"elapsed"`
+}
+
+var progress struct {
+	mu  sync.Mutex
+	out *os.File
+}
+
+// openProgressFD sends progress events to the already open file descriptor
+// fd, like git's --progress-fd style options.
+func openProgressFD(fd int) error {
+	if fd <= 2 {
+		return fmt.Errorf("--progress-fd must be 3 or more, not %d, so it doesn't mix with stdin, stdout, or stderr", fd)
+	}
+	f := os.NewFile(uintptr(fd), "progress")
+	if f == nil {
+		return fmt.Errorf("--progress-fd %d is not a valid file descriptor", fd)
+	}
+	if _, err := f.Stat(); err != nil {
+		return fmt.Errorf("--progress-fd %d is not open: %v", fd, err)
+	}
+	progress.out = f
+	return nil
+}
+
+// emitProgress writes an event for run to the progress stream, if there is
+// one. If the other end goes away we stop writing, rather than failing the
+// command.
+func emitProgress(event string, id types.PrefixUUID, run *TestRun, prev RunStatus) {
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	if progress.out == nil {
+		return
+	}
+	now := time.Now().UTC()
+	ev := &ProgressEvent{
+		Time:           now,
+		Event:          event,
+		PipelineID:     id,
+		RunID:          run.ID,
+		RunNumber:      run.Number,
+		Branch:         run.CommitBranch,
+		CommitSHA:      run.CommitSHA,
+		PreviousStatus: prev,
+		Status:         run.Status,
+	}
+	if !run.CreatedAt.IsZero() {
+		if run.InProgress() {
+			ev.Elapsed = now.Sub(run.CreatedAt).Seconds()
+		} else {
+			ev.Elapsed = run.Duration().Seconds()
+		}
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	if _, err := progress.out.Write(append(data, '\n')); err != nil {
+		if !errors.Is(err, syscall.EPIPE) {
+			warnf("could not write to --progress-fd, no longer writing progress: %v", err)
+		}
+		progress.out = nil
+	}
+}
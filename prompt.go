@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Glyphs printed by the prompt command.
+const (
+	promptSucceeded  = "✓"
+	promptFailed     = "✗"
+	promptErrored    = "!"
+	promptCancelled  = "-"
+	promptInProgress = "…"
+	// promptStale is appended when the recorded run is for a different
+	// commit than HEAD, or is older than the staleness window.
+	promptStale = "?"
+)
+
+// promptColor returns the 256-color terminal color for a test run status.
+func promptColor(status RunStatus, stale bool) int {
+	if stale {
+		return 244 // grey
+	}
+	switch status {
+	case StatusSucceeded:
+		return 2 // green
+	case StatusFailed, StatusErrored:
+		return 1 // red
+	case StatusCancelled:
+		return 244 // grey
+	default:
+		return 3 // yellow
+	}
+}
+
+// formatPrompt renders glyph for a prompt framework.
+//
+// "plain" prints the glyph alone. "starship" colors it with ANSI escapes,
+// for a custom module's command. "p10k" prints a `p10k segment` call to eval
+// from a powerlevel10k custom segment function.
+func formatPrompt(format, glyph string, color int) (string, error) {
+	switch format {
+	case "", "plain":
+		return glyph, nil
+	case "starship":
+		return fmt.Sprintf("\x1b[38;5;%dm%s\x1b[0m", color, glyph), nil
+	case "p10k":
+		return fmt.Sprintf("p10k segment -f %d -t '%s'", color, glyph), nil
+	default:
+		return "", fmt.Errorf("unknown prompt format %q, want plain, starship, or p10k", format)
+	}
+}
+
+// promptGlyph returns the glyph for a test run status.
+func promptGlyph(status RunStatus) string {
+	switch status {
+	case StatusSucceeded:
+		return promptSucceeded
+	case StatusFailed:
+		return promptFailed
+	case StatusErrored:
+		return promptErrored
+	case StatusCancelled:
+		return promptCancelled
+	default:
+		return promptInProgress
+	}
+}
+
+// prompt prints a short status for the current branch, for embedding in a
+// shell prompt. It only reads the local status file, never the network, and
+// prints nothing if there is no recorded status. Statuses for a different
+// commit than HEAD, or older than staleAfter, are marked stale.
+func prompt(format string, staleAfter time.Duration) error {
+	// Check the format first, so a typo is reported even outside a repo.
+	if _, err := formatPrompt(format, "", 0); err != nil {
+		return err
+	}
+	state, err := readRepoState()
+	if err != nil {
+		// Not in a repo or not on a branch; print nothing.
+		return nil
+	}
+	path, err := statusPath()
+	if err != nil {
+		return nil
+	}
+	sf, err := readStatusFile(path)
+	if err != nil {
+		return nil
+	}
+	status, ok := sf[state.Root][state.Branch]
+	if !ok {
+		return nil
+	}
+	glyph := promptGlyph(status.Status)
+	stale := staleAfter > 0 && time.Since(status.UpdatedAt) > staleAfter
+	if state.Head != "" && !strings.HasPrefix(state.Head, status.CommitSHA) && !strings.HasPrefix(status.CommitSHA, state.Head) {
+		stale = true
+	}
+	if stale {
+		glyph += promptStale
+	}
+	out, err := formatPrompt(format, glyph, promptColor(status.Status, stale))
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
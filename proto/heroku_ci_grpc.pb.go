@@ -0,0 +1,353 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: proto/heroku_ci.proto
+
+package herokucipb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	HerokuCI_GetStatus_FullMethodName     = "/herokuci.v1.HerokuCI/GetStatus"
+	HerokuCI_ListRuns_FullMethodName      = "/herokuci.v1.HerokuCI/ListRuns"
+	HerokuCI_ListRunEvents_FullMethodName = "/herokuci.v1.HerokuCI/ListRunEvents"
+	HerokuCI_StreamEvents_FullMethodName  = "/herokuci.v1.HerokuCI/StreamEvents"
+	HerokuCI_ListSilences_FullMethodName  = "/herokuci.v1.HerokuCI/ListSilences"
+	HerokuCI_Ack_FullMethodName           = "/herokuci.v1.HerokuCI/Ack"
+	HerokuCI_Snooze_FullMethodName        = "/herokuci.v1.HerokuCI/Snooze"
+)
+
+// HerokuCIClient is the client API for HerokuCI service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HerokuCIClient interface {
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error)
+	ListRuns(ctx context.Context, in *ListRunsRequest, opts ...grpc.CallOption) (*ListRunsResponse, error)
+	ListRunEvents(ctx context.Context, in *ListRunEventsRequest, opts ...grpc.CallOption) (*ListRunEventsResponse, error)
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JournalEvent], error)
+	ListSilences(ctx context.Context, in *ListSilencesRequest, opts ...grpc.CallOption) (*Silences, error)
+	Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*Silences, error)
+	Snooze(ctx context.Context, in *SnoozeRequest, opts ...grpc.CallOption) (*Silences, error)
+}
+
+type herokuCIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHerokuCIClient(cc grpc.ClientConnInterface) HerokuCIClient {
+	return &herokuCIClient{cc}
+}
+
+func (c *herokuCIClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*GetStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStatusResponse)
+	err := c.cc.Invoke(ctx, HerokuCI_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *herokuCIClient) ListRuns(ctx context.Context, in *ListRunsRequest, opts ...grpc.CallOption) (*ListRunsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRunsResponse)
+	err := c.cc.Invoke(ctx, HerokuCI_ListRuns_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *herokuCIClient) ListRunEvents(ctx context.Context, in *ListRunEventsRequest, opts ...grpc.CallOption) (*ListRunEventsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRunEventsResponse)
+	err := c.cc.Invoke(ctx, HerokuCI_ListRunEvents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *herokuCIClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JournalEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &HerokuCI_ServiceDesc.Streams[0], HerokuCI_StreamEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamEventsRequest, JournalEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HerokuCI_StreamEventsClient = grpc.ServerStreamingClient[JournalEvent]
+
+func (c *herokuCIClient) ListSilences(ctx context.Context, in *ListSilencesRequest, opts ...grpc.CallOption) (*Silences, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Silences)
+	err := c.cc.Invoke(ctx, HerokuCI_ListSilences_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *herokuCIClient) Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*Silences, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Silences)
+	err := c.cc.Invoke(ctx, HerokuCI_Ack_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *herokuCIClient) Snooze(ctx context.Context, in *SnoozeRequest, opts ...grpc.CallOption) (*Silences, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Silences)
+	err := c.cc.Invoke(ctx, HerokuCI_Snooze_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HerokuCIServer is the server API for HerokuCI service.
+// All implementations must embed UnimplementedHerokuCIServer
+// for forward compatibility.
+type HerokuCIServer interface {
+	GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error)
+	ListRuns(context.Context, *ListRunsRequest) (*ListRunsResponse, error)
+	ListRunEvents(context.Context, *ListRunEventsRequest) (*ListRunEventsResponse, error)
+	StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[JournalEvent]) error
+	ListSilences(context.Context, *ListSilencesRequest) (*Silences, error)
+	Ack(context.Context, *AckRequest) (*Silences, error)
+	Snooze(context.Context, *SnoozeRequest) (*Silences, error)
+	mustEmbedUnimplementedHerokuCIServer()
+}
+
+// UnimplementedHerokuCIServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedHerokuCIServer struct{}
+
+func (UnimplementedHerokuCIServer) GetStatus(context.Context, *GetStatusRequest) (*GetStatusResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedHerokuCIServer) ListRuns(context.Context, *ListRunsRequest) (*ListRunsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListRuns not implemented")
+}
+func (UnimplementedHerokuCIServer) ListRunEvents(context.Context, *ListRunEventsRequest) (*ListRunEventsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListRunEvents not implemented")
+}
+func (UnimplementedHerokuCIServer) StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[JournalEvent]) error {
+	return status.Error(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedHerokuCIServer) ListSilences(context.Context, *ListSilencesRequest) (*Silences, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListSilences not implemented")
+}
+func (UnimplementedHerokuCIServer) Ack(context.Context, *AckRequest) (*Silences, error) {
+	return nil, status.Error(codes.Unimplemented, "method Ack not implemented")
+}
+func (UnimplementedHerokuCIServer) Snooze(context.Context, *SnoozeRequest) (*Silences, error) {
+	return nil, status.Error(codes.Unimplemented, "method Snooze not implemented")
+}
+func (UnimplementedHerokuCIServer) mustEmbedUnimplementedHerokuCIServer() {}
+func (UnimplementedHerokuCIServer) testEmbeddedByValue()                  {}
+
+// UnsafeHerokuCIServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HerokuCIServer will
+// result in compilation errors.
+type UnsafeHerokuCIServer interface {
+	mustEmbedUnimplementedHerokuCIServer()
+}
+
+func RegisterHerokuCIServer(s grpc.ServiceRegistrar, srv HerokuCIServer) {
+	// If the following call panics, it indicates UnimplementedHerokuCIServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&HerokuCI_ServiceDesc, srv)
+}
+
+func _HerokuCI_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HerokuCIServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HerokuCI_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HerokuCIServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HerokuCI_ListRuns_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRunsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HerokuCIServer).ListRuns(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HerokuCI_ListRuns_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HerokuCIServer).ListRuns(ctx, req.(*ListRunsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HerokuCI_ListRunEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRunEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HerokuCIServer).ListRunEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HerokuCI_ListRunEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HerokuCIServer).ListRunEvents(ctx, req.(*ListRunEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HerokuCI_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(HerokuCIServer).StreamEvents(m, &grpc.GenericServerStream[StreamEventsRequest, JournalEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type HerokuCI_StreamEventsServer = grpc.ServerStreamingServer[JournalEvent]
+
+func _HerokuCI_ListSilences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSilencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HerokuCIServer).ListSilences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HerokuCI_ListSilences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HerokuCIServer).ListSilences(ctx, req.(*ListSilencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HerokuCI_Ack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HerokuCIServer).Ack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HerokuCI_Ack_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HerokuCIServer).Ack(ctx, req.(*AckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HerokuCI_Snooze_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnoozeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HerokuCIServer).Snooze(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HerokuCI_Snooze_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HerokuCIServer).Snooze(ctx, req.(*SnoozeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// HerokuCI_ServiceDesc is the grpc.ServiceDesc for HerokuCI service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var HerokuCI_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "herokuci.v1.HerokuCI",
+	HandlerType: (*HerokuCIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStatus",
+			Handler:    _HerokuCI_GetStatus_Handler,
+		},
+		{
+			MethodName: "ListRuns",
+			Handler:    _HerokuCI_ListRuns_Handler,
+		},
+		{
+			MethodName: "ListRunEvents",
+			Handler:    _HerokuCI_ListRunEvents_Handler,
+		},
+		{
+			MethodName: "ListSilences",
+			Handler:    _HerokuCI_ListSilences_Handler,
+		},
+		{
+			MethodName: "Ack",
+			Handler:    _HerokuCI_Ack_Handler,
+		},
+		{
+			MethodName: "Snooze",
+			Handler:    _HerokuCI_Snooze_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _HerokuCI_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/heroku_ci.proto",
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A prTiming is how long a pull request waited for green CI.
+type prTiming struct {
+	PR *PullRequest
+	// ToFirstGreen is the time from the pull request being opened to the
+	// first run on its branch that passed. It is 0 if the branch was
+	// already green when the pull request was opened.
+	ToFirstGreen   time.Duration
+	HasFirstGreen  bool
+	PushToGreen    time.Duration
+	HasPushToGreen bool
+}
+
+// pullRequestsSince returns the pull requests, open or closed, opened on
+// owner/repo since since, newest first.
+func pullRequestsSince(ctx context.Context, gh *GitHubClient, owner, repo string, since time.Time) ([]*PullRequest, error) {
+	found := make([]*PullRequest, 0)
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("state", "all")
+		query.Set("sort", "created")
+		query.Set("direction", "desc")
+		query.Set("per_page", "100")
+		query.Set("page", strconv.Itoa(page))
+		req, err := gh.NewRequest("GET", "/repos/"+owner+"/"+repo+"/pulls?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		prs := make([]*PullRequest, 0)
+		if err := gh.Do(req, &prs); err != nil {
+			return nil, err
+		}
+		for _, pr := range prs {
+			if pr.CreatedAt.Before(since) {
+				return found, nil
+			}
+			found = append(found, pr)
+		}
+		if len(prs) < 100 {
+			return found, nil
+		}
+	}
+}
+
+// timePR works out how long pr waited for green from runs, the pipeline's
+// runs in any order. The push of the head commit is taken to be when
+// Heroku started the first run for it, since pushes start runs.
+func timePR(pr *PullRequest, runs []*TestRun) *prTiming {
+	t := &prTiming{PR: pr}
+	// The last run on the branch that finished before the pull request was
+	// opened, and the first passing one after.
+	var before, firstGreen *TestRun
+	var headPushed, headGreen time.Time
+	for _, run := range runs {
+		if run.CommitSHA == pr.Head.SHA {
+			if headPushed.IsZero() || run.CreatedAt.Before(headPushed) {
+				headPushed = run.CreatedAt
+			}
+			if run.Status == StatusSucceeded && (headGreen.IsZero() || run.UpdatedAt.Before(headGreen)) {
+				headGreen = run.UpdatedAt
+			}
+		}
+		if run.CommitBranch != pr.Head.Ref || run.InProgress() {
+			continue
+		}
+		if run.UpdatedAt.Before(pr.CreatedAt) {
+			if before == nil || run.UpdatedAt.After(before.UpdatedAt) {
+				before = run
+			}
+		} else if run.Status == StatusSucceeded && (firstGreen == nil || run.UpdatedAt.Before(firstGreen.UpdatedAt)) {
+			firstGreen = run
+		}
+	}
+	switch {
+	case before != nil && before.Status == StatusSucceeded:
+		t.HasFirstGreen = true
+	case firstGreen != nil:
+		t.ToFirstGreen, t.HasFirstGreen = firstGreen.UpdatedAt.Sub(pr.CreatedAt), true
+	}
+	if !headGreen.IsZero() {
+		t.PushToGreen, t.HasPushToGreen = headGreen.Sub(headPushed), true
+	}
+	return t
+}
+
+// fmtWait formats how long a pull request waited, to the minute.
+func fmtWait(d time.Duration, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	return d.Round(time.Minute).String()
+}
+
+// printPRTimings prints the median and 90th percentile waits for green
+// across timings, and then the top newest pull requests.
+func printPRTimings(w io.Writer, timings []*prTiming, top int) error {
+	var first, push statsGroup
+	for _, t := range timings {
+		if t.HasFirstGreen {
+			first.Durations = append(first.Durations, t.ToFirstGreen)
+		}
+		if t.HasPushToGreen {
+			push.Durations = append(push.Durations, t.PushToGreen)
+		}
+	}
+	sortDurations(&first)
+	sortDurations(&push)
+	fmt.Fprintf(w, "%s opened\n", plural(len(timings), "pull request", "pull requests"))
+	s := newTable("WAIT", "PRS", "MEDIAN", "P90")
+	s.addf("opened to first green", fmtInt(len(first.Durations)), fmtWait(first.percentile(0.5), len(first.Durations) > 0), fmtWait(first.percentile(0.9), len(first.Durations) > 0))
+	s.addf("last push to green", fmtInt(len(push.Durations)), fmtWait(push.percentile(0.5), len(push.Durations) > 0), fmtWait(push.percentile(0.9), len(push.Durations) > 0))
+	if err := s.print(w); err != nil {
+		return err
+	}
+	if len(timings) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	shown := timings
+	if top > 0 && len(shown) > top {
+		shown = shown[:top]
+	}
+	t := newTable("PR", "BRANCH", "OPENED", "TO FIRST GREEN", "PUSH TO GREEN", "TITLE")
+	t.flex = []int{1, 5}
+	for _, pt := range shown {
+		t.addf("#"+strconv.Itoa(pt.PR.Number), pt.PR.Head.Ref, fmtDateTimeMinutes(pt.PR.CreatedAt),
+			fmtWait(pt.ToFirstGreen, pt.HasFirstGreen), fmtWait(pt.PushToGreen, pt.HasPushToGreen), firstLine(pt.PR.Title, 60))
+	}
+	if err := t.print(w); err != nil {
+		return err
+	}
+	if hidden := len(timings) - len(shown); hidden > 0 {
+		fmt.Fprintf(w, "(%s more; pass --top=0 to see them all)\n", fmtInt(hidden))
+	}
+	return nil
+}
+
+// prStats times each pull request opened on origin since start against the
+// pipeline's runs.
+func prStats(ctx context.Context, gh *GitHubClient, owner, repo string, runs []*TestRun, start time.Time) ([]*prTiming, error) {
+	prs, err := pullRequestsSince(ctx, gh, owner, repo, start)
+	if err != nil {
+		return nil, err
+	}
+	timings := make([]*prTiming, len(prs))
+	for i, pr := range prs {
+		timings[i] = timePR(pr, runs)
+	}
+	return timings, nil
+}
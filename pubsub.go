@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// pubsubSink publishes events to a Google Cloud Pub/Sub topic.
+type pubsubSink struct {
+	// topic is the topic's full name, projects/<project>/topics/<topic>.
+	topic string
+}
+
+func newPubSubSink(topic string) (*pubsubSink, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" {
+		return nil, fmt.Errorf("invalid Pub/Sub topic %q, want projects/<project>/topics/<topic>", topic)
+	}
+	return &pubsubSink{topic: topic}, nil
+}
+
+func (s *pubsubSink) String() string { return s.topic }
+
+// googleAccessToken returns an OAuth access token from
+// $GOOGLE_OAUTH_ACCESS_TOKEN, or else from the gcloud CLI.
+func googleAccessToken(ctx context.Context) (string, error) {
+	if tok := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); tok != "" {
+		return tok, nil
+	}
+	out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output()
+	if err != nil {
+		return "", fmt.Errorf("no Google credentials: set $GOOGLE_OAUTH_ACCESS_TOKEN or log in with gcloud (%v)", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (s *pubsubSink) Publish(ctx context.Context, data []byte, attrs map[string]string) error {
+	token, err := googleAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"messages": []map[string]interface{}{{
+			"data":       base64.StdEncoding.EncodeToString(data),
+			"attributes": attrs,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", "https://pubsub.googleapis.com/v1/"+s.topic+":publish", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("pubsub returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
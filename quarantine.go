@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// quarantineFile is the repository's list of known flaky tests, one name or
+// glob per line, relative to the top of the checkout. A run whose only
+// failures are on the list is reported as quarantined, with its own exit
+// code, so a deploy gate can choose to let it through while the flakes
+// get fixed.
+const quarantineFile = ".heroku-ci-quarantine"
+
+// A quarantine is the parsed quarantine file.
+type quarantine struct {
+	patterns []string
+}
+
+// loadQuarantine reads the quarantine file at the top of the checkout. It
+// returns nil and no error if there isn't one.
+func loadQuarantine() (*quarantine, error) {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), quarantineFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseQuarantine(data)
+}
+
+// parseQuarantine parses a quarantine file. Blank lines and anything after
+// a # are ignored.
+func parseQuarantine(data []byte) (*quarantine, error) {
+	q := new(quarantine)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: bad pattern %q: %v", quarantineFile, n, line, err)
+		}
+		q.patterns = append(q.patterns, line)
+	}
+	return q, scanner.Err()
+}
+
+// has reports whether test is on the list.
+func (q *quarantine) has(test string) bool {
+	for _, pat := range q.patterns {
+		if pat == test {
+			return true
+		}
+		if ok, _ := path.Match(pat, test); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// A quarantinedError is returned when a run failed, but only tests on the
+// quarantine list failed.
+type quarantinedError struct {
+	run   *TestRun
+	tests []string
+}
+
+func (e *quarantinedError) Error() string {
+	return fmt.Sprintf("test run #%d failed (quarantined only): %s", e.run.Number, strings.Join(e.tests, ", "))
+}
+
+// quarantinedFailures returns the failing tests of run, which failed, if
+// every one of them is quarantined. It returns nil if any other test
+// failed, if no failing tests can be found in the output, or if there is no
+// quarantine file, so the run is treated as an ordinary failure.
+func quarantinedFailures(ctx context.Context, client *Client, run *TestRun) []string {
+	if run.Status != StatusFailed {
+		return nil
+	}
+	q, err := loadQuarantine()
+	if err != nil {
+		warnf("could not read the quarantine list: %v", err)
+		return nil
+	}
+	if q == nil || len(q.patterns) == 0 {
+		return nil
+	}
+	out, err := fetchRunOutput(ctx, client, run)
+	if err != nil {
+		warnf("could not check the failures against the quarantine list: %v", err)
+		return nil
+	}
+	tests, err := out.FailingTests()
+	out.Close()
+	if err != nil {
+		warnf("could not check the failures against the quarantine list: %v", err)
+		return nil
+	}
+	if len(tests) == 0 {
+		return nil
+	}
+	for _, t := range tests {
+		if !q.has(t) {
+			return nil
+		}
+	}
+	return tests
+}
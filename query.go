@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// A resultQuery picks fields out of a result for a shell script, so it
+// doesn't need jq. It is either a Go template, like '{{.status}}', or
+// JSONPath expressions in braces, like '{.status} {.links.dashboard}', with
+// any text in between printed as is. Both see the result's JSON field names.
+type resultQuery struct {
+	tmpl *template.Template
+	// parts alternate between literal text and JSONPath expressions.
+	parts []queryPart
+}
+
+type queryPart struct {
+	text string
+	path []pathStep
+}
+
+// A pathStep is one step of a JSONPath expression: a field name, an array
+// index, or a wildcard matching every field or element.
+type pathStep struct {
+	field    string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parseQuery(s string) (*resultQuery, error) {
+	if strings.Contains(s, "{{") {
+		tmpl, err := template.New("query").Option("missingkey=error").Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --query template: %v", err)
+		}
+		return &resultQuery{tmpl: tmpl}, nil
+	}
+	if !strings.Contains(s, "{") {
+		// Let people leave off the braces for a single expression.
+		s = "{" + s + "}"
+	}
+	q := new(resultQuery)
+	for s != "" {
+		open := strings.IndexByte(s, '{')
+		if open < 0 {
+			q.parts = append(q.parts, queryPart{text: s})
+			break
+		}
+		if open > 0 {
+			q.parts = append(q.parts, queryPart{text: s[:open]})
+		}
+		end := strings.IndexByte(s[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid --query %q: missing }", s)
+		}
+		path, err := parsePath(s[open+1 : open+end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid --query: %v", err)
+		}
+		q.parts = append(q.parts, queryPart{path: path})
+		s = s[open+end+1:]
+	}
+	return q, nil
+}
+
+// parsePath parses expressions like ".nodes[0].status", "$.links.api" and
+// ".nodes[*].exit_code".
+func parsePath(expr string) ([]pathStep, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	if s == "" || s == "." {
+		return []pathStep{}, nil
+	}
+	steps := make([]pathStep, 0)
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			n := strings.IndexAny(s, ".[")
+			if n < 0 {
+				n = len(s)
+			}
+			name := s[:n]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("empty field name in %q", expr)
+			case "*":
+				steps = append(steps, pathStep{wildcard: true})
+			default:
+				steps = append(steps, pathStep{field: name})
+			}
+			s = s[n:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ] in %q", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			switch {
+			case inner == "*":
+				steps = append(steps, pathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, pathStep{field: inner[1 : len(inner)-1]})
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q in %q", inner, expr)
+				}
+				steps = append(steps, pathStep{index: i, isIndex: true})
+			}
+			s = s[end+1:]
+		default:
+			if len(steps) > 0 {
+				return nil, fmt.Errorf("unexpected %q in %q", s[0], expr)
+			}
+			// "status" is short for ".status".
+			s = "." + s
+		}
+	}
+	return steps, nil
+}
+
+// eval returns the values at path in v, which was decoded from JSON.
+func eval(v interface{}, path []pathStep) []interface{} {
+	vals := []interface{}{v}
+	for _, step := range path {
+		next := make([]interface{}, 0, len(vals))
+		for _, val := range vals {
+			switch val := val.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					for _, k := range fieldNames(val) {
+						next = append(next, val[k])
+					}
+				} else if f, ok := val[step.field]; ok && !step.isIndex {
+					next = append(next, f)
+				}
+			case []interface{}:
+				switch {
+				case step.wildcard:
+					next = append(next, val...)
+				case step.isIndex:
+					i := step.index
+					if i < 0 {
+						i += len(val)
+					}
+					if i >= 0 && i < len(val) {
+						next = append(next, val[i])
+					}
+				}
+			}
+		}
+		vals = next
+	}
+	return vals
+}
+
+func fieldNames(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// queryValue formats a value the way a shell script wants it: strings bare,
+// null as the empty string, and anything else as JSON.
+func queryValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Print writes the parts of result the query asks for to w, followed by a
+// newline.
+func (q *resultQuery) Print(w io.Writer, result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if q.tmpl != nil {
+		if err := q.tmpl.Execute(&buf, v); err != nil {
+			return fmt.Errorf("--query: %v", err)
+		}
+	} else {
+		for _, part := range q.parts {
+			if part.path == nil {
+				buf.WriteString(part.text)
+				continue
+			}
+			vals := eval(v, part.path)
+			if len(vals) == 0 {
+				return errors.New("--query: no value at " + describePath(part.path))
+			}
+			for i, val := range vals {
+				if i > 0 {
+					buf.WriteByte(' ')
+				}
+				s, err := queryValue(val)
+				if err != nil {
+					return err
+				}
+				buf.WriteString(s)
+			}
+		}
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// PrintEach prints the query for each of results, one after the other.
+func (q *resultQuery) PrintEach(w io.Writer, results []interface{}) error {
+	for _, result := range results {
+		if err := q.Print(w, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func describePath(path []pathStep) string {
+	var b strings.Builder
+	for _, step := range path {
+		switch {
+		case step.wildcard:
+			b.WriteString("[*]")
+		case step.isIndex:
+			fmt.Fprintf(&b, "[%d]", step.index)
+		default:
+			b.WriteString("." + step.field)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQueryListAndStatus(t *testing.T) {
+	runs := []interface{}{
+		&TestRun{Number: 105, Status: StatusFailed, CommitBranch: "feature"},
+		&TestRun{Number: 104, Status: StatusSucceeded, CommitBranch: "main"},
+	}
+	branches := []interface{}{
+		&branchRun{Branch: &localBranch{Name: "main", Tip: "e7f6a5b4"}, Run: &TestRun{Number: 104, Status: StatusSucceeded, CommitSHA: "e7f6a5b4"}},
+		&branchRun{Branch: &localBranch{Name: "new", Tip: "1a2b3c4d"}},
+	}
+	tests := []struct {
+		query   string
+		results []interface{}
+		want    string
+	}{
+		{"{.number} {.status}", runs, "105 failed\n104 succeeded\n"},
+		{"{{.commit_branch}}", runs, "feature\nmain\n"},
+		{"{.branch}: {.note}", branches, "main: ready\nnew: no recent runs\n"},
+		{"{{.branch}} {{if .run}}#{{.run.number}}{{else}}-{{end}}", branches, "main #104\nnew -\n"},
+	}
+	for _, tt := range tests {
+		q, err := parseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("parseQuery(%q): %v", tt.query, err)
+		}
+		var buf bytes.Buffer
+		if err := q.PrintEach(&buf, tt.results); err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQueryMissingField(t *testing.T) {
+	q, err := parseQuery("{.run.status}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := &branchRun{Branch: &localBranch{Name: "new"}}
+	if err := q.Print(new(bytes.Buffer), br); err == nil {
+		t.Error("got nil error for a branch with no run")
+	}
+}
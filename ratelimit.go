@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// apiLimits is set from Config when heroku-ci starts. Every goroutine in the
+// process shares one Client, and so one budget, however many pipelines it
+// watches.
+var apiLimits = struct {
+	// RequestsPerSecond is the sustained rate of Heroku API requests.
+	// Heroku allows 4500 an hour per account, 1.25 a second.
+	RequestsPerSecond float64
+	// Burst is how many requests can be sent at once after a quiet spell.
+	Burst int
+	// MaxConcurrent caps the requests in flight at once.
+	MaxConcurrent int
+}{RequestsPerSecond: 1.25, Burst: 20, MaxConcurrent: 8}
+
+// A requestLimiter is a token bucket plus a cap on concurrent requests.
+type requestLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	slots  chan struct{}
+	// warned is set once the limiter has warned that it is holding
+	// requests back.
+	warned bool
+}
+
+// How long the limiter holds a request back before it warns that requests
+// are being paced.
+const pacingWarnAfter = 5 * time.Second
+
+// How much of the account's Heroku rate limit to leave for other tools, like
+// the Heroku CLI and dashboard, that share it.
+const rateLimitReserve = 50
+
+// How many times to send a request Heroku rejected with a 429. A 429 means
+// the request wasn't processed, so writes are retried too.
+const rateLimitAttempts = 5
+
+// The longest we back off after a 429, unless Heroku asks for longer.
+const maxRateLimitBackoff = time.Minute
+
+// newRequestLimiter returns a limiter for the configured apiLimits, or nil
+// if they are turned off.
+func newRequestLimiter() *requestLimiter {
+	if apiLimits.RequestsPerSecond <= 0 && apiLimits.MaxConcurrent <= 0 {
+		return nil
+	}
+	l := &requestLimiter{
+		rate:  apiLimits.RequestsPerSecond,
+		burst: float64(apiLimits.Burst),
+		last:  time.Now(),
+	}
+	if l.burst < 1 {
+		l.burst = 1
+	}
+	l.tokens = l.burst
+	if apiLimits.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, apiLimits.MaxConcurrent)
+	}
+	return l
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (l *requestLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	d := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	if d > pacingWarnAfter && !l.warned {
+		l.warned = true
+		warnf("pacing Heroku API requests to stay under the rate limit; waiting %s (see [api] in the config file)", d.Round(time.Second))
+	}
+	return d
+}
+
+// observeRemaining lowers the limiter's budget to what Heroku says is left
+// of the account's rate limit, in the RateLimit-Remaining header, less
+// rateLimitReserve. Other clients of the same account spend the same limit,
+// so this can be much less than our own bucket thinks.
+func (l *requestLimiter) observeRemaining(remaining int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return
+	}
+	left := float64(remaining - rateLimitReserve)
+	if left < -rateLimitReserve {
+		left = -rateLimitReserve
+	}
+	if left < l.tokens {
+		l.tokens = left
+	}
+}
+
+// rateLimitBackoff returns how long to wait before sending a request again
+// after its attempt'th try got a 429: exponential and jittered, but never
+// less than Heroku's Retry-After.
+func rateLimitBackoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := time.Second << uint(attempt-1)
+	if d > maxRateLimitBackoff {
+		d = maxRateLimitBackoff
+	}
+	d = jitter(d)
+	if retryAfter > d {
+		d = retryAfter
+	}
+	return d
+}
+
+// A rateLimitTransport passes the RateLimit-Remaining header of each
+// response to a limiter.
+type rateLimitTransport struct {
+	next    http.RoundTripper
+	limiter *requestLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(r)
+	if err == nil {
+		if n, err := strconv.Atoi(resp.Header.Get("RateLimit-Remaining")); err == nil {
+			t.limiter.observeRemaining(n)
+		}
+	}
+	return resp, err
+}
+
+// wait blocks until a request may be sent. Call the returned func once the
+// request is done.
+func (l *requestLimiter) wait(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if d := l.reserve(); d > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(d):
+		}
+	}
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case l.slots <- struct{}{}:
+	}
+	return func() { <-l.slots }, nil
+}
+
+// jitter returns d plus or minus up to a fifth, so watchers that started
+// together drift apart instead of polling in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	spread := int64(d) / 5
+	return d - time.Duration(spread) + time.Duration(rand.Int63n(2*spread+1))
+}
+
+// stagger returns how long the i'th of n watchers should wait before its
+// first poll, spreading them evenly over one poll interval.
+func stagger(i, n int, interval time.Duration) time.Duration {
+	if n <= 1 {
+		return 0
+	}
+	return interval * time.Duration(i) / time.Duration(n)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// A redactRule is a pattern for secrets. The first and second submatches,
+// if there are any, are kept on either side of the mask, so
+// "Authorization: Basic abc" becomes "Authorization: [REDACTED]".
+type redactRule struct {
+	re *regexp.Regexp
+}
+
+func (r redactRule) replace(s string) string {
+	switch r.re.NumSubexp() {
+	case 0:
+		return r.re.ReplaceAllLiteralString(s, redacted)
+	case 1:
+		return r.re.ReplaceAllString(s, "${1}"+redacted)
+	default:
+		return r.re.ReplaceAllString(s, "${1}"+redacted+"${2}")
+	}
+}
+
+// defaultRedactRules match credentials that show up in HTTP traffic and
+// build output.
+var defaultRedactRules = []redactRule{
+	{regexp.MustCompile(`(?im)^((?:proxy-)?authorization:\s*|(?:set-)?cookie:\s*|x-amz-security-token:\s*)\S.*$`)},
+	{regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/=-]+`)},
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^@\s/]+(@)`)},
+	{regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{regexp.MustCompile(`(hooks\.slack\.com/services/)[A-Za-z0-9/]+`)},
+	{regexp.MustCompile(`(discord(?:app)?\.com/api/webhooks/)[^\s"']+`)},
+	{regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`)},
+	{regexp.MustCompile(`\bHRKU-[A-Za-z0-9_-]{20,}`)},
+	{regexp.MustCompile(`(\b[A-Za-z0-9_]*(?:_KEY|_TOKEN|_SECRET|_PASSWORD)=)[^\s'"]+`)},
+}
+
+// defaultSecretEnv are the environment variables whose values are always
+// redacted.
+var defaultSecretEnv = []string{"*_KEY", "*_TOKEN", "*_SECRET", "*_PASSWORD", "HEROKU_API_KEY", "GITHUB_TOKEN"}
+
+// A redactor masks secrets in text heroku-ci prints: known secret values,
+// like the Heroku token, and anything matching a redaction rule.
+type redactor struct {
+	mu      sync.Mutex
+	secrets []string
+	rules   []redactRule
+}
+
+// redact is used for everything heroku-ci prints that might contain a
+// secret.
+var redact = &redactor{rules: defaultRedactRules}
+
+// addSecret redacts every occurrence of s. Short values are ignored, since
+// masking them would mangle ordinary output.
+func (r *redactor) addSecret(s string) {
+	if len(s) < 8 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, have := range r.secrets {
+		if have == s {
+			return
+		}
+	}
+	r.secrets = append(r.secrets, s)
+}
+
+// addEnv redacts the values of environment variables whose names match any
+// of the glob patterns.
+func (r *redactor) addEnv(patterns []string) {
+	for _, kv := range os.Environ() {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			continue
+		}
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, kv[:i]); ok {
+				r.addSecret(kv[i+1:])
+				break
+			}
+		}
+	}
+}
+
+// String returns s with secrets masked.
+func (r *redactor) String(s string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	for _, rule := range r.rules {
+		s = rule.replace(s)
+	}
+	return s
+}
+
+// configureRedaction adds the secrets and patterns in cfg to redact.
+func configureRedaction(cfg *Config) error {
+	redact.addEnv(append(append([]string(nil), defaultSecretEnv...), cfg.RedactEnv...))
+	for name, pattern := range cfg.RedactPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid redact pattern %s: %v", name, err)
+		}
+		redact.mu.Lock()
+		redact.rules = append(redact.rules, redactRule{re})
+		redact.mu.Unlock()
+	}
+	for _, nc := range cfg.Notifiers {
+		redact.addSecret(nc.Token)
+		if nc.Type == "slack" || nc.Type == "discord" {
+			redact.addSecret(nc.URL)
+		}
+	}
+	for _, tok := range cfg.APITokens {
+		redact.addSecret(tok.Token)
+	}
+	redact.addSecret(cfg.AuditWebhook)
+	return nil
+}
+
+// A redactWriter masks secrets in everything written to it before passing
+// it on. Output is held until a full line has been written, so a secret
+// split across two writes is still caught; Flush writes out the rest.
+type redactWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// redactWriters are flushed by flushRedactWriters, before heroku-ci exits.
+var redactWriters struct {
+	mu sync.Mutex
+	ws []*redactWriter
+}
+
+func newRedactWriter(w io.Writer) *redactWriter {
+	rw := &redactWriter{w: w}
+	redactWriters.mu.Lock()
+	redactWriters.ws = append(redactWriters.ws, rw)
+	redactWriters.mu.Unlock()
+	return rw
+}
+
+// flushRedactWriters flushes every redactWriter, so the last line of output
+// isn't lost when it doesn't end in a newline.
+func flushRedactWriters() {
+	redactWriters.mu.Lock()
+	defer redactWriters.mu.Unlock()
+	for _, w := range redactWriters.ws {
+		w.Flush()
+	}
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	i := bytes.LastIndexByte(w.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	if _, err := io.WriteString(w.w, redact.String(string(w.buf[:i+1]))); err != nil {
+		return 0, err
+	}
+	w.buf = append(w.buf[:0], w.buf[i+1:]...)
+	return len(p), nil
+}
+
+// Flush writes out, redacted, anything held back for want of a newline.
+func (w *redactWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w.w, redact.String(string(w.buf)))
+	w.buf = w.buf[:0]
+	return err
+}
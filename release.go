@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+type Release struct {
+	CreatedAt   time.Time        `json:"created_at"`
+	ID          types.PrefixUUID `json:"id"`
+	Version     int              `json:"version"`
+	Description string           `json:"description"`
+	Status      string           `json:"status"`
+	Slug        *struct {
+		ID types.PrefixUUID `json:"id"`
+	} `json:"slug"`
+}
+
+type Slug struct {
+	ID                types.PrefixUUID `json:"id"`
+	Commit            string           `json:"commit"`
+	CommitDescription string           `json:"commit_description"`
+}
+
+// latestRelease returns the most recent release for app.
+func latestRelease(ctx context.Context, client *Client, app string) (*Release, error) {
+	req, err := client.NewRequest("GET", "/apps/"+app+"/releases", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Range", "version ..; order=desc, max=1")
+	releases := make([]*Release, 0)
+	if err := client.Do(req, &releases); err != nil {
+		return nil, err
+	}
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("app %s has no releases", app)
+	}
+	return releases[0], nil
+}
+
+func getSlug(ctx context.Context, client *Client, app string, id types.PrefixUUID) (*Slug, error) {
+	req, err := client.NewRequest("GET", "/apps/"+app+"/slugs/"+id.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	slug := new(Slug)
+	if err := client.Do(req, slug); err != nil {
+		return nil, err
+	}
+	return slug, nil
+}
+
+// findTestRunForCommit returns the most recent test run for sha on any branch
+// of the pipeline.
+func findTestRunForCommit(ctx context.Context, client *Client, id types.PrefixUUID, sha string) (*TestRun, error) {
+	req, err := client.NewRequest("GET", "/pipelines/"+id.String()+"/test-runs", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	runs := make([]*TestRun, 0)
+	if err := client.Do(req, &runs); err != nil {
+		return nil, err
+	}
+	var foundRun *TestRun
+	for i := range runs {
+		n := getMinTipLength(runs[i].CommitSHA, sha)
+		if n == 0 || runs[i].CommitSHA[:n] != sha[:n] {
+			continue
+		}
+		if foundRun == nil || runs[i].CreatedAt.After(foundRun.CreatedAt) {
+			foundRun = runs[i]
+		}
+	}
+	if foundRun == nil {
+		return nil, notFoundf("could not find a test run for commit %s", shortSHA(sha))
+	}
+	return foundRun, nil
+}
+
+// setConfigVar sets a single config var on app.
+func setConfigVar(ctx context.Context, client *Client, app, key, value string) error {
+	data, err := json.Marshal(map[string]string{key: value})
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest("PATCH", "/apps/"+app+"/config-vars", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return client.Do(req, nil)
+}
+
+// addGitNote attaches note to commit under the heroku-ci notes ref,
+// replacing any existing heroku-ci note on that commit.
+func addGitNote(commit, note string) error {
+	out, err := exec.Command("git", "notes", "--ref", "heroku-ci", "add", "-f", "-m", note, commit).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git notes: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// annotateRelease finds the latest release of app, matches the release's
+// commit to a test run, and prints the pairing. If configVar is nonempty the
+// test run ID is stored in that config var on the app, and if gitNote is true
+// the pairing is recorded as a git note on the commit.
+func annotateRelease(ctx context.Context, client *Client, app, configVar string, gitNote bool) error {
+	if app == "" {
+		return errors.New("please provide an app name with --app")
+	}
+	release, err := latestRelease(ctx, client, app)
+	if err != nil {
+		return err
+	}
+	if release.Slug == nil {
+		return fmt.Errorf("release v%d of %s does not have a slug, so we can't tell which commit it deployed", release.Version, app)
+	}
+	slug, err := getSlug(ctx, client, app, release.Slug.ID)
+	if err != nil {
+		return err
+	}
+	if slug.Commit == "" {
+		return fmt.Errorf("the slug for release v%d of %s does not record a commit", release.Version, app)
+	}
+	coupling, err := appPipelineCoupling(ctx, client, app)
+	if err != nil {
+		return err
+	}
+	run, err := findTestRunForCommit(ctx, client, coupling.Pipeline.ID, slug.Commit)
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("%s v%d (commit %s) was tested by run %s on %s, which %s", app, release.Version, shortSHA(slug.Commit), run.ID.String()[:8], run.CommitBranch, run.Status)
+	fmt.Println(summary)
+	if configVar != "" {
+		err := setConfigVar(ctx, client, app, configVar, run.ID.String())
+		var serr *ScopeError
+		switch {
+		case errors.As(err, &serr):
+			// The pairing is still useful without the config var.
+			warnf("could not set %s: %v", configVar, err)
+		case err != nil:
+			return err
+		default:
+			fmt.Printf("Set %s=%s on %s\n", configVar, run.ID.String(), app)
+			recordAudit(ctx, &AuditEntry{
+				Account:    client.ID,
+				Action:     "config-var.set",
+				PipelineID: coupling.Pipeline.ID.String(),
+				RunNumber:  run.Number,
+				Target:     app,
+				Text:       fmt.Sprintf("Set %s=%s on %s", configVar, run.ID.String(), app),
+			})
+		}
+	}
+	if gitNote {
+		note := fmt.Sprintf("Heroku release: %s v%d\nHeroku CI test run: %s (%s)", app, release.Version, run.ID.String(), run.Status)
+		if err := addGitNote(slug.Commit, note); err != nil {
+			return err
+		}
+		fmt.Printf("Added git note to %s (refs/notes/heroku-ci)\n", shortSHA(slug.Commit))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// replay prints the status timeline the journal recorded for the run with the
+// given ID, and then the run's output if it is in the log cache. id may be a
+// prefix of the run ID, like the 8 characters wait prints.
+func replay(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: heroku-ci replay <run-id>")
+	}
+	prefix := strings.ToLower(args[0])
+	events, err := readJournal(journalFilter{RunPrefix: prefix})
+	if err != nil {
+		return err
+	}
+	matched := make([]*JournalEvent, 0)
+	runID := ""
+	for i := range events {
+		id := events[i].RunID.String()
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		if runID != "" && id != runID {
+			return fmt.Errorf("run ID %q is ambiguous: matches %s and %s", args[0], runID, id)
+		}
+		runID = id
+		matched = append(matched, events[i])
+	}
+	if len(matched) == 0 {
+		return notFoundf("no journal entries for run %q", args[0])
+	}
+	first := matched[0]
+	fmt.Fprintf(w, "Test run %s on branch %s (%s)\n", runID[:8], first.CommitBranch, shortSHA(first.CommitSHA))
+	for _, ev := range matched {
+		elapsed := roundDuration(ev.Time.Sub(first.Time))
+		fmt.Fprintf(w, "%s  +%-10s %s\n", fmtDateTime(ev.Time), elapsed, ev.Status)
+	}
+	last := matched[len(matched)-1]
+	out := cachedRunLog(&TestRun{ID: last.RunID, Status: last.Status})
+	fmt.Fprintln(w)
+	if out == nil {
+		fmt.Fprintf(w, "No cached output for run #%d; heroku-ci logs %d downloads it.\n", last.RunNumber, last.RunNumber)
+		return nil
+	}
+	defer out.Close()
+	return out.Each(func(line string) error {
+		_, err := fmt.Fprintln(w, line)
+		return err
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayPrintsCachedOutput(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	disabled := cacheDisabled
+	t.Cleanup(func() { cacheDisabled = disabled })
+	cacheDisabled = false
+	journalConfig.Store = "file"
+	t.Cleanup(func() { journalConfig.Store = "" })
+	const runID = "aaaa0001-0000-4000-8000-000000000000"
+	for _, status := range []RunStatus{StatusRunning, StatusFailed} {
+		if err := (fileJournal{}).Append(testJournalEvent(t, runID, status)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := replay(&buf, []string{"aaaa0001"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "No cached output for run #104") {
+		t.Errorf("without a cached log, got:\n%s", buf.String())
+	}
+
+	ev := testJournalEvent(t, runID, StatusFailed)
+	path, err := logCachePath(&TestRun{ID: ev.RunID, Status: ev.Status})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("--- FAIL: TestWidget\nFAIL\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := replay(&buf, []string{"aaaa0001"}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "failed\n\n--- FAIL: TestWidget\nFAIL\n") {
+		t.Errorf("want the timeline, then the cached output; got:\n%s", out)
+	}
+}
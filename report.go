@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// The most lines of each failure's test summary to include in a report.
+const reportSummaryLines = 30
+
+type reportRun struct {
+	*TestRun
+	URL string
+}
+
+type reportFailure struct {
+	reportRun
+	Summary []string
+	// Err is why we couldn't get the summary, if we couldn't.
+	Err string
+}
+
+type groupTable struct {
+	Title  string
+	Groups []*statsGroup
+}
+
+type reportData struct {
+	Pipeline     string
+	From, To     string
+	Generated    string
+	Total        *statsGroup
+	Branches     groupTable
+	Authors      groupTable
+	Graph        template.HTML
+	History      []reportRun
+	HiddenRuns   int
+	Failures     []reportFailure
+	DashboardURL string
+}
+
+// reportOptions control what report includes.
+type reportOptions struct {
+	// Runs is how many of the newest runs to list.
+	Runs int
+	// Failures is how many of the newest failing runs to show the test
+	// summary of.
+	Failures int
+	Top      int
+}
+
+// writeReport writes a standalone HTML report on the pipeline's runs in the
+// last since to path.
+func writeReport(ctx context.Context, client *Client, id types.PrefixUUID, since time.Duration, path string, opts reportOptions) error {
+	now := time.Now()
+	start := now.Add(-since)
+	bar := newProgressBar(os.Stderr, start, now)
+	stats, err := collectStats(ctx, client, id, start, statsPeriod(since), nil, bar)
+	bar.done()
+	if err != nil {
+		return err
+	}
+	if stats.Runs == 0 {
+		return fmt.Errorf("no finished test runs since %s", fmtDateTimeMinutes(start))
+	}
+	name := pipelineName(ctx, client, id)
+	if name == "" {
+		name = id.String()
+	}
+	total := &statsGroup{Name: "all"}
+	for _, g := range stats.ByBranch {
+		total.merge(g)
+	}
+	sortDurations(total)
+	data := &reportData{
+		Pipeline:     name,
+		From:         fmtDateTimeMinutes(start),
+		To:           fmtDateTimeMinutes(now),
+		Generated:    fmtDateTime(now),
+		Total:        total,
+		Graph:        template.HTML(graphSVG(newTrend(stats, start, now))),
+		DashboardURL: "https://dashboard.heroku.com/pipelines/" + id.String() + "/tests",
+	}
+	data.Branches = groupTable{Title: "Branch"}
+	data.Branches.Groups, _ = topGroups(stats.ByBranch, opts.Top)
+	data.Authors = groupTable{Title: "Author"}
+	data.Authors.Groups, _ = topGroups(stats.ByAuthor, opts.Top)
+	for i, run := range stats.All {
+		if opts.Runs > 0 && i >= opts.Runs {
+			data.HiddenRuns = len(stats.All) - opts.Runs
+			break
+		}
+		data.History = append(data.History, reportRun{run, dashboardURL(id, run)})
+	}
+	for _, run := range stats.All {
+		if len(data.Failures) >= opts.Failures {
+			break
+		}
+		if !isFailing(run.Status) {
+			continue
+		}
+		f := reportFailure{reportRun: reportRun{run, dashboardURL(id, run)}}
+		out, err := fetchRunOutput(ctx, client, run)
+		if err != nil {
+			f.Err = err.Error()
+		} else {
+			f.Summary = summarySection(out.Tail())
+			out.Close()
+			if len(f.Summary) > reportSummaryLines {
+				f.Summary = f.Summary[len(f.Summary)-reportSummaryLines:]
+			}
+		}
+		data.Failures = append(data.Failures, f)
+	}
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0644)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"int":      fmtInt,
+	"datetime": fmtDateTimeMinutes,
+	"short":    shortSHA,
+	"redact":   redact.String,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Pipeline}}: Heroku CI report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.25em 0.75em; border-bottom: 1px solid #ddd; }
+td.n { text-align: right; }
+.succeeded { color: #2e7d32; }
+.failed, .errored { color: #c62828; }
+.cancelled { color: #777; }
+pre { background: #f6f6f6; padding: 0.75em; overflow-x: auto; font-size: 0.85em; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>{{.Pipeline}}</h1>
+<p class="muted">Heroku CI runs from {{.From}} to {{.To}}. Generated {{.Generated}}. <a href="{{.DashboardURL}}">Dashboard</a></p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Runs</th><th>Passed</th><th>Failed</th><th>Pass rate</th><th>Median</th><th>P90</th></tr>
+<tr><td class="n">{{int .Total.Runs}}</td><td class="n">{{int .Total.Passed}}</td><td class="n">{{int .Total.Failed}}</td><td class="n">{{.Total.PassRate}}</td><td class="n">{{.Total.Median}}</td><td class="n">{{.Total.P90}}</td></tr>
+</table>
+
+<h2>Trends</h2>
+{{.Graph}}
+
+{{define "groups"}}
+<table>
+<tr><th>{{.Title}}</th><th>Runs</th><th>Passed</th><th>Failed</th><th>Pass rate</th><th>Median</th><th>P90</th></tr>
+{{range .Groups}}<tr><td>{{.Name}}</td><td class="n">{{int .Runs}}</td><td class="n">{{int .Passed}}</td><td class="n">{{int .Failed}}</td><td class="n">{{.PassRate}}</td><td class="n">{{.Median}}</td><td class="n">{{.P90}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>By branch</h2>
+{{template "groups" .Branches}}
+<h2>By author</h2>
+{{template "groups" .Authors}}
+
+{{if .Failures}}
+<h2>Recent failures</h2>
+{{range .Failures}}
+<h3><a href="{{.URL}}">Run #{{.Number}}</a> on {{.CommitBranch}} <span class="muted">({{short .CommitSHA}}, {{datetime .CreatedAt}})</span></h3>
+{{if .Err}}<p class="muted">Couldn't get the test output: {{.Err}}</p>{{else}}<pre>{{range .Summary}}{{redact .}}
+{{end}}</pre>{{end}}
+{{end}}
+{{end}}
+
+<h2>History</h2>
+<table>
+<tr><th>Run</th><th>Branch</th><th>Commit</th><th>Status</th><th>Started</th><th>Duration</th></tr>
+{{range .History}}<tr><td><a href="{{.URL}}">#{{.Number}}</a></td><td>{{.CommitBranch}}</td><td title="{{redact .CommitMessage}}">{{short .CommitSHA}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{datetime .CreatedAt}}</td><td class="n">{{.Duration}}</td></tr>
+{{end}}</table>
+{{if .HiddenRuns}}<p class="muted">{{int .HiddenRuns}} older runs not shown.</p>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// onlyNodesVar is the test config var that tells a rerun which nodes to run.
+// Test scripts opt in by exiting early when it is set and does not list
+// $CI_NODE_INDEX.
+const onlyNodesVar = "CI_ONLY_NODE_INDEXES"
+
+// getTestRun returns the pipeline's run with the given number.
+func getTestRun(ctx context.Context, client *Client, id types.PrefixUUID, number int) (*TestRun, error) {
+	req, err := client.NewRequest("GET", "/pipelines/"+id.String()+"/test-runs/"+strconv.Itoa(number), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	run := new(TestRun)
+	if err := client.Do(req, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// nodeFailed reports whether node finished unsuccessfully.
+func nodeFailed(node *TestNode) bool {
+	if node.ExitCode != nil {
+		return *node.ExitCode != 0
+	}
+	return RunStatus(node.Status).Failed()
+}
+
+// failedNodes returns the indexes of the nodes that failed, in order.
+func failedNodes(nodes []*TestNode) []int {
+	indexes := make([]int, 0)
+	for _, node := range nodes {
+		if nodeFailed(node) {
+			indexes = append(indexes, node.Index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+// rerunOptions control rerun.
+type rerunOptions struct {
+	// Run is the number, ID, or shorthand of the run to rerun. If empty,
+	// the latest finished run on the branch is used.
+	Run string
+	// FailedNodes reruns only the nodes that failed.
+	FailedNodes bool
+	SourceURL   string
+	Yes         bool
+}
+
+// rerun starts a new test run for the same commit as a failed run and waits
+// for it. With FailedNodes, only the failed nodes run their tests, and the
+// result is reported together with the nodes that passed the first time.
+func rerun(ctx context.Context, client *Client, id types.PrefixUUID, branch string, opts rerunOptions) error {
+	// Rerunning only the failed nodes sets a config var on the pipeline's
+	// test environment, which needs more than starting a run.
+	action, need := "rerun", permDeploy
+	if opts.FailedNodes {
+		action, need = "rerun --failed-nodes", permOperate
+	}
+	if err := checkPermission(ctx, client, id, action, need); err != nil {
+		return err
+	}
+	var prior *TestRun
+	var err error
+	if opts.Run != "" {
+		prior, err = findRun(ctx, client, id, branch, opts.Run)
+	} else {
+		var runs []*TestRun
+		runs, err = recentTestRuns(ctx, client, id, logsSearchDepth)
+		prior = latestRunOn(runs, branch, 0, RunStatus.Terminal)
+		if err == nil && prior == nil {
+			err = notFoundf("no finished test runs on %s", branch)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if prior.InProgress() {
+		return fmt.Errorf("run #%d is still %s", prior.Number, prior.Status)
+	}
+	var priorNodes []*TestNode
+	var only []int
+	if opts.FailedNodes {
+		priorNodes, err = getTestNodes(ctx, client, prior.ID)
+		if err != nil {
+			return err
+		}
+		only = failedNodes(priorNodes)
+		if len(only) == 0 {
+			return fmt.Errorf("no nodes failed in run #%d", prior.Number)
+		}
+		if len(only) == len(priorNodes) {
+			warnf("every node failed in run #%d, rerunning all of them", prior.Number)
+			only = nil
+		}
+	}
+	if err := checkWritable("rerun a test run"); err != nil {
+		return err
+	}
+	sourceURL := opts.SourceURL
+	if sourceURL == "" {
+		if sourceURL, err = githubTarballURL(prior.CommitSHA); err != nil {
+			return err
+		}
+	}
+	if only != nil {
+		vars, err := testConfigVars(ctx, client, id)
+		if err != nil {
+			return err
+		}
+		if v, ok := vars[onlyNodesVar]; ok {
+			return fmt.Errorf("%s is already set to %q on the pipeline's test stage; another rerun may be in progress", onlyNodesVar, v)
+		}
+		question := fmt.Sprintf("This sets %s=%s on the pipeline's test stage until the new run's nodes start, so other runs started meanwhile will skip the same nodes. Continue?", onlyNodesVar, joinInts(only))
+		if err := confirm(question, opts.Yes); err != nil {
+			return err
+		}
+		value := joinInts(only)
+		if err := setTestConfigVar(ctx, client, id, onlyNodesVar, &value); err != nil {
+			return err
+		}
+	}
+	// unset removes the config var. It uses its own context, so the var is
+	// removed even if ctx has been cancelled.
+	unset := func() {
+		if only == nil {
+			return
+		}
+		cctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := setTestConfigVar(cctx, client, id, onlyNodesVar, nil); err != nil {
+			warnf("could not remove %s from the pipeline's test stage, remove it by hand: %v", onlyNodesVar, err)
+		}
+	}
+	run, err := createTestRun(ctx, client, id, prior.CommitBranch, prior.CommitSHA, prior.CommitMessage, sourceURL)
+	if err != nil {
+		unset()
+		return err
+	}
+	text := fmt.Sprintf("Started test run #%d to rerun #%d on %s (%s)", run.Number, prior.Number, prior.CommitBranch, shortSHA(prior.CommitSHA))
+	if only != nil {
+		text += ", nodes " + joinInts(only)
+	}
+	fmt.Println(text)
+	recordAudit(ctx, &AuditEntry{
+		Account:    client.ID,
+		Action:     "rerun",
+		PipelineID: id.String(),
+		RunNumber:  run.Number,
+		Target:     prior.CommitBranch,
+		Text:       text,
+	})
+	if only != nil {
+		err := waitForNodesStarted(ctx, client, run)
+		unset()
+		if err != nil {
+			return err
+		}
+	}
+	run, _, err = waitForTestRun(ctx, client, id, run, "", nil)
+	if err != nil {
+		return err
+	}
+	if only == nil {
+		fmt.Printf("Test run #%d completed after %s with status %s\n", run.Number, run.Duration(), run.Status)
+		if run.Status != StatusSucceeded {
+			return &runFailedError{run}
+		}
+		return nil
+	}
+	nodes, err := getTestNodes(ctx, client, run.ID)
+	if err != nil {
+		return err
+	}
+	return reportMergedNodes(prior, priorNodes, run, nodes, only)
+}
+
+// reportMergedNodes prints each node's result, taking the nodes in only from
+// the rerun and the rest from the prior run, and returns an error if any of
+// them failed.
+func reportMergedNodes(prior *TestRun, priorNodes []*TestNode, rerun *TestRun, rerunNodes []*TestNode, only []int) error {
+	rerunByIndex := make(map[int]*TestNode, len(rerunNodes))
+	for _, node := range rerunNodes {
+		rerunByIndex[node.Index] = node
+	}
+	rerunSet := make(map[int]bool, len(only))
+	for _, i := range only {
+		rerunSet[i] = true
+	}
+	t := newTable("NODE", "RESULT", "RUN")
+	var failed []int
+	for _, node := range priorNodes {
+		from, result := prior, node
+		if rerunSet[node.Index] {
+			from, result = rerun, rerunByIndex[node.Index]
+		}
+		status := "missing"
+		if result != nil {
+			status = nodeStatus(result)
+		}
+		if result == nil || nodeFailed(result) {
+			failed = append(failed, node.Index)
+		}
+		t.addf(node.Index, status, "#"+strconv.Itoa(from.Number))
+	}
+	if err := t.print(os.Stdout); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return checkFailedf("nodes %s failed", joinInts(failed))
+	}
+	if rerun.Status != StatusSucceeded {
+		return checkFailedf("the rerun's nodes passed, but the run %s", rerun.Status)
+	}
+	fmt.Printf("Every node has passed for %s\n", shortSHA(prior.CommitSHA))
+	return nil
+}
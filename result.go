@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A RunResult describes a finished test run, for programs that run
+// heroku-ci and want to act on the result without asking Heroku again.
+type RunResult struct {
+	PipelineID types.PrefixUUID `json:"pipeline_id"`
+	RunID      types.PrefixUUID `json:"run_id"`
+	RunNumber  int              `json:"run_number"`
+	Branch     string           `json:"branch"`
+	CommitSHA  string           `json:"commit_sha"`
+	Status     RunStatus        `json:"status"`
+	Succeeded  bool             `json:"succeeded"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	// Duration is the run's duration in seconds.
+	Duration float64       `json:"duration"`
+	Warnings int           `json:"setup_warnings"`
+	Nodes    []*NodeResult `json:"nodes"`
+	Links    ResultLinks   `json:"links"`
+	// FailingTests, ChangedFiles, and Owners are set if the run failed: the
+	// tests named in its output, the files changed since the last passing
+	// run, and their CODEOWNERS.
+	FailingTests []string `json:"failing_tests,omitempty"`
+	ChangedFiles []string `json:"changed_files,omitempty"`
+	Owners       []string `json:"owners,omitempty"`
+}
+
+// A NodeResult is the outcome of one node of a test run.
+type NodeResult struct {
+	Index    int    `json:"index"`
+	Status   string `json:"status"`
+	ExitCode *int   `json:"exit_code"`
+	// Duration is the node's duration in seconds.
+	Duration float64 `json:"duration"`
+}
+
+// ResultLinks point at the run elsewhere.
+type ResultLinks struct {
+	Dashboard string `json:"dashboard"`
+	API       string `json:"api"`
+}
+
+// dashboardURL returns the run's page on the Heroku dashboard.
+func dashboardURL(id types.PrefixUUID, run *TestRun) string {
+	return fmt.Sprintf("https://dashboard.heroku.com/pipelines/%s/tests/%d", id.String(), run.Number)
+}
+
+// newRunResult describes run. If the run's nodes can't be fetched, the
+// result is returned without them.
+func newRunResult(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun, warnings []string) *RunResult {
+	res := &RunResult{
+		PipelineID: id,
+		RunID:      run.ID,
+		RunNumber:  run.Number,
+		Branch:     run.CommitBranch,
+		CommitSHA:  run.CommitSHA,
+		Status:     run.Status,
+		Succeeded:  run.Status == StatusSucceeded,
+		CreatedAt:  run.CreatedAt,
+		FinishedAt: run.UpdatedAt,
+		Duration:   run.Duration().Seconds(),
+		Warnings:   len(warnings),
+		Nodes:      make([]*NodeResult, 0),
+		Links: ResultLinks{
+			Dashboard: dashboardURL(id, run),
+			API:       "https://api.heroku.com/test-runs/" + run.ID.String(),
+		},
+	}
+	nodes, err := getTestNodes(ctx, client, run.ID)
+	if err != nil {
+		warnf("could not get test nodes for the result file: %v", err)
+		return res
+	}
+	for _, node := range nodes {
+		res.Nodes = append(res.Nodes, &NodeResult{
+			Index:    node.Index,
+			Status:   node.Status,
+			ExitCode: node.ExitCode,
+			Duration: roundDuration(node.UpdatedAt.Sub(node.CreatedAt)).Seconds(),
+		})
+	}
+	return res
+}
+
+// writeResultFile writes res to path as JSON.
+func writeResultFile(path string, res *RunResult) error {
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0644)
+}
+
+// envExports returns res as HEROKU_CI_* variables, in order.
+func envExports(res *RunResult) [][2]string {
+	return [][2]string{
+		{"HEROKU_CI_STATUS", string(res.Status)},
+		{"HEROKU_CI_SUCCEEDED", strconv.FormatBool(res.Succeeded)},
+		{"HEROKU_CI_PIPELINE_ID", res.PipelineID.String()},
+		{"HEROKU_CI_RUN_ID", res.RunID.String()},
+		{"HEROKU_CI_RUN_NUMBER", strconv.Itoa(res.RunNumber)},
+		{"HEROKU_CI_BRANCH", res.Branch},
+		{"HEROKU_CI_COMMIT_SHA", res.CommitSHA},
+		{"HEROKU_CI_DURATION", strconv.FormatFloat(res.Duration, 'f', -1, 64)},
+		{"HEROKU_CI_URL", res.Links.Dashboard},
+	}
+}
+
+// shellQuote single-quotes s, which both sh and most .env parsers accept.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeEnvFile writes res to path as KEY='value' lines that can be sourced
+// by a shell or loaded as a .env file.
+func writeEnvFile(path string, res *RunResult) error {
+	var buf strings.Builder
+	for _, kv := range envExports(res) {
+		fmt.Fprintf(&buf, "%s=%s\n", kv[0], shellQuote(kv[1]))
+	}
+	return writeFileAtomic(path, []byte(buf.String()), 0644)
+}
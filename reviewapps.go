@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+type ReviewApp struct {
+	CreatedAt time.Time        `json:"created_at"`
+	ID        types.PrefixUUID `json:"id"`
+	Branch    string           `json:"branch"`
+	Status    string           `json:"status"`
+	PRNumber  int              `json:"pr_number"`
+	App       *struct {
+		ID types.PrefixUUID `json:"id"`
+	} `json:"app"`
+}
+
+type App struct {
+	ID         types.PrefixUUID `json:"id"`
+	Name       string           `json:"name"`
+	WebURL     string           `json:"web_url"`
+	BuildStack struct {
+		Name string `json:"name"`
+	} `json:"build_stack"`
+}
+
+// fullSHA returns the full commit SHA that ref points to.
+func fullSHA(ref string) (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--verify", ref+"^{commit}").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git: could not resolve %s: %s", ref, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// githubTarballURL returns the GitHub API URL for a tarball of the origin
+// repository at sha.
+func githubTarballURL(sha string) (string, error) {
+	owner, repo, err := githubRepo()
+	if err != nil {
+		return "", fmt.Errorf("%v; pass --source-url", err)
+	}
+	return fmt.Sprintf("https://api.github.com/repos/%s/%s/tarball/%s", owner, repo, sha), nil
+}
+
+func listReviewApps(ctx context.Context, client *Client, id types.PrefixUUID) ([]*ReviewApp, error) {
+	req, err := client.NewRequest("GET", "/pipelines/"+id.String()+"/review-apps", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	apps := make([]*ReviewApp, 0)
+	if err := client.Do(req, &apps); err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// findReviewApp returns the review app for branch.
+func findReviewApp(ctx context.Context, client *Client, id types.PrefixUUID, branch string) (*ReviewApp, error) {
+	apps, err := listReviewApps(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range apps {
+		if apps[i].Branch == branch {
+			return apps[i], nil
+		}
+	}
+	return nil, notFoundf("no review app found for branch %s", branch)
+}
+
+func createReviewApp(ctx context.Context, client *Client, id types.PrefixUUID, branch, sourceURL string) error {
+	if err := checkPermission(ctx, client, id, "review-app create", permDeploy); err != nil {
+		return err
+	}
+	sha, err := fullSHA(localRef(branch))
+	if err != nil {
+		return err
+	}
+	if sourceURL == "" {
+		sourceURL, err = githubTarballURL(sha)
+		if err != nil {
+			return err
+		}
+	}
+	body := map[string]interface{}{
+		"branch":   branch,
+		"pipeline": id.String(),
+		"source_blob": map[string]string{
+			"url":     sourceURL,
+			"version": sha,
+		},
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest("POST", "/review-apps", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	app := new(ReviewApp)
+	if err := client.Do(req, app); err != nil {
+		return err
+	}
+	fmt.Printf("Created review app %s for %s (%s), status %s\n", app.ID.String()[:8], branch, shortSHA(sha), app.Status)
+	recordAudit(ctx, &AuditEntry{
+		Account:    client.ID,
+		Action:     "review-app.create",
+		PipelineID: id.String(),
+		Target:     branch,
+		Text:       fmt.Sprintf("Created review app %s for %s (%s)", app.ID.String()[:8], branch, shortSHA(sha)),
+	})
+	return nil
+}
+
+func deleteReviewApp(ctx context.Context, client *Client, id types.PrefixUUID, branch string, yes, dryRun bool) error {
+	app, err := findReviewApp(ctx, client, id, branch)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("Dry run: would delete review app %s for %s\n", app.ID.String()[:8], branch)
+		return nil
+	}
+	if err := checkPermission(ctx, client, id, "review-app delete", permOperate); err != nil {
+		return err
+	}
+	if err := confirm(fmt.Sprintf("Delete review app %s for %s?", app.ID.String()[:8], branch), yes); err != nil {
+		return err
+	}
+	req, err := client.NewRequest("DELETE", "/review-apps/"+app.ID.String(), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if err := client.Do(req, nil); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted review app %s for %s\n", app.ID.String()[:8], branch)
+	recordAudit(ctx, &AuditEntry{
+		Account:    client.ID,
+		Action:     "review-app.delete",
+		PipelineID: id.String(),
+		Target:     branch,
+		Text:       fmt.Sprintf("Deleted review app %s for %s", app.ID.String()[:8], branch),
+	})
+	return nil
+}
+
+func openReviewApp(ctx context.Context, client *Client, id types.PrefixUUID, branch string) error {
+	reviewApp, err := findReviewApp(ctx, client, id, branch)
+	if err != nil {
+		return err
+	}
+	if reviewApp.App == nil {
+		return fmt.Errorf("review app for %s has not been created yet (status %s)", branch, reviewApp.Status)
+	}
+	app, err := getApp(ctx, client, reviewApp.App.ID.String())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Opening %s\n", app.WebURL)
+	return openURL(app.WebURL)
+}
+
+// openURL opens url in the user's web browser.
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Run()
+}
+
+// reviewAppCommand runs the review-app subcommand named by args[0]. yes
+// skips the confirmation before deleting a review app, and dryRun prints
+// the review app that would be deleted instead.
+func reviewAppCommand(ctx context.Context, client *Client, id types.PrefixUUID, args []string, sourceURL string, yes, dryRun bool) error {
+	if len(args) == 0 {
+		return errors.New("usage: heroku-ci review-app create|delete|open [branch]")
+	}
+	branch, err := getBranchFromArgs(args[1:])
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "create":
+		return createReviewApp(ctx, client, id, branch, sourceURL)
+	case "delete":
+		return deleteReviewApp(ctx, client, id, branch, yes, dryRun)
+	case "open":
+		return openReviewApp(ctx, client, id, branch)
+	default:
+		return fmt.Errorf("unknown review-app command %q, want create, delete, or open", args[0])
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// runJSON is where wait --format=json --tail-status writes the run each
+// time its status changes. out is nil unless both flags are set.
+var runJSON struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// printRunJSON writes run to w as a single line of JSON, in the same shape
+// as a line of export.
+func printRunJSON(w io.Writer, run *TestRun) error {
+	return json.NewEncoder(w).Encode(run)
+}
+
+// emitStatusJSON writes run to the --tail-status stream, if there is one.
+func emitStatusJSON(run *TestRun) {
+	runJSON.mu.Lock()
+	defer runJSON.mu.Unlock()
+	if runJSON.out == nil {
+		return
+	}
+	if err := printRunJSON(runJSON.out, run); err != nil {
+		warnf("could not write run status, no longer writing it: %v", err)
+		runJSON.out = nil
+	}
+}
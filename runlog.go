@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// How much of each node's output to keep in memory. Past this the output is
+// spooled to a temporary file, so a suite that prints hundreds of megabytes
+// doesn't run a small CI container out of memory.
+const maxLogMemory = 4 << 20
+
+// The longest line we read from a run's output.
+const maxLogLine = 1024 * 1024
+
+// A runLog is the output of a test run, one part per node. Callers read it
+// a line at a time with Each; only the last maxSummaryLines lines, which is
+// all most of them need, are kept at hand by Tail.
+type runLog struct {
+	parts []*logSpool
+	// shared logs were prefetched, and are read by more than one caller, so
+	// Close leaves them open.
+	shared bool
+}
+
+// Each calls fn with every line of the output, in node order, and stops at
+// the first error fn returns.
+func (l *runLog) Each(fn func(line string) error) error {
+	for _, p := range l.parts {
+		if err := p.each(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tail returns the last maxSummaryLines lines of the output.
+func (l *runLog) Tail() []string {
+	// Walk back from the last node until there are enough lines.
+	start, need := len(l.parts), maxSummaryLines
+	for start > 0 && need > 0 {
+		start--
+		need -= len(l.parts[start].tail)
+	}
+	tail := make([]string, 0, maxSummaryLines)
+	for _, p := range l.parts[start:] {
+		tail = append(tail, p.tail...)
+	}
+	if len(tail) > maxSummaryLines {
+		tail = tail[len(tail)-maxSummaryLines:]
+	}
+	return tail
+}
+
+// FailingTests returns the tests named anywhere in the output, as
+// failingTests does for a slice of lines.
+func (l *runLog) FailingTests() ([]string, error) {
+	f := newTestFinder()
+	err := l.Each(func(line string) error {
+		f.add(line)
+		return nil
+	})
+	return f.tests, err
+}
+
+// Close removes any temporary files behind the log.
+func (l *runLog) Close() error {
+	if l.shared {
+		return nil
+	}
+	var first error
+	for _, p := range l.parts {
+		if err := p.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// A logSpool holds one node's output: in memory while it is small, and in a
+// temporary file once it passes maxLogMemory.
+type logSpool struct {
+	lines []string
+	size  int
+	// tail holds at least the last maxSummaryLines lines.
+	tail []string
+
+	file    *os.File
+	w       *bufio.Writer
+	written int64
+	// remove is true for a temporary file that couldn't be unlinked when it
+	// was created, so close has to remove it.
+	remove bool
+}
+
+func (s *logSpool) add(line string) error {
+	s.keepTail(line)
+	if s.file == nil && s.size+len(line) > maxLogMemory {
+		f, err := os.CreateTemp("", "heroku-ci-log-")
+		if err != nil {
+			return err
+		}
+		// Where the OS allows it, unlink the file straight away, so it goes
+		// away however we exit.
+		s.file, s.remove = f, os.Remove(f.Name()) != nil
+		s.w = bufio.NewWriter(f)
+		for _, l := range s.lines {
+			if err := s.write(l); err != nil {
+				return err
+			}
+		}
+		s.lines = nil
+	}
+	if s.file != nil {
+		return s.write(line)
+	}
+	s.lines = append(s.lines, line)
+	s.size += len(line)
+	return nil
+}
+
+func (s *logSpool) keepTail(line string) {
+	s.tail = append(s.tail, line)
+	if len(s.tail) >= 2*maxSummaryLines {
+		s.tail = append(make([]string, 0, 2*maxSummaryLines), s.tail[len(s.tail)-maxSummaryLines:]...)
+	}
+}
+
+func (s *logSpool) write(line string) error {
+	n, err := s.w.WriteString(line + "\n")
+	s.written += int64(n)
+	return err
+}
+
+// finish flushes the spool once the whole output has been added.
+func (s *logSpool) finish() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Flush()
+}
+
+func (s *logSpool) each(fn func(string) error) error {
+	if s.file == nil {
+		for _, line := range s.lines {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// A section reader reads with ReadAt, so several callers can read a
+	// shared log at once.
+	scanner := bufio.NewScanner(io.NewSectionReader(s.file, 0, s.written))
+	// Lines may have gained a node prefix since they were read.
+	scanner.Buffer(make([]byte, 64*1024), 2*maxLogLine)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *logSpool) close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if s.remove {
+		if rerr := os.Remove(s.file.Name()); err == nil {
+			err = rerr
+		}
+	}
+	s.file = nil
+	return err
+}
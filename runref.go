@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// Run shorthands name a recent run on a branch instead of its number or ID:
+//
+//	@latest          the newest run, finished or not
+//	@latest-1        the run before that
+//	@last-failed     the newest run that failed
+//	@last-passed     the newest run that passed
+//	main@last-failed the newest failed run on main, not the current branch
+var runShorthands = map[string]func(RunStatus) bool{
+	"latest":      func(RunStatus) bool { return true },
+	"last-failed": isFailing,
+	"last-passed": isPassing,
+}
+
+// parseRunShorthand splits a run shorthand into its branch, which is empty
+// if it doesn't name one, the kind of run, and how many of those runs to
+// go back. ok is false if ref isn't a shorthand.
+func parseRunShorthand(ref string) (branch, kind string, back int, ok bool) {
+	i := strings.LastIndexByte(ref, '@')
+	if i < 0 {
+		return "", "", 0, false
+	}
+	branch, kind = ref[:i], ref[i+1:]
+	if _, ok := runShorthands[kind]; ok {
+		return branch, kind, 0, true
+	}
+	j := strings.LastIndexByte(kind, '-')
+	if j < 0 {
+		return "", "", 0, false
+	}
+	digits := kind[j+1:]
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return "", "", 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return "", "", 0, false
+	}
+	if _, ok := runShorthands[kind[:j]]; !ok {
+		return "", "", 0, false
+	}
+	return branch, kind[:j], n, true
+}
+
+// isRunShorthand reports whether ref is a run shorthand like @latest.
+func isRunShorthand(ref string) bool {
+	_, _, _, ok := parseRunShorthand(ref)
+	return ok
+}
+
+// findRun returns the pipeline's run with the given number, ID, or
+// shorthand. Shorthands that don't name a branch look at runs on branch.
+func findRun(ctx context.Context, client *Client, id types.PrefixUUID, branch, ref string) (*TestRun, error) {
+	if b, kind, back, ok := parseRunShorthand(ref); ok {
+		if b != "" {
+			branch = b
+		}
+		if branch == "" {
+			return nil, fmt.Errorf("no branch for %s; name one, like main%s", ref, ref[strings.LastIndexByte(ref, '@'):])
+		}
+		return findRunShorthand(ctx, client, id, branch, kind, back)
+	}
+	if n, err := strconv.Atoi(ref); err == nil {
+		return getTestRun(ctx, client, id, n)
+	}
+	req, err := client.NewRequest("GET", "/test-runs/"+ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	run := new(TestRun)
+	if err := client.Do(req, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func findRunShorthand(ctx context.Context, client *Client, id types.PrefixUUID, branch, kind string, back int) (*TestRun, error) {
+	runs, err := recentTestRuns(ctx, client, id, logsSearchDepth)
+	if err != nil {
+		return nil, err
+	}
+	ok := runShorthands[kind]
+	seen := 0
+	for _, run := range runs {
+		if run.CommitBranch != branch || !ok(run.Status) {
+			continue
+		}
+		if seen == back {
+			return run, nil
+		}
+		seen++
+	}
+	if back > 0 {
+		return nil, notFoundf("only %s matching @%s on %s in the last %d runs", plural(seen, "run", "runs"), kind, branch, logsSearchDepth)
+	}
+	return nil, notFoundf("no runs matching @%s on %s in the last %d runs", kind, branch, logsSearchDepth)
+}
+
+// runFromArgs returns the branch and run named by a command's arguments,
+// which may be a branch or a run shorthand. ref is empty if they name a
+// branch; with no arguments, the branch is the current one.
+func runFromArgs(args []string) (branch, ref string, err error) {
+	if len(args) > 0 && isRunShorthand(args[0]) {
+		ref = args[0]
+		if b, _, _, _ := parseRunShorthand(ref); b != "" {
+			return b, ref, nil
+		}
+		args = args[1:]
+	}
+	branch, err = getBranchFromArgs(args)
+	return branch, ref, err
+}
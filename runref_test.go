@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestParseRunShorthand(t *testing.T) {
+	tests := []struct {
+		ref    string
+		branch string
+		kind   string
+		back   int
+		ok     bool
+	}{
+		{"@latest", "", "latest", 0, true},
+		{"@latest-0", "", "latest", 0, true},
+		{"@latest-3", "", "latest", 3, true},
+		{"@last-failed", "", "last-failed", 0, true},
+		{"@last-failed-2", "", "last-failed", 2, true},
+		{"@last-passed", "", "last-passed", 0, true},
+		{"main@latest-1", "main", "latest", 1, true},
+		{"feature/x@last-failed", "feature/x", "last-failed", 0, true},
+		{"a@b@latest", "a@b", "latest", 0, true},
+
+		{"latest", "", "", 0, false},
+		{"12", "", "", 0, false},
+		{"@", "", "", 0, false},
+		{"@newest", "", "", 0, false},
+		{"@latest-", "", "", 0, false},
+		{"@latest-x", "", "", 0, false},
+		{"@latest--1", "", "", 0, false},
+		{"@latest-+1", "", "", 0, false},
+		{"@latest-1x", "", "", 0, false},
+		{"@last-1", "", "", 0, false},
+		{"main@", "", "", 0, false},
+	}
+	for _, tt := range tests {
+		branch, kind, back, ok := parseRunShorthand(tt.ref)
+		if branch != tt.branch || kind != tt.kind || back != tt.back || ok != tt.ok {
+			t.Errorf("parseRunShorthand(%q) = %q, %q, %d, %t; want %q, %q, %d, %t",
+				tt.ref, branch, kind, back, ok, tt.branch, tt.kind, tt.back, tt.ok)
+		}
+	}
+}
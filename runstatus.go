@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// RunStatus is the status of a Heroku CI test run.
+type RunStatus = herokuci.RunStatus
+
+// The statuses Heroku documents for test runs.
+const (
+	StatusPending   = herokuci.StatusPending
+	StatusCreating  = herokuci.StatusCreating
+	StatusBuilding  = herokuci.StatusBuilding
+	StatusRunning   = herokuci.StatusRunning
+	StatusDebugging = herokuci.StatusDebugging
+	StatusErrored   = herokuci.StatusErrored
+	StatusFailed    = herokuci.StatusFailed
+	StatusSucceeded = herokuci.StatusSucceeded
+	StatusCancelled = herokuci.StatusCancelled
+)
+
+// Heroku may add a status we don't know about; RunStatus.Terminal guesses
+// what it means, and we warn, once per status, so a wrong guess is noticed.
+func init() {
+	herokuci.OnUnknownStatus = warnUnknownStatus
+}
+
+var warnedStatuses sync.Map
+
+func warnUnknownStatus(s RunStatus) {
+	if _, loaded := warnedStatuses.LoadOrStore(s, true); loaded {
+		return
+	}
+	guess := "still in progress"
+	if strings.HasSuffix(string(s), "ed") {
+		guess = "finished"
+	}
+	warnf("Heroku returned unknown test run status %q, treating it as %s", s, guess)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// schemaVersion is the version of the JSON schemas in schema/. Within a
+// version, fields are only ever added; removing, renaming, or retyping a
+// field bumps it.
+const schemaVersion = 1
+
+// schemaFiles describe every JSON document heroku-ci writes for other
+// programs to read. Each file's $id ends in /v<schemaVersion>/<name>.json.
+//
+//go:embed schema
+var schemaFiles embed.FS
+
+// schemaNames returns the name of every schema, sorted.
+func schemaNames() []string {
+	entries, err := schemaFiles.ReadDir("schema")
+	if err != nil {
+		panic(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printSchema prints the schema for the named output, or with no name, a
+// list of the schemas.
+func printSchema(w io.Writer, name string) error {
+	if name == "" {
+		t := newTable("OUTPUT", "DESCRIPTION")
+		t.flex = []int{1}
+		for _, name := range schemaNames() {
+			data, _ := schemaFiles.ReadFile(path.Join("schema", name+".json"))
+			var s struct {
+				Description string `json:"description"`
+			}
+			if err := json.Unmarshal(data, &s); err != nil {
+				return fmt.Errorf("schema %s: %v", name, err)
+			}
+			t.addf(name, s.Description)
+		}
+		return t.print(w)
+	}
+	data, err := schemaFiles.ReadFile(path.Join("schema", strings.TrimSuffix(name, ".json")+".json"))
+	if err != nil {
+		return usagef("no schema for %q; want one of %s", name, strings.Join(schemaNames(), ", "))
+	}
+	_, err = w.Write(data)
+	return err
+}
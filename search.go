@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// searchFilter selects the runs search prints. Empty fields match every
+// run.
+type searchFilter struct {
+	// Message matches runs whose commit message contains it, ignoring case.
+	Message string
+	// Author matches runs whose actor email contains it, ignoring case, so
+	// "alice" finds alice@example.com.
+	Author string
+	// Branch is a glob matched against the run's branch, as in path.Match.
+	Branch   string
+	Statuses []RunStatus
+	// Since is the oldest a run can be.
+	Since time.Time
+	// Label matches runs given the label with `heroku-ci label`.
+	Label string
+	// labelled are the IDs of the runs with Label, set by searchRuns.
+	labelled map[string]bool
+}
+
+func (f searchFilter) match(run *TestRun) bool {
+	if f.Message != "" && !strings.Contains(strings.ToLower(run.CommitMessage), strings.ToLower(f.Message)) {
+		return false
+	}
+	if f.Author != "" && !strings.Contains(strings.ToLower(run.ActorEmail), strings.ToLower(f.Author)) {
+		return false
+	}
+	if f.Branch != "" {
+		if ok, _ := path.Match(f.Branch, run.CommitBranch); !ok {
+			return false
+		}
+	}
+	if f.Label != "" && !f.labelled[run.ID.String()] {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, s := range f.Statuses {
+		if run.Status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// searchRuns returns up to limit of the newest runs created since f.Since
+// that match f, newest first. Pages are fetched newest first, and the search
+// stops at the first run older than f.Since. If the API can't be reached it
+// searches the journal instead.
+func searchRuns(ctx context.Context, client *Client, id types.PrefixUUID, f searchFilter, limit int) ([]*TestRun, error) {
+	if f.Branch != "" {
+		if _, err := path.Match(f.Branch, ""); err != nil {
+			return nil, fmt.Errorf("bad --branch pattern %q: %v", f.Branch, err)
+		}
+	}
+	if f.Label != "" {
+		l, err := loadLabels()
+		if err != nil {
+			return nil, err
+		}
+		if f.labelled = l.labelled(id, f.Label); len(f.labelled) == 0 {
+			return []*TestRun{}, nil
+		}
+	}
+	found := make([]*TestRun, 0)
+	collect := func(page []*TestRun) error {
+		for _, run := range page {
+			if run.CreatedAt.Before(f.Since) {
+				return herokuci.ErrStopWalk
+			}
+			if f.match(run) {
+				found = append(found, run)
+				if limit > 0 && len(found) == limit {
+					return herokuci.ErrStopWalk
+				}
+			}
+		}
+		return nil
+	}
+	err := client.WalkTestRuns(ctx, id, herokuci.WalkOptions{NewestFirst: true}, collect)
+	if isOffline(err) {
+		runs, jerr := offlineRuns(id)
+		if jerr != nil {
+			return nil, err
+		}
+		found = found[:0]
+		err = collect(runs)
+		if err == herokuci.ErrStopWalk {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// firstLine returns the first line of a commit message, cut to n
+// characters.
+func firstLine(msg string, n int) string {
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	msg = strings.TrimSpace(msg)
+	if r := []rune(msg); len(r) > n {
+		msg = string(r[:n-1]) + "…"
+	}
+	return msg
+}
+
+// printSearchResults prints one line per run, with the first line of its
+// commit message.
+func printSearchResults(w io.Writer, runs []*TestRun) {
+	t := newTable("RUN", "BRANCH", "COMMIT", "STATUS", "CREATED", "AUTHOR", "MESSAGE")
+	t.flex = []int{1, 5, 6}
+	for _, run := range runs {
+		t.addf("#"+strconv.Itoa(run.Number), run.CommitBranch, shortSHA(run.CommitSHA),
+			run.Status, fmtDateTimeMinutes(run.CreatedAt), run.ActorEmail, redact.String(firstLine(run.CommitMessage, 60)))
+	}
+	t.print(w)
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestSearchRunsByLabel(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	s, client := newCreateServer(t)
+	ctx := t.Context()
+	id := s.pipeline.ID
+	run, err := findRun(ctx, client, id, "", "102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := labelRun(id, run, []string{"perf"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := searchRuns(ctx, client, id, searchFilter{Label: "perf"}, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Number != 102 {
+		t.Fatalf("got %d runs, want #102", len(runs))
+	}
+	runs, err = searchRuns(ctx, client, id, searchFilter{Label: "other"}, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("got %d runs with an unused label, want none", len(runs))
+	}
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// APIToken lets a client of the serve API in, from a [token.<name>] section
+// of the config file.
+type APIToken struct {
+	Name  string
+	Token string
+	// Write lets the token acknowledge runs and snooze pipelines, as well
+	// as read.
+	Write bool
+}
+
+// serveOptions configure the serve API.
+type serveOptions struct {
+	Addr string
+	// GRPCAddr, if set, is where to serve the gRPC API as well.
+	GRPCAddr string
+	Tokens   []APIToken
+	// TLSCert and TLSKey are PEM files; both or neither must be set.
+	TLSCert, TLSKey string
+	// ClientCA, if set, is a PEM file of the CAs client certificates must
+	// be signed by.
+	ClientCA string
+}
+
+// apiServer serves heroku-ci's local state over HTTP and gRPC, so prompts,
+// badges, and bots on other machines can share one heroku-ci.
+type apiServer struct {
+	client *Client
+	tokens []APIToken
+
+	mu    sync.Mutex
+	names map[string]string // pipeline names by ID
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// An authError is why a request's token was turned away.
+type authError struct {
+	// Forbidden is true if the token is valid but may not do the request.
+	Forbidden bool
+	msg       string
+}
+
+func (e *authError) Error() string { return e.msg }
+
+// A badRequestError means the request itself was wrong, and retrying it
+// won't help.
+type badRequestError struct{ msg string }
+
+func (e *badRequestError) Error() string { return e.msg }
+
+func badRequest(format string, args ...interface{}) error {
+	return &badRequestError{msg: fmt.Sprintf(format, args...)}
+}
+
+// checkToken returns the token in auth, an Authorization header or gRPC
+// metadata value, or an *authError if it has none or the token may not
+// do the request. With no tokens configured, every request is allowed;
+// serve only allows that on a loopback address.
+func (s *apiServer) checkToken(auth string, write bool) (*APIToken, error) {
+	if len(s.tokens) == 0 {
+		return &APIToken{Name: "local", Write: true}, nil
+	}
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, &authError{msg: "missing bearer token"}
+	}
+	got := []byte(strings.TrimPrefix(auth, "Bearer "))
+	var found *APIToken
+	// Compare against every token, so the time taken doesn't say which
+	// one nearly matched.
+	for i := range s.tokens {
+		if subtle.ConstantTimeCompare(got, []byte(s.tokens[i].Token)) == 1 {
+			found = &s.tokens[i]
+		}
+	}
+	if found == nil {
+		return nil, &authError{msg: "invalid token"}
+	}
+	if write && !found.Write {
+		return nil, &authError{Forbidden: true, msg: fmt.Sprintf("token %q is read-only", found.Name)}
+	}
+	return found, nil
+}
+
+// status returns the latest run heroku-ci saw on each branch of each
+// checkout, what the prompt command shows.
+func (s *apiServer) status() (statusFile, error) {
+	path, err := statusPath()
+	if err != nil {
+		return nil, err
+	}
+	return readStatusFile(path)
+}
+
+// runs returns the pipeline's runs as last seen in the journal, newest
+// first, limited to branch if it isn't empty.
+func (s *apiServer) runs(pipeline, branch string) ([]*TestRun, error) {
+	id, err := types.NewPrefixUUID(pipeline)
+	if err != nil {
+		return nil, badRequest("pipeline: want a pipeline ID")
+	}
+	runs, _, err := journalRuns(id)
+	if err != nil {
+		return nil, err
+	}
+	if branch == "" {
+		return runs, nil
+	}
+	filtered := runs[:0]
+	for _, run := range runs {
+		if run.CommitBranch == branch {
+			filtered = append(filtered, run)
+		}
+	}
+	return filtered, nil
+}
+
+// runEvents returns the journal events for the runs whose IDs start with
+// prefix.
+func (s *apiServer) runEvents(prefix string) ([]*JournalEvent, error) {
+	if prefix == "" {
+		return nil, badRequest("run: want a run ID")
+	}
+	return readJournal(journalFilter{RunPrefix: prefix})
+}
+
+func (s *apiServer) silences() (*silences, error) {
+	path, err := silencesPath()
+	if err != nil {
+		return nil, err
+	}
+	return readSilences(path)
+}
+
+// ack acknowledges a run, by number, ID, or a shorthand that names a
+// branch, like heroku-ci ack.
+func (s *apiServer) ack(ctx context.Context, pipeline, ref, note string) (*silences, error) {
+	id, err := types.NewPrefixUUID(pipeline)
+	if err != nil || ref == "" {
+		return nil, badRequest("pipeline_id and run are required")
+	}
+	run, err := findRun(ctx, s.client, id, "", ref)
+	if err != nil {
+		return nil, err
+	}
+	name := pipelineName(ctx, s.client, id)
+	if name == "" {
+		name = id.String()
+	}
+	if err := acknowledge(id, name, run, note); err != nil {
+		return nil, err
+	}
+	return s.silences()
+}
+
+// snooze snoozes a pipeline, like heroku-ci snooze. A duration of "0"
+// lifts the snooze.
+func (s *apiServer) snooze(ctx context.Context, pipeline, duration string) (*silences, error) {
+	id, err := types.NewPrefixUUID(pipeline)
+	if err != nil {
+		return nil, badRequest("pipeline_id is required")
+	}
+	var d time.Duration
+	if duration != "0" {
+		if d, err = parseSince(duration); err != nil || d <= 0 {
+			return nil, badRequest("invalid duration %q, want something like 1h, 30m, or 2d", duration)
+		}
+	}
+	name := pipelineName(ctx, s.client, id)
+	if name == "" {
+		name = id.String()
+	}
+	if err := snoozePipeline(id, name, d); err != nil {
+		return nil, err
+	}
+	return s.silences()
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	writeJSON(w, code, &apiError{Error: fmt.Sprintf(format, args...)})
+}
+
+// respond writes v, or the right error response for err.
+func respond(w http.ResponseWriter, v interface{}, err error) {
+	var bad *badRequestError
+	var herr *HerokuError
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, v)
+	case errors.As(err, &bad):
+		writeError(w, http.StatusBadRequest, "%v", err)
+	case errors.As(err, &herr):
+		// Heroku turned down the request we made for it.
+		writeError(w, http.StatusBadGateway, "%v", err)
+	default:
+		writeError(w, http.StatusInternalServerError, "%v", err)
+	}
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.Handle("/ui/", http.FileServer(http.FS(uiFiles)))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/v1/status", s.handleStatus)
+	mux.HandleFunc("/v1/pipelines", s.handlePipelines)
+	mux.HandleFunc("/v1/runs", s.handleRuns)
+	mux.HandleFunc("/v1/runs/", s.handleRunEvents)
+	mux.HandleFunc("/v1/events", s.handleEvents)
+	mux.HandleFunc("/events", s.handleSSE)
+	mux.HandleFunc("/v1/silences", s.handleSilences)
+	mux.HandleFunc("/v1/acks", s.handleAck)
+	mux.HandleFunc("/v1/snoozes", s.handleSnooze)
+	return mux
+}
+
+// want checks the method, and for writes the token's permission, and
+// reports whether to carry on with the request.
+func (s *apiServer) want(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, "%s not allowed, use %s", r.Method, method)
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if auth == "" && method == "GET" {
+		// Browsers' EventSource can't set headers, so reads may pass the
+		// token in the URL instead.
+		if t := r.URL.Query().Get("access_token"); t != "" {
+			auth = "Bearer " + t
+		}
+	}
+	tok, err := s.checkToken(auth, method != "GET")
+	if err != nil {
+		if err.(*authError).Forbidden {
+			writeError(w, http.StatusForbidden, "%v", err)
+			return false
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="heroku-ci"`)
+		writeError(w, http.StatusUnauthorized, "%v", err)
+		return false
+	}
+	if method != "GET" {
+		log.Printf("serve: %s %s by token %q", r.Method, r.URL.Path, tok.Name)
+	}
+	return true
+}
+
+func (s *apiServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.want(w, r, "GET") {
+		return
+	}
+	sf, err := s.status()
+	respond(w, sf, err)
+}
+
+// handleRuns returns the runs in ?pipeline=, optionally on ?branch=.
+func (s *apiServer) handleRuns(w http.ResponseWriter, r *http.Request) {
+	if !s.want(w, r, "GET") {
+		return
+	}
+	q := r.URL.Query()
+	runs, err := s.runs(q.Get("pipeline"), normalizeBranch(q.Get("branch")))
+	respond(w, runs, err)
+}
+
+// handleRunEvents returns the journal events for /v1/runs/<id>/events,
+// where id may be a prefix of the run ID.
+func (s *apiServer) handleRunEvents(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/v1/runs/")
+	prefix := strings.TrimSuffix(rest, "/events")
+	if prefix == rest || prefix == "" || strings.Contains(prefix, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if !s.want(w, r, "GET") {
+		return
+	}
+	events, err := s.runEvents(prefix)
+	respond(w, events, err)
+}
+
+// handleEvents streams journal events as they are recorded, one JSON
+// object per line, optionally only those for ?pipeline= or ?run=.
+func (s *apiServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !s.want(w, r, "GET") {
+		return
+	}
+	q := r.URL.Query()
+	f := journalFilter{PipelineID: q.Get("pipeline"), RunPrefix: q.Get("run")}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	enc := json.NewEncoder(w)
+	err := followJournal(r.Context(), f, time.Now(), func(ev *JournalEvent) error {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil && r.Context().Err() == nil {
+		log.Printf("serve: streaming events: %v", err)
+	}
+}
+
+func (s *apiServer) handleSilences(w http.ResponseWriter, r *http.Request) {
+	if !s.want(w, r, "GET") {
+		return
+	}
+	sl, err := s.silences()
+	respond(w, sl, err)
+}
+
+// handleAck acknowledges a run. The body is
+// {"pipeline_id": "...", "run": "1312", "note": "..."}.
+func (s *apiServer) handleAck(w http.ResponseWriter, r *http.Request) {
+	if !s.want(w, r, "POST") {
+		return
+	}
+	var body struct {
+		PipelineID string `json:"pipeline_id"`
+		Run        string `json:"run"`
+		Note       string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	sl, err := s.ack(r.Context(), body.PipelineID, body.Run, body.Note)
+	respond(w, sl, err)
+}
+
+// handleSnooze snoozes a pipeline. The body is
+// {"pipeline_id": "...", "duration": "1h"}.
+func (s *apiServer) handleSnooze(w http.ResponseWriter, r *http.Request) {
+	if !s.want(w, r, "POST") {
+		return
+	}
+	var body struct {
+		PipelineID string `json:"pipeline_id"`
+		Duration   string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	sl, err := s.snooze(r.Context(), body.PipelineID, body.Duration)
+	respond(w, sl, err)
+}
+
+// isLoopback reports whether addr only listens on the local machine.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func serveTLSConfig(opts serveOptions) (*tls.Config, error) {
+	if (opts.TLSCert == "") != (opts.TLSKey == "") {
+		return nil, errors.New("--tls-cert and --tls-key must be used together")
+	}
+	if opts.TLSCert == "" {
+		if opts.ClientCA != "" {
+			return nil, errors.New("--client-ca needs --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if opts.ClientCA != "" {
+		pem, err := os.ReadFile(opts.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", opts.ClientCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// serve runs the HTTP API, and the gRPC API if opts.GRPCAddr is set, until
+// ctx is cancelled.
+func serve(ctx context.Context, client *Client, opts serveOptions) error {
+	for _, addr := range []string{opts.Addr, opts.GRPCAddr} {
+		if addr != "" && len(opts.Tokens) == 0 && !isLoopback(addr) {
+			return fmt.Errorf("refusing to serve on %s without any tokens; add a [token.<name>] section to the config file, or listen on 127.0.0.1", addr)
+		}
+	}
+	tlsConfig, err := serveTLSConfig(opts)
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil && !isLoopback(opts.Addr) {
+		warnf("serving on %s without TLS; tokens are sent in the clear", opts.Addr)
+	}
+	s := &apiServer{client: client, tokens: opts.Tokens}
+	srv := &http.Server{
+		Addr:              opts.Addr,
+		Handler:           s.routes(),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          log.New(newRedactWriter(os.Stderr), "serve: ", log.LstdFlags),
+	}
+	ln, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	fmt.Printf("Serving the heroku-ci API on %s://%s\n", scheme, ln.Addr())
+	errc := make(chan error, 2)
+	go func() { errc <- srv.Serve(ln) }()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if opts.GRPCAddr != "" {
+		go func() { errc <- serveGRPC(ctx, s, opts.GRPCAddr, tlsConfig) }()
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	return srv.Shutdown(shutdownCtx)
+}
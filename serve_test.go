@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeChecksTokens(t *testing.T) {
+	s := &apiServer{tokens: []APIToken{
+		{Name: "bot", Token: "read-token-0123456789"},
+		{Name: "ops", Token: "write-token-0123456789", Write: true},
+	}}
+	mux := http.NewServeMux()
+	for _, method := range []string{"GET", "POST"} {
+		mux.HandleFunc("/"+strings.ToLower(method), func(w http.ResponseWriter, r *http.Request) {
+			if s.want(w, r, method) {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		})
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   string
+		want   int
+	}{
+		{"no token", "GET", "/get", "", http.StatusUnauthorized},
+		{"wrong token", "GET", "/get", "Bearer nope", http.StatusUnauthorized},
+		{"not bearer", "GET", "/get", "Basic read-token-0123456789", http.StatusUnauthorized},
+		{"read token", "GET", "/get", "Bearer read-token-0123456789", http.StatusNoContent},
+		{"read token writing", "POST", "/post", "Bearer read-token-0123456789", http.StatusForbidden},
+		{"write token reading", "GET", "/get", "Bearer write-token-0123456789", http.StatusNoContent},
+		{"write token writing", "POST", "/post", "Bearer write-token-0123456789", http.StatusNoContent},
+		{"query token reading", "GET", "/get?access_token=read-token-0123456789", "", http.StatusNoContent},
+		{"query token writing", "POST", "/post?access_token=write-token-0123456789", "", http.StatusUnauthorized},
+		{"wrong method", "POST", "/get", "Bearer write-token-0123456789", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequestWithContext(t.Context(), tt.method, srv.URL+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without a WWW-Authenticate header", tt.name)
+		}
+	}
+}
+
+func TestCheckTokenWithoutTokens(t *testing.T) {
+	s := &apiServer{}
+	tok, err := s.checkToken("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tok.Write {
+		t.Errorf("with no tokens configured, want a write token, got %+v", tok)
+	}
+}
+
+func TestServeRefusesOpenNonLoopback(t *testing.T) {
+	tests := []serveOptions{
+		{Addr: "0.0.0.0:0"},
+		{Addr: ":0"},
+		{Addr: "127.0.0.1:0", GRPCAddr: "0.0.0.0:0"},
+	}
+	for _, opts := range tests {
+		err := serve(t.Context(), nil, opts)
+		if err == nil || !strings.Contains(err.Error(), "without any tokens") {
+			t.Errorf("serve(%+v): got %v, want a refusal", opts, err)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A SetupPhase is one step of a test node's setup, as announced by a
+// "-----> " line in the setup stream.
+type SetupPhase struct {
+	Name     string        `json:"name"`
+	Category string        `json:"category"`
+	Duration time.Duration `json:"duration"`
+}
+
+// SetupRecord holds the setup phases for one node of a test run.
+type SetupRecord struct {
+	PipelineID   types.PrefixUUID `json:"pipeline_id"`
+	RunID        types.PrefixUUID `json:"run_id"`
+	CommitBranch string           `json:"commit_branch"`
+	Node         int              `json:"node"`
+	CreatedAt    time.Time        `json:"created_at"`
+	Phases       []SetupPhase     `json:"phases"`
+}
+
+// Categories that setup phases are grouped into for reporting.
+var setupCategories = []string{"cache restore", "buildpack compile", "addon provision", "test setup", "cache save", "other"}
+
+// categorizeSetupPhase sorts a "-----> " header into one of setupCategories.
+func categorizeSetupPhase(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "restor") && strings.Contains(lower, "cache"):
+		return "cache restore"
+	case strings.Contains(lower, "cach"):
+		return "cache save"
+	case strings.Contains(lower, "provision") || strings.Contains(lower, "addon") || strings.Contains(lower, "add-on"):
+		return "addon provision"
+	case strings.Contains(lower, "test-setup") || strings.Contains(lower, "test setup"):
+		return "test setup"
+	case strings.Contains(lower, "install") || strings.Contains(lower, "build") || strings.Contains(lower, "compil") ||
+		strings.Contains(lower, "detected") || strings.Contains(lower, "buildpack"):
+		return "buildpack compile"
+	default:
+		return "other"
+	}
+}
+
+// Substrings that mark a setup line as a warning worth surfacing. Buildpacks
+// prefix warnings with " !", and the rest catch deprecation and end-of-life
+// notices and cache problems.
+var setupWarningMarkers = []string{
+	"warning",
+	"deprecat",
+	"end-of-life",
+	"end of life",
+	"no longer supported",
+	"cache is corrupt",
+	"corrupted cache",
+}
+
+// Most warnings to keep from a single node's setup, so a noisy buildpack
+// can't flood the summary.
+const maxSetupWarnings = 20
+
+func isSetupWarning(line string) bool {
+	if strings.HasPrefix(line, "!") {
+		return true
+	}
+	lower := strings.ToLower(line)
+	for _, marker := range setupWarningMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanSetup reads a setup stream from r as it arrives. It times each phase
+// by when its header line shows up; the final phase ends when the stream does.
+// It also collects any lines that look like buildpack warnings.
+func scanSetup(r io.Reader, now func() time.Time) ([]SetupPhase, []string, error) {
+	phases := make([]SetupPhase, 0)
+	warnings := make([]string, 0)
+	seen := make(map[string]bool)
+	var cur string
+	var start time.Time
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "----->") {
+			if isSetupWarning(line) && !seen[line] && len(warnings) < maxSetupWarnings {
+				seen[line] = true
+				warnings = append(warnings, line)
+			}
+			continue
+		}
+		t := now()
+		if cur != "" {
+			phases = append(phases, SetupPhase{Name: cur, Category: categorizeSetupPhase(cur), Duration: t.Sub(start)})
+		}
+		cur = strings.TrimSpace(strings.TrimPrefix(line, "----->"))
+		start = t
+	}
+	if cur != "" {
+		phases = append(phases, SetupPhase{Name: cur, Category: categorizeSetupPhase(cur), Duration: now().Sub(start)})
+	}
+	return phases, warnings, scanner.Err()
+}
+
+func appendSetupRecord(rec *SetupRecord) error {
+	path, err := setupPhasesPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return withLock(path, func() error {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func readSetupRecords() ([]*SetupRecord, error) {
+	path, err := setupPhasesPath()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*SetupRecord, 0)
+	err = withLock(path, func() error {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dec := json.NewDecoder(f)
+		for {
+			rec := new(SetupRecord)
+			if err := dec.Decode(rec); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			records = append(records, rec)
+		}
+	})
+	return records, err
+}
+
+// streamSetup reads the setup stream for node. If recordPhases is true, the
+// phase timings are stored. It returns any warnings found in the stream.
+func streamSetup(ctx context.Context, id types.PrefixUUID, run *TestRun, node *TestNode, recordPhases bool) ([]string, error) {
+	req, err := http.NewRequest("GET", node.SetupStreamURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("setup stream for node %d: unexpected status %d", node.Index, resp.StatusCode)
+	}
+	phases, warnings, err := scanSetup(resp.Body, time.Now)
+	if err != nil {
+		return warnings, err
+	}
+	if !recordPhases || len(phases) == 0 {
+		return warnings, nil
+	}
+	return warnings, appendSetupRecord(&SetupRecord{
+		PipelineID:   id,
+		RunID:        run.ID,
+		CommitBranch: run.CommitBranch,
+		Node:         node.Index,
+		CreatedAt:    run.CreatedAt,
+		Phases:       phases,
+	})
+}
+
+// A setupWatch reads the setup streams of a test run's nodes in the
+// background.
+type setupWatch struct {
+	done chan struct{}
+
+	mu       sync.Mutex
+	warnings []string
+}
+
+// Warnings returns the buildpack warnings found so far, prefixed by node
+// index if the run has more than one node.
+func (s *setupWatch) Warnings() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.warnings...)
+}
+
+// watchSetup reads the setup stream of every node in run in the background,
+// collecting warnings. If recordPhases is true it also stores the phase
+// timings; this only makes sense if setup has not happened yet. The watch's
+// done channel is closed once every stream has finished. Errors are reported
+// but do not affect the wait.
+func watchSetup(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun, recordPhases bool) *setupWatch {
+	watch := &setupWatch{done: make(chan struct{})}
+	go func() {
+		defer close(watch.done)
+		var nodes []*TestNode
+		// Nodes and their stream URLs show up a little while after the run
+		// is created.
+		for {
+			var err error
+			nodes, err = getTestNodes(ctx, client, run.ID)
+			if err == nil && len(nodes) > 0 && nodes[0].SetupStreamURL != "" {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+		var wg sync.WaitGroup
+		for i := range nodes {
+			if nodes[i].SetupStreamURL == "" {
+				continue
+			}
+			wg.Add(1)
+			go func(node *TestNode) {
+				defer wg.Done()
+				warnings, err := streamSetup(ctx, id, run, node, recordPhases)
+				if err != nil && !errors.Is(err, context.Canceled) {
+					warnf("could not read setup output: %v", err)
+				}
+				watch.mu.Lock()
+				for _, w := range warnings {
+					if len(nodes) > 1 {
+						w = fmt.Sprintf("node %d: %s", node.Index, w)
+					}
+					watch.warnings = append(watch.warnings, w)
+				}
+				watch.mu.Unlock()
+			}(nodes[i])
+		}
+		wg.Wait()
+	}()
+	return watch
+}
+
+// setupReport prints how long each category of setup phase has taken across
+// the last limit recorded runs in the pipeline, comparing the older half of
+// those runs to the newer half.
+func setupReport(id types.PrefixUUID, limit int) error {
+	records, err := readSetupRecords()
+	if err != nil {
+		return err
+	}
+	// total phase time per category, per run
+	type runTotals struct {
+		createdAt time.Time
+		totals    map[string]time.Duration
+	}
+	byRun := make(map[string]*runTotals)
+	for _, rec := range records {
+		if rec.PipelineID.String() != id.String() {
+			continue
+		}
+		key := rec.RunID.String()
+		rt, ok := byRun[key]
+		if !ok {
+			rt = &runTotals{createdAt: rec.CreatedAt, totals: make(map[string]time.Duration)}
+			byRun[key] = rt
+		}
+		// Nodes run setup in parallel, so a run's setup time for a category
+		// is the slowest node's.
+		nodeTotals := make(map[string]time.Duration)
+		for _, phase := range rec.Phases {
+			nodeTotals[phase.Category] += phase.Duration
+		}
+		for cat, d := range nodeTotals {
+			if d > rt.totals[cat] {
+				rt.totals[cat] = d
+			}
+		}
+	}
+	if len(byRun) == 0 {
+		return errors.New("no setup phases recorded for this pipeline yet; they are recorded by wait when it watches a run's setup")
+	}
+	runs := make([]*runTotals, 0, len(byRun))
+	for _, rt := range byRun {
+		runs = append(runs, rt)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].createdAt.Before(runs[j].createdAt) })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	half := len(runs) / 2
+	older, newer := runs[:half], runs[half:]
+	avg := func(rs []*runTotals, cat string) time.Duration {
+		if len(rs) == 0 {
+			return 0
+		}
+		var total time.Duration
+		for _, rt := range rs {
+			total += rt.totals[cat]
+		}
+		return total / time.Duration(len(rs))
+	}
+	fmt.Printf("Setup phases across %s runs (%s to %s)\n\n", fmtInt(len(runs)), fmtShortDate(runs[0].createdAt), fmtShortDate(runs[len(runs)-1].createdAt))
+	t := newTable("PHASE", "OLDER AVG", "NEWER AVG", "LATEST", "CHANGE")
+	for _, cat := range setupCategories {
+		seen := false
+		for _, rt := range runs {
+			if _, ok := rt.totals[cat]; ok {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			continue
+		}
+		o, n := avg(older, cat), avg(newer, cat)
+		change := "-"
+		if o > 0 {
+			change = fmt.Sprintf("%+.0f%%", 100*float64(n-o)/float64(o))
+		}
+		t.addf(cat, roundDuration(o), roundDuration(n), roundDuration(runs[len(runs)-1].totals[cat]), change)
+	}
+	return t.print(os.Stdout)
+}
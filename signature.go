@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A FailureSignature identifies a way a run failed: the same failing tests
+// with the same key error lines, regardless of timings, addresses, and
+// other details that change from one run to the next.
+type FailureSignature struct {
+	PipelineID types.PrefixUUID `json:"pipeline_id"`
+	RunID      types.PrefixUUID `json:"run_id"`
+	RunNumber  int              `json:"run_number"`
+	Branch     string           `json:"branch"`
+	CreatedAt  time.Time        `json:"created_at"`
+	// Signature is a short hash of Tests and Errors.
+	Signature string   `json:"signature"`
+	Tests     []string `json:"tests,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// Lines in a summary that say what went wrong.
+var errorLine = regexp.MustCompile(`(?i)\b(?:error|exception|panic|assert(?:ion)?|expected|timed? ?out|undefined|refused|cannot|could not|not found)\b`)
+
+// Details that differ between two failures that are otherwise the same.
+var (
+	hexAddress = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`)
+	uuids      = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+	lineNumber = regexp.MustCompile(`:\d+(?::\d+)?\b`)
+	numbers    = regexp.MustCompile(`\b\d+\b`)
+	tempPaths  = regexp.MustCompile(`/tmp/\S+|/app/tmp/\S+`)
+)
+
+// The most error lines that go into a signature. The first few are usually
+// the cause; later ones tend to be cascading failures.
+const maxSignatureErrors = 5
+
+// normalizeErrorLine strips the details of a line of output that vary from
+// run to run.
+func normalizeErrorLine(line string) string {
+	if i := strings.Index(line, ": "); strings.HasPrefix(line, "node ") && i > 0 {
+		line = line[i+2:]
+	}
+	line = uuids.ReplaceAllString(line, "<id>")
+	line = hexAddress.ReplaceAllString(line, "<addr>")
+	line = tempPaths.ReplaceAllString(line, "<tmp>")
+	line = lineNumber.ReplaceAllString(line, ":<line>")
+	line = numbers.ReplaceAllString(line, "<n>")
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// failureSignature computes the signature of a failed run from the tests
+// named in its output and the last lines of it.
+func failureSignature(tests, tail []string) (sig string, errs []string) {
+	sort.Strings(tests)
+	seen := make(map[string]bool)
+	for _, line := range summarySection(tail) {
+		if !errorLine.MatchString(line) {
+			continue
+		}
+		line = normalizeErrorLine(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		errs = append(errs, line)
+		if len(errs) == maxSignatureErrors {
+			break
+		}
+	}
+	h := sha256.New()
+	for _, t := range tests {
+		fmt.Fprintf(h, "test %s\n", t)
+	}
+	for _, e := range errs {
+		fmt.Fprintf(h, "error %s\n", e)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:10], errs
+}
+
+// Summary describes the failure in a few words: its failing tests, or its
+// first error line if no tests were found.
+func (s *FailureSignature) Summary() string {
+	switch {
+	case len(s.Tests) > 2:
+		return fmt.Sprintf("%s, %s, and %d more", s.Tests[0], s.Tests[1], len(s.Tests)-2)
+	case len(s.Tests) > 0:
+		return strings.Join(s.Tests, ", ")
+	case len(s.Errors) > 0:
+		return s.Errors[0]
+	default:
+		return "(no failing tests or errors found in the output)"
+	}
+}
+
+func appendSignatures(sigs []*FailureSignature) error {
+	if len(sigs) == 0 {
+		return nil
+	}
+	path, err := signaturesPath()
+	if err != nil {
+		return err
+	}
+	var data []byte
+	for _, s := range sigs {
+		line, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return withLock(path, func() error {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// readSignatures returns the recorded signatures by run ID.
+func readSignatures() (map[string]*FailureSignature, error) {
+	path, err := signaturesPath()
+	if err != nil {
+		return nil, err
+	}
+	sigs := make(map[string]*FailureSignature)
+	err = withLock(path, func() error {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dec := json.NewDecoder(f)
+		for {
+			s := new(FailureSignature)
+			if err := dec.Decode(s); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			sigs[s.RunID.String()] = s
+		}
+	})
+	return sigs, err
+}
+
+// runSignatures returns the signature of every run in runs, which must
+// have failed. Signatures are recorded, since a finished run's output never
+// changes, so only runs we haven't seen before have their output fetched,
+// historyWorkers at a time.
+func runSignatures(ctx context.Context, client *Client, id types.PrefixUUID, runs []*TestRun) ([]*FailureSignature, error) {
+	known, err := readSignatures()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*FailureSignature, len(runs))
+	var todo []int
+	for i, run := range runs {
+		if s, ok := known[run.ID.String()]; ok {
+			out[i] = s
+		} else {
+			todo = append(todo, i)
+		}
+	}
+	var mu sync.Mutex
+	var firstErr error
+	fresh := make([]*FailureSignature, 0, len(todo))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < historyWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				run := runs[i]
+				var tests, tail []string
+				output, err := fetchRunOutput(ctx, client, run)
+				if err == nil {
+					tests, err = output.FailingTests()
+					tail = output.Tail()
+					output.Close()
+				}
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("output of run #%d: %v", run.Number, err)
+					}
+					mu.Unlock()
+					continue
+				}
+				s := &FailureSignature{PipelineID: id, RunID: run.ID, RunNumber: run.Number, Branch: run.CommitBranch, CreatedAt: run.CreatedAt}
+				s.Tests = tests
+				s.Signature, s.Errors = failureSignature(tests, tail)
+				out[i] = s
+				fresh = append(fresh, s)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, i := range todo {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	if err := appendSignatures(fresh); err != nil {
+		warnf("could not record failure signatures: %v", err)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return out, nil
+}
+
+// A signatureGroup is every failure with the same signature.
+type signatureGroup struct {
+	Signature *FailureSignature
+	Runs      int
+	Branches  map[string]bool
+	Last      time.Time
+}
+
+// groupSignatures groups sigs by signature, the most frequent first.
+func groupSignatures(sigs []*FailureSignature) []*signatureGroup {
+	m := make(map[string]*signatureGroup)
+	for _, s := range sigs {
+		g, ok := m[s.Signature]
+		if !ok {
+			g = &signatureGroup{Signature: s, Branches: make(map[string]bool)}
+			m[s.Signature] = g
+		}
+		g.Runs++
+		g.Branches[s.Branch] = true
+		if s.CreatedAt.After(g.Last) {
+			g.Last = s.CreatedAt
+		}
+	}
+	groups := make([]*signatureGroup, 0, len(m))
+	for _, g := range m {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Runs != groups[j].Runs {
+			return groups[i].Runs > groups[j].Runs
+		}
+		return groups[i].Last.After(groups[j].Last)
+	})
+	return groups
+}
+
+// printSignatureGroups prints the top groups, most frequent first.
+func printSignatureGroups(w io.Writer, groups []*signatureGroup, top int) error {
+	shown := groups
+	if top > 0 && len(shown) > top {
+		shown = shown[:top]
+	}
+	t := newTable("SIGNATURE", "RUNS", "BRANCHES", "LAST SEEN", "FAILURE")
+	t.flex = []int{4}
+	for _, g := range shown {
+		t.addf(g.Signature.Signature, fmtInt(g.Runs), fmtInt(len(g.Branches)), fmtDateTimeMinutes(g.Last), redact.String(g.Signature.Summary()))
+	}
+	if err := t.print(w); err != nil {
+		return err
+	}
+	if hidden := len(groups) - len(shown); hidden > 0 {
+		fmt.Fprintf(w, "(%s more; pass --top=0 to see them all)\n", fmtInt(hidden))
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// An ack says someone knows a branch is broken. Failure notifications for
+// the branch stop until a run on it passes.
+type ack struct {
+	PipelineID string    `json:"pipeline_id"`
+	Pipeline   string    `json:"pipeline"`
+	Branch     string    `json:"branch"`
+	RunNumber  int       `json:"run_number"`
+	By         string    `json:"by"`
+	At         time.Time `json:"at"`
+	Note       string    `json:"note,omitempty"`
+}
+
+// A snooze stops every failure notification for a pipeline until Until.
+type snooze struct {
+	PipelineID string    `json:"pipeline_id"`
+	Pipeline   string    `json:"pipeline"`
+	Until      time.Time `json:"until"`
+	By         string    `json:"by"`
+}
+
+// silences are the acks and snoozes recorded in silencesPath.
+type silences struct {
+	Acks    []*ack    `json:"acks"`
+	Snoozes []*snooze `json:"snoozes"`
+}
+
+// readSilences returns the recorded silences, without any snoozes that
+// have expired.
+func readSilences(path string) (*silences, error) {
+	s := new(silences)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	now := time.Now()
+	snoozes := s.Snoozes[:0]
+	for _, sn := range s.Snoozes {
+		if sn.Until.After(now) {
+			snoozes = append(snoozes, sn)
+		}
+	}
+	s.Snoozes = snoozes
+	return s, nil
+}
+
+// updateSilences calls fn with the recorded silences and saves what it
+// leaves, holding the lock so concurrent heroku-ci processes don't lose each
+// other's changes.
+func updateSilences(fn func(s *silences) error) error {
+	path, err := silencesPath()
+	if err != nil {
+		return err
+	}
+	return withLock(path, func() error {
+		s, err := readSilences(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path, append(data, '\n'), 0644)
+	})
+}
+
+// loadSilences returns the recorded silences, or nil if they can't be read.
+func loadSilences() *silences {
+	path, err := silencesPath()
+	if err != nil {
+		return nil
+	}
+	s, err := readSilences(path)
+	if err != nil {
+		warnf("could not read acknowledgements: %v", err)
+		return nil
+	}
+	return s
+}
+
+// silencedBy returns why failure notifications for branch in the pipeline
+// are silenced, or "" if they aren't.
+func (s *silences) silencedBy(id types.PrefixUUID, branch string) string {
+	if s == nil {
+		return ""
+	}
+	for _, sn := range s.Snoozes {
+		if sn.PipelineID == id.String() {
+			return fmt.Sprintf("snoozed by %s until %s", sn.By, fmtDateTimeMinutes(sn.Until))
+		}
+	}
+	for _, a := range s.Acks {
+		if a.PipelineID == id.String() && a.Branch == branch {
+			return fmt.Sprintf("%s acknowledged by %s at run #%d", branch, a.By, a.RunNumber)
+		}
+	}
+	return ""
+}
+
+// silenceNotification reports whether to skip the notification for a
+// finished run, because the branch was acknowledged or the pipeline
+// snoozed. A passing run clears the branch's acknowledgements, so the next
+// failure is announced again.
+func silenceNotification(id types.PrefixUUID, run *TestRun) bool {
+	if isPassing(run.Status) {
+		s := loadSilences()
+		if s == nil || s.silencedBy(id, run.CommitBranch) == "" {
+			return false
+		}
+		err := updateSilences(func(s *silences) error {
+			acks := s.Acks[:0]
+			for _, a := range s.Acks {
+				if a.PipelineID != id.String() || a.Branch != run.CommitBranch {
+					acks = append(acks, a)
+				}
+			}
+			s.Acks = acks
+			return nil
+		})
+		if err != nil {
+			warnf("could not clear acknowledgements: %v", err)
+		}
+		return false
+	}
+	if !isFailing(run.Status) {
+		return false
+	}
+	if why := loadSilences().silencedBy(id, run.CommitBranch); why != "" {
+		warnf("not notifying about run #%d: %s", run.Number, why)
+		return true
+	}
+	return false
+}
+
+// silencer returns the name to record on acks and snoozes.
+func silencer() string {
+	if name := gitConfig("user.name"); name != "" {
+		return name
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// acknowledge silences failure notifications for the branch of run until
+// a run on it passes.
+func acknowledge(id types.PrefixUUID, name string, run *TestRun, note string) error {
+	a := &ack{
+		PipelineID: id.String(),
+		Pipeline:   name,
+		Branch:     run.CommitBranch,
+		RunNumber:  run.Number,
+		By:         silencer(),
+		At:         time.Now().UTC(),
+		Note:       note,
+	}
+	err := updateSilences(func(s *silences) error {
+		acks := s.Acks[:0]
+		for _, old := range s.Acks {
+			if old.PipelineID != a.PipelineID || old.Branch != a.Branch {
+				acks = append(acks, old)
+			}
+		}
+		s.Acks = append(acks, a)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Acknowledged run #%d on %s; no more failure notifications for %s until a run passes.\n", run.Number, run.CommitBranch, run.CommitBranch)
+	return nil
+}
+
+// snoozePipeline silences failure notifications for the pipeline for d, or
+// lifts a snooze if d is zero.
+func snoozePipeline(id types.PrefixUUID, name string, d time.Duration) error {
+	sn := &snooze{PipelineID: id.String(), Pipeline: name, Until: time.Now().Add(d).UTC(), By: silencer()}
+	err := updateSilences(func(s *silences) error {
+		snoozes := s.Snoozes[:0]
+		for _, old := range s.Snoozes {
+			if old.PipelineID != sn.PipelineID {
+				snoozes = append(snoozes, old)
+			}
+		}
+		if d > 0 {
+			snoozes = append(snoozes, sn)
+		}
+		s.Snoozes = snoozes
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if d == 0 {
+		fmt.Printf("%s is no longer snoozed.\n", name)
+		return nil
+	}
+	fmt.Printf("Snoozed failure notifications for %s until %s.\n", name, fmtDateTimeMinutes(sn.Until))
+	return nil
+}
+
+// printSilences lists the acknowledgements and snoozes for the pipelines in
+// ids, or every pipeline if ids is empty.
+func printSilences(w io.Writer, s *silences, ids []types.PrefixUUID) {
+	want := func(id string) bool {
+		if len(ids) == 0 {
+			return true
+		}
+		for _, i := range ids {
+			if i.String() == id {
+				return true
+			}
+		}
+		return false
+	}
+	for _, sn := range s.Snoozes {
+		if want(sn.PipelineID) {
+			fmt.Fprintf(w, "%s: snoozed by %s until %s\n", sn.Pipeline, sn.By, fmtDateTimeMinutes(sn.Until))
+		}
+	}
+	for _, a := range s.Acks {
+		if !want(a.PipelineID) {
+			continue
+		}
+		note := ""
+		if a.Note != "" {
+			note = ": " + a.Note
+		}
+		fmt.Fprintf(w, "%s %s: acknowledged at run #%d by %s, %s%s\n", a.Pipeline, a.Branch, a.RunNumber, a.By, fmtDateTimeMinutes(a.At), note)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// The prefix of the temporary branches smoke pushes.
+const smokeBranchPrefix = "heroku-ci-smoke/"
+
+// How long to wait after pushing for Heroku to start a run of the branch on
+// its own, as it does for pipelines with automatic CI on GitHub pushes.
+const smokeAutoRunWait = 15 * time.Second
+
+// smokeOptions configure smoke.
+type smokeOptions struct {
+	// Remote is the git remote to push the temporary branch to.
+	Remote string
+	// SourceURL, if set, is a tarball to test instead of pushing HEAD.
+	SourceURL string
+	// KeepBranch leaves the temporary branch on the remote afterwards.
+	KeepBranch bool
+}
+
+// uncommittedChanges reports whether the working tree has changes HEAD
+// doesn't include.
+func uncommittedChanges() bool {
+	out, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+	return err == nil && len(strings.TrimSpace(string(out))) > 0
+}
+
+// gitPush runs git push with args, and returns its output in the error if it
+// fails.
+func gitPush(ctx context.Context, args ...string) error {
+	out, err := exec.CommandContext(ctx, "git", append([]string{"push", "--quiet"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git push %s: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// smoke tests HEAD without touching any real branch: it pushes HEAD to a
+// temporary branch, runs CI on it, reports the result, and deletes the
+// branch again. With a source URL nothing is pushed, and the run is
+// labelled with the temporary branch name only.
+func smoke(ctx context.Context, client *Client, id types.PrefixUUID, opts smokeOptions) error {
+	if err := checkPermission(ctx, client, id, "smoke", permDeploy); err != nil {
+		return err
+	}
+	if err := checkWritable("start a smoke test run"); err != nil {
+		return err
+	}
+	sha, err := fullSHA("HEAD")
+	if err != nil {
+		return err
+	}
+	message, err := commitSubject(sha)
+	if err != nil {
+		return err
+	}
+	if uncommittedChanges() {
+		warnf("smoke tests HEAD (%s); your uncommitted changes aren't included", shortSHA(sha))
+	}
+	branch := fmt.Sprintf("%s%s-%d", smokeBranchPrefix, shortSHA(sha), time.Now().Unix())
+	start := time.Now()
+	sourceURL := opts.SourceURL
+	if sourceURL == "" {
+		if err := gitPush(ctx, opts.Remote, sha+":refs/heads/"+branch); err != nil {
+			return err
+		}
+		fmt.Printf("Pushed %s to %s/%s\n", shortSHA(sha), opts.Remote, branch)
+		if !opts.KeepBranch {
+			defer func() {
+				// Clean up even if ctx was cancelled.
+				dctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				if err := gitPush(dctx, "--delete", opts.Remote, branch); err != nil {
+					warnf("could not delete the temporary branch: %v", err)
+					return
+				}
+				fmt.Printf("Deleted %s/%s\n", opts.Remote, branch)
+			}()
+		}
+		if sourceURL, err = githubTarballURL(sha); err != nil {
+			return err
+		}
+	}
+	run, err := smokeRun(ctx, client, id, branch, sha, message, sourceURL, start, opts.SourceURL == "")
+	if err != nil {
+		return err
+	}
+	recordAudit(ctx, &AuditEntry{
+		Account:    client.ID,
+		Action:     "smoke",
+		PipelineID: id.String(),
+		RunNumber:  run.Number,
+		Target:     branch,
+		Text:       fmt.Sprintf("Started smoke test run #%d of %s", run.Number, shortSHA(sha)),
+	})
+	run, warnings, err := waitForTestRun(ctx, client, id, run, "", nil)
+	if err != nil {
+		return err
+	}
+	return finishWait(ctx, client, id, run, warnings, waitOptions{Hints: true, FailOnFailure: true})
+}
+
+// smokeRun returns the run for the temporary branch. If the branch was
+// pushed, Heroku may start one on its own; otherwise it starts one.
+func smokeRun(ctx context.Context, client *Client, id types.PrefixUUID, branch, sha, message, sourceURL string, since time.Time, pushed bool) (*TestRun, error) {
+	if pushed {
+		deadline := time.Now().Add(smokeAutoRunWait)
+		for time.Now().Before(deadline) {
+			run, err := findCreatedRun(ctx, client, id, branch, sha, since.Add(-createLookback), 0)
+			if err != nil {
+				return nil, err
+			}
+			if run != nil {
+				fmt.Printf("Heroku started test run #%d for the push\n", run.Number)
+				return run, nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(jitter(pollInterval)):
+			}
+		}
+	}
+	run, err := createTestRun(ctx, client, id, branch, sha, message, sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Started test run #%d on %s\n", run.Number, branch)
+	return run, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A Source is somewhere to upload a tarball for Heroku to build, from POST
+// /sources. The URLs expire after an hour.
+type Source struct {
+	SourceBlob struct {
+		GetURL string `json:"get_url"`
+		PutURL string `json:"put_url"`
+	} `json:"source_blob"`
+}
+
+// runOptions configure runSource.
+type runOptions struct {
+	// WorkingTree tests the working tree's uncommitted changes to tracked
+	// files too, instead of only HEAD.
+	WorkingTree bool
+	// ClearCache builds the run without the build cache.
+	ClearCache bool
+}
+
+// archiveTree writes a gzipped tarball of tree, a commit or tree-ish, to a
+// temporary file, and returns it rewound to the start. The caller removes it.
+func archiveTree(ctx context.Context, tree string) (*os.File, error) {
+	f, err := os.CreateTemp("", "heroku-ci-source-")
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "git", "archive", "--format=tar.gz", tree)
+	cmd.Stdout = f
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("git archive %s: %s", tree, strings.TrimSpace(stderr.String()))
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// workingTree returns a tree-ish with the working tree's changes to tracked
+// files, or HEAD if there are none. git stash create makes the commit without
+// touching the working tree or the stash list.
+func workingTree(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "stash", "create").Output()
+	if err != nil {
+		return "", fmt.Errorf("git stash create: %v", err)
+	}
+	if sha := strings.TrimSpace(string(out)); sha != "" {
+		return sha, nil
+	}
+	return "HEAD", nil
+}
+
+// uploadSource uploads the tarball in f, and returns a URL Heroku can fetch
+// it from.
+func uploadSource(ctx context.Context, client *Client, f *os.File) (string, error) {
+	req, err := client.NewRequest("POST", "/sources", nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	src := new(Source)
+	if err := client.Do(req, src); err != nil {
+		return "", err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	put, err := http.NewRequest("PUT", src.SourceBlob.PutURL, f)
+	if err != nil {
+		return "", err
+	}
+	put = put.WithContext(ctx)
+	// The URL is signed for a request without a content type.
+	put.ContentLength = fi.Size()
+	resp, err := http.DefaultClient.Do(put)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("uploading source: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return src.SourceBlob.GetURL, nil
+}
+
+// runSource starts a test run of HEAD on the current branch from a tarball
+// uploaded to Heroku, like `heroku ci:run`, so nothing has to be pushed, and
+// waits for it to finish.
+func runSource(ctx context.Context, client *Client, id types.PrefixUUID, opts runOptions) error {
+	if err := checkPermission(ctx, client, id, "run", permDeploy); err != nil {
+		return err
+	}
+	if err := checkWritable("start a test run"); err != nil {
+		return err
+	}
+	branch, err := currentBranch()
+	if err != nil {
+		return err
+	}
+	sha, err := fullSHA("HEAD")
+	if err != nil {
+		return err
+	}
+	message, err := commitSubject(sha)
+	if err != nil {
+		return err
+	}
+	tree := sha
+	if opts.WorkingTree {
+		if tree, err = workingTree(ctx); err != nil {
+			return err
+		}
+	} else if uncommittedChanges() {
+		warnf("testing HEAD (%s); pass --working-tree to include your uncommitted changes", shortSHA(sha))
+	}
+	f, err := archiveTree(ctx, tree)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	sourceURL, err := uploadSource(ctx, client, f)
+	if err != nil {
+		return err
+	}
+	run, err := postTestRun(ctx, client, id, &testRunRequest{
+		CommitBranch:  herokuBranch(ctx, branch),
+		CommitMessage: message,
+		CommitSHA:     sha,
+		SourceBlobURL: sourceURL,
+		ClearCache:    opts.ClearCache,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started test run #%d on %s (%s)\n", run.Number, run.CommitBranch, shortSHA(sha))
+	recordAudit(ctx, &AuditEntry{
+		Account:    client.ID,
+		Action:     "run",
+		PipelineID: id.String(),
+		RunNumber:  run.Number,
+		Target:     branch,
+		Text:       fmt.Sprintf("Started test run #%d of %s from an uploaded tarball", run.Number, shortSHA(sha)),
+	})
+	run, warnings, err := waitForTestRun(ctx, client, id, run, "", nil)
+	if err != nil {
+		return err
+	}
+	return finishWait(ctx, client, id, run, warnings, waitOptions{Hints: true, FailOnFailure: true})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// How often handleSSE sends a comment to keep idle connections, and the
+// proxies in front of them, from timing out.
+const sseKeepalive = 15 * time.Second
+
+// handleSSE streams journal events as Server-Sent Events, one "run" event
+// per state change, optionally only those for ?pipeline= or ?run=. Each
+// event's ID is its time, so a client that reconnects with Last-Event-ID
+// picks up the events it missed.
+func (s *apiServer) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if !s.want(w, r, "GET") {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming isn't supported on this connection")
+		return
+	}
+	since := time.Now()
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		t, err := time.Parse(time.RFC3339Nano, id)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid Last-Event-ID %q", id)
+			return
+		}
+		since = t
+	}
+	q := r.URL.Query()
+	f := journalFilter{PipelineID: q.Get("pipeline"), RunPrefix: q.Get("run")}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	// Tell nginx not to buffer the stream.
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var mu sync.Mutex
+	write := func(format string, args ...interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	write("retry: %d\n\n", (5 * time.Second).Milliseconds())
+	// The keepalive goroutine must be done writing before we return.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(sseKeepalive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				write(": keepalive\n\n")
+			}
+		}
+	}()
+	defer wg.Wait()
+	defer close(done)
+	err := followJournal(r.Context(), f, since, func(ev *JournalEvent) error {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		return write("id: %s\nevent: run\ndata: %s\n\n", ev.Time.UTC().Format(time.RFC3339Nano), data)
+	})
+	if err != nil && r.Context().Err() == nil {
+		log.Printf("serve: streaming events: %v", err)
+	}
+}
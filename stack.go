@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	git "github.com/kevinburke/go-git"
+	types "github.com/kevinburke/go-types"
+)
+
+// Stacks that Heroku no longer supports.
+var eolStacks = map[string]bool{
+	"cedar-14":  true,
+	"heroku-16": true,
+	"heroku-18": true,
+	"heroku-20": true,
+}
+
+type appStack struct {
+	App   string
+	Stage string
+	Stack string
+}
+
+// pipelineStacks returns the build stack of every app in the pipeline.
+func pipelineStacks(ctx context.Context, client *Client, id types.PrefixUUID) ([]appStack, error) {
+	couplings, err := pipelineCouplings(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+	stacks := make([]appStack, 0, len(couplings))
+	for _, coupling := range couplings {
+		app, err := getApp(ctx, client, coupling.App.ID.String())
+		if err != nil {
+			return nil, err
+		}
+		stacks = append(stacks, appStack{App: coupling.App.Name, Stage: coupling.Stage, Stack: app.BuildStack.Name})
+	}
+	return stacks, nil
+}
+
+// appJSONStack returns the stack declared in the repo's app.json, which is
+// what Heroku CI and review apps build on. It returns the empty string if
+// app.json does not set a stack.
+func appJSONStack(root string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, "app.json"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var appJSON struct {
+		Stack string `json:"stack"`
+	}
+	if err := json.Unmarshal(data, &appJSON); err != nil {
+		return "", fmt.Errorf("app.json: %v", err)
+	}
+	return appJSON.Stack, nil
+}
+
+// declaredRuntimes returns the language runtimes the repo pins, keyed by the
+// file that declares them.
+func declaredRuntimes(root string) map[string]string {
+	runtimes := make(map[string]string)
+	for _, name := range []string{"runtime.txt", ".python-version", ".ruby-version", ".node-version", ".nvmrc", ".go-version"} {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		if v := strings.TrimSpace(string(data)); v != "" {
+			runtimes[name] = strings.SplitN(v, "\n", 2)[0]
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil {
+		var pkg struct {
+			Engines struct {
+				Node string `json:"node"`
+			} `json:"engines"`
+		}
+		if json.Unmarshal(data, &pkg) == nil && pkg.Engines.Node != "" {
+			runtimes["package.json"] = "node " + pkg.Engines.Node
+		}
+	}
+	return runtimes
+}
+
+// checkStack compares the stacks of the pipeline's apps with the stack
+// declared for CI in app.json, and prints the repo's declared runtimes so
+// they can be checked against the stacks. It returns an error if it found
+// any mismatches.
+func checkStack(ctx context.Context, client *Client, id types.PrefixUUID) error {
+	root, err := git.Root("")
+	if err != nil {
+		return err
+	}
+	stacks, err := pipelineStacks(ctx, client, id)
+	if err != nil {
+		return err
+	}
+	ciStack, err := appJSONStack(root)
+	if err != nil {
+		return err
+	}
+	t := newTable("APP", "STAGE", "STACK")
+	t.flex = []int{0, 2}
+	for _, s := range stacks {
+		t.add(s.App, s.Stage, s.Stack)
+	}
+	ciDisplay := ciStack
+	if ciDisplay == "" {
+		ciDisplay = "(default, app.json does not set a stack)"
+	}
+	t.add("Heroku CI", "test", ciDisplay)
+	t.print(os.Stdout)
+
+	runtimes := declaredRuntimes(root)
+	if len(runtimes) > 0 {
+		fmt.Println("\nDeclared runtimes:")
+		for _, name := range []string{"runtime.txt", ".python-version", ".ruby-version", ".node-version", ".nvmrc", ".go-version", "package.json"} {
+			if v, ok := runtimes[name]; ok {
+				fmt.Printf("  %-16s %s\n", name, v)
+			}
+		}
+	}
+
+	problems := make([]string, 0)
+	var prodStack string
+	for _, s := range stacks {
+		if eolStacks[s.Stack] {
+			problems = append(problems, fmt.Sprintf("%s is on %s, which is no longer supported", s.App, s.Stack))
+		}
+		if s.Stage == "production" && prodStack == "" {
+			prodStack = s.Stack
+		}
+	}
+	for _, s := range stacks {
+		if prodStack != "" && s.Stack != prodStack && s.Stage != "production" {
+			problems = append(problems, fmt.Sprintf("%s (%s) is on %s but production is on %s", s.App, s.Stage, s.Stack, prodStack))
+		}
+	}
+	if ciStack != "" && prodStack != "" && ciStack != prodStack {
+		problems = append(problems, fmt.Sprintf("CI runs on %s (from app.json) but production is on %s", ciStack, prodStack))
+	}
+	if eolStacks[ciStack] {
+		problems = append(problems, fmt.Sprintf("app.json asks for %s, which is no longer supported", ciStack))
+	}
+	if len(problems) == 0 {
+		fmt.Println("\nNo stack drift found.")
+		return nil
+	}
+	fmt.Println()
+	for _, p := range problems {
+		fmt.Printf("warning: %s\n", p)
+	}
+	return fmt.Errorf("found %d stack problems", len(problems))
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+)
+
+// A statsGroup aggregates the finished runs on one branch, or by one author.
+type statsGroup struct {
+	Name   string
+	Runs   int
+	Passed int
+	Failed int
+	// Durations are the durations of the runs that passed or failed.
+	Durations []time.Duration
+}
+
+func (g *statsGroup) add(run *TestRun) {
+	g.Runs++
+	switch {
+	case isPassing(run.Status):
+		g.Passed++
+	case isFailing(run.Status):
+		g.Failed++
+	default:
+		// Cancelled runs say nothing about how long the tests take.
+		return
+	}
+	g.Durations = append(g.Durations, run.UpdatedAt.Sub(run.CreatedAt))
+}
+
+func (g *statsGroup) merge(o *statsGroup) {
+	g.Runs += o.Runs
+	g.Passed += o.Passed
+	g.Failed += o.Failed
+	g.Durations = append(g.Durations, o.Durations...)
+}
+
+// percentile returns the p'th percentile of the group's durations, which
+// must be sorted.
+func (g *statsGroup) percentile(p float64) time.Duration {
+	if len(g.Durations) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(g.Durations)-1))
+	return g.Durations[i]
+}
+
+// runStats are the aggregations over a pipeline's recent runs.
+type runStats struct {
+	Runs     int
+	Oldest   time.Time
+	ByBranch map[string]*statsGroup
+	ByAuthor map[string]*statsGroup
+	// ByPeriod groups runs by when they were created, in periods of
+	// Period, keyed by the start of the period in Unix seconds.
+	Period   time.Duration
+	ByPeriod map[int64]*statsGroup
+	// All is every run, newest first once collectStats returns.
+	All []*TestRun
+}
+
+func newRunStats(period time.Duration) *runStats {
+	return &runStats{
+		ByBranch: make(map[string]*statsGroup),
+		ByAuthor: make(map[string]*statsGroup),
+		Period:   period,
+		ByPeriod: make(map[int64]*statsGroup),
+	}
+}
+
+func (s *runStats) add(run *TestRun) {
+	s.Runs++
+	s.All = append(s.All, run)
+	if s.Oldest.IsZero() || run.CreatedAt.Before(s.Oldest) {
+		s.Oldest = run.CreatedAt
+	}
+	author := run.ActorEmail
+	if author == "" {
+		author = "unknown"
+	}
+	for _, g := range []struct {
+		m   map[string]*statsGroup
+		key string
+	}{{s.ByBranch, run.CommitBranch}, {s.ByAuthor, author}} {
+		group, ok := g.m[g.key]
+		if !ok {
+			group = &statsGroup{Name: g.key}
+			g.m[g.key] = group
+		}
+		group.add(run)
+	}
+	start := run.CreatedAt.Truncate(s.Period)
+	group, ok := s.ByPeriod[start.Unix()]
+	if !ok {
+		group = &statsGroup{Name: fmtShortDate(start)}
+		s.ByPeriod[start.Unix()] = group
+	}
+	group.add(run)
+}
+
+func (s *runStats) merge(o *runStats) {
+	s.Runs += o.Runs
+	s.All = append(s.All, o.All...)
+	if s.Oldest.IsZero() || (!o.Oldest.IsZero() && o.Oldest.Before(s.Oldest)) {
+		s.Oldest = o.Oldest
+	}
+	for _, pair := range [][2]map[string]*statsGroup{{s.ByBranch, o.ByBranch}, {s.ByAuthor, o.ByAuthor}} {
+		for k, og := range pair[1] {
+			if g, ok := pair[0][k]; ok {
+				g.merge(og)
+			} else {
+				pair[0][k] = og
+			}
+		}
+	}
+	for k, og := range o.ByPeriod {
+		if g, ok := s.ByPeriod[k]; ok {
+			g.merge(og)
+		} else {
+			s.ByPeriod[k] = og
+		}
+	}
+}
+
+var sinceUnits = regexp.MustCompile(`^(\d+)(d|w|mo|y)$`)
+
+// parseSince parses how far back to look: a Go duration like "72h", or a
+// number of days, weeks, months, or years, like "30d" or "1y".
+func parseSince(s string) (time.Duration, error) {
+	if m := sinceUnits.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, err
+		}
+		day := 24 * time.Hour
+		unit := map[string]time.Duration{"d": day, "w": 7 * day, "mo": 30 * day, "y": 365 * day}[m[2]]
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --since %q, want a duration like 72h, 30d, 6mo, or 1y", s)
+	}
+	return d, nil
+}
+
+// A progressBar shows how far back in time we have fetched runs. It only
+// draws if w is a terminal.
+type progressBar struct {
+	w       *os.File
+	enabled bool
+	from    time.Time
+	to      time.Time
+	drawn   bool
+}
+
+func newProgressBar(w *os.File, from, to time.Time) *progressBar {
+	fi, err := w.Stat()
+	return &progressBar{
+		w:       w,
+		enabled: err == nil && fi.Mode()&os.ModeCharDevice != 0 && !accessible,
+		from:    from,
+		to:      to,
+	}
+}
+
+const progressBarWidth = 30
+
+// update draws the bar for having fetched every run back to oldest.
+func (b *progressBar) update(oldest time.Time, runs int) {
+	if !b.enabled {
+		return
+	}
+	frac := 1.0
+	if total := b.to.Sub(b.from); total > 0 && oldest.After(b.from) {
+		frac = float64(b.to.Sub(oldest)) / float64(total)
+	}
+	if frac > 1 {
+		frac = 1
+	}
+	filled := int(frac * progressBarWidth)
+	fmt.Fprintf(b.w, "\r[%s%s] %3d%%  %s runs, back to %s", strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled), int(frac*100), fmtInt(runs), fmtShortDate(oldest))
+	b.drawn = true
+}
+
+// done erases the bar.
+func (b *progressBar) done() {
+	if b.drawn {
+		fmt.Fprintf(b.w, "\r\x1b[K")
+	}
+}
+
+// collectStats aggregates the pipeline's runs created since since, or only
+// those keep returns true for if it isn't nil. Pages of runs are fetched
+// newest first, historyWorkers at a time, and aggregated as they arrive, so
+// only the totals are kept however long the history is. If the API can't be
+// reached, the runs in the journal are aggregated instead.
+func collectStats(ctx context.Context, client *Client, id types.PrefixUUID, since time.Time, period time.Duration, keep func(*TestRun) bool, bar *progressBar) (*runStats, error) {
+	total := newRunStats(period)
+	collect := func(page []*TestRun) error {
+		older := false
+		for _, run := range page {
+			if run.CreatedAt.Before(since) {
+				older = true
+				continue
+			}
+			if run.Status.Terminal() && (keep == nil || keep(run)) {
+				total.add(run)
+			}
+		}
+		bar.update(total.Oldest, total.Runs)
+		if older {
+			return herokuci.ErrStopWalk
+		}
+		return nil
+	}
+	err := client.WalkTestRuns(ctx, id, herokuci.WalkOptions{NewestFirst: true, Workers: historyWorkers}, collect)
+	if isOffline(err) {
+		bar.done()
+		runs, jerr := offlineRuns(id)
+		if jerr != nil {
+			return nil, err
+		}
+		total = newRunStats(period)
+		err = collect(runs)
+		if err == herokuci.ErrStopWalk {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range []map[string]*statsGroup{total.ByBranch, total.ByAuthor} {
+		for _, g := range m {
+			sortDurations(g)
+		}
+	}
+	for _, g := range total.ByPeriod {
+		sortDurations(g)
+	}
+	sort.Slice(total.All, func(i, j int) bool { return total.All[i].Number > total.All[j].Number })
+	return total, nil
+}
+
+// topGroups returns the top groups in m by number of runs, and how many
+// were left out. top of 0 returns them all.
+func topGroups(m map[string]*statsGroup, top int) ([]*statsGroup, int) {
+	groups := make([]*statsGroup, 0, len(m))
+	for _, g := range m {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Runs != groups[j].Runs {
+			return groups[i].Runs > groups[j].Runs
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	hidden := 0
+	if top > 0 && len(groups) > top {
+		hidden = len(groups) - top
+		groups = groups[:top]
+	}
+	return groups, hidden
+}
+
+// PassRate formats the share of the group's finished runs that passed.
+func (g *statsGroup) PassRate() string {
+	finished := g.Passed + g.Failed
+	if finished == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(g.Passed)/float64(finished))
+}
+
+// Median and P90 are the group's median and 90th percentile durations,
+// rounded for display.
+func (g *statsGroup) Median() time.Duration {
+	return roundDuration(g.percentile(0.5)).Round(time.Second)
+}
+
+func (g *statsGroup) P90() time.Duration {
+	return roundDuration(g.percentile(0.9)).Round(time.Second)
+}
+
+// sortDurations sorts g's durations, which percentile needs.
+func sortDurations(g *statsGroup) {
+	sort.Slice(g.Durations, func(i, j int) bool { return g.Durations[i] < g.Durations[j] })
+}
+
+// printStatsTable prints the top groups in m, by number of runs.
+func printStatsTable(w io.Writer, title string, m map[string]*statsGroup, top int) error {
+	groups, hidden := topGroups(m, top)
+	t := newTable(title, "RUNS", "PASSED", "FAILED", "PASS RATE", "MEDIAN", "P90")
+	t.flex = []int{0}
+	for _, g := range groups {
+		t.addf(g.Name, fmtInt(g.Runs), fmtInt(g.Passed), fmtInt(g.Failed), g.PassRate(), g.Median(), g.P90())
+	}
+	if err := t.print(w); err != nil {
+		return err
+	}
+	if hidden > 0 {
+		fmt.Fprintf(w, "(%s more; pass --top=0 to see them all)\n", fmtInt(hidden))
+	}
+	return nil
+}
+
+// statsOptions control what stats prints.
+type statsOptions struct {
+	// By is "branch", "author", or both, separated by a comma.
+	By  string
+	Top int
+	// Graph prints sparklines of the duration and pass rate over time.
+	Graph bool
+	// GraphFile, if set, is an SVG or PNG file to draw the trends in.
+	GraphFile string
+	// PRs also reports how long origin's pull requests waited for green.
+	PRs bool
+	// Label only counts runs with this label.
+	Label string
+	// Failures also groups failed runs by failure signature.
+	Failures bool
+}
+
+// printFailureSignatures prints the most common ways runs created since
+// start failed.
+func printFailureSignatures(ctx context.Context, client *Client, id types.PrefixUUID, start time.Time, opts statsOptions) error {
+	filter := searchFilter{Since: start, Statuses: []RunStatus{StatusFailed, StatusErrored}, Label: opts.Label}
+	runs, err := searchRuns(ctx, client, id, filter, 0)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	if len(runs) == 0 {
+		fmt.Println("No failed runs.")
+		return nil
+	}
+	sigs, err := runSignatures(ctx, client, id, runs)
+	if err != nil {
+		return err
+	}
+	groups := groupSignatures(sigs)
+	fmt.Printf("%s with %s\n\n", plural(len(runs), "failed run", "failed runs"), plural(len(groups), "signature", "signatures"))
+	return printSignatureGroups(os.Stdout, groups, opts.Top)
+}
+
+// statsPeriod returns how long a period of a trend covers: a day for up to
+// three months of history, and a week for more.
+func statsPeriod(since time.Duration) time.Duration {
+	if since > 90*24*time.Hour {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// printStats prints per-branch and per-author statistics for the runs
+// created in the last since.
+func printStats(ctx context.Context, client *Client, id types.PrefixUUID, since time.Duration, opts statsOptions) error {
+	by := opts.By
+	var byBranch, byAuthor bool
+	for _, b := range strings.Split(by, ",") {
+		switch strings.TrimSpace(b) {
+		case "branch":
+			byBranch = true
+		case "author":
+			byAuthor = true
+		default:
+			return fmt.Errorf("unknown --by %q, want branch, author, or both", b)
+		}
+	}
+	var gh *GitHubClient
+	var owner, repo string
+	if opts.PRs {
+		var err error
+		if gh, err = newGitHubClient(); err != nil {
+			return err
+		}
+		if owner, repo, err = githubRepo(); err != nil {
+			return err
+		}
+	}
+	var keep func(*TestRun) bool
+	if opts.Label != "" {
+		l, err := loadLabels()
+		if err != nil {
+			return err
+		}
+		labelled := l.labelled(id, opts.Label)
+		if len(labelled) == 0 {
+			return notFoundf("no runs are labelled %q", opts.Label)
+		}
+		keep = func(run *TestRun) bool { return labelled[run.ID.String()] }
+	}
+	now := time.Now()
+	start := now.Add(-since)
+	bar := newProgressBar(os.Stderr, start, now)
+	stats, err := collectStats(ctx, client, id, start, statsPeriod(since), keep, bar)
+	bar.done()
+	if err != nil {
+		return err
+	}
+	if stats.Runs == 0 {
+		return fmt.Errorf("no finished test runs since %s", fmtDateTimeMinutes(start))
+	}
+	if opts.Label != "" {
+		fmt.Printf("%s finished runs labelled %s since %s\n\n", fmtInt(stats.Runs), opts.Label, fmtDateTimeMinutes(start))
+	} else {
+		fmt.Printf("%s finished runs since %s\n\n", fmtInt(stats.Runs), fmtDateTimeMinutes(start))
+	}
+	if byBranch {
+		if err := printStatsTable(os.Stdout, "BRANCH", stats.ByBranch, opts.Top); err != nil {
+			return err
+		}
+	}
+	if byAuthor {
+		if byBranch {
+			fmt.Println()
+		}
+		if err := printStatsTable(os.Stdout, "AUTHOR", stats.ByAuthor, opts.Top); err != nil {
+			return err
+		}
+	}
+	trend := newTrend(stats, start, now)
+	if opts.Graph {
+		fmt.Println()
+		printSparklines(os.Stdout, trend)
+	}
+	if opts.GraphFile != "" {
+		if err := writeGraph(opts.GraphFile, trend); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "wrote %s\n", opts.GraphFile)
+	}
+	if opts.Failures {
+		if err := printFailureSignatures(ctx, client, id, start, opts); err != nil {
+			return err
+		}
+	}
+	if opts.PRs {
+		runs, err := searchRuns(ctx, client, id, searchFilter{Since: start}, 0)
+		if err != nil {
+			return err
+		}
+		timings, err := prStats(ctx, gh, owner, repo, runs, start)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		return printPRTimings(os.Stdout, timings, opts.Top)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	git "github.com/kevinburke/go-git"
+)
+
+// The status file records the latest test run heroku-ci has seen for each
+// branch of each checkout, so that the prompt command can answer without
+// touching the network.
+
+// BranchStatus is the latest test run heroku-ci has seen for a branch.
+type BranchStatus struct {
+	RunID     string    `json:"run_id"`
+	CommitSHA string    `json:"commit_sha"`
+	Status    RunStatus `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// statusFile maps a repository root to its branches' statuses.
+type statusFile map[string]map[string]*BranchStatus
+
+func readStatusFile(path string) (statusFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return make(statusFile), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sf := make(statusFile)
+	if err := json.Unmarshal(data, &sf); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+// canonicalRoot returns root with symlinks resolved, so a checkout has the
+// same key no matter how we got to it.
+func canonicalRoot(root string) string {
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		return resolved
+	}
+	return root
+}
+
+// currentRepoRoot returns the root of the git checkout we are running in, or
+// the empty string if we aren't in one.
+func currentRepoRoot() string {
+	root, err := git.Root("")
+	if err != nil {
+		return ""
+	}
+	return canonicalRoot(root)
+}
+
+// recordBranchStatus stores run as the latest status for branch in the
+// checkout at root.
+func recordBranchStatus(root, branch string, run *TestRun) error {
+	path, err := statusPath()
+	if err != nil {
+		return err
+	}
+	return withLock(path, func() error {
+		sf, err := readStatusFile(path)
+		if err != nil {
+			// A corrupt status file is only a cache; start over.
+			sf = make(statusFile)
+		}
+		branches, ok := sf[root]
+		if !ok {
+			branches = make(map[string]*BranchStatus)
+			sf[root] = branches
+		}
+		if prev, ok := branches[branch]; ok && prev.RunID != run.ID.String() && prev.UpdatedAt.After(run.UpdatedAt) {
+			// Don't let an older run overwrite a newer one.
+			return nil
+		}
+		branches[branch] = &BranchStatus{
+			RunID:     run.ID.String(),
+			CommitSHA: run.CommitSHA,
+			Status:    run.Status,
+			UpdatedAt: run.UpdatedAt,
+		}
+		data, err := json.Marshal(sf)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path, data, 0644)
+	})
+}
+
+// repoState describes the checkout in the working directory, read directly
+// from the .git directory instead of by running git, which is too slow for a
+// shell prompt.
+type repoState struct {
+	Root   string
+	Branch string
+	Head   string
+}
+
+var errNotOnBranch = errors.New("not on a branch")
+
+func readRepoState() (*repoState, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	var gitDir string
+	for {
+		candidate := filepath.Join(dir, ".git")
+		fi, err := os.Stat(candidate)
+		if err == nil {
+			if fi.IsDir() {
+				gitDir = candidate
+			} else {
+				// A worktree or submodule: .git is a file pointing at the
+				// real git directory.
+				data, err := os.ReadFile(candidate)
+				if err != nil {
+					return nil, err
+				}
+				line := strings.TrimSpace(string(data))
+				if !strings.HasPrefix(line, "gitdir:") {
+					return nil, errors.New("could not parse " + candidate)
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, errors.New("not in a git repository")
+		}
+		dir = parent
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+	ref := strings.TrimSpace(string(head))
+	if !strings.HasPrefix(ref, "ref: refs/heads/") {
+		return nil, errNotOnBranch
+	}
+	ref = strings.TrimPrefix(ref, "ref: ")
+	state := &repoState{
+		Root:   canonicalRoot(dir),
+		Branch: strings.TrimPrefix(ref, "refs/heads/"),
+	}
+	// Refs live in the common directory, which differs from gitDir for
+	// worktrees.
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+	state.Head = readRef(commonDir, ref)
+	return state, nil
+}
+
+// readRef returns the SHA that ref points to, or the empty string if we
+// can't find it.
+func readRef(gitDir, ref string) string {
+	if data, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	f, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A journalStore keeps the journal: the file in the data directory by
+// default, or a SQL database a whole team can share.
+type journalStore interface {
+	Append(ev *JournalEvent) error
+	// Events returns the events that match f, oldest first.
+	Events(f journalFilter) ([]*JournalEvent, error)
+	String() string
+}
+
+// A journalFilter selects journal events. Empty fields match everything.
+type journalFilter struct {
+	PipelineID string
+	// RunPrefix matches run IDs that start with it, like the 8 characters
+	// wait prints.
+	RunPrefix string
+}
+
+func (f journalFilter) match(ev *JournalEvent) bool {
+	if f.PipelineID != "" && ev.PipelineID.String() != f.PipelineID {
+		return false
+	}
+	return strings.HasPrefix(ev.RunID.String(), strings.ToLower(f.RunPrefix))
+}
+
+// journalConfig is set from Config when heroku-ci starts.
+var journalConfig struct {
+	// Store is "file", "sqlite", or "postgres". If empty, it is sqlite, or
+	// file in a heroku-ci built without cgo.
+	Store string
+	// DSN is the database to connect to. For SQLite it defaults to
+	// journal.db in the data directory.
+	DSN string
+}
+
+// sqlDrivers maps a journal store to the database/sql driver for it; see
+// store_sqlite.go and store_postgres.go. The SQLite driver needs cgo, so a
+// heroku-ci built with CGO_ENABLED=0 doesn't have it.
+var sqlDrivers = map[string]string{}
+
+// defaultJournalStore returns the store to use when none is configured.
+func defaultJournalStore() string {
+	if _, ok := sqlDrivers["sqlite"]; ok {
+		return "sqlite"
+	}
+	return "file"
+}
+
+// journalLocation returns where the configured journal store keeps the
+// journal, without opening it.
+func journalLocation() (string, error) {
+	kind := journalConfig.Store
+	if kind == "" {
+		kind = defaultJournalStore()
+	}
+	switch {
+	case kind == "file":
+		return journalPath()
+	case journalConfig.DSN != "":
+		return kind + " " + redact.String(journalConfig.DSN), nil
+	case kind == "sqlite":
+		return journalDBPath()
+	}
+	return kind, nil
+}
+
+var (
+	journalOnce     sync.Once
+	journalBackend  journalStore
+	journalStoreErr error
+)
+
+// openJournal returns the configured journal store, opening it the first
+// time it is called.
+func openJournal() (journalStore, error) {
+	journalOnce.Do(func() {
+		journalBackend, journalStoreErr = newJournalStore(journalConfig.Store, journalConfig.DSN)
+	})
+	return journalBackend, journalStoreErr
+}
+
+func newJournalStore(kind, dsn string) (journalStore, error) {
+	if kind == "" {
+		kind = defaultJournalStore()
+	}
+	switch kind {
+	case "file":
+		return fileJournal{}, nil
+	case "sqlite", "postgres":
+	default:
+		return nil, fmt.Errorf("unknown journal store %q, want file, sqlite, or postgres", kind)
+	}
+	driver, ok := sqlDrivers[kind]
+	if !ok {
+		// Only SQLite can be missing.
+		return nil, fmt.Errorf("this heroku-ci was built without cgo, which the sqlite journal store needs; rebuild it with CGO_ENABLED=1, or set journal.store = file")
+	}
+	// upgrade is set for the default SQLite database, the first time it's
+	// used, so the journal heroku-ci kept in a file before comes along.
+	upgrade := false
+	if dsn == "" {
+		if kind == "postgres" {
+			return nil, fmt.Errorf("journal.dsn is required for the postgres journal store")
+		}
+		path, err := journalDBPath()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			upgrade = true
+		}
+		dsn = path
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqlJournal{db: db, kind: kind, dsn: dsn}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not set up the %s journal: %v", kind, err)
+	}
+	if upgrade {
+		if err := s.importFile(); err != nil {
+			warnf("could not copy %s into the journal database: %v", fileJournal{}, err)
+		}
+	}
+	return s, nil
+}
+
+// fileJournal is the journal.ndjson file in the data directory.
+type fileJournal struct{}
+
+func (fileJournal) String() string {
+	path, _ := journalPath()
+	return path
+}
+
+func (fileJournal) Append(ev *JournalEvent) error {
+	path, err := journalPath()
+	if err != nil {
+		return err
+	}
+	return withLock(path, func() error {
+		return appendJournalLocked(path, ev)
+	})
+}
+
+func (fileJournal) Events(f journalFilter) ([]*JournalEvent, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+	var events []*JournalEvent
+	err = withLock(path, func() error {
+		var err error
+		events, err = readJournalLocked(path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	matched := events[:0]
+	for _, ev := range events {
+		if f.match(ev) {
+			matched = append(matched, ev)
+		}
+	}
+	return matched, nil
+}
+
+// sqlJournal keeps the journal in a SQLite or Postgres database.
+type sqlJournal struct {
+	db   *sql.DB
+	kind string
+	dsn  string
+}
+
+func (s *sqlJournal) String() string {
+	return s.kind + " " + redact.String(s.dsn)
+}
+
+var journalSchema = map[string]string{
+	"sqlite": `CREATE TABLE IF NOT EXISTS journal_events (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	time TIMESTAMP NOT NULL,
+	pipeline_id TEXT NOT NULL,
+	run_id TEXT NOT NULL,
+	run_number INTEGER NOT NULL,
+	commit_branch TEXT NOT NULL,
+	commit_sha TEXT NOT NULL,
+	previous_status TEXT NOT NULL,
+	status TEXT NOT NULL
+)`,
+	"postgres": `CREATE TABLE IF NOT EXISTS journal_events (
+	seq BIGSERIAL PRIMARY KEY,
+	time TIMESTAMPTZ NOT NULL,
+	pipeline_id TEXT NOT NULL,
+	run_id TEXT NOT NULL,
+	run_number INTEGER NOT NULL,
+	commit_branch TEXT NOT NULL,
+	commit_sha TEXT NOT NULL,
+	previous_status TEXT NOT NULL,
+	status TEXT NOT NULL
+)`,
+}
+
+// migrate creates the journal table and its indexes if they don't exist.
+func (s *sqlJournal) migrate() error {
+	for _, stmt := range []string{
+		journalSchema[s.kind],
+		`CREATE INDEX IF NOT EXISTS journal_events_pipeline ON journal_events (pipeline_id)`,
+		`CREATE INDEX IF NOT EXISTS journal_events_run ON journal_events (run_id)`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bind rewrites ? placeholders as $1, $2, ... for Postgres.
+func (s *sqlJournal) bind(query string) string {
+	if s.kind != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (s *sqlJournal) Append(ev *JournalEvent) error {
+	if err := s.insert(s.db, ev); err != nil {
+		return err
+	}
+	return s.prune()
+}
+
+// maxJournalRows caps the SQL journals, like maxJournalSize and
+// maxJournalBackups cap the file one; it is about as many events as the
+// file journal keeps in four files.
+var maxJournalRows = 150000
+
+// prune deletes the oldest events once the table holds more than
+// maxJournalRows. seq only grows, so this keeps the newest rows.
+func (s *sqlJournal) prune() error {
+	_, err := s.db.Exec(s.bind(`DELETE FROM journal_events
+	WHERE seq <= (SELECT MAX(seq) FROM journal_events) - ?`), maxJournalRows)
+	return err
+}
+
+// insert adds ev to the journal table, with db or a transaction.
+func (s *sqlJournal) insert(db interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, ev *JournalEvent) error {
+	_, err := db.Exec(s.bind(`INSERT INTO journal_events
+	(time, pipeline_id, run_id, run_number, commit_branch, commit_sha, previous_status, status)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		ev.Time, ev.PipelineID.String(), ev.RunID.String(), ev.RunNumber,
+		ev.CommitBranch, ev.CommitSHA, string(ev.PreviousStatus), string(ev.Status))
+	return err
+}
+
+// importFile copies the events in the file journal into s.
+func (s *sqlJournal) importFile() error {
+	events, err := fileJournal{}.Events(journalFilter{})
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, ev := range events {
+		if err := s.insert(tx, ev); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlJournal) Events(f journalFilter) ([]*JournalEvent, error) {
+	query := `SELECT time, pipeline_id, run_id, run_number, commit_branch, commit_sha, previous_status, status
+	FROM journal_events WHERE run_id LIKE ?`
+	args := []interface{}{strings.ToLower(f.RunPrefix) + "%"}
+	if f.PipelineID != "" {
+		query += ` AND pipeline_id = ?`
+		args = append(args, f.PipelineID)
+	}
+	rows, err := s.db.Query(s.bind(query+` ORDER BY seq`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := make([]*JournalEvent, 0)
+	for rows.Next() {
+		ev := new(JournalEvent)
+		var prev, status string
+		if err := rows.Scan(&ev.Time, &ev.PipelineID, &ev.RunID, &ev.RunNumber,
+			&ev.CommitBranch, &ev.CommitSHA, &prev, &status); err != nil {
+			return nil, err
+		}
+		ev.PreviousStatus, ev.Status = RunStatus(prev), RunStatus(status)
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,7 @@
+package main
+
+import _ "github.com/lib/pq"
+
+func init() {
+	sqlDrivers["postgres"] = "postgres"
+}
@@ -0,0 +1,11 @@
+//go:build cgo
+
+package main
+
+// The SQLite driver is cgo, so heroku-ci built with CGO_ENABLED=0 keeps its
+// journal in a file instead.
+import _ "github.com/mattn/go-sqlite3"
+
+func init() {
+	sqlDrivers["sqlite"] = "sqlite3"
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+func testJournalEvent(t *testing.T, run string, status RunStatus) *JournalEvent {
+	t.Helper()
+	pipelineID, err := types.NewPrefixUUID("0de00001-0000-4000-8000-000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runID, err := types.NewPrefixUUID(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &JournalEvent{
+		Time:         time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		PipelineID:   pipelineID,
+		RunID:        runID,
+		RunNumber:    104,
+		CommitBranch: "main",
+		CommitSHA:    "e7f6a5b4",
+		Status:       status,
+	}
+}
+
+func TestSQLiteJournalIsDefaultAndImportsFile(t *testing.T) {
+	if _, ok := sqlDrivers["sqlite"]; !ok {
+		t.Skip("built without cgo")
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir)
+	if err := (fileJournal{}).Append(testJournalEvent(t, "aaaa0001-0000-4000-8000-000000000000", StatusPending)); err != nil {
+		t.Fatal(err)
+	}
+	store, err := newJournalStore("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := store.(*sqlJournal)
+	if !ok {
+		t.Fatalf("default store is %s, want sqlite", store)
+	}
+	defer s.db.Close()
+	if want := filepath.Join(dir, "heroku-ci", "journal.db"); s.dsn != want {
+		t.Errorf("journal at %s, want %s", s.dsn, want)
+	}
+	if err := s.Append(testJournalEvent(t, "bbbb0001-0000-4000-8000-000000000000", StatusRunning)); err != nil {
+		t.Fatal(err)
+	}
+	events, err := s.Events(journalFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Status != StatusPending || events[1].Status != StatusRunning {
+		t.Fatalf("got %d events, want the imported one and the appended one", len(events))
+	}
+	if !events[0].Time.Equal(testJournalEvent(t, "aaaa0001-0000-4000-8000-000000000000", "").Time) {
+		t.Errorf("imported event's time is %v", events[0].Time)
+	}
+	events, err = s.Events(journalFilter{RunPrefix: "BBBB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].RunID.String() != "bbbb0001-0000-4000-8000-000000000000" {
+		t.Errorf("RunPrefix filter: got %d events", len(events))
+	}
+
+	// The file is only imported into a new database.
+	s.db.Close()
+	store, err = newJournalStore("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*sqlJournal).db.Close()
+	if events, err := store.Events(journalFilter{}); err != nil || len(events) != 2 {
+		t.Errorf("reopened journal: got %d events, %v; want 2", len(events), err)
+	}
+}
+
+func TestSQLiteJournalIsCapped(t *testing.T) {
+	if _, ok := sqlDrivers["sqlite"]; !ok {
+		t.Skip("built without cgo")
+	}
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	max := maxJournalRows
+	t.Cleanup(func() { maxJournalRows = max })
+	maxJournalRows = 2
+	store, err := newJournalStore("sqlite", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.(*sqlJournal).db.Close()
+	for _, status := range []RunStatus{StatusPending, StatusBuilding, StatusRunning, StatusSucceeded} {
+		if err := store.Append(testJournalEvent(t, "aaaa0001-0000-4000-8000-000000000000", status)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := store.Events(journalFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Status != StatusRunning || events[1].Status != StatusSucceeded {
+		t.Fatalf("got %d events, want the newest 2", len(events))
+	}
+}
+
+func TestFileJournalStore(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir)
+	store, err := newJournalStore("file", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Append(testJournalEvent(t, "aaaa0001-0000-4000-8000-000000000000", StatusPending)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "heroku-ci", "journal.db")); !os.IsNotExist(err) {
+		t.Errorf("file store created journal.db: %v", err)
+	}
+	events, err := store.Events(journalFilter{PipelineID: "0de00001-0000-4000-8000-000000000000"})
+	if err != nil || len(events) != 1 {
+		t.Errorf("got %d events, %v; want 1", len(events), err)
+	}
+}
+
+func TestJournalBind(t *testing.T) {
+	s := &sqlJournal{kind: "postgres"}
+	if got, want := s.bind("a = ? AND b = ?"), "a = $1 AND b = $2"; got != want {
+		t.Errorf("postgres: got %q, want %q", got, want)
+	}
+	s.kind = "sqlite"
+	if got, want := s.bind("a = ?"), "a = ?"; got != want {
+		t.Errorf("sqlite: got %q, want %q", got, want)
+	}
+}
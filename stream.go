@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Heroku's API has no long-poll for test runs, but each node's setup and
+// output streams stay open until that part of the run is done. While one is
+// open we can poll the run much less often, and poll right away when it
+// closes, which is when the run's status changes.
+const (
+	// pollInterval is how often to poll a run when no stream is open.
+	pollInterval = 2 * time.Second
+	// streamPollInterval is how often to poll a run while a stream is open,
+	// in case the stream stalls.
+	streamPollInterval = 10 * time.Second
+	// maxPollInterval is the furthest polling backs off while no stream is
+	// open and the run's status stays the same, as while it waits in a
+	// queue for a dyno.
+	maxPollInterval = 30 * time.Second
+	// pollBackoffEvery is how many polls with no change there are between
+	// doublings of the interval.
+	pollBackoffEvery = 3
+)
+
+// A streamWatch reads a test run's node streams in the background and
+// signals when one of them closes.
+type streamWatch struct {
+	// wake receives a value whenever a stream closes.
+	wake chan struct{}
+	open int32
+}
+
+// Open reports whether any of the run's streams are open.
+func (s *streamWatch) Open() bool {
+	return atomic.LoadInt32(&s.open) > 0
+}
+
+// interval returns how long to wait before the next poll, after idle polls
+// in a row that saw no change.
+func (s *streamWatch) interval(idle int) time.Duration {
+	if s.Open() {
+		return streamPollInterval
+	}
+	d := pollInterval
+	for i := 0; i < idle/pollBackoffEvery && d < maxPollInterval; i++ {
+		d *= 2
+	}
+	if d > maxPollInterval {
+		d = maxPollInterval
+	}
+	return d
+}
+
+// watchStreams follows the setup and then output stream of every node in
+// run, until ctx is cancelled or the streams end. If the streams can't be
+// read, the watch never wakes and never reports an open stream, so callers
+// fall back to polling.
+func watchStreams(ctx context.Context, client *Client, run *TestRun) *streamWatch {
+	watch := &streamWatch{wake: make(chan struct{}, 1)}
+	go func() {
+		var nodes []*TestNode
+		for {
+			var err error
+			nodes, err = getTestNodes(ctx, client, run.ID)
+			if err == nil && len(nodes) > 0 && (nodes[0].SetupStreamURL != "" || nodes[0].OutputStreamURL != "") {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+		}
+		for _, node := range nodes {
+			go func(node *TestNode) {
+				for _, u := range []string{node.SetupStreamURL, node.OutputStreamURL} {
+					if u == "" {
+						continue
+					}
+					if err := watch.follow(ctx, u); err != nil {
+						// Polling will catch up on its own.
+						return
+					}
+					select {
+					case watch.wake <- struct{}{}:
+					default:
+					}
+				}
+			}(node)
+		}
+	}()
+	return watch
+}
+
+// follow reads the stream at u until it ends.
+func (s *streamWatch) follow(ctx context.Context, u string) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	atomic.AddInt32(&s.open, 1)
+	defer atomic.AddInt32(&s.open, -1)
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
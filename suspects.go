@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// The most changed files to list one by one; past this we only list
+// directories.
+const maxListedFiles = 15
+
+// A failureHint points at what might have broken a failing run: the files
+// changed since the last run that passed, and the tests that failed.
+type failureHint struct {
+	// Green is the last passing run before the failing one, or nil if there
+	// wasn't one in the recent history.
+	Green *TestRun
+	Files []string
+	Tests []string
+	// Owners are the CODEOWNERS of the changed files and failing tests,
+	// those owning the most files first.
+	Owners []string
+}
+
+// failureHints caches the hint for each failing run, since the
+// notifications, events, and wait's own summary all want it.
+var failureHints struct {
+	sync.Mutex
+	m map[string]*cachedHint
+}
+
+type cachedHint struct {
+	hint *failureHint
+	err  error
+}
+
+// runFailureHint is findFailureHint, computed once per run.
+func runFailureHint(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun) (*failureHint, error) {
+	failureHints.Lock()
+	defer failureHints.Unlock()
+	if c, ok := failureHints.m[run.ID.String()]; ok {
+		return c.hint, c.err
+	}
+	h, err := findFailureHint(ctx, client, id, run)
+	if failureHints.m == nil {
+		failureHints.m = make(map[string]*cachedHint)
+	}
+	failureHints.m[run.ID.String()] = &cachedHint{h, err}
+	return h, err
+}
+
+// Lines that name a failing test, for the test frameworks we know. The
+// first group is the test's name.
+var failingTestPatterns = []*regexp.Regexp{
+	// go test
+	regexp.MustCompile(`^\s*--- FAIL: (\S+)`),
+	// RSpec
+	regexp.MustCompile(`^rspec (\./\S+)`),
+	// pytest
+	regexp.MustCompile(`^FAILED (\S+)`),
+	// Jest and Mocha
+	regexp.MustCompile(`^\s*● (.+?)\s*$`),
+	regexp.MustCompile(`^\s*\d+\) (.+?)\s*:?\s*$`),
+	// PHPUnit, JUnit and others
+	regexp.MustCompile(`^\s*(?:FAIL|FAILED|✗|✕)\s+(\S.*?)\s*$`),
+}
+
+// The most failing tests to list.
+const maxListedTests = 20
+
+// failingTests returns the names of the failing tests in a run's output, in
+// the order they first appear.
+func failingTests(lines []string) []string {
+	f := newTestFinder()
+	for _, line := range lines {
+		f.add(line)
+	}
+	return f.tests
+}
+
+// A testFinder collects the distinct test names in lines of output, in the
+// order they first appear.
+type testFinder struct {
+	seen  map[string]bool
+	tests []string
+}
+
+func newTestFinder() *testFinder {
+	return &testFinder{seen: make(map[string]bool), tests: make([]string, 0)}
+}
+
+func (f *testFinder) add(line string) {
+	line = ansiEscape.ReplaceAllString(line, "")
+	if i := strings.Index(line, ": "); strings.HasPrefix(line, "node ") && i > 0 {
+		line = line[i+2:]
+	}
+	for _, re := range failingTestPatterns {
+		m := re.FindStringSubmatch(line)
+		if m == nil || m[1] == "" {
+			continue
+		}
+		if !f.seen[m[1]] {
+			f.seen[m[1]] = true
+			f.tests = append(f.tests, m[1])
+		}
+		return
+	}
+}
+
+// changedFiles returns the files that differ between the commits base and
+// head. It uses the local checkout if it has both commits, and asks GitHub
+// otherwise.
+func changedFiles(ctx context.Context, base, head string) ([]string, error) {
+	if haveCommit(base) && haveCommit(head) {
+		// -z, so paths with spaces or non-ASCII characters come out as
+		// they are instead of quoted.
+		out, err := exec.CommandContext(ctx, "git", "diff", "--name-only", "-z", base, head).Output()
+		if err != nil {
+			return nil, fmt.Errorf("git diff %s %s: %v", shortSHA(base), shortSHA(head), err)
+		}
+		return strings.FieldsFunc(string(out), func(r rune) bool { return r == 0 }), nil
+	}
+	gh, err := newGitHubClient()
+	if err != nil {
+		return nil, fmt.Errorf("%s and %s aren't in this checkout, and: %v", shortSHA(base), shortSHA(head), err)
+	}
+	owner, repo, err := githubRepo()
+	if err != nil {
+		return nil, err
+	}
+	req, err := gh.NewRequest("GET", fmt.Sprintf("/repos/%s/%s/compare/%s...%s", owner, repo, base, head), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	var cmp struct {
+		Files []struct {
+			Filename string `json:"filename"`
+		} `json:"files"`
+	}
+	if err := gh.Do(req, &cmp); err != nil {
+		return nil, err
+	}
+	files := make([]string, len(cmp.Files))
+	for i, f := range cmp.Files {
+		files[i] = f.Filename
+	}
+	return files, nil
+}
+
+// findFailureHint compares the failing run to the last run that passed on
+// its branch, or on any branch if its branch never passed recently. If the
+// changed files can't be listed, the hint is returned with the error.
+func findFailureHint(ctx context.Context, client *Client, id types.PrefixUUID, run *TestRun) (*failureHint, error) {
+	h := new(failureHint)
+	out, err := fetchRunOutput(ctx, client, run)
+	if err != nil {
+		return nil, err
+	}
+	h.Tests = failingTests(summarySection(out.Tail()))
+	out.Close()
+	runs, err := recentTestRuns(ctx, client, id, logsSearchDepth)
+	if err != nil {
+		return nil, err
+	}
+	h.Green = latestRunOn(runs, run.CommitBranch, run.Number, isPassing)
+	if h.Green == nil {
+		for _, r := range runs {
+			if r.Number < run.Number && isPassing(r.Status) {
+				h.Green = r
+				break
+			}
+		}
+	}
+	if h.Green != nil && h.Green.CommitSHA != run.CommitSHA {
+		if h.Files, err = changedFiles(ctx, h.Green.CommitSHA, run.CommitSHA); err != nil {
+			err = fmt.Errorf("could not list the files changed since run #%d: %v", h.Green.Number, err)
+		}
+	}
+	owners, ownersErr := loadCodeowners()
+	if ownersErr != nil && err == nil {
+		err = fmt.Errorf("could not read CODEOWNERS: %v", ownersErr)
+	}
+	if owners != nil {
+		h.Owners = owners.implicatedOwners(h)
+	}
+	return h, err
+}
+
+// changedDirs returns the directories that contain files, with how many of
+// the files are in each, from most to fewest. Files at the top of the repo
+// are counted under ".".
+func changedDirs(files []string) ([]string, map[string]int) {
+	counts := make(map[string]int)
+	for _, f := range files {
+		counts[path.Dir(f)]++
+	}
+	dirs := make([]string, 0, len(counts))
+	for d := range counts {
+		dirs = append(dirs, d)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if counts[dirs[i]] != counts[dirs[j]] {
+			return counts[dirs[i]] > counts[dirs[j]]
+		}
+		return dirs[i] < dirs[j]
+	})
+	return dirs, counts
+}
+
+// print describes the hint for a failing run.
+func (h *failureHint) print(w io.Writer) {
+	if len(h.Tests) > 0 {
+		fmt.Fprintf(w, "\nFailing tests:\n")
+		for i, t := range h.Tests {
+			if i == maxListedTests {
+				fmt.Fprintf(w, "  (%d more)\n", len(h.Tests)-maxListedTests)
+				break
+			}
+			fmt.Fprintf(w, "  %s\n", t)
+		}
+	}
+	if len(h.Owners) > 0 {
+		fmt.Fprintf(w, "\nOwners (from CODEOWNERS): %s\n", strings.Join(h.Owners, ", "))
+	}
+	if h.Green == nil {
+		fmt.Fprintf(w, "\nNo recent run passed, so there are no changes to compare.\n")
+		return
+	}
+	if len(h.Files) == 0 {
+		fmt.Fprintf(w, "\nNo files changed since the last passing run #%d (%s); the failure may be flaky.\n", h.Green.Number, shortSHA(h.Green.CommitSHA))
+		return
+	}
+	fmt.Fprintf(w, "\nChanged since the last passing run #%d (%s, %s):\n", h.Green.Number, shortSHA(h.Green.CommitSHA), h.Green.CommitBranch)
+	if len(h.Files) <= maxListedFiles {
+		for _, f := range h.Files {
+			fmt.Fprintf(w, "  %s\n", f)
+		}
+		return
+	}
+	dirs, counts := changedDirs(h.Files)
+	for i, d := range dirs {
+		if i == maxListedFiles {
+			fmt.Fprintf(w, "  (%d more directories)\n", len(dirs)-maxListedFiles)
+			break
+		}
+		fmt.Fprintf(w, "  %s/ (%s)\n", d, plural(counts[d], "file", "files"))
+	}
+}
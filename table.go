@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Below this many columns, tables print each row as a block of
+// "NAME  value" lines instead of side by side.
+const narrowTableWidth = 60
+
+// Columns are never shortened past this many characters, or their header.
+const minColumnWidth = 6
+
+// A table is rows of cells printed in aligned columns, like tabwriter with
+// two spaces of padding. On a terminal too narrow for it, the widest
+// columns are cut short with an ellipsis; on a very narrow one, each row is
+// printed as a record.
+type table struct {
+	header []string
+	rows   [][]string
+	// indent starts every line.
+	indent string
+	// flex are the columns to shorten first, like branch names and commit
+	// messages, so short fixed ones like dates stay whole if they can.
+	flex []int
+}
+
+func newTable(header ...string) *table {
+	return &table{header: header}
+}
+
+// add appends a row. Every row should have a cell for each column.
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// addf appends a row of cells formatted with fmt.Sprint.
+func (t *table) addf(cells ...interface{}) {
+	row := make([]string, len(cells))
+	for i, c := range cells {
+		row[i] = fmt.Sprint(c)
+	}
+	t.add(row...)
+}
+
+// outputWidth returns how wide w is, or 0 if it isn't a terminal and
+// $COLUMNS isn't set. $COLUMNS wins, so tables can be sized by hand.
+func outputWidth(w io.Writer) int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	if f, ok := w.(*os.File); ok {
+		return terminalColumns(f)
+	}
+	return 0
+}
+
+// print writes the table to w, fitting it to w's width if w is a terminal.
+func (t *table) print(w io.Writer) error {
+	return t.printWidth(w, outputWidth(w))
+}
+
+// printWidth writes the table fitted to width columns, or as is if width is
+// 0. In accessible mode it always prints records.
+func (t *table) printWidth(w io.Writer, width int) error {
+	if accessible || width > 0 && width < narrowTableWidth {
+		return t.printRecords(w)
+	}
+	widths := make([]int, len(t.header))
+	for i, h := range t.header {
+		widths[i] = utf8.RuneCountInString(h)
+	}
+	for _, row := range t.rows {
+		for i, c := range row {
+			if i < len(widths) && utf8.RuneCountInString(c) > widths[i] {
+				widths[i] = utf8.RuneCountInString(c)
+			}
+		}
+	}
+	if width > 0 {
+		if len(t.flex) > 0 {
+			shrinkColumns(widths, t.header, t.flex, width-len(t.indent))
+		}
+		shrinkColumns(widths, t.header, nil, width-len(t.indent))
+	}
+	var b strings.Builder
+	line := func(cells []string) {
+		b.WriteString(t.indent)
+		for i, c := range cells {
+			if i >= len(widths) {
+				break
+			}
+			c = truncateCell(c, widths[i])
+			b.WriteString(c)
+			if i < len(cells)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c)+2))
+			}
+		}
+		b.WriteByte('\n')
+	}
+	if len(t.header) > 0 {
+		line(t.header)
+	}
+	for _, row := range t.rows {
+		line(row)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// shrinkColumns narrows the widest of cols, or of every column if cols is
+// nil, one character at a time, until the table fits in width or none of
+// them can shrink further.
+func shrinkColumns(widths []int, header []string, cols []int, width int) {
+	if cols == nil {
+		for i := range widths {
+			cols = append(cols, i)
+		}
+	}
+	total := func() int {
+		n := 2 * (len(widths) - 1)
+		for _, w := range widths {
+			n += w
+		}
+		return n
+	}
+	for total() > width {
+		widest := -1
+		for _, i := range cols {
+			w := widths[i]
+			min := minColumnWidth
+			if h := utf8.RuneCountInString(header[i]); h > min {
+				min = h
+			}
+			if w > min && (widest < 0 || w > widths[widest]) {
+				widest = i
+			}
+		}
+		if widest < 0 {
+			return
+		}
+		widths[widest]--
+	}
+}
+
+// truncateCell cuts s to n characters, ending with an ellipsis if it was
+// longer.
+func truncateCell(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
+
+// printRecords writes each row as lines of header and value, with a blank
+// line between rows.
+func (t *table) printRecords(w io.Writer) error {
+	width := 0
+	for _, h := range t.header {
+		if n := utf8.RuneCountInString(h); n > width {
+			width = n
+		}
+	}
+	var b strings.Builder
+	for i, row := range t.rows {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		for j, c := range row {
+			if j >= len(t.header) {
+				break
+			}
+			h := t.header[j]
+			fmt.Fprintf(&b, "%s%s%s  %s\n", t.indent, h, strings.Repeat(" ", width-utf8.RuneCountInString(h)), c)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
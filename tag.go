@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// tagOnSuccess creates an annotated tag named tag at the run's commit and
+// pushes it to origin. If githubRelease is true it also creates a GitHub
+// release for the tag.
+func tagOnSuccess(ctx context.Context, tag string, run *TestRun, githubRelease bool) error {
+	sha := run.CommitSHA
+	msg := fmt.Sprintf("%s\n\nHeroku CI test run %s succeeded.", tag, run.ID.String())
+	if out, err := exec.CommandContext(ctx, "git", "tag", "-a", tag, "-m", msg, sha).CombinedOutput(); err != nil {
+		return fmt.Errorf("git tag: %s", strings.TrimSpace(string(out)))
+	}
+	if out, err := exec.CommandContext(ctx, "git", "push", "origin", "refs/tags/"+tag).CombinedOutput(); err != nil {
+		return fmt.Errorf("git push: %s", strings.TrimSpace(string(out)))
+	}
+	fmt.Printf("Tagged %s as %s and pushed it to origin.\n", shortSHA(sha), tag)
+	recordAudit(ctx, &AuditEntry{
+		Action:    "tag",
+		RunNumber: run.Number,
+		Target:    tag,
+		Text:      fmt.Sprintf("Tagged %s as %s after test run #%d succeeded", shortSHA(sha), tag, run.Number),
+	})
+	if !githubRelease {
+		return nil
+	}
+	gh, err := newGitHubClient()
+	if err != nil {
+		return err
+	}
+	owner, repo, err := githubRepo()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"tag_name":               tag,
+		"name":                   tag,
+		"generate_release_notes": true,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := gh.NewRequest("POST", "/repos/"+owner+"/"+repo+"/releases", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	var release struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := gh.Do(req, &release); err != nil {
+		return err
+	}
+	fmt.Printf("Created GitHub release %s\n", release.HTMLURL)
+	recordAudit(ctx, &AuditEntry{
+		Action: "github-release",
+		Target: tag,
+		Text:   fmt.Sprintf("Created GitHub release %s", release.HTMLURL),
+	})
+	return nil
+}
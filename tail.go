@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// How many times to reconnect to a stream that drops before giving up on it.
+const tailAttempts = 5
+
+// How long wait waits for the rest of the output once the run has finished.
+const tailDrainTimeout = 10 * time.Second
+
+// tailOutput makes wait copy each run's setup and test output to stdout as
+// it is printed, set by wait --tail.
+var tailOutput bool
+
+// A lineWriter writes whole lines to w, so lines from several nodes don't
+// run into each other.
+type lineWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lineWriter) println(line string) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	fmt.Fprintln(lw.w, line)
+}
+
+// tailRun copies the setup and then test output of every node in run to w as
+// it is printed, until the streams end or ctx is cancelled. Each line starts
+// with prefix, and with the node's index if there is more than one node.
+func tailRun(ctx context.Context, client *Client, run *TestRun, prefix string, w io.Writer) error {
+	nodes, err := nodeStreams(ctx, client, run)
+	if err != nil {
+		return err
+	}
+	lw := &lineWriter{w: w}
+	var wg sync.WaitGroup
+	errs := make([]error, len(nodes))
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			node := nodes[i]
+			p := prefix
+			if len(nodes) > 1 {
+				p = fmt.Sprintf("%snode %d: ", prefix, node.Index)
+			}
+			if node.SetupStreamURL != "" {
+				if err := tailStream(ctx, node.SetupStreamURL, p, lw); err != nil {
+					errs[i] = fmt.Errorf("setup output for node %d: %v", node.Index, err)
+					return
+				}
+			}
+			u := node.OutputStreamURL
+			if u == "" {
+				if u, errs[i] = outputStreamURL(ctx, client, run, node.Index); errs[i] != nil || u == "" {
+					return
+				}
+			}
+			if err := tailStream(ctx, u, p, lw); err != nil {
+				errs[i] = fmt.Errorf("output for node %d: %v", node.Index, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeStreams returns run's nodes once their streams are ready, which is a
+// little while after the run is created.
+func nodeStreams(ctx context.Context, client *Client, run *TestRun) ([]*TestNode, error) {
+	for {
+		nodes, err := getTestNodes(ctx, client, run.ID)
+		if err == nil && len(nodes) > 0 && (nodes[0].SetupStreamURL != "" || nodes[0].OutputStreamURL != "") {
+			return nodes, nil
+		}
+		if err == nil && run.Status.Terminal() {
+			// A run that failed before it got a dyno never will have output.
+			return nil, notFoundf("run #%d finished without any output", run.Number)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(jitter(pollInterval)):
+		}
+	}
+}
+
+// outputStreamURL waits for the node's output stream, which may only show up
+// once setup is done. It returns "" if the node finished without one.
+func outputStreamURL(ctx context.Context, client *Client, run *TestRun, index int) (string, error) {
+	for {
+		nodes, err := getTestNodes(ctx, client, run.ID)
+		if err != nil {
+			return "", err
+		}
+		for _, node := range nodes {
+			if node.Index != index {
+				continue
+			}
+			if node.OutputStreamURL != "" {
+				return node.OutputStreamURL, nil
+			}
+			if node.ExitCode != nil {
+				return "", nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(jitter(pollInterval)):
+		}
+	}
+}
+
+// tailStream prints each line of the stream at u, reconnecting if the
+// connection drops. Heroku replays a stream from the start, so lines printed
+// before the drop are skipped.
+func tailStream(ctx context.Context, u, prefix string, lw *lineWriter) error {
+	printed := 0
+	for attempt := 1; ; attempt++ {
+		n, err := readStream(ctx, u, printed, func(line string) {
+			lw.println(prefix + redact.String(line))
+		})
+		printed += n
+		if err == nil || ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt == tailAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jitter(pollInterval)):
+		}
+	}
+}
+
+// readStream calls fn with each line of the stream at u after the first
+// skip, and returns how many lines it passed to fn.
+func readStream(ctx context.Context, u string, skip int, fn func(line string)) (int, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLine)
+	seen, n := 0, 0
+	for scanner.Scan() {
+		if seen++; seen <= skip {
+			continue
+		}
+		fn(scanner.Text())
+		n++
+	}
+	return n, scanner.Err()
+}
+
+// tailLogs prints the output of the named run, or the newest run on branch,
+// as it happens, and reports how the run finished.
+func tailLogs(ctx context.Context, client *Client, id types.PrefixUUID, branch, ref string, w io.Writer) error {
+	var run *TestRun
+	var err error
+	if ref != "" {
+		run, err = findRun(ctx, client, id, branch, ref)
+	} else {
+		run, err = findTestRun(ctx, client, id, branch, "")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Output of run #%d on %s (%s):\n", run.Number, run.CommitBranch, run.Status)
+	if err := tailRun(ctx, client, run, "", w); err != nil {
+		return err
+	}
+	if run.Status.Terminal() {
+		return nil
+	}
+	// The streams close when the run is done, but the API can take a moment
+	// to catch up.
+	for i := 0; i < 5; i++ {
+		if run, err = getTestRun(ctx, client, id, run.Number); err != nil {
+			return err
+		}
+		if run.Status.Terminal() {
+			fmt.Fprintf(w, "Run #%d finished with status %s\n", run.Number, run.Status)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+	return nil
+}
+
+// startTail tails run in the background for wait --tail. The returned func
+// waits, up to tailDrainTimeout, for the rest of the output once the run is
+// done.
+func startTail(ctx context.Context, client *Client, run *TestRun, prefix string) func() {
+	if !tailOutput {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := tailRun(ctx, client, run, prefix, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+			warnf("could not follow the output of run #%d: %v", run.Number, err)
+		}
+	}()
+	return func() {
+		select {
+		case <-done:
+		case <-time.After(tailDrainTimeout):
+		}
+	}
+}
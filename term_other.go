@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package main
+
+import "os"
+
+// terminalColumns returns 0 here; set $COLUMNS to size tables.
+func terminalColumns(f *os.File) int {
+	return 0
+}
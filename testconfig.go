@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// Heroku CI has no per-run environment, so rerun --failed-nodes and matrix
+// set the pipeline's test config vars, start a run, and wait for it to read
+// them before changing them again.
+
+// testConfigVars returns the config vars of the pipeline's test stage.
+func testConfigVars(ctx context.Context, client *Client, id types.PrefixUUID) (map[string]string, error) {
+	req, err := client.NewRequest("GET", "/pipelines/"+id.String()+"/stage/test/config-vars", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	vars := make(map[string]string)
+	if err := client.Do(req, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// setTestConfigVars sets config vars on the pipeline's test stage. A nil
+// value removes the variable.
+func setTestConfigVars(ctx context.Context, client *Client, id types.PrefixUUID, vars map[string]*string) error {
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest("PATCH", "/pipelines/"+id.String()+"/stage/test/config-vars", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return client.Do(req, nil)
+}
+
+// setTestConfigVar sets one config var on the pipeline's test stage, or
+// removes it if value is nil.
+func setTestConfigVar(ctx context.Context, client *Client, id types.PrefixUUID, key string, value *string) error {
+	return setTestConfigVars(ctx, client, id, map[string]*string{key: value})
+}
+
+// nodesStarting reports whether a run with this status may not have read
+// the pipeline's test config vars yet. The test app is created while the
+// run is creating, but its dynos only read the vars when they boot, after
+// building.
+func nodesStarting(status RunStatus) bool {
+	return status == StatusPending || status == StatusCreating || status == StatusBuilding
+}
+
+// waitForNodesStarted polls run until its dynos have booted, which is when
+// they read the pipeline's test config vars.
+func waitForNodesStarted(ctx context.Context, client *Client, run *TestRun) error {
+	for nodesStarting(run.Status) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jitter(pollInterval)):
+		}
+		req, err := client.NewRequest("GET", "/test-runs/"+run.ID.String(), nil)
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+		if err := client.Do(req, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// A tidyCandidate is a local branch that looks safe to delete.
+type tidyCandidate struct {
+	*branchRun
+	// Why says how we know the branch was merged.
+	Why string
+}
+
+// defaultBase returns origin's default branch, like origin/main.
+func defaultBase(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "--verify", "--quiet", "origin/HEAD").Output()
+	if err != nil || strings.TrimSpace(string(out)) == "" {
+		return "", usagef("origin has no default branch; pass --base, or run `git remote set-head origin --auto`")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// mergedBranches returns the local branches whose tips are reachable from
+// base.
+func mergedBranches(ctx context.Context, base string) (map[string]bool, error) {
+	out, err := exec.CommandContext(ctx, "git", "for-each-ref", "--merged="+base, "--format=%(refname)", "refs/heads/").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref --merged=%s: %v", base, err)
+	}
+	merged := make(map[string]bool)
+	for _, ref := range strings.Fields(string(out)) {
+		merged[normalizeBranch(ref)] = true
+	}
+	return merged, nil
+}
+
+// tidyCandidates returns the local branches that were merged into base, or
+// whose upstream was deleted, and whose newest run passed on their tip. The
+// checked out branch and base's own branch are never candidates. skipped is
+// how many merged branches were left out for lack of a green run.
+func tidyCandidates(ctx context.Context, client *Client, id types.PrefixUUID, base string) (candidates []*tidyCandidate, skipped int, err error) {
+	branches, err := localBranches()
+	if err != nil {
+		return nil, 0, err
+	}
+	merged, err := mergedBranches(ctx, base)
+	if err != nil {
+		return nil, 0, err
+	}
+	current, _ := currentBranch()
+	baseBranch := base
+	if i := strings.IndexByte(base, '/'); i >= 0 {
+		baseBranch = base[i+1:]
+	}
+	why := make(map[string]string)
+	picked := make([]*localBranch, 0)
+	for _, b := range branches {
+		if b.Name == current || b.Name == baseBranch || b.Name == base {
+			continue
+		}
+		switch {
+		case merged[b.Name]:
+			why[b.Name] = "merged into " + base
+		case b.Gone:
+			why[b.Name] = "upstream deleted"
+		default:
+			continue
+		}
+		picked = append(picked, b)
+	}
+	runs, err := matchBranchRuns(ctx, client, id, picked)
+	if err != nil {
+		return nil, 0, err
+	}
+	candidates = make([]*tidyCandidate, 0, len(runs))
+	for _, br := range runs {
+		if br.Note() != "ready" {
+			skipped++
+			continue
+		}
+		candidates = append(candidates, &tidyCandidate{branchRun: br, Why: why[br.Branch.Name]})
+	}
+	return candidates, skipped, nil
+}
+
+// printTidyCandidates lists the branches tidy would delete.
+func printTidyCandidates(w io.Writer, candidates []*tidyCandidate, skipped int) error {
+	if len(candidates) == 0 {
+		fmt.Fprintln(w, "No merged branches with a green run to delete")
+	} else {
+		t := newTable("BRANCH", "RUN", "COMMIT", "WHY")
+		t.flex = []int{0, 3}
+		for _, c := range candidates {
+			t.addf(c.Branch.Name, "#"+strconv.Itoa(c.Run.Number), shortSHA(c.Branch.Tip), c.Why)
+		}
+		if err := t.print(w); err != nil {
+			return err
+		}
+	}
+	if skipped > 0 {
+		fmt.Fprintf(w, "Kept %s: merged, but without a passing run on the tip.\n", plural(skipped, "branch", "branches"))
+	}
+	return nil
+}
+
+// tidy suggests local branches to delete, and with del, deletes them after
+// asking. If dryRun is true it only says how many it would delete.
+func tidy(ctx context.Context, client *Client, id types.PrefixUUID, base string, del, yes, dryRun bool) error {
+	if base == "" {
+		var err error
+		if base, err = defaultBase(ctx); err != nil {
+			return err
+		}
+	}
+	candidates, skipped, err := tidyCandidates(ctx, client, id, base)
+	if err != nil {
+		return err
+	}
+	if err := printTidyCandidates(os.Stdout, candidates, skipped); err != nil {
+		return err
+	}
+	if !del || len(candidates) == 0 {
+		if len(candidates) > 0 {
+			fmt.Println("Run `heroku-ci tidy --delete` to delete them.")
+		}
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("Dry run: would delete %s\n", plural(len(candidates), "local branch", "local branches"))
+		return nil
+	}
+	if err := confirm(fmt.Sprintf("Delete %s?", plural(len(candidates), "local branch", "local branches")), yes); err != nil {
+		return err
+	}
+	var failed error
+	for _, c := range candidates {
+		// -D, since a squash-merged branch isn't merged as far as git
+		// knows; the run passing on the tip is the check that matters here.
+		out, err := exec.CommandContext(ctx, "git", "branch", "-D", c.Branch.Name).CombinedOutput()
+		if err != nil {
+			warnf("could not delete %s: %s", c.Branch.Name, strings.TrimSpace(string(out)))
+			failed = errors.New("some branches could not be deleted")
+			continue
+		}
+		fmt.Print(string(out))
+	}
+	return failed
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// How often top refreshes the screen.
+const topRefreshInterval = 5 * time.Second
+
+// How many recent runs per pipeline top looks at for in-progress runs.
+const topSearchDepth = 50
+
+// A topPipeline is a pipeline shown by top.
+type topPipeline struct {
+	Name string
+	ID   types.PrefixUUID
+}
+
+// A topRow is one in-progress run on the top screen.
+type topRow struct {
+	Pipeline  string
+	Run       *TestRun
+	NodesDone int
+	Nodes     int
+}
+
+// topPipelines resolves the pipelines to show: the names given on the
+// command line, or else those in the top.pipelines config setting, or else
+// the current repository's pipeline.
+func topPipelines(ctx context.Context, client *Client, cfg *Config, pipelineID string, names []string) ([]*topPipeline, error) {
+	if len(names) == 0 {
+		names = cfg.TopPipelines
+	}
+	if len(names) == 0 {
+		id, err := resolvePipelineID(ctx, client, pipelineID)
+		if err != nil {
+			return nil, err
+		}
+		name := getPipeline()
+		if name == "" {
+			name = id.String()[:8]
+		}
+		return []*topPipeline{{Name: name, ID: id}}, nil
+	}
+	pipelines := make([]*topPipeline, 0, len(names))
+	for _, name := range names {
+		p, err := findPipelineByName(ctx, client, name)
+		if err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, &topPipeline{Name: name, ID: p.ID})
+	}
+	return pipelines, nil
+}
+
+// topScreen is everything top shows.
+type topScreen struct {
+	Rows []*topRow
+	// Offline is set if we couldn't reach Heroku and read the runs
+	// heroku-ci last saw in progress from the journal instead, as of AsOf.
+	Offline bool
+	AsOf    time.Time
+	// Silences lists the acknowledged branches and snoozed pipelines.
+	Silences []byte
+}
+
+// topRows returns every in-progress run in pipelines, with node progress.
+func topRows(ctx context.Context, client *Client, pipelines []*topPipeline) (*topScreen, error) {
+	var mu sync.Mutex
+	var firstErr error
+	screen := &topScreen{Rows: make([]*topRow, 0)}
+	var wg sync.WaitGroup
+	for _, p := range pipelines {
+		wg.Add(1)
+		go func(p *topPipeline) {
+			defer wg.Done()
+			runs, err := recentTestRuns(ctx, client, p.ID, topSearchDepth)
+			offline := false
+			if err != nil && isOffline(err) {
+				var asOf time.Time
+				if runs, asOf, err = journalRuns(p.ID); err == nil {
+					offline = true
+					mu.Lock()
+					screen.Offline = true
+					if asOf.After(screen.AsOf) {
+						screen.AsOf = asOf
+					}
+					mu.Unlock()
+				}
+			}
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %v", p.Name, err)
+				}
+				mu.Unlock()
+				return
+			}
+			for _, run := range runs {
+				if !run.InProgress() {
+					continue
+				}
+				row := &topRow{Pipeline: p.Name, Run: run}
+				// Node progress is nice to have; show the run without it
+				// rather than failing the whole screen.
+				if !offline {
+					if nodes, err := getTestNodes(ctx, client, run.ID); err == nil {
+						row.Nodes = len(nodes)
+						for _, node := range nodes {
+							if RunStatus(node.Status).Terminal() {
+								row.NodesDone++
+							}
+						}
+					}
+				}
+				mu.Lock()
+				screen.Rows = append(screen.Rows, row)
+				mu.Unlock()
+			}
+		}(p)
+	}
+	wg.Wait()
+	return screen, firstErr
+}
+
+// sortTopRows orders rows by the given key: "age" puts the longest running
+// first, "newest" the most recently started first, and "pipeline" groups
+// rows by pipeline.
+func sortTopRows(rows []*topRow, key string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		switch key {
+		case "newest":
+			return a.Run.CreatedAt.After(b.Run.CreatedAt)
+		case "pipeline":
+			if a.Pipeline != b.Pipeline {
+				return a.Pipeline < b.Pipeline
+			}
+		}
+		return a.Run.CreatedAt.Before(b.Run.CreatedAt)
+	})
+}
+
+func renderTop(screen *topScreen, now time.Time) []byte {
+	rows := screen.Rows
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "heroku-ci top - %d runs in progress - %s\n", len(rows), fmtClock(now))
+	if screen.Offline {
+		fmt.Fprintf(&buf, "*** %s; showing runs heroku-ci last saw in progress ***\n", offlineBanner(screen.AsOf))
+	}
+	buf.Write(screen.Silences)
+	buf.WriteString("\n")
+	t := newTable("PIPELINE", "RUN", "BRANCH", "COMMIT", "STATUS", "NODES", "ELAPSED")
+	t.flex = []int{0, 2}
+	for _, row := range rows {
+		nodes := "-"
+		if row.Nodes > 0 {
+			nodes = strconv.Itoa(row.NodesDone) + "/" + strconv.Itoa(row.Nodes)
+		}
+		number := "-"
+		if row.Run.Number > 0 {
+			number = "#" + strconv.Itoa(row.Run.Number)
+		}
+		t.addf(row.Pipeline, number, row.Run.CommitBranch, shortSHA(row.Run.CommitSHA), row.Run.Status, nodes,
+			now.Sub(row.Run.CreatedAt).Round(time.Second))
+	}
+	// The screen is drawn on stdout.
+	t.printWidth(&buf, outputWidth(os.Stdout))
+	return buf.Bytes()
+}
+
+// top shows every in-progress run in pipelines, refreshing the screen in
+// place until ctx is cancelled. If stdout is not a terminal, or once is
+// true, it prints the table once and returns. In accessible mode the table
+// is printed once and then each change is announced on its own line.
+func top(ctx context.Context, client *Client, pipelines []*topPipeline, sortKey string, once bool) error {
+	switch sortKey {
+	case "age", "newest", "pipeline":
+	default:
+		return fmt.Errorf("unknown sort %q, want age, newest, or pipeline", sortKey)
+	}
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeCharDevice == 0 {
+		once = true
+	}
+	ids := make([]types.PrefixUUID, len(pipelines))
+	for i, p := range pipelines {
+		ids[i] = p.ID
+	}
+	var last *topScreen
+	for {
+		rows, err := topRows(ctx, client, pipelines)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		sortTopRows(rows.Rows, sortKey)
+		if s := loadSilences(); s != nil {
+			var silenced bytes.Buffer
+			printSilences(&silenced, s, ids)
+			rows.Silences = silenced.Bytes()
+		}
+		screen := renderTop(rows, time.Now())
+		if once {
+			_, err := os.Stdout.Write(screen)
+			return err
+		}
+		switch {
+		case accessible && last != nil:
+			announceTopChanges(os.Stdout, last.Rows, rows.Rows)
+		case accessible:
+			os.Stdout.Write(screen)
+		default:
+			// Move the cursor home and clear the screen, then redraw.
+			os.Stdout.WriteString("\x1b[H\x1b[2J")
+			os.Stdout.Write(screen)
+		}
+		last = rows
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(jitter(topRefreshInterval)):
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// How many times to try creating a test run before giving up.
+const createAttempts = 3
+
+// How far back to look for a run created by an earlier attempt. This allows
+// for some clock skew between us and Heroku.
+const createLookback = time.Minute
+
+// ambiguousError reports whether err leaves us unsure whether the request
+// reached Heroku: a network failure, or a server error that might have
+// happened after the work was done.
+func ambiguousError(err error) bool {
+	var herr *HerokuError
+	if errors.As(err, &herr) {
+		return herr.StatusCode >= 500
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// recentTestRuns returns up to n of the pipeline's most recent test runs,
+// newest first.
+func recentTestRuns(ctx context.Context, client *Client, id types.PrefixUUID, n int) ([]*TestRun, error) {
+	req, err := client.NewRequest("GET", "/pipelines/"+id.String()+"/test-runs", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Range", fmt.Sprintf("number ..; order=desc, max=%d", n))
+	runs := make([]*TestRun, 0)
+	if err := client.Do(req, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// findCreatedRun returns a run for sha on branch created after since and
+// numbered above after, or nil if there isn't one.
+func findCreatedRun(ctx context.Context, client *Client, id types.PrefixUUID, branch, sha string, since time.Time, after int) (*TestRun, error) {
+	runs, err := recentTestRuns(ctx, client, id, 20)
+	if err != nil {
+		return nil, err
+	}
+	for _, run := range runs {
+		if run.CommitBranch == branch && run.CommitSHA == sha && run.CreatedAt.After(since) && run.Number > after {
+			return run, nil
+		}
+	}
+	return nil, nil
+}
+
+// A testRunRequest is the body of a request to create a test run.
+type testRunRequest struct {
+	CommitBranch  string `json:"commit_branch"`
+	CommitMessage string `json:"commit_message"`
+	CommitSHA     string `json:"commit_sha"`
+	Pipeline      string `json:"pipeline"`
+	SourceBlobURL string `json:"source_blob_url"`
+	// ClearCache starts the run without the build cache.
+	ClearCache bool `json:"clear_cache,omitempty"`
+}
+
+// createTestRun starts a test run for sha on branch, built from the tarball
+// at sourceURL.
+//
+// Heroku has no idempotency keys, so if a POST fails in a way that leaves us
+// unsure whether the run was created, we look for a run for the same commit
+// numbered above the pipeline's newest run before our first attempt, before
+// trying again. Retries never create a second run, and a run started earlier
+// for the same commit, like the previous cell of a matrix or the run being
+// rerun, is never mistaken for ours.
+func createTestRun(ctx context.Context, client *Client, id types.PrefixUUID, branch, sha, message, sourceURL string) (*TestRun, error) {
+	return postTestRun(ctx, client, id, &testRunRequest{
+		CommitBranch:  branch,
+		CommitMessage: message,
+		CommitSHA:     sha,
+		SourceBlobURL: sourceURL,
+	})
+}
+
+// postTestRun creates the test run described by body, in the same way as
+// createTestRun.
+func postTestRun(ctx context.Context, client *Client, id types.PrefixUUID, body *testRunRequest) (*TestRun, error) {
+	body.Pipeline = id.String()
+	branch, sha := body.CommitBranch, body.CommitSHA
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	since := time.Now().Add(-createLookback)
+	// Runs are numbered in order, so ours, if it was created, is numbered
+	// above every run that exists now.
+	after, lerr := client.LatestTestRunNumber(ctx, id)
+	for attempt := 1; ; attempt++ {
+		req, err := client.NewRequest("POST", "/test-runs", bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		run := new(TestRun)
+		err = client.Do(req, run)
+		if err == nil {
+			return run, nil
+		}
+		if !ambiguousError(err) || attempt == createAttempts || ctx.Err() != nil {
+			return nil, err
+		}
+		if lerr != nil {
+			// Without the newest run number, we can't tell our run apart
+			// from an earlier one for the same commit.
+			return nil, fmt.Errorf("%v; could not check for a duplicate run: %v", err, lerr)
+		}
+		warnf("creating test run failed (%v), checking whether it was created anyway", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+		existing, ferr := findCreatedRun(ctx, client, id, branch, sha, since, after)
+		if ferr != nil {
+			// We can't tell, and retrying might duplicate the run.
+			return nil, fmt.Errorf("%v; could not check for a duplicate run: %v", err, ferr)
+		}
+		if existing != nil {
+			return existing, nil
+		}
+	}
+}
+
+// commitSubject returns the first line of sha's commit message.
+func commitSubject(sha string) (string, error) {
+	out, err := exec.Command("git", "log", "-1", "--format=%s", sha).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git log: %s", strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// trigger starts a test run for the tip of branch. If dryRun is true it
+// prints the run it would start, and stops.
+func trigger(ctx context.Context, client *Client, id types.PrefixUUID, branch, sourceURL string, dryRun bool) error {
+	sha, err := fullSHA(localRef(branch))
+	if err != nil {
+		return err
+	}
+	message, err := commitSubject(sha)
+	if err != nil {
+		return err
+	}
+	if sourceURL == "" {
+		sourceURL, err = githubTarballURL(sha)
+		if err != nil {
+			return err
+		}
+	}
+	if dryRun {
+		fmt.Printf("Dry run: would start a test run on %s (%s) from %s\n", branch, sha, sourceURL)
+		return nil
+	}
+	if err := checkPermission(ctx, client, id, "trigger", permDeploy); err != nil {
+		return err
+	}
+	run, err := createTestRun(ctx, client, id, branch, sha, message, sourceURL)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started test run #%d on %s (%s)\n", run.Number, branch, shortSHA(sha))
+	recordAudit(ctx, &AuditEntry{
+		Account:    client.ID,
+		Action:     "trigger",
+		PipelineID: id.String(),
+		RunNumber:  run.Number,
+		Target:     branch,
+		Text:       fmt.Sprintf("Started test run #%d on %s (%s)", run.Number, branch, shortSHA(sha)),
+	})
+	return nil
+}
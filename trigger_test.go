@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createServer is the demo API, plus POST /test-runs, which adds a run to the
+// first demo pipeline. The next POST fails with a 503 if failNext is set, and
+// creates the run anyway if createOnFail is set too.
+type createServer struct {
+	mu           sync.Mutex
+	demo         *demoServer
+	pipeline     *demoPipeline
+	failNext     bool
+	createOnFail bool
+	posts        int
+}
+
+func (s *createServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method != "POST" || r.URL.Path != "/test-runs" {
+		s.demo.ServeHTTP(w, r)
+		return
+	}
+	s.posts++
+	var body testRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		demoJSON(w, http.StatusBadRequest, &HerokuError{ID: "bad_request", Message: err.Error()})
+		return
+	}
+	fail := s.failNext
+	s.failNext = false
+	if fail && !s.createOnFail {
+		demoJSON(w, http.StatusServiceUnavailable, &HerokuError{ID: "unavailable", Message: "try again"})
+		return
+	}
+	runs := s.pipeline.Runs
+	run := demoRun{
+		Number:  runs[len(runs)-1].Number + 1,
+		Branch:  body.CommitBranch,
+		SHA:     body.CommitSHA,
+		Message: body.CommitMessage,
+		Final:   StatusSucceeded,
+		Takes:   time.Minute,
+	}
+	s.pipeline.Runs = append(runs, run)
+	if fail {
+		demoJSON(w, http.StatusServiceUnavailable, &HerokuError{ID: "unavailable", Message: "try again"})
+		return
+	}
+	demoJSON(w, http.StatusCreated, s.demo.testRun(s.pipeline, run, time.Now()))
+}
+
+func newCreateServer(t *testing.T) (*createServer, *Client) {
+	t.Helper()
+	p := demoPipelines[0]
+	runs := p.Runs
+	t.Cleanup(func() { p.Runs = runs })
+	p.Runs = append([]demoRun(nil), runs...)
+	cacheDisabled = true
+	s := &createServer{demo: &demoServer{start: time.Now()}, pipeline: p}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	s.demo.url = srv.URL
+	return s, demoClient(srv.URL)
+}
+
+func TestPostTestRunIgnoresEarlierRunForSameCommit(t *testing.T) {
+	s, client := newCreateServer(t)
+	ctx := t.Context()
+	id := s.pipeline.ID
+	// Like two cells of a matrix: the same branch and commit, one after
+	// the other.
+	first, err := createTestRun(ctx, client, id, "main", "abc123", "Test", "https://example.com/src.tgz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.failNext = true
+	second, err := createTestRun(ctx, client, id, "main", "abc123", "Test", "https://example.com/src.tgz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Number == first.Number {
+		t.Fatalf("second run is the first run, #%d", first.Number)
+	}
+	if second.Number != first.Number+1 {
+		t.Errorf("second run is #%d, want #%d", second.Number, first.Number+1)
+	}
+	if s.posts != 3 {
+		t.Errorf("got %d POSTs, want 3", s.posts)
+	}
+}
+
+func TestPostTestRunFindsRunCreatedByFailedAttempt(t *testing.T) {
+	s, client := newCreateServer(t)
+	ctx := t.Context()
+	id := s.pipeline.ID
+	before := len(s.pipeline.Runs)
+	s.failNext, s.createOnFail = true, true
+	run, err := createTestRun(ctx, client, id, "main", "abc123", "Test", "https://example.com/src.tgz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(s.pipeline.Runs) - before; got != 1 {
+		t.Fatalf("created %d runs, want 1", got)
+	}
+	if want := s.pipeline.Runs[before].Number; run.Number != want {
+		t.Errorf("got run #%d, want #%d", run.Number, want)
+	}
+	if s.posts != 1 {
+		t.Errorf("got %d POSTs, want 1", s.posts)
+	}
+}
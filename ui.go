@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"net/http"
+	"sort"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+// uiFiles is the wallboard serve shows at /. The page itself holds no data;
+// its script asks the API for it, with the token the viewer signs in with.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+func (s *apiServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	http.ServeFileFS(w, r, uiFiles, "ui/index.html")
+}
+
+// A pipelineSummary is the latest run heroku-ci saw on each branch of a
+// pipeline.
+type pipelineSummary struct {
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Branches []*branchSummary `json:"branches"`
+}
+
+type branchSummary struct {
+	Branch string   `json:"branch"`
+	Run    *TestRun `json:"run"`
+}
+
+// How long to wait for Heroku to name a pipeline before showing its ID.
+const pipelineNameTimeout = 3 * time.Second
+
+// name returns the pipeline's name, asking Heroku the first time.
+func (s *apiServer) name(ctx context.Context, id types.PrefixUUID) string {
+	s.mu.Lock()
+	name, ok := s.names[id.String()]
+	s.mu.Unlock()
+	if ok {
+		return name
+	}
+	ctx, cancel := context.WithTimeout(ctx, pipelineNameTimeout)
+	defer cancel()
+	name = pipelineName(ctx, s.client, id)
+	if name == "" {
+		// Try again next time.
+		return ""
+	}
+	s.mu.Lock()
+	if s.names == nil {
+		s.names = make(map[string]string)
+	}
+	s.names[id.String()] = name
+	s.mu.Unlock()
+	return name
+}
+
+// pipelines returns every pipeline in the journal, by name, with the
+// latest run on each branch.
+func (s *apiServer) pipelines(ctx context.Context) ([]*pipelineSummary, error) {
+	events, err := readJournal(journalFilter{})
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]types.PrefixUUID)
+	for _, ev := range events {
+		ids[ev.PipelineID.String()] = ev.PipelineID
+	}
+	summaries := make([]*pipelineSummary, 0, len(ids))
+	for _, id := range ids {
+		runs, _, err := journalRuns(id)
+		if err != nil {
+			return nil, err
+		}
+		p := &pipelineSummary{ID: id.String(), Name: s.name(ctx, id), Branches: make([]*branchSummary, 0)}
+		seen := make(map[string]bool)
+		// runs are newest first.
+		for _, run := range runs {
+			if !seen[run.CommitBranch] {
+				seen[run.CommitBranch] = true
+				p.Branches = append(p.Branches, &branchSummary{Branch: run.CommitBranch, Run: run})
+			}
+		}
+		sort.Slice(p.Branches, func(i, j int) bool { return p.Branches[i].Branch < p.Branches[j].Branch })
+		summaries = append(summaries, p)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Name != summaries[j].Name {
+			return summaries[i].Name < summaries[j].Name
+		}
+		return summaries[i].ID < summaries[j].ID
+	})
+	return summaries, nil
+}
+
+func (s *apiServer) handlePipelines(w http.ResponseWriter, r *http.Request) {
+	if !s.want(w, r, "GET") {
+		return
+	}
+	ps, err := s.pipelines(r.Context())
+	respond(w, ps, err)
+}
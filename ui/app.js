@@ -0,0 +1,127 @@
+// The heroku-ci wallboard: the latest run on every branch heroku-ci has
+// seen, updated as runs change state.
+(function() {
+  "use strict";
+
+  var stateEl = document.getElementById("state");
+  var listEl = document.getElementById("pipelines");
+  var loginEl = document.getElementById("login");
+  var pipelines = [];
+
+  function token() {
+    return localStorage.getItem("heroku-ci-token") || "";
+  }
+
+  function headers() {
+    var t = token();
+    return t ? {"Authorization": "Bearer " + t} : {};
+  }
+
+  function showLogin(message) {
+    stateEl.textContent = message;
+    loginEl.hidden = false;
+  }
+
+  loginEl.addEventListener("submit", function(e) {
+    e.preventDefault();
+    localStorage.setItem("heroku-ci-token", document.getElementById("token").value);
+    loginEl.hidden = true;
+    start();
+  });
+
+  function ago(iso) {
+    var s = Math.round((Date.now() - new Date(iso).getTime()) / 1000);
+    if (s < 60) return s + "s ago";
+    if (s < 3600) return Math.round(s / 60) + "m ago";
+    if (s < 86400) return Math.round(s / 3600) + "h ago";
+    return Math.round(s / 86400) + "d ago";
+  }
+
+  function cell(row, text, className) {
+    var td = row.insertCell();
+    td.textContent = text;
+    if (className) td.className = className;
+    return td;
+  }
+
+  function render(changed) {
+    listEl.textContent = "";
+    pipelines.forEach(function(p) {
+      var h = document.createElement("h2");
+      h.textContent = p.name || p.id;
+      listEl.appendChild(h);
+      var table = document.createElement("table");
+      var head = table.insertRow();
+      ["Branch", "Run", "Commit", "Status", "Updated"].forEach(function(t) {
+        var th = document.createElement("th");
+        th.textContent = t;
+        head.appendChild(th);
+      });
+      p.branches.forEach(function(b) {
+        var row = table.insertRow();
+        if (changed === p.id + " " + b.branch) row.className = "changed";
+        cell(row, b.branch);
+        cell(row, b.run.number ? "#" + b.run.number : b.run.id.slice(0, 8), "n");
+        cell(row, (b.run.commit_sha || "").slice(0, 7));
+        cell(row, b.run.status, b.run.status);
+        cell(row, ago(b.run.updated_at), "muted").title = b.run.updated_at;
+      });
+      listEl.appendChild(table);
+    });
+    if (pipelines.length === 0) {
+      stateEl.textContent = "No runs yet. heroku-ci records runs as it waits on them.";
+    }
+  }
+
+  // update applies a journal event to the latest run on its branch.
+  function update(ev) {
+    var p = pipelines.find(function(p) { return p.id === ev.pipeline_id; });
+    if (!p) {
+      load();
+      return;
+    }
+    var b = p.branches.find(function(b) { return b.branch === ev.commit_branch; });
+    if (!b) {
+      b = {branch: ev.commit_branch, run: {}};
+      p.branches.push(b);
+      p.branches.sort(function(x, y) { return x.branch < y.branch ? -1 : 1; });
+    }
+    if (b.run.id && b.run.id !== ev.run_id && b.run.updated_at > ev.time) {
+      return;
+    }
+    b.run = {id: ev.run_id, number: ev.run_number || b.run.number, commit_sha: ev.commit_sha, status: ev.status, updated_at: ev.time};
+    render(p.id + " " + b.branch);
+  }
+
+  function load() {
+    return fetch("/v1/pipelines", {headers: headers()}).then(function(resp) {
+      if (resp.status === 401) {
+        showLogin("Sign in with a heroku-ci API token.");
+        throw new Error("unauthorized");
+      }
+      return resp.json();
+    }).then(function(body) {
+      pipelines = body;
+      render();
+    });
+  }
+
+  // follow subscribes to the server's event stream. EventSource
+  // reconnects by itself, picking up where it left off.
+  function follow() {
+    var url = "/events";
+    if (token()) url += "?access_token=" + encodeURIComponent(token());
+    var source = new EventSource(url);
+    source.onopen = function() { stateEl.textContent = "Live."; };
+    source.onerror = function() { stateEl.textContent = "Disconnected; reconnecting…"; };
+    source.addEventListener("run", function(e) { update(JSON.parse(e.data)); });
+  }
+
+  function start() {
+    load().then(follow, function() {});
+  }
+
+  start();
+  // Keep the "updated" times fresh.
+  setInterval(function() { render(); }, 30000);
+})();
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	herokuci "github.com/kevinburke/heroku-ci/lib"
+	"github.com/kevinburke/rest"
+)
+
+var releasesURL = "https://api.github.com/repos/kevinburke/heroku-ci/releases/latest"
+
+// How long a cached update check is considered fresh.
+const updateCheckInterval = 24 * time.Hour
+
+// updateCheck is the result of the last update check, cached on disk so we
+// only hit GitHub once per day.
+type updateCheck struct {
+	CheckedAt     time.Time `json:"checked_at"`
+	LatestVersion string    `json:"latest_version"`
+	// FailedAt is when the last check failed, if it did since CheckedAt. A
+	// failed check waits out the interval too, so being offline doesn't
+	// cost every command a timeout.
+	FailedAt time.Time `json:"failed_at,omitempty"`
+}
+
+// fresh reports whether u is recent enough that we shouldn't ask GitHub
+// again yet.
+func (u *updateCheck) fresh() bool {
+	return time.Since(u.CheckedAt) < updateCheckInterval || time.Since(u.FailedAt) < updateCheckInterval
+}
+
+// Environment variables that indicate we are running in a CI environment,
+// where nobody is around to read an update notice.
+var ciEnvVars = []string{
+	"CI",
+	"CONTINUOUS_INTEGRATION",
+	"BUILD_NUMBER",
+	"GITHUB_ACTIONS",
+	"HEROKU_TEST_RUN_ID",
+	"JENKINS_URL",
+	"TEAMCITY_VERSION",
+}
+
+func inCI() bool {
+	for _, name := range ciEnvVars {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// updateCheckEnabled reports whether we should check for a newer version.
+func updateCheckEnabled(cfg *Config) bool {
+	return cfg.UpdatesCheck && !inCI()
+}
+
+func readUpdateCache() (*updateCheck, error) {
+	path, err := updateCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	u := new(updateCheck)
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func writeUpdateCache(u *updateCheck) error {
+	path, err := updateCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// fetchLatestVersion asks GitHub for the most recent heroku-ci release.
+func fetchLatestVersion(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequest("GET", releasesURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "heroku-ci/"+Version)
+	resp, err := updateHTTPClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("update check: unexpected status %d from %s", resp.StatusCode, releasesURL)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(release.TagName, "v"), nil
+}
+
+// updateHTTPClient returns the client update checks are sent with: the
+// Heroku API client's transport, so DEBUG_HTTP_TRAFFIC shows them, and its
+// api_timeout.
+func updateHTTPClient() *http.Client {
+	opts := append([]herokuci.Option{herokuci.WithHTTPClient(&http.Client{Transport: rest.DefaultTransport})}, apiClientOptions...)
+	return herokuci.NewClient("", opts...).HTTPClient()
+}
+
+// checkForUpdates returns the latest released version, from the cache if we
+// checked or failed to recently, or from GitHub otherwise. For a day after
+// a failure it returns the last version it found, which may be empty.
+func checkForUpdates(ctx context.Context) (string, error) {
+	u, err := readUpdateCache()
+	if err != nil {
+		u = new(updateCheck)
+	}
+	if u.fresh() {
+		return u.LatestVersion, nil
+	}
+	latest, err := fetchLatestVersion(ctx)
+	if err != nil {
+		u.FailedAt = time.Now().UTC()
+		writeUpdateCache(u)
+		return "", err
+	}
+	// Failing to write the cache just means we check again next time.
+	writeUpdateCache(&updateCheck{CheckedAt: time.Now().UTC(), LatestVersion: latest})
+	return latest, nil
+}
+
+// startUpdateCheck checks for updates in the background. The returned channel
+// receives a notice if a newer version is available, and is closed when the
+// check completes. If update checks are disabled the channel is nil.
+func startUpdateCheck(ctx context.Context, cfg *Config) <-chan string {
+	if !updateCheckEnabled(cfg) {
+		return nil
+	}
+	ch := make(chan string, 1)
+	go func() {
+		defer close(ch)
+		latest, err := checkForUpdates(ctx)
+		if err != nil {
+			return
+		}
+		if newerVersion(latest, Version) {
+			ch <- updateNotice(latest)
+		}
+	}()
+	return ch
+}
+
+// printUpdateNotice prints the result of a background update check as a
+// warning, waiting briefly for the check to finish if it is still running.
+func printUpdateNotice(ch <-chan string) {
+	if ch == nil {
+		return
+	}
+	select {
+	case notice := <-ch:
+		if notice != "" {
+			warnf("%s", notice)
+		}
+	case <-time.After(time.Second):
+	}
+}
+
+func updateNotice(latest string) string {
+	return fmt.Sprintf("A new version of heroku-ci is available: %s -> %s (https://github.com/kevinburke/heroku-ci/releases)", Version, latest)
+}
+
+// newerVersion reports whether version a is newer than version b. Versions
+// are dot-separated integers, like "0.1" or "1.2.3".
+func newerVersion(a, b string) bool {
+	if a == "" {
+		return false
+	}
+	aparts := strings.Split(a, ".")
+	bparts := strings.Split(b, ".")
+	for i := 0; i < len(aparts) || i < len(bparts); i++ {
+		var an, bn int
+		if i < len(aparts) {
+			an, _ = strconv.Atoi(aparts[i])
+		}
+		if i < len(bparts) {
+			bn, _ = strconv.Atoi(bparts[i])
+		}
+		if an != bn {
+			return an > bn
+		}
+	}
+	return false
+}
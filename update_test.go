@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckForUpdatesCachesFailures(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var hits atomic.Int32
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if ua := r.Header.Get("User-Agent"); ua != "heroku-ci/"+Version {
+			t.Errorf("User-Agent: got %q", ua)
+		}
+		if fail.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"tag_name": "v99.0"}`))
+	}))
+	defer srv.Close()
+	defer func(u string) { releasesURL = u }(releasesURL)
+	releasesURL = srv.URL
+
+	if _, err := checkForUpdates(t.Context()); err == nil {
+		t.Fatal("want an error from the first check")
+	}
+	fail.Store(false)
+	latest, err := checkForUpdates(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != "" || hits.Load() != 1 {
+		t.Errorf("after a failure: got %q and %d requests, want no new request", latest, hits.Load())
+	}
+
+	// Once the interval is up it asks again.
+	u, err := readUpdateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.FailedAt = time.Now().Add(-updateCheckInterval - time.Minute)
+	if err := writeUpdateCache(u); err != nil {
+		t.Fatal(err)
+	}
+	latest, err = checkForUpdates(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != "99.0" || hits.Load() != 2 {
+		t.Errorf("got %q and %d requests, want 99.0 from a second request", latest, hits.Load())
+	}
+	if latest, _ := checkForUpdates(t.Context()); latest != "99.0" || hits.Load() != 2 {
+		t.Errorf("cached: got %q and %d requests", latest, hits.Load())
+	}
+}
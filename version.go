@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// These are set at build time via -ldflags, for example:
+//
+//	go build -ldflags="-X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// If they are unset we fall back to the VCS information the Go toolchain
+// embeds in the binary.
+var (
+	commit = ""
+	date   = ""
+)
+
+// buildInfo returns the commit and build date for the running binary, or empty
+// strings if they are not known.
+func buildInfo() (string, string) {
+	c, d := commit, date
+	if c != "" && d != "" {
+		return c, d
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return c, d
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if c == "" {
+				c = setting.Value
+			}
+		case "vcs.time":
+			if d == "" {
+				d = setting.Value
+			}
+		}
+	}
+	return c, d
+}
+
+// versionString returns a one-line description of the running binary.
+func versionString() string {
+	s := "heroku-ci version " + Version
+	c, d := buildInfo()
+	if len(c) > 12 {
+		c = c[:12]
+	}
+	if c != "" {
+		s += " (" + c
+		if d != "" {
+			s += ", built " + d
+		}
+		s += ")"
+	} else if d != "" {
+		s += " (built " + d + ")"
+	}
+	return fmt.Sprintf("%s %s/%s", s, runtime.GOOS, runtime.GOARCH)
+}
+
+// printVersionInfo prints the version of the running binary, and whether a
+// newer release is available.
+func printVersionInfo(ctx context.Context, cfg *Config) {
+	fmt.Println(versionString())
+	if !updateCheckEnabled(cfg) {
+		return
+	}
+	latest, err := checkForUpdates(ctx)
+	if err != nil {
+		return
+	}
+	if newerVersion(latest, Version) {
+		fmt.Println(updateNotice(latest))
+	}
+}
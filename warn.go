@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// warningsDisabled is set by --no-warnings.
+var warningsDisabled bool
+
+// warnf prints a warning to stderr: something the user should know, like a
+// stale cached response or a newer release, that doesn't change the
+// command's result. Warnings never go to stdout, so scripts that read it see
+// only status and results.
+func warnf(format string, args ...interface{}) {
+	if warningsDisabled {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+}